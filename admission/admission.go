@@ -0,0 +1,71 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 09:48:33
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 09:48:33
+ * @Description: 并发限制与优先级准入队列
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package admission
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"time"
+)
+
+// Priority 请求优先级，数值越大越先放行
+type Priority int
+
+const (
+	PriorityBatch       Priority = -10 // 批处理任务
+	PriorityNormal      Priority = 0   // 默认优先级
+	PriorityInteractive Priority = 10  // 交互式请求
+)
+
+const (
+	PriorityKey httpclient.ContextKey = "go_aisdk_admission_priority" // 请求优先级在上下文中的键
+)
+
+// 排队结果
+const (
+	OutcomeAdmitted = "admitted" // 放行
+	OutcomeRejected = "rejected" // 队列已满被拒绝
+	OutcomeTimeout  = "timeout"  // 排队超时
+	OutcomeCanceled = "canceled" // 排队时上下文被取消
+)
+
+var (
+	ErrQueueFull    = errors.New("admission queue is full")        // 准入队列已满
+	ErrQueueTimeout = errors.New("admission queue wait timed out") // 排队超时
+)
+
+// WithPriority 设置请求优先级
+func WithPriority(ctx context.Context, priority Priority) (newCtx context.Context) {
+	return context.WithValue(ctx, PriorityKey, priority)
+}
+
+// PriorityFromContext 从上下文中获取请求优先级
+func PriorityFromContext(ctx context.Context) (priority Priority, ok bool) {
+	priority, ok = ctx.Value(PriorityKey).(Priority)
+	return
+}
+
+// Observer 准入队列指标观察者，metrics.PrometheusCollector 实现了该接口，实现需并发安全
+type Observer interface {
+	ObserveQueue(queue string, inFlight, queued int)              // 队列的并发数或排队数变化时调用
+	ObserveWait(queue string, wait time.Duration, outcome string) // 请求结束排队时调用，outcome 为 Outcome* 常量之一
+}
+
+// Stats 准入队列统计信息
+type Stats struct {
+	Limit    int   `json:"limit"`     // 最大并发数
+	InFlight int   `json:"in_flight"` // 当前并发数，包括未关闭的流式传输
+	Queued   int   `json:"queued"`    // 当前排队数
+	Admitted int64 `json:"admitted"`  // 累计放行数
+	Rejected int64 `json:"rejected"`  // 累计因队列已满被拒绝数
+	TimedOut int64 `json:"timed_out"` // 累计排队超时数
+	Canceled int64 `json:"canceled"`  // 累计排队时被取消数
+}
@@ -0,0 +1,144 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 14:12:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 14:12:40
+ * @Description: 准入控制中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package admission
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConcurrency = 10  // 默认每个队列的最大并发数
+	defaultMaxQueueSize   = 100 // 默认每个队列的最大排队数
+)
+
+// QueueFunc 队列划分函数，返回请求所属的队列名称，同一队列的请求共享并发名额
+type QueueFunc func(requestInfo *httpclient.RequestInfo) (queue string)
+
+// MiddlewareConfig 准入控制中间件配置
+type MiddlewareConfig struct {
+	MaxConcurrency  int            // 每个队列的最大并发数，默认10
+	Limits          map[string]int // 按队列名称覆盖最大并发数，如 {"openai:gpt-4o": 5}
+	MaxQueueSize    int            // 每个队列的最大排队数，超出时返回 ErrQueueFull，默认100，小于0时不排队
+	MaxQueueTime    time.Duration  // 最大排队时间，超出时返回 ErrQueueTimeout，0 表示只受上下文限制
+	DefaultPriority Priority       // 上下文中没有设置优先级时使用的优先级，默认 PriorityNormal
+	QueueFunc       QueueFunc      // 队列划分函数，默认按 "提供商:模型" 划分
+	Observer        Observer       // 指标观察者，可为 nil
+}
+
+// Middleware 准入控制中间件，限制每个提供商/模型的并发请求数，超出时按优先级排队
+//
+//	同一优先级的排队请求按 UserInfo.User 在用户之间轮流放行，避免单个用户的批量请求占满队列；
+//	流式传输在关闭或结束前一直占用名额，调用方必须读取完或关闭流
+type Middleware struct {
+	config MiddlewareConfig
+	queues map[string]*queue
+	mu     sync.Mutex
+}
+
+// NewMiddleware 创建准入控制中间件
+func NewMiddleware(config MiddlewareConfig) (m *Middleware) {
+	if config.MaxConcurrency <= 0 {
+		config.MaxConcurrency = defaultMaxConcurrency
+	}
+	switch {
+	case config.MaxQueueSize == 0:
+		config.MaxQueueSize = defaultMaxQueueSize
+	case config.MaxQueueSize < 0:
+		config.MaxQueueSize = 0
+	}
+	if config.QueueFunc == nil {
+		config.QueueFunc = defaultQueueFunc
+	}
+	return &Middleware{
+		config: config,
+		queues: make(map[string]*queue),
+	}
+}
+
+// Process 处理请求
+func (m *Middleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	priority, ok := PriorityFromContext(ctx)
+	if !ok {
+		priority = m.config.DefaultPriority
+	}
+	// 获取并发名额
+	var release func()
+	if release, err = m.queue(m.config.QueueFunc(requestInfo)).acquire(ctx, priority, requestInfo.User, m.config.MaxQueueTime); err != nil {
+		return
+	}
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		release()
+		return
+	}
+	// 流式传输在结束或关闭时归还名额
+	if stream, ok := response.(httpclient.StreamObservable); ok {
+		stream.AddObserver(nil, func(err error) {
+			release()
+		})
+		return
+	}
+	release()
+	return
+}
+
+// Name 返回中间件名称
+func (m *Middleware) Name() (name string) {
+	return "admission"
+}
+
+// Priority 返回中间件优先级
+func (m *Middleware) Priority() (priority int) {
+	return 19 // 准入控制中间件在缓存和用量预算检查之后、重试之前执行，命中缓存或超出预算的请求不占用名额，重试期间持续占用名额
+}
+
+// Stats 获取所有队列的统计信息
+func (m *Middleware) Stats() (stats map[string]Stats) {
+	m.mu.Lock()
+	queues := make([]*queue, 0, len(m.queues))
+	for _, q := range m.queues {
+		queues = append(queues, q)
+	}
+	m.mu.Unlock()
+
+	stats = make(map[string]Stats, len(queues))
+	for _, q := range queues {
+		stats[q.name] = q.snapshot()
+	}
+	return
+}
+
+// queue 获取队列，不存在时创建
+func (m *Middleware) queue(name string) (q *queue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ok bool
+	if q, ok = m.queues[name]; ok {
+		return
+	}
+	limit := m.config.MaxConcurrency
+	if n, ok := m.config.Limits[name]; ok && n > 0 {
+		limit = n
+	}
+	q = newQueue(name, limit, m.config.MaxQueueSize, m.config.Observer)
+	m.queues[name] = q
+	return
+}
+
+// defaultQueueFunc 默认按 "提供商:模型" 划分队列
+func defaultQueueFunc(requestInfo *httpclient.RequestInfo) (queue string) {
+	return requestInfo.Provider + ":" + requestInfo.Model
+}
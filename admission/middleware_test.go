@@ -0,0 +1,207 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 16:20:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 16:20:51
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package admission
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testQueue = "openai:gpt-4o"
+
+// newTestContext 创建带有请求信息的上下文
+func newTestContext(user string, priority Priority) (ctx context.Context) {
+	ctx = httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  "openai",
+		ModelType: "chat",
+		Model:     "gpt-4o",
+		Method:    "CreateChatCompletion",
+		User:      user,
+	})
+	return WithPriority(ctx, priority)
+}
+
+// waitStats 等待队列统计信息满足条件
+func waitStats(t *testing.T, m *Middleware, cond func(stats Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond(m.Stats()[testQueue]) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for queue stats, got %+v", m.Stats()[testQueue])
+}
+
+// holdSlot 占用一个名额，返回释放名额的函数
+func holdSlot(t *testing.T, m *Middleware) (release func()) {
+	t.Helper()
+	var (
+		held = make(chan struct{})
+		done = make(chan struct{})
+	)
+	go m.Process(newTestContext("holder", PriorityNormal), nil, func(ctx context.Context, request any) (response any, err error) {
+		close(held)
+		<-done
+		return
+	})
+	<-held
+	return func() { close(done) }
+}
+
+func TestMiddleware_MaxConcurrency(t *testing.T) {
+	var (
+		m                = NewMiddleware(MiddlewareConfig{MaxConcurrency: 2})
+		current, maxSeen atomic.Int32
+		wg               sync.WaitGroup
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Process(newTestContext("alice", PriorityNormal), nil, func(ctx context.Context, request any) (response any, err error) {
+				n := current.Add(1)
+				for {
+					if seen := maxSeen.Load(); n <= seen || maxSeen.CompareAndSwap(seen, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				current.Add(-1)
+				return
+			})
+		}()
+	}
+	wg.Wait()
+
+	if maxSeen.Load() != 2 {
+		t.Errorf("expected at most 2 concurrent calls, got %d", maxSeen.Load())
+	}
+	if stats := m.Stats()[testQueue]; stats.Admitted != 8 || stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMiddleware_PriorityAndFairness(t *testing.T) {
+	m := NewMiddleware(MiddlewareConfig{MaxConcurrency: 1})
+	release := holdSlot(t, m)
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	enqueue := func(name, user string, priority Priority) {
+		queued := m.Stats()[testQueue].Queued
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Process(newTestContext(user, priority), nil, func(ctx context.Context, request any) (response any, err error) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return
+			})
+		}()
+		waitStats(t, m, func(stats Stats) bool { return stats.Queued == queued+1 })
+	}
+	// 批处理任务先排队，alice 连续提交3个请求后 bob 提交1个请求
+	enqueue("batch", "carol", PriorityBatch)
+	enqueue("alice-1", "alice", PriorityNormal)
+	enqueue("alice-2", "alice", PriorityNormal)
+	enqueue("alice-3", "alice", PriorityNormal)
+	enqueue("bob-1", "bob", PriorityNormal)
+	enqueue("interactive", "dave", PriorityInteractive)
+	release()
+	wg.Wait()
+
+	want := []string{"interactive", "alice-1", "bob-1", "alice-2", "alice-3", "batch"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestMiddleware_QueueLimits(t *testing.T) {
+	m := NewMiddleware(MiddlewareConfig{MaxConcurrency: 1, MaxQueueSize: 1, MaxQueueTime: 30 * time.Millisecond})
+	release := holdSlot(t, m)
+	defer release()
+
+	noop := func(ctx context.Context, request any) (response any, err error) { return }
+	// 排队超时
+	result := make(chan error, 1)
+	go func() {
+		_, err := m.Process(newTestContext("alice", PriorityNormal), nil, noop)
+		result <- err
+	}()
+	waitStats(t, m, func(stats Stats) bool { return stats.Queued == 1 })
+	// 队列已满
+	if _, err := m.Process(newTestContext("bob", PriorityNormal), nil, noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	if err := <-result; !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("expected ErrQueueTimeout, got %v", err)
+	}
+	// 排队时上下文被取消
+	ctx, cancel := context.WithCancel(newTestContext("carol", PriorityNormal))
+	go func() {
+		_, err := m.Process(ctx, nil, noop)
+		result <- err
+	}()
+	waitStats(t, m, func(stats Stats) bool { return stats.Queued == 1 })
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if stats := m.Stats()[testQueue]; stats.Rejected != 1 || stats.TimedOut != 1 || stats.Canceled != 1 || stats.Queued != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	// 不排队
+	m = NewMiddleware(MiddlewareConfig{MaxConcurrency: 1, MaxQueueSize: -1})
+	defer holdSlot(t, m)()
+	if _, err := m.Process(newTestContext("alice", PriorityNormal), nil, noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull without a queue, got %v", err)
+	}
+}
+
+func TestMiddleware_StreamHoldsSlot(t *testing.T) {
+	m := NewMiddleware(MiddlewareConfig{Limits: map[string]int{testQueue: 1}})
+	pr, pw := io.Pipe()
+	resp, err := m.Process(newTestContext("alice", PriorityNormal), nil, func(ctx context.Context, request any) (response any, err error) {
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](pr, nil, nil)}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := m.Stats()[testQueue]; stats.Limit != 1 || stats.InFlight != 1 {
+		t.Fatalf("expected the stream to hold its slot, got %+v", stats)
+	}
+	// 流式传输占用名额时新请求排队等待
+	ctx, cancel := context.WithTimeout(newTestContext("bob", PriorityNormal), 10*time.Millisecond)
+	defer cancel()
+	if _, err = m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) { return }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second call to wait for the stream, got %v", err)
+	}
+	// 关闭流后归还名额
+	pw.Close()
+	resp.(models.ChatResponseStream).Close()
+	if stats := m.Stats()[testQueue]; stats.InFlight != 0 {
+		t.Errorf("expected the slot to be released, got %+v", stats)
+	}
+}
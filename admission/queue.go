@@ -0,0 +1,248 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 10:35:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 10:35:17
+ * @Description: 按优先级和用户公平调度的有界队列
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package admission
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// waiter 排队中的请求
+type waiter struct {
+	ready    chan struct{} // 放行时关闭
+	admitted bool          // 是否已放行
+	users    *userQueue    // 所在的用户队列
+	elem     *list.Element // 在用户队列中的位置
+}
+
+// userQueue 同一用户的排队请求，先进先出
+type userQueue struct {
+	user    string
+	waiters *list.List
+}
+
+// level 同一优先级的排队请求，在用户之间轮流放行
+type level struct {
+	priority Priority
+	users    []*userQueue          // 有排队请求的用户，按轮转顺序
+	byUser   map[string]*userQueue // 用户到用户队列的映射
+	next     int                   // 下一个放行的用户位置
+}
+
+// queue 准入队列，限制并发数，超出时按优先级排队（并发安全）
+type queue struct {
+	name     string
+	limit    int      // 最大并发数
+	maxSize  int      // 最大排队数
+	observer Observer // 指标观察者
+	inFlight int      // 当前并发数
+	queued   int      // 当前排队数
+	levels   []*level // 按优先级从高到低排序
+	stats    Stats    // 累计统计
+	mu       sync.Mutex
+}
+
+// newQueue 创建准入队列
+func newQueue(name string, limit, maxSize int, observer Observer) (q *queue) {
+	return &queue{
+		name:     name,
+		limit:    limit,
+		maxSize:  maxSize,
+		observer: observer,
+	}
+}
+
+// acquire 获取并发名额，名额已满时排队等待，直到被放行、排队超时或上下文被取消
+//
+//	成功时返回归还名额的函数，可重复调用
+func (q *queue) acquire(ctx context.Context, priority Priority, user string, maxWait time.Duration) (release func(), err error) {
+	start := time.Now()
+	q.mu.Lock()
+	// 有空闲名额且没有排队的请求时直接放行
+	if q.inFlight < q.limit && q.queued == 0 {
+		q.inFlight++
+		q.stats.Admitted++
+		q.notify()
+		q.mu.Unlock()
+		q.observeWait(0, OutcomeAdmitted)
+		return q.releaser(), nil
+	}
+	if q.queued >= q.maxSize {
+		q.stats.Rejected++
+		q.mu.Unlock()
+		q.observeWait(0, OutcomeRejected)
+		return nil, ErrQueueFull
+	}
+	w := q.push(priority, user)
+	q.notify()
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var outcome string
+	select {
+	case <-w.ready:
+		q.observeWait(time.Since(start), OutcomeAdmitted)
+		return q.releaser(), nil
+	case <-ctx.Done():
+		err, outcome = ctx.Err(), OutcomeCanceled
+	case <-timeout:
+		err, outcome = ErrQueueTimeout, OutcomeTimeout
+	}
+
+	q.mu.Lock()
+	if outcome == OutcomeTimeout {
+		q.stats.TimedOut++
+	} else {
+		q.stats.Canceled++
+	}
+	admitted := w.admitted
+	if admitted {
+		// 放行与取消同时发生，不计入放行数，稍后归还已获得的名额
+		q.stats.Admitted--
+	} else {
+		q.remove(w)
+		q.notify()
+	}
+	q.mu.Unlock()
+	if admitted {
+		q.release()
+	}
+	q.observeWait(time.Since(start), outcome)
+	return nil, err
+}
+
+// releaser 获取只生效一次的归还名额函数
+func (q *queue) releaser() (release func()) {
+	var once sync.Once
+	return func() {
+		once.Do(q.release)
+	}
+}
+
+// release 归还名额并按优先级放行排队的请求
+func (q *queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inFlight--
+	for q.inFlight < q.limit {
+		w := q.pop()
+		if w == nil {
+			break
+		}
+		w.admitted = true
+		q.inFlight++
+		q.stats.Admitted++
+		close(w.ready)
+	}
+	q.notify()
+}
+
+// push 将请求加入对应优先级和用户的队列，调用方需持有锁
+func (q *queue) push(priority Priority, user string) (w *waiter) {
+	i, found := slices.BinarySearchFunc(q.levels, priority, func(l *level, p Priority) int {
+		return int(p - l.priority) // 按优先级从高到低排序
+	})
+	if !found {
+		q.levels = slices.Insert(q.levels, i, &level{priority: priority, byUser: make(map[string]*userQueue)})
+	}
+	l := q.levels[i]
+	uq, ok := l.byUser[user]
+	if !ok {
+		uq = &userQueue{user: user, waiters: list.New()}
+		l.byUser[user] = uq
+		l.users = append(l.users, uq)
+	}
+	w = &waiter{ready: make(chan struct{}), users: uq}
+	w.elem = uq.waiters.PushBack(w)
+	q.queued++
+	return
+}
+
+// pop 取出下一个放行的请求：优先级最高的队列中轮到的用户最早排队的请求，调用方需持有锁
+func (q *queue) pop() (w *waiter) {
+	for _, l := range q.levels {
+		if len(l.users) == 0 {
+			continue
+		}
+		if l.next >= len(l.users) {
+			l.next = 0
+		}
+		uq := l.users[l.next]
+		w = uq.waiters.Remove(uq.waiters.Front()).(*waiter)
+		if uq.waiters.Len() == 0 {
+			l.removeUser(l.next)
+		} else {
+			l.next++
+		}
+		q.queued--
+		return
+	}
+	return nil
+}
+
+// remove 将请求移出队列，调用方需持有锁
+func (q *queue) remove(w *waiter) {
+	uq := w.users
+	uq.waiters.Remove(w.elem)
+	q.queued--
+	if uq.waiters.Len() > 0 {
+		return
+	}
+	for _, l := range q.levels {
+		if i := slices.Index(l.users, uq); i >= 0 {
+			l.removeUser(i)
+			return
+		}
+	}
+}
+
+// removeUser 移除没有排队请求的用户，保持轮转顺序
+func (l *level) removeUser(i int) {
+	delete(l.byUser, l.users[i].user)
+	l.users = slices.Delete(l.users, i, i+1)
+	if i < l.next {
+		l.next--
+	}
+}
+
+// snapshot 获取统计信息
+func (q *queue) snapshot() (stats Stats) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats = q.stats
+	stats.Limit = q.limit
+	stats.InFlight = q.inFlight
+	stats.Queued = q.queued
+	return
+}
+
+// notify 通知观察者并发数和排队数，调用方需持有锁
+func (q *queue) notify() {
+	if q.observer != nil {
+		q.observer.ObserveQueue(q.name, q.inFlight, q.queued)
+	}
+}
+
+// observeWait 通知观察者排队结果
+func (q *queue) observeWait(wait time.Duration, outcome string) {
+	if q.observer != nil {
+		q.observer.ObserveWait(q.name, wait, outcome)
+	}
+}
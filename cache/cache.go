@@ -0,0 +1,41 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 10:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 16:20:51
+ * @Description: 响应缓存，缓存存储接口定义
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"time"
+)
+
+// Store 缓存存储接口，接口语义与 Redis 的 GET/SET EX/DEL 保持一致，便于接入外部存储
+type Store interface {
+	// 获取缓存，缓存不存在或已过期时 ok 为 false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// 设置缓存，ttl 小于等于0表示永不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error)
+	// 删除缓存
+	Delete(ctx context.Context, key string) (err error)
+}
+
+const (
+	BypassKey httpclient.ContextKey = "go_aisdk_cache_bypass" // 跳过缓存标记在上下文中的键
+)
+
+// WithBypass 设置跳过缓存，本次请求既不读取缓存也不写入缓存
+func WithBypass(ctx context.Context) (newCtx context.Context) {
+	return context.WithValue(ctx, BypassKey, true)
+}
+
+// IsBypass 判断是否跳过缓存
+func IsBypass(ctx context.Context) (ok bool) {
+	bypass, _ := ctx.Value(BypassKey).(bool)
+	return bypass
+}
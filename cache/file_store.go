@@ -0,0 +1,130 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 11:36:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 15:52:30
+ * @Description: 磁盘缓存存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	errCacheDirEmpty = errors.New("cache dir is empty") // 缓存目录为空
+)
+
+// fileItem 磁盘缓存项
+type fileItem struct {
+	Key      string `json:"key"`       // 缓存键
+	Value    []byte `json:"value"`     // 缓存值
+	ExpireAt int64  `json:"expire_at"` // 过期时间（Unix 纳秒时间戳），0表示永不过期
+}
+
+// FileStore 磁盘缓存存储，每个缓存项保存为一个文件
+type FileStore struct {
+	dir string // 缓存目录
+}
+
+// NewFileStore 创建磁盘缓存存储，目录不存在时会自动创建
+func NewFileStore(dir string) (store *FileStore, err error) {
+	if dir == "" {
+		err = errCacheDirEmpty
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		err = fmt.Errorf("failed to create cache dir: %w", err)
+		return
+	}
+	store = &FileStore{
+		dir: dir,
+	}
+	return
+}
+
+// Get 获取缓存
+func (s *FileStore) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(s.filePath(key)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	var item fileItem
+	if err = json.Unmarshal(data, &item); err != nil {
+		err = fmt.Errorf("failed to unmarshal cache item: %w", err)
+		return
+	}
+	// 已过期则删除
+	if item.ExpireAt > 0 && time.Now().UnixNano() > item.ExpireAt {
+		err = s.Delete(ctx, key)
+		return
+	}
+	return item.Value, true, nil
+}
+
+// Set 设置缓存
+func (s *FileStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	item := fileItem{
+		Key:   key,
+		Value: value,
+	}
+	if ttl > 0 {
+		item.ExpireAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	var data []byte
+	if data, err = json.Marshal(item); err != nil {
+		err = fmt.Errorf("failed to marshal cache item: %w", err)
+		return
+	}
+	// 先写入临时文件再重命名，避免并发读取到不完整的数据
+	var tmpFile *os.File
+	if tmpFile, err = os.CreateTemp(s.dir, "tmp-*"); err != nil {
+		err = fmt.Errorf("failed to create cache file: %w", err)
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		err = fmt.Errorf("failed to write cache file: %w", err)
+		return
+	}
+	if err = tmpFile.Close(); err != nil {
+		err = fmt.Errorf("failed to write cache file: %w", err)
+		return
+	}
+	if err = os.Rename(tmpFile.Name(), s.filePath(key)); err != nil {
+		err = fmt.Errorf("failed to write cache file: %w", err)
+		return
+	}
+	return
+}
+
+// Delete 删除缓存
+func (s *FileStore) Delete(ctx context.Context, key string) (err error) {
+	if err = os.Remove(s.filePath(key)); err != nil && errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// filePath 获取缓存文件路径，使用键的哈希值作为文件名，避免非法字符
+func (s *FileStore) filePath(key string) (path string) {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
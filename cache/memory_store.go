@@ -0,0 +1,123 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 10:25:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 15:47:12
+ * @Description: 内存 LRU 缓存存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMemoryStoreCapacity = 1000 // 默认内存缓存容量
+)
+
+// memoryItem 内存缓存项
+type memoryItem struct {
+	key      string    // 缓存键
+	value    []byte    // 缓存值
+	expireAt time.Time // 过期时间，零值表示永不过期
+}
+
+// MemoryStore 内存 LRU 缓存存储
+type MemoryStore struct {
+	capacity int                      // 最大缓存条目数
+	ll       *list.List               // 访问顺序链表，表头为最近访问
+	items    map[string]*list.Element // 缓存项索引
+	mu       sync.Mutex               // 互斥锁
+}
+
+// NewMemoryStore 创建内存 LRU 缓存存储，capacity 小于等于0时使用默认容量
+func NewMemoryStore(capacity int) (store *MemoryStore) {
+	if capacity <= 0 {
+		capacity = defaultMemoryStoreCapacity
+	}
+	return &MemoryStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 获取缓存
+func (s *MemoryStore) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var elem *list.Element
+	if elem, ok = s.items[key]; !ok {
+		return
+	}
+	item := elem.Value.(*memoryItem)
+	// 已过期则删除
+	if !item.expireAt.IsZero() && time.Now().After(item.expireAt) {
+		s.removeElement(elem)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(elem)
+	return slices.Clone(item.value), true, nil
+}
+
+// Set 设置缓存
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	// 已存在则更新
+	if elem, ok := s.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.value = slices.Clone(value)
+		item.expireAt = expireAt
+		s.ll.MoveToFront(elem)
+		return
+	}
+	// 新增缓存项
+	s.items[key] = s.ll.PushFront(&memoryItem{
+		key:      key,
+		value:    slices.Clone(value),
+		expireAt: expireAt,
+	})
+	// 超出容量时淘汰最久未访问的缓存项
+	for s.ll.Len() > s.capacity {
+		s.removeElement(s.ll.Back())
+	}
+	return
+}
+
+// Delete 删除缓存
+func (s *MemoryStore) Delete(ctx context.Context, key string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.removeElement(elem)
+	}
+	return
+}
+
+// Len 获取缓存条目数（包含尚未清理的过期条目）
+func (s *MemoryStore) Len() (n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
+
+// removeElement 删除缓存项
+func (s *MemoryStore) removeElement(elem *list.Element) {
+	s.ll.Remove(elem)
+	delete(s.items, elem.Value.(*memoryItem).key)
+}
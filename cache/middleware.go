@@ -0,0 +1,289 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 13:05:19
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 14:12:40
+ * @Description: 响应缓存中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL       = time.Hour                    // 默认缓存有效期
+	defaultKeyPrefix = "aisdk:chat:"                // 默认缓存键前缀
+	cacheHeaderKey   = "X-AISDK-Cache"              // 缓存命中标记头键
+	cacheHeaderHit   = "hit"                        // 缓存命中标记
+	methodChat       = "CreateChatCompletion"       // 阻塞式聊天方法
+	methodChatStream = "CreateChatCompletionStream" // 流式聊天方法
+)
+
+// Condition 缓存条件函数，返回 false 时该请求不使用缓存
+type Condition func(request models.ChatRequest) (ok bool)
+
+// MiddlewareConfig 缓存中间件配置
+type MiddlewareConfig struct {
+	Store     Store         // 缓存存储，默认使用内存 LRU 存储
+	TTL       time.Duration // 缓存有效期，默认1小时
+	KeyPrefix string        // 缓存键前缀，默认 "aisdk:chat:"
+	Condition Condition     // 缓存条件，为空时缓存所有聊天请求
+}
+
+// entry 缓存条目
+type entry struct {
+	Response  *models.ChatBaseResponse  `json:"response,omitempty"`   // 阻塞式调用的响应
+	Chunks    []models.ChatBaseResponse `json:"chunks,omitempty"`     // 流式调用的数据块
+	CreatedAt int64                     `json:"created_at,omitempty"` // 缓存时间
+}
+
+// Middleware 缓存中间件
+//
+//	按提供商、模型、消息和参数计算缓存键，缓存读写失败不会影响请求本身
+type Middleware struct {
+	config MiddlewareConfig
+}
+
+// NewMiddleware 创建缓存中间件
+func NewMiddleware(config MiddlewareConfig) (m *Middleware) {
+	if config.Store == nil {
+		config.Store = NewMemoryStore(defaultMemoryStoreCapacity)
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultKeyPrefix
+	}
+	return &Middleware{
+		config: config,
+	}
+}
+
+// Process 处理请求
+func (m *Middleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	// 判断是否使用缓存
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok || !m.isCacheable(ctx, requestInfo.Method, chatReq) {
+		return next(ctx, request)
+	}
+	// 计算缓存键
+	var key string
+	if key, err = m.cacheKey(chatReq); err != nil {
+		return next(ctx, request)
+	}
+	// 读取缓存
+	if e, hit := m.load(ctx, key); hit {
+		if response, ok = replay(requestInfo, e); ok {
+			markHit(requestInfo)
+			return
+		}
+	}
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 写入缓存
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		m.save(ctx, key, &entry{Response: &resp.ChatBaseResponse})
+	case models.ChatResponseStream:
+		// 流式传输可能在请求返回后才结束，写入缓存时不能继承请求的取消信号
+		saveCtx := context.WithoutCancel(ctx)
+		record(resp, func(chunks []models.ChatBaseResponse) {
+			m.save(saveCtx, key, &entry{Chunks: chunks})
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *Middleware) Name() (name string) {
+	return "cache"
+}
+
+// Priority 返回中间件优先级
+func (m *Middleware) Priority() (priority int) {
+	return 15 // 缓存中间件在监控之后、重试之前执行，命中缓存时不再重试
+}
+
+// isCacheable 判断请求是否使用缓存
+func (m *Middleware) isCacheable(ctx context.Context, method string, request models.ChatRequest) (ok bool) {
+	if IsBypass(ctx) {
+		return false
+	}
+	switch method {
+	case methodChat:
+		// 阻塞式调用不支持流式传输，交给后续处理器返回错误
+		if models.BoolValue(request.Stream) {
+			return false
+		}
+	case methodChatStream:
+	default:
+		return false
+	}
+	if m.config.Condition != nil {
+		return m.config.Condition(request)
+	}
+	return true
+}
+
+// cacheKey 计算缓存键
+//
+//	忽略用户标识和流式传输参数，使阻塞式和流式调用共享同一份缓存
+func (m *Middleware) cacheKey(request models.ChatRequest) (key string, err error) {
+	request.UserInfo = models.UserInfo{}
+	request.Stream = nil
+	request.StreamOptions = nil
+
+	var b []byte
+	if b, err = json.Marshal(request); err != nil {
+		return
+	}
+	h := sha256.New()
+	h.Write([]byte(request.Provider.String()))
+	h.Write([]byte{'\n'})
+	h.Write(b)
+	return m.config.KeyPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// load 读取缓存
+func (m *Middleware) load(ctx context.Context, key string) (e *entry, ok bool) {
+	var (
+		data []byte
+		err  error
+	)
+	if data, ok, err = m.config.Store.Get(ctx, key); err != nil || !ok {
+		return nil, false
+	}
+	e = &entry{}
+	if err = json.Unmarshal(data, e); err != nil {
+		return nil, false
+	}
+	return e, e.Response != nil || len(e.Chunks) > 0
+}
+
+// save 写入缓存
+func (m *Middleware) save(ctx context.Context, key string, e *entry) {
+	e.CreatedAt = time.Now().Unix()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = m.config.Store.Set(ctx, key, data, m.config.TTL)
+}
+
+// record 记录流式传输的数据块，流式传输正常结束后回调 onFinish
+func record(stream models.ChatResponseStream, onFinish func(chunks []models.ChatBaseResponse)) {
+	if stream.StreamReader == nil {
+		return
+	}
+	var (
+		mu     sync.Mutex
+		chunks []models.ChatBaseResponse
+	)
+	stream.AddHook(httpclient.StreamHook[models.ChatBaseResponse]{
+		OnChunk: func(chunk models.ChatBaseResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			chunks = append(chunks, chunk)
+		},
+		OnClose: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && len(chunks) > 0 {
+				onFinish(chunks)
+			}
+		},
+	})
+}
+
+// markHit 将请求信息标记为缓存命中
+func markHit(requestInfo *httpclient.RequestInfo) {
+	now := time.Now()
+	requestInfo.EndTime = now
+	requestInfo.TotalDurationMs = now.Sub(requestInfo.StartTime).Milliseconds()
+	requestInfo.IsSuccess = true
+	requestInfo.Error = nil
+}
+
+// replay 回放缓存
+func replay(requestInfo *httpclient.RequestInfo, e *entry) (response any, ok bool) {
+	header := httpclient.HttpHeader{}
+	header.Header().Set(cacheHeaderKey, cacheHeaderHit)
+	if requestInfo.RequestID != "" && requestInfo.RequestID != "unknown" {
+		header.SetRequestID(requestInfo.RequestID)
+	}
+
+	switch requestInfo.Method {
+	case methodChat:
+		resp := models.ChatResponse{HttpHeader: header}
+		if e.Response != nil {
+			resp.ChatBaseResponse = *e.Response
+		} else {
+			resp.ChatBaseResponse = models.MergeChatStreamChunks(e.Chunks)
+		}
+		return resp, true
+	case methodChatStream:
+		chunks := e.Chunks
+		if len(chunks) == 0 {
+			chunks = responseToChunks(*e.Response)
+		}
+		// 将数据块编码为 SSE 格式
+		buf := &bytes.Buffer{}
+		for _, chunk := range chunks {
+			b, err := json.Marshal(chunk)
+			if err != nil {
+				return nil, false
+			}
+			buf.WriteString("data: ")
+			buf.Write(b)
+			buf.WriteString("\n\n")
+		}
+		buf.WriteString("data: [DONE]\n\n")
+		stream := httpclient.NewStreamReader[models.ChatBaseResponse](io.NopCloser(buf), http.Header(header), nil)
+		return models.ChatResponseStream{StreamReader: stream}, true
+	}
+	return nil, false
+}
+
+// responseToChunks 将阻塞式调用的响应转换为流式传输的数据块
+func responseToChunks(response models.ChatBaseResponse) (chunks []models.ChatBaseResponse) {
+	chunk := response
+	chunk.StreamStats = nil
+	chunk.Choices = make([]models.ChatChoice, len(response.Choices))
+	for i, choice := range response.Choices {
+		chunk.Choices[i] = models.ChatChoice{
+			FinishReason: choice.FinishReason,
+			Index:        choice.Index,
+			LogProbs:     choice.LogProbs,
+			Delta:        choice.Message,
+		}
+	}
+	return []models.ChatBaseResponse{chunk}
+}
+
+// DefaultMiddlewareConfig 默认缓存配置
+func DefaultMiddlewareConfig() (config MiddlewareConfig) {
+	return MiddlewareConfig{
+		Store:     NewMemoryStore(defaultMemoryStoreCapacity),
+		TTL:       defaultTTL,
+		KeyPrefix: defaultKeyPrefix,
+		Condition: nil,
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 14:40:27
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 16:20:51
+ * @Description: 缓存中间件测试
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newTestContext 创建带有请求信息的上下文
+func newTestContext(method string) (ctx context.Context) {
+	return httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  consts.OpenAI.String(),
+		Method:    method,
+		RequestID: "test-request-id",
+	})
+}
+
+// newTestRequest 创建测试请求
+func newTestRequest(content string) (request models.ChatRequest) {
+	return models.ChatRequest{
+		Provider:    consts.OpenAI,
+		Model:       consts.OpenAIGPT4o,
+		Messages:    []models.ChatMessage{&models.UserMessage{Content: content}},
+		Temperature: models.Float32(0),
+	}
+}
+
+// collectStream 读取流式传输的全部内容
+func collectStream(t *testing.T, stream models.ChatResponseStream) (content string) {
+	t.Helper()
+	err := stream.ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil {
+				content += choice.Delta.Content
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return
+}
+
+func TestMiddleware_Chat(t *testing.T) {
+	var (
+		m     = NewMiddleware(MiddlewareConfig{})
+		calls int
+		next  = func(ctx context.Context, request any) (response any, err error) {
+			calls++
+			return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+				ID: "chatcmpl-1",
+				Choices: []models.ChatChoice{
+					{FinishReason: models.ChatFinishReasonStop, Message: &models.ChatCompletionMessage{Role: "assistant", Content: "Hi"}},
+				},
+			}}, nil
+		}
+	)
+
+	for i := 0; i < 2; i++ {
+		resp, err := m.Process(newTestContext(methodChat), newTestRequest("hello"), next)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if content := resp.(models.ChatResponse).Choices[0].Message.Content; content != "Hi" {
+			t.Errorf("expected content to be Hi, got %s", content)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected next to be called once, got %d", calls)
+	}
+
+	// 不同的消息不应命中缓存
+	m.Process(newTestContext(methodChat), newTestRequest("another"), next)
+	if calls != 2 {
+		t.Errorf("expected next to be called twice, got %d", calls)
+	}
+
+	// 跳过缓存
+	m.Process(WithBypass(newTestContext(methodChat)), newTestRequest("hello"), next)
+	if calls != 3 {
+		t.Errorf("expected bypass to call next, got %d calls", calls)
+	}
+
+	// 阻塞式调用的缓存以流式传输的方式回放
+	resp, err := m.Process(newTestContext(methodChatStream), newTestRequest("hello"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream := resp.(models.ChatResponseStream)
+	if stream.Header().Get(cacheHeaderKey) != cacheHeaderHit {
+		t.Error("expected cache hit header")
+	}
+	if content := collectStream(t, stream); content != "Hi" {
+		t.Errorf("expected replayed content to be Hi, got %s", content)
+	}
+	if calls != 3 {
+		t.Errorf("expected stream replay not to call next, got %d calls", calls)
+	}
+}
+
+func TestMiddleware_ChatStream(t *testing.T) {
+	var (
+		m     = NewMiddleware(MiddlewareConfig{})
+		calls int
+		next  = func(ctx context.Context, request any) (response any, err error) {
+			calls++
+			body := io.NopCloser(strings.NewReader(
+				"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}]}\n\n" +
+					"data: {\"choices\":[{\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\n" +
+					"data: [DONE]\n\n",
+			))
+			return models.ChatResponseStream{
+				StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](body, nil, nil),
+			}, nil
+		}
+	)
+
+	resp, err := m.Process(newTestContext(methodChatStream), newTestRequest("hello"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content := collectStream(t, resp.(models.ChatResponseStream)); content != "Hello world" {
+		t.Errorf("expected content to be Hello world, got %s", content)
+	}
+
+	resp, err = m.Process(newTestContext(methodChatStream), newTestRequest("hello"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content := collectStream(t, resp.(models.ChatResponseStream)); content != "Hello world" {
+		t.Errorf("expected replayed content to be Hello world, got %s", content)
+	}
+
+	// 流式传输的缓存以阻塞式调用的方式回放
+	resp, err = m.Process(newTestContext(methodChat), newTestRequest("hello"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chatResp := resp.(models.ChatResponse)
+	if content := chatResp.Choices[0].Message.Content; content != "Hello world" {
+		t.Errorf("expected merged content to be Hello world, got %s", content)
+	}
+	if chatResp.RequestID() != "test-request-id" {
+		t.Errorf("expected request id to be test-request-id, got %s", chatResp.RequestID())
+	}
+	if calls != 1 {
+		t.Errorf("expected next to be called once, got %d", calls)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 11:40:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 11:40:52
+ * @Description: 语义缓存中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSemanticThreshold = 0.95              // 默认余弦相似度阈值
+	defaultSemanticPrefix    = "aisdk:semantic:" // 默认作用域前缀
+)
+
+// Embedder 向量化函数，将文本转换为嵌入向量
+type Embedder func(ctx context.Context, text string) (vector []float32, err error)
+
+// SemanticMiddlewareConfig 语义缓存中间件配置
+type SemanticMiddlewareConfig struct {
+	Embedder  Embedder      // 向量化函数，为空时不使用语义缓存
+	Store     VectorStore   // 向量存储，默认使用内存向量存储
+	Threshold float64       // 余弦相似度阈值，不低于该值时命中缓存，默认0.95
+	TTL       time.Duration // 缓存有效期，默认1小时
+	Condition Condition     // 缓存条件，为空时缓存所有聊天请求
+}
+
+// SemanticMiddleware 语义缓存中间件
+//
+//	对最后一条用户消息进行向量化，并在相同提供商、模型和系统提示词的作用域内检索相似的历史请求，
+//	只比较最后一条用户消息，需要区分对话历史时可以通过 Condition 限制使用范围
+type SemanticMiddleware struct {
+	config SemanticMiddlewareConfig
+}
+
+// NewSemanticMiddleware 创建语义缓存中间件
+func NewSemanticMiddleware(config SemanticMiddlewareConfig) (m *SemanticMiddleware) {
+	if config.Store == nil {
+		config.Store = NewMemoryVectorStore(defaultMemoryVectorStoreCapacity)
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = defaultSemanticThreshold
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	return &SemanticMiddleware{
+		config: config,
+	}
+}
+
+// Process 处理请求
+func (m *SemanticMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	// 判断是否使用缓存
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok || m.config.Embedder == nil || !m.isCacheable(ctx, requestInfo.Method, chatReq) {
+		return next(ctx, request)
+	}
+	// 获取最后一条用户消息
+	var text string
+	if text, ok = lastUserText(chatReq.Messages); !ok {
+		return next(ctx, request)
+	}
+	// 向量化
+	var vector []float32
+	if vector, err = m.config.Embedder(ctx, text); err != nil || len(vector) == 0 {
+		return next(ctx, request)
+	}
+	// 检索缓存
+	scope := semanticScope(chatReq)
+	if e, hit := m.search(ctx, scope, vector); hit {
+		if response, ok = replay(requestInfo, e); ok {
+			markHit(requestInfo)
+			return
+		}
+	}
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 写入缓存
+	id := hashText(text)
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		m.save(ctx, scope, id, vector, &entry{Response: &resp.ChatBaseResponse})
+	case models.ChatResponseStream:
+		// 流式传输可能在请求返回后才结束，写入缓存时不能继承请求的取消信号
+		saveCtx := context.WithoutCancel(ctx)
+		record(resp, func(chunks []models.ChatBaseResponse) {
+			m.save(saveCtx, scope, id, vector, &entry{Chunks: chunks})
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *SemanticMiddleware) Name() (name string) {
+	return "semantic_cache"
+}
+
+// Priority 返回中间件优先级
+func (m *SemanticMiddleware) Priority() (priority int) {
+	return 16 // 语义缓存中间件在精确缓存之后执行，精确匹配优先
+}
+
+// isCacheable 判断请求是否使用缓存
+func (m *SemanticMiddleware) isCacheable(ctx context.Context, method string, request models.ChatRequest) (ok bool) {
+	if IsBypass(ctx) {
+		return false
+	}
+	switch method {
+	case methodChat:
+		// 阻塞式调用不支持流式传输，交给后续处理器返回错误
+		if models.BoolValue(request.Stream) {
+			return false
+		}
+	case methodChatStream:
+	default:
+		return false
+	}
+	if m.config.Condition != nil {
+		return m.config.Condition(request)
+	}
+	return true
+}
+
+// search 检索缓存
+func (m *SemanticMiddleware) search(ctx context.Context, scope string, vector []float32) (e *entry, ok bool) {
+	matches, err := m.config.Store.Search(ctx, scope, vector, 1)
+	if err != nil || len(matches) == 0 || matches[0].Score < m.config.Threshold {
+		return nil, false
+	}
+	e = &entry{}
+	if err = json.Unmarshal(matches[0].Value, e); err != nil {
+		return nil, false
+	}
+	return e, e.Response != nil || len(e.Chunks) > 0
+}
+
+// save 写入缓存
+func (m *SemanticMiddleware) save(ctx context.Context, scope, id string, vector []float32, e *entry) {
+	now := time.Now()
+	e.CreatedAt = now.Unix()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = m.config.Store.Add(ctx, scope, VectorRecord{
+		ID:       id,
+		Vector:   vector,
+		Value:    data,
+		ExpireAt: now.Add(m.config.TTL),
+	})
+}
+
+// lastUserText 获取最后一条用户消息的文本，最后一条消息不是用户消息或包含非文本内容时返回 false
+func lastUserText(messages []models.ChatMessage) (text string, ok bool) {
+	if len(messages) == 0 {
+		return
+	}
+	userMsg, isUser := messages[len(messages)-1].(*models.UserMessage)
+	if !isUser || userMsg == nil {
+		return
+	}
+	if len(userMsg.MultimodalContent) == 0 {
+		return userMsg.Content, userMsg.Content != ""
+	}
+	parts := make([]string, 0, len(userMsg.MultimodalContent))
+	for _, part := range userMsg.MultimodalContent {
+		if part.Type != "" && part.Type != models.ChatUserMsgPartTypeText {
+			return "", false
+		}
+		if part.ImageURL != nil || part.InputAudio != nil || part.File != nil || part.InputVideo != nil {
+			return "", false
+		}
+		parts = append(parts, part.Text)
+	}
+	text = strings.Join(parts, "\n")
+	return text, text != ""
+}
+
+// semanticScope 计算语义缓存的作用域，由提供商、模型以及系统和开发者消息决定
+func semanticScope(request models.ChatRequest) (scope string) {
+	h := sha256.New()
+	h.Write([]byte(request.Provider.String()))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(request.Model))
+	for _, message := range request.Messages {
+		switch msg := message.(type) {
+		case *models.SystemMessage:
+			h.Write([]byte("\nsystem:"))
+			h.Write([]byte(msg.Content))
+		case *models.DeveloperMessage:
+			h.Write([]byte("\ndeveloper:"))
+			h.Write([]byte(msg.Content))
+		}
+	}
+	return defaultSemanticPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// hashText 计算文本的哈希值
+func hashText(text string) (hash string) {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// DefaultSemanticMiddlewareConfig 默认语义缓存配置
+func DefaultSemanticMiddlewareConfig(embedder Embedder) (config SemanticMiddlewareConfig) {
+	return SemanticMiddlewareConfig{
+		Embedder:  embedder,
+		Store:     NewMemoryVectorStore(defaultMemoryVectorStoreCapacity),
+		Threshold: defaultSemanticThreshold,
+		TTL:       defaultTTL,
+		Condition: nil,
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 14:05:33
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 14:05:33
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/models"
+	"testing"
+)
+
+// testEmbedder 根据预设的向量表进行向量化
+func testEmbedder(vectors map[string][]float32) (embedder Embedder) {
+	return func(ctx context.Context, text string) (vector []float32, err error) {
+		if vector = vectors[text]; vector == nil {
+			return nil, errors.New("unknown text")
+		}
+		return
+	}
+}
+
+func TestSemanticMiddleware(t *testing.T) {
+	var (
+		m = NewSemanticMiddleware(SemanticMiddlewareConfig{
+			Embedder: testEmbedder(map[string][]float32{
+				"what is go":        {1, 0, 0},
+				"what is golang":    {0.99, 0.05, 0},
+				"how to cook pasta": {0, 0, 1},
+			}),
+			Threshold: 0.9,
+		})
+		calls int
+		next  = func(ctx context.Context, request any) (response any, err error) {
+			calls++
+			return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+				Choices: []models.ChatChoice{
+					{FinishReason: models.ChatFinishReasonStop, Message: &models.ChatCompletionMessage{Role: "assistant", Content: "A language"}},
+				},
+			}}, nil
+		}
+		withSystem = func(request models.ChatRequest, prompt string) (r models.ChatRequest) {
+			request.Messages = append([]models.ChatMessage{&models.SystemMessage{Content: prompt}}, request.Messages...)
+			return request
+		}
+	)
+
+	m.Process(newTestContext(methodChat), newTestRequest("what is go"), next)
+	// 相似的问题命中缓存
+	resp, err := m.Process(newTestContext(methodChat), newTestRequest("what is golang"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content := resp.(models.ChatResponse).Choices[0].Message.Content; content != "A language" {
+		t.Errorf("expected content to be A language, got %s", content)
+	}
+	if calls != 1 {
+		t.Errorf("expected next to be called once, got %d", calls)
+	}
+	// 不相似的问题不命中缓存
+	m.Process(newTestContext(methodChat), newTestRequest("how to cook pasta"), next)
+	if calls != 2 {
+		t.Errorf("expected next to be called twice, got %d", calls)
+	}
+	// 不同的模型不命中缓存
+	request := newTestRequest("what is golang")
+	request.Model = "another-model"
+	m.Process(newTestContext(methodChat), request, next)
+	if calls != 3 {
+		t.Errorf("expected another model to call next, got %d calls", calls)
+	}
+	// 不同的系统提示词不命中缓存
+	m.Process(newTestContext(methodChat), withSystem(newTestRequest("what is golang"), "be brief"), next)
+	if calls != 4 {
+		t.Errorf("expected another system prompt to call next, got %d calls", calls)
+	}
+	m.Process(newTestContext(methodChat), withSystem(newTestRequest("what is go"), "be brief"), next)
+	if calls != 4 {
+		t.Errorf("expected same system prompt to hit cache, got %d calls", calls)
+	}
+	// 向量化失败时直接执行请求
+	m.Process(newTestContext(methodChat), newTestRequest("unknown"), next)
+	if calls != 5 {
+		t.Errorf("expected embedding failure to call next, got %d calls", calls)
+	}
+	// 以流式传输的方式回放
+	resp, err = m.Process(newTestContext(methodChatStream), newTestRequest("what is golang"), next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content := collectStream(t, resp.(models.ChatResponseStream)); content != "A language" {
+		t.Errorf("expected replayed content to be A language, got %s", content)
+	}
+	if calls != 5 {
+		t.Errorf("expected stream replay not to call next, got %d calls", calls)
+	}
+}
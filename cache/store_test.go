@@ -0,0 +1,100 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 14:18:02
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 15:52:30
+ * @Description: 缓存存储测试
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testStore 测试缓存存储的通用行为
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	t.Run("get missing key", func(t *testing.T) {
+		if _, ok, err := store.Get(ctx, "missing"); err != nil || ok {
+			t.Errorf("expected cache miss, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := store.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		value, ok, err := store.Get(ctx, "key")
+		if err != nil || !ok {
+			t.Fatalf("expected cache hit, got ok=%v err=%v", ok, err)
+		}
+		if string(value) != "value" {
+			t.Errorf("expected value to be value, got %s", value)
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		if err := store.Set(ctx, "expired", []byte("value"), time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if _, ok, err := store.Get(ctx, "expired"); err != nil || ok {
+			t.Errorf("expected expired key to miss, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := store.Delete(ctx, "key"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok, _ := store.Get(ctx, "key"); ok {
+			t.Error("expected deleted key to miss")
+		}
+		if err := store.Delete(ctx, "key"); err != nil {
+			t.Errorf("deleting a missing key should not fail, got %v", err)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(10))
+
+	t.Run("evict least recently used", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			store = NewMemoryStore(2)
+		)
+		store.Set(ctx, "a", []byte("1"), 0)
+		store.Set(ctx, "b", []byte("2"), 0)
+		// 访问 a，使 b 成为最久未访问的缓存项
+		store.Get(ctx, "a")
+		store.Set(ctx, "c", []byte("3"), 0)
+
+		if _, ok, _ := store.Get(ctx, "b"); ok {
+			t.Error("expected b to be evicted")
+		}
+		if _, ok, _ := store.Get(ctx, "a"); !ok {
+			t.Error("expected a to be kept")
+		}
+		if store.Len() != 2 {
+			t.Errorf("expected store length to be 2, got %d", store.Len())
+		}
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStore(t, store)
+
+	if _, err = NewFileStore(""); err == nil {
+		t.Error("expected error for empty cache dir")
+	}
+}
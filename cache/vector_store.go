@@ -0,0 +1,170 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 11:02:18
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 11:02:18
+ * @Description: 向量存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMemoryVectorStoreCapacity = 1000 // 默认内存向量存储容量
+)
+
+// VectorRecord 向量记录
+type VectorRecord struct {
+	ID       string    // 记录ID
+	Vector   []float32 // 向量
+	Value    []byte    // 记录值
+	ExpireAt time.Time // 过期时间，零值表示永不过期
+}
+
+// VectorMatch 向量检索结果
+type VectorMatch struct {
+	VectorRecord
+	Score float64 // 余弦相似度
+}
+
+// VectorStore 向量存储接口
+//
+//	记录按作用域隔离，检索只在同一作用域内进行
+type VectorStore interface {
+	// Add 添加记录，ID 相同时覆盖
+	Add(ctx context.Context, scope string, record VectorRecord) (err error)
+	// Search 检索与向量最相似的 topK 条记录，按相似度从高到低排序
+	Search(ctx context.Context, scope string, vector []float32, topK int) (matches []VectorMatch, err error)
+	// Delete 删除记录
+	Delete(ctx context.Context, scope, id string) (err error)
+}
+
+// memoryVectorItem 内存向量存储条目
+type memoryVectorItem struct {
+	scope  string
+	record VectorRecord
+}
+
+// MemoryVectorStore 基于暴力检索的内存向量存储，超出容量时淘汰最早写入的记录
+type MemoryVectorStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	scopes   map[string]map[string]*list.Element
+}
+
+// NewMemoryVectorStore 创建内存向量存储，capacity <= 0 时使用默认容量
+func NewMemoryVectorStore(capacity int) (s *MemoryVectorStore) {
+	if capacity <= 0 {
+		capacity = defaultMemoryVectorStoreCapacity
+	}
+	return &MemoryVectorStore{
+		capacity: capacity,
+		ll:       list.New(),
+		scopes:   make(map[string]map[string]*list.Element),
+	}
+}
+
+// Add 添加记录
+func (s *MemoryVectorStore) Add(ctx context.Context, scope string, record VectorRecord) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(scope, record.ID)
+	elems, ok := s.scopes[scope]
+	if !ok {
+		elems = make(map[string]*list.Element)
+		s.scopes[scope] = elems
+	}
+	elems[record.ID] = s.ll.PushFront(&memoryVectorItem{scope: scope, record: record})
+	// 淘汰最早写入的记录
+	for s.ll.Len() > s.capacity {
+		item := s.ll.Back().Value.(*memoryVectorItem)
+		s.removeLocked(item.scope, item.record.ID)
+	}
+	return
+}
+
+// Search 检索最相似的记录
+func (s *MemoryVectorStore) Search(ctx context.Context, scope string, vector []float32, topK int) (matches []VectorMatch, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, elem := range s.scopes[scope] {
+		record := elem.Value.(*memoryVectorItem).record
+		if !record.ExpireAt.IsZero() && now.After(record.ExpireAt) {
+			s.removeLocked(scope, id)
+			continue
+		}
+		matches = append(matches, VectorMatch{
+			VectorRecord: record,
+			Score:        CosineSimilarity(vector, record.Vector),
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return
+}
+
+// Delete 删除记录
+func (s *MemoryVectorStore) Delete(ctx context.Context, scope, id string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(scope, id)
+	return
+}
+
+// Len 返回当前记录数量
+func (s *MemoryVectorStore) Len() (n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
+
+// removeLocked 删除记录（调用方需持有锁）
+func (s *MemoryVectorStore) removeLocked(scope, id string) {
+	elems, ok := s.scopes[scope]
+	if !ok {
+		return
+	}
+	if elem, ok := elems[id]; ok {
+		s.ll.Remove(elem)
+		delete(elems, id)
+	}
+	if len(elems) == 0 {
+		delete(s.scopes, scope)
+	}
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，维度不同或存在零向量时返回 0
+func CosineSimilarity(a, b []float32) (score float64) {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
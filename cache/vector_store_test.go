@@ -0,0 +1,77 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 13:48:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 13:48:09
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"dimension mismatch", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryVectorStore(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = NewMemoryVectorStore(2)
+	)
+	s.Add(ctx, "a", VectorRecord{ID: "1", Vector: []float32{1, 0}, Value: []byte("x")})
+	s.Add(ctx, "a", VectorRecord{ID: "2", Vector: []float32{0, 1}, Value: []byte("y")})
+
+	matches, err := s.Search(ctx, "a", []float32{1, 0.1}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != "1" || matches[0].Score < matches[1].Score {
+		t.Fatalf("expected matches sorted by score, got %+v", matches)
+	}
+	// 作用域隔离
+	if matches, _ = s.Search(ctx, "b", []float32{1, 0}, 1); len(matches) != 0 {
+		t.Errorf("expected no matches in another scope, got %d", len(matches))
+	}
+	// 超出容量时淘汰最早写入的记录
+	s.Add(ctx, "b", VectorRecord{ID: "3", Vector: []float32{1, 0}})
+	if s.Len() != 2 {
+		t.Errorf("expected len to be 2, got %d", s.Len())
+	}
+	if matches, _ = s.Search(ctx, "a", []float32{1, 0}, 0); len(matches) != 1 || matches[0].ID != "2" {
+		t.Errorf("expected record 1 to be evicted, got %+v", matches)
+	}
+	// 过期记录不参与检索
+	s.Add(ctx, "c", VectorRecord{ID: "4", Vector: []float32{1, 0}, ExpireAt: time.Now().Add(-time.Second)})
+	if matches, _ = s.Search(ctx, "c", []float32{1, 0}, 0); len(matches) != 0 {
+		t.Errorf("expected expired record to be skipped, got %d", len(matches))
+	}
+	// 删除
+	s.Delete(ctx, "a", "2")
+	if matches, _ = s.Search(ctx, "a", []float32{1, 0}, 0); len(matches) != 0 {
+		t.Errorf("expected record to be deleted, got %d", len(matches))
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-16 14:31:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:29:12
+ * @LastEditTime: 2025-07-20 16:55:12
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -15,7 +15,10 @@ import (
 	"github.com/liusuxian/go-aisdk/core"
 	"github.com/liusuxian/go-aisdk/errors"
 	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/memory"
 	"github.com/liusuxian/go-aisdk/models"
+	"github.com/liusuxian/go-aisdk/structured"
+	"github.com/liusuxian/go-aisdk/tools"
 )
 
 // CreateChatCompletion 创建聊天
@@ -66,3 +69,44 @@ func (c *SDKClient) CreateChatCompletionStream(ctx context.Context, request mode
 	response = resp.(models.ChatResponseStream)
 	return
 }
+
+// CreateStructured 创建结构化输出的聊天，根据模型特性选择响应格式，将模型输出校验并解析为 T 类型，校验失败时自动要求模型修复
+func CreateStructured[T any](
+	ctx context.Context,
+	c *SDKClient,
+	request models.ChatRequest,
+	config structured.Config,
+	opts ...httpclient.HTTPClientOption,
+) (result structured.Result[T], err error) {
+	feature, _ := c.GetModelFeature(request.Provider, consts.ChatModel, request.Model)
+	return structured.Create[T](ctx, func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		return c.CreateChatCompletion(ctx, request, opts...)
+	}, feature, request, config)
+}
+
+// NewSummarizer 使用指定的聊天模型创建摘要函数，用于对话记忆的摘要策略
+func (c *SDKClient) NewSummarizer(provider consts.Provider, model string, opts ...httpclient.HTTPClientOption) (summarizer memory.Summarizer) {
+	return memory.NewChatSummarizer(func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		return c.CreateChatCompletion(ctx, request, opts...)
+	}, provider, model)
+}
+
+// RunTools 执行工具调用循环：调用模型、执行模型请求的工具并追加工具结果，直到模型不再请求调用工具
+func (c *SDKClient) RunTools(ctx context.Context, runner *tools.Runner, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (result tools.Result, err error) {
+	return runner.Run(ctx, func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		return c.CreateChatCompletion(ctx, request, opts...)
+	}, request)
+}
+
+// RunToolsStream 使用流式聊天执行工具调用循环，onChunk 接收每次模型调用的数据块，可为空
+func (c *SDKClient) RunToolsStream(
+	ctx context.Context,
+	runner *tools.Runner,
+	request models.ChatRequest,
+	onChunk func(chunk models.ChatBaseResponse) (err error),
+	opts ...httpclient.HTTPClientOption,
+) (result tools.Result, err error) {
+	return runner.RunStream(ctx, func(ctx context.Context, request models.ChatRequest) (response models.ChatResponseStream, err error) {
+		return c.CreateChatCompletionStream(ctx, request, opts...)
+	}, request, onChunk)
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:09:20
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 15:49:53
+ * @LastEditTime: 2025-08-03 17:05:48
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -25,10 +25,14 @@ import (
 
 // SDKClient SDK客户端
 type SDKClient struct {
-	configManager   *conf.SDKConfigManager // 配置管理器
-	flakeInstance   *flake.Flake           // 分布式唯一ID生成器
-	middlewareChain *httpclient.Chain      // 中间件链
-	noCheckMethods  map[string]bool        // 不需要检查模型支持的方法
+	configManager   *conf.SDKConfigManager                   // 配置管理器
+	providers       map[consts.Provider]core.ProviderService // 客户端自己的提供商实例，创建后只读
+	flakeInstance   *flake.Flake                             // 分布式唯一ID生成器
+	middlewareChain *httpclient.Chain                        // 中间件链
+	noCheckMethods  map[string]bool                          // 不需要检查模型支持的方法
+	tenantPool      *tenantPool                              // 多租户提供商池，未启用时为 nil
+	lifecycle       *lifecycle                               // 生命周期，跟踪进行中的请求和后台任务
+	httpOpts        []httpclient.HTTPClientOption            // 所有请求共用的 HTTP 客户端选项
 }
 
 // SDKClientOption SDK客户端选项
@@ -37,9 +41,26 @@ type SDKClientOption func(c *clientOption)
 // clientOption 客户端选项
 type clientOption struct {
 	middlewares []httpclient.Middleware
+	httpOpts    []httpclient.HTTPClientOption // 所有请求共用的 HTTP 客户端选项
+	afterCreate []func(client *SDKClient)     // 客户端创建完成后的回调
+	flake       flake.Settings                // 分布式唯一ID生成器配置
 }
 
-// NewSDKClient 创建一个SDK客户端
+// WithFlakeSettings 设置生成请求ID的分布式唯一ID生成器配置，默认使用私有IP地址生成机器ID
+func WithFlakeSettings(settings flake.Settings) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.flake = settings
+	}
+}
+
+// WithHTTPClientOptions 设置所有请求共用的 HTTP 客户端选项，如录制回放磁带，每次调用传入的选项在这些选项之后应用
+func WithHTTPClientOptions(opts ...httpclient.HTTPClientOption) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.httpOpts = append(c.httpOpts, opts...)
+	}
+}
+
+// NewSDKClient 使用配置文件创建一个SDK客户端，不读取环境变量；需要 AISDK_ 前缀的环境变量覆盖配置文件时使用 NewSDKClientWithSources
 func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient, err error) {
 	// 创建SDK配置管理器
 	var configManager *conf.SDKConfigManager
@@ -47,30 +68,45 @@ func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient
 		err = errors.WrapFailedToCreateConfigManager(err.Error())
 		return
 	}
-	// 创建一个分布式唯一ID生成器
-	var flakeInstance *flake.Flake
-	if flakeInstance, err = flake.New(flake.Settings{}); err != nil {
-		err = errors.WrapFailedToCreateFlakeInstance(err.Error())
+	return newSDKClient(configManager, opts...)
+}
+
+// NewSDKClientWithConfig 使用代码中给出的配置创建一个SDK客户端，不读取配置文件和环境变量
+func NewSDKClientWithConfig(config conf.SDKConfig, opts ...SDKClientOption) (client *SDKClient, err error) {
+	return NewSDKClientWithSources([]conf.Source{&conf.StaticSource{Config: config}}, opts...)
+}
+
+// NewSDKClientWithSources 从多个配置来源创建一个SDK客户端，来源按顺序合并，后面的覆盖前面的
+//
+// 推荐的顺序：默认值（conf.StaticSource）< 配置文件（conf.FileSource）< 环境变量（conf.EnvSource）< 代码（conf.StaticSource）
+func NewSDKClientWithSources(sources []conf.Source, opts ...SDKClientOption) (client *SDKClient, err error) {
+	// 创建SDK配置管理器
+	var configManager *conf.SDKConfigManager
+	if configManager, err = conf.NewSDKConfigManagerWithSources(sources...); err != nil {
+		err = errors.WrapFailedToCreateConfigManager(err.Error())
 		return
 	}
-	// 初始化所有提供商
-	for _, provider := range core.ListProviders() {
-		// 获取提供商
-		var ps core.ProviderService
-		if ps = core.GetProvider(provider); ps == nil {
-			err = errors.WrapProviderNotSupported(provider)
-			return
-		}
-		// 获取提供商配置
-		providerConfig := configManager.GetProviderConfig(provider)
-		// 初始化提供商配置
-		ps.InitializeProviderConfig(&providerConfig)
-	}
+	return newSDKClient(configManager, opts...)
+}
+
+// newSDKClient 使用配置管理器创建一个SDK客户端
+func newSDKClient(configManager *conf.SDKConfigManager, opts ...SDKClientOption) (client *SDKClient, err error) {
 	// 处理选项
 	cliOpt := &clientOption{}
 	for _, opt := range opts {
 		opt(cliOpt)
 	}
+	// 创建一个分布式唯一ID生成器
+	var flakeInstance *flake.Flake
+	if flakeInstance, err = flake.New(cliOpt.flake); err != nil {
+		err = errors.WrapFailedToCreateFlakeInstance(err.Error())
+		return
+	}
+	// 创建并初始化客户端自己的提供商实例，不同客户端之间互不影响
+	var providers map[consts.Provider]core.ProviderService
+	if providers, err = newProviders(configManager); err != nil {
+		return
+	}
 	// 按优先级排序中间件
 	sort.Slice(cliOpt.middlewares, func(i, j int) bool {
 		return cliOpt.middlewares[i].Priority() < cliOpt.middlewares[j].Priority()
@@ -80,11 +116,37 @@ func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient
 	// 创建SDK客户端
 	client = &SDKClient{
 		configManager:   configManager,
+		providers:       providers,
 		flakeInstance:   flakeInstance,
 		middlewareChain: middlewareChain,
 		noCheckMethods: map[string]bool{
 			"ListModels": true,
 		},
+		lifecycle: newLifecycle(),
+		httpOpts:  cliOpt.httpOpts,
+	}
+	// 配置热更新时原地更新提供商
+	configManager.OnChange(client.applyConfigChanges)
+	for _, fn := range cliOpt.afterCreate {
+		fn(client)
+	}
+	return
+}
+
+// newProviders 通过工厂函数创建所有注册的提供商实例，并使用配置管理器中的配置初始化
+func newProviders(configManager *conf.SDKConfigManager) (providers map[consts.Provider]core.ProviderService, err error) {
+	providers = make(map[consts.Provider]core.ProviderService)
+	for _, provider := range core.ListProviders() {
+		// 创建提供商
+		var ps core.ProviderService
+		if ps = core.NewProvider(provider); ps == nil {
+			return nil, errors.WrapProviderNotSupported(provider)
+		}
+		// 获取提供商配置
+		providerConfig := configManager.GetProviderConfig(provider)
+		// 初始化提供商配置
+		ps.InitializeProviderConfig(&providerConfig)
+		providers[provider] = ps
 	}
 	return
 }
@@ -108,6 +170,16 @@ func (c *SDKClient) ListModels(ctx context.Context, request models.ListModelsReq
 	return
 }
 
+// GetModelFeature 获取模型特性，提供商或模型不支持时 ok 为 false
+func (c *SDKClient) GetModelFeature(provider consts.Provider, modelType consts.ModelType, model string) (feature consts.ModelFeature, ok bool) {
+	var ps core.ProviderService
+	if ps = c.providers[provider]; ps == nil {
+		return
+	}
+	feature, ok = ps.GetSupportedModels()[modelType][model]
+	return
+}
+
 // handlerRequest 处理请求
 func (c *SDKClient) handlerRequest(
 	ctx context.Context,
@@ -117,12 +189,28 @@ func (c *SDKClient) handlerRequest(
 	request any,
 	handler func(ctx context.Context, ps core.ProviderService, req any) (resp any, err error),
 ) (resp any, err error) {
+	// 登记进行中的请求，客户端关闭后拒绝新请求
+	var end func(resp any)
+	if ctx, end, err = c.lifecycle.begin(ctx); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			end(nil)
+			return
+		}
+		end(resp)
+	}()
 	// 生成唯一请求ID
 	var requestId string
 	if requestId, err = c.flakeInstance.RequestID(); err != nil {
 		err = &errors.SDKError{RequestID: requestId, Err: err}
 		return
 	}
+	// 设置所有请求共用的 HTTP 客户端选项到上下文
+	ctx = httpclient.WithClientOptions(ctx, c.httpOpts...)
+	// 获取租户ID
+	tenant := resolveTenant(ctx, userInfo)
 	// 设置请求信息到上下文
 	ctx = httpclient.SetRequestInfo(ctx, &httpclient.RequestInfo{
 		Provider:  string(modelInfo.Provider),
@@ -132,16 +220,24 @@ func (c *SDKClient) handlerRequest(
 		StartTime: time.Now(),
 		RequestID: requestId,
 		User:      userInfo.User,
+		Tenant:    tenant,
 	})
 	// 定义最终处理函数
 	finalHandler := func(ctx context.Context, req any) (resp any, err error) {
 		// 获取提供商
-		var ps core.ProviderService
-		if ps = core.GetProvider(modelInfo.Provider); ps == nil {
-			return nil, errors.WrapProviderNotSupported(modelInfo.Provider)
+		ps, release, e := c.getProvider(ctx, modelInfo.Provider, tenant)
+		if e != nil {
+			return nil, e
 		}
+		// 请求结束后释放提供商，被淘汰的租户提供商在此之后才关闭
+		defer func() {
+			if err != nil {
+				release()
+				return
+			}
+			afterResponse(resp, release)
+		}()
 		// 根据方法名称决定是否需要判断模型支持
-		var e error
 		if !c.noCheckMethods[method] {
 			// 判断模型是否支持
 			if e = c.isModelSupported(ps, modelInfo); e != nil {
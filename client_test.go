@@ -0,0 +1,97 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 15:32:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 16:48:27
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/core"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/httpclient/cassette"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// providerConfigGetter 获取提供商当前配置
+type providerConfigGetter interface {
+	ProviderConfig() (config *conf.ProviderConfig)
+}
+
+// newTestProviders 使用指定的 OpenAI API密钥创建提供商实例
+func newTestProviders(t *testing.T, apiKey string) (providers map[consts.Provider]core.ProviderService) {
+	configManager, err := conf.NewSDKConfigManagerWithSources(&conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"openai": {BaseURL: "https://api.openai.com/v1", APIKeys: []string{apiKey}},
+	}}})
+	if err != nil {
+		t.Errorf("failed to create config manager: %v", err)
+		return
+	}
+	if providers, err = newProviders(configManager); err != nil {
+		t.Errorf("failed to create providers: %v", err)
+	}
+	return
+}
+
+func TestNewProviders(t *testing.T) {
+	var (
+		clients = make([]map[consts.Provider]core.ProviderService, 8)
+		wg      sync.WaitGroup
+	)
+	// 并发为使用不同API密钥的客户端创建提供商
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clients[i] = newTestProviders(t, fmt.Sprintf("sk-tenant-%d", i))
+		}()
+	}
+	wg.Wait()
+
+	for i, providers := range clients {
+		if len(providers) != len(core.ListProviders()) {
+			t.Fatalf("expected %d providers, got %d", len(core.ListProviders()), len(providers))
+		}
+		ps := providers[consts.OpenAI]
+		if i > 0 && ps == clients[0][consts.OpenAI] {
+			t.Fatal("clients should not share provider instances")
+		}
+		want := fmt.Sprintf("sk-tenant-%d", i)
+		if keys := ps.(providerConfigGetter).ProviderConfig().APIKeys; len(keys) != 1 || keys[0] != want {
+			t.Errorf("client %d: expected key %s, got %v", i, want, keys)
+		}
+	}
+}
+
+func TestHTTPClientOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	os.WriteFile(path, []byte(`{"version":1,"interactions":[{
+		"request":{"method":"GET","url":"https://api.openai.com/v1/models","header":{"Authorization":["***"]}},
+		"response":{"status_code":200,"header":{"Content-Type":["application/json"]},"body":"{\"object\":\"list\",\"data\":[{\"id\":\"gpt-4o\"}]}"}}]}`), 0644)
+	c, err := cassette.New(cassette.Config{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 上下文中的客户端选项对提供商的请求生效，不访问网络
+	var (
+		ps  = newTestProviders(t, "sk-test")[consts.OpenAI]
+		ctx = httpclient.WithClientOptions(context.Background(), c.HTTPClientOption())
+	)
+	response, err := ps.ListModels(ctx, consts.OpenAI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].ID != "gpt-4o" {
+		t.Errorf("unexpected replayed response: %+v", response)
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 19:09:15
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:42:03
+ * @LastEditTime: 2025-07-28 17:19:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -10,26 +10,81 @@
 package conf
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"github.com/liusuxian/go-aisdk/internal/redact"
+	"github.com/liusuxian/go-aisdk/loadbalancer"
 	"maps"
+	"net/url"
 	"os"
+	"reflect"
 	"slices"
+	"sync"
 )
 
 var (
-	errConfigFileEmpty = errors.New("config file path is empty")
+	errConfigFileEmpty  = errors.New("config file path is empty")                           // 配置文件路径为空
+	errInvalidRateLimit = errors.New("rate limit requires positive requests and window")    // 速率限制配置无效
+	errEmptyAPIKey      = errors.New("api key is empty")                                    // API密钥为空
+	errDuplicateAPIKey  = errors.New("duplicate api key")                                   // API密钥重复
+	errInvalidBaseURL   = errors.New("base url must be an absolute http(s) url")            // 基础URL无效
+	errInvalidProxy     = errors.New("proxy must be an http, https, socks5 or socks5h url") // 代理地址无效
+	errIncompleteClient = errors.New("client cert file and key file must be set together")  // 客户端证书配置不完整
 )
 
 // ProviderConfig AI服务提供商的配置
 type ProviderConfig struct {
-	BaseURL          string            `json:"base_url"`          // 基础URL，用于自定义API服务器的地址
-	APIKeys          []string          `json:"api_keys"`          // API密钥列表
-	OrgID            string            `json:"org_id"`            // 组织ID，对于某些提供商可能需要
-	APIVersion       string            `json:"api_version"`       // API版本，对于某些提供商可能需要
-	AssistantVersion string            `json:"assistant_version"` // 助手版本，对于某些提供商可能需要
-	Extra            map[string]string `json:"extra"`             // 额外参数，对于某些提供商可能需要
+	BaseURL          string             `json:"base_url"`          // 基础URL，用于自定义API服务器的地址
+	APIKeys          []string           `json:"api_keys"`          // API密钥列表
+	OrgID            string             `json:"org_id"`            // 组织ID，对于某些提供商可能需要
+	APIVersion       string             `json:"api_version"`       // API版本，对于某些提供商可能需要
+	AssistantVersion string             `json:"assistant_version"` // 助手版本，对于某些提供商可能需要
+	Extra            map[string]string  `json:"extra"`             // 额外参数，对于某些提供商可能需要
+	LoadBalancer     LoadBalancerConfig `json:"load_balancer"`     // API密钥负载均衡配置
+	HTTP             HTTPConfig         `json:"http"`              // HTTP 传输层配置
+}
+
+// HTTPConfig HTTP 传输层配置，每个提供商持有一个长连接传输层，零值字段使用默认值
+type HTTPConfig struct {
+	Timeout               Duration `json:"timeout"`                 // 请求超时时间，默认10s，小于0时不限制
+	Proxy                 string   `json:"proxy"`                   // 代理地址，支持 http、https、socks5、socks5h，为空时使用环境变量 HTTP_PROXY、HTTPS_PROXY、NO_PROXY
+	CACertFile            string   `json:"ca_cert_file"`            // 自定义 CA 证书文件（PEM），追加到系统证书池
+	ClientCertFile        string   `json:"client_cert_file"`        // mTLS 客户端证书文件（PEM）
+	ClientKeyFile         string   `json:"client_key_file"`         // mTLS 客户端私钥文件（PEM）
+	InsecureSkipVerify    bool     `json:"insecure_skip_verify"`    // 是否跳过服务端证书校验，仅用于测试
+	DialTimeout           Duration `json:"dial_timeout"`            // 建立连接超时时间，默认30s
+	TLSHandshakeTimeout   Duration `json:"tls_handshake_timeout"`   // TLS 握手超时时间，默认10s
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"` // 等待响应头超时时间，默认不限制
+	IdleConnTimeout       Duration `json:"idle_conn_timeout"`       // 空闲连接超时时间，默认90s
+	MaxIdleConns          int      `json:"max_idle_conns"`          // 最大空闲连接数，默认100
+	MaxIdleConnsPerHost   int      `json:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数，默认10
+	MaxConnsPerHost       int      `json:"max_conns_per_host"`      // 每个主机的最大连接数，默认不限制
+	DisableHTTP2          bool     `json:"disable_http2"`           // 是否禁用 HTTP/2，默认启用
+}
+
+// LoadBalancerConfig API密钥负载均衡配置
+type LoadBalancerConfig struct {
+	Strategy     string          `json:"strategy"`      // 负载均衡策略：least_used（默认）、round_robin、weighted_random、least_in_flight、ewma_latency、sticky_user
+	RateLimit    RateLimitConfig `json:"rate_limit"`    // 每个API密钥的速率限制，配置了 Redis 时所有实例共同遵守
+	Redis        *RedisConfig    `json:"redis"`         // 多实例共享API密钥状态的 Redis 配置，为空时只使用本地状态
+	SyncInterval Duration        `json:"sync_interval"` // 从 Redis 同步使用次数和冷却状态的间隔，默认1s
+}
+
+// RateLimitConfig 速率限制配置
+type RateLimitConfig struct {
+	Requests int64    `json:"requests"` // 每个时间窗口内允许的请求数，为0时不限制
+	Window   Duration `json:"window"`   // 时间窗口，如 "1m"
+}
+
+// RedisConfig Redis 配置
+type RedisConfig struct {
+	Addr      string `json:"addr"`       // 地址，默认 127.0.0.1:6379
+	Username  string `json:"username"`   // 用户名
+	Password  string `json:"password"`   // 密码
+	DB        int    `json:"db"`         // 数据库编号
+	KeyPrefix string `json:"key_prefix"` // 键前缀，默认 aisdk:lb:
+	PoolSize  int    `json:"pool_size"`  // 最大空闲连接数，默认10
 }
 
 // SDKConfig SDK整体配置
@@ -37,53 +92,208 @@ type SDKConfig struct {
 	Providers map[string]ProviderConfig `json:"providers"` // AI服务提供商的配置
 }
 
-// SDKConfigManager SDK配置管理器
+// ProviderChange 提供商配置变化
+type ProviderChange struct {
+	Provider string          // 提供商名称
+	Old      *ProviderConfig // 变化前的配置，新增提供商时为 nil
+	New      *ProviderConfig // 变化后的配置，移除提供商时为 nil
+}
+
+// ChangeHandler 配置变化回调
+type ChangeHandler func(changes []ProviderChange)
+
+// SDKConfigManager SDK配置管理器（并发安全）
 type SDKConfigManager struct {
-	configPath string    // 配置文件路径
-	config     SDKConfig // SDK配置
+	sources  []Source             // 配置来源，按顺序合并
+	config   SDKConfig            // SDK配置
+	secrets  *redact.Registration // 当前配置中需要在日志中脱敏的密钥
+	handlers []ChangeHandler      // 配置变化回调
+	mu       sync.RWMutex         // 读写锁
+	reloadMu sync.Mutex           // 串行化重新加载，保证回调按配置变化的顺序执行
 }
 
-// NewSDKConfigManager 创建SDK配置管理器
+// NewSDKConfigManager 从配置文件创建SDK配置管理器，不读取环境变量
+//
+// 需要环境变量覆盖配置文件时使用 NewSDKConfigManagerWithSources(&FileSource{Path: configPath}, &EnvSource{})；
+// 加载的配置会经过 SDKConfig.Validate 校验
 func NewSDKConfigManager(configPath string) (manager *SDKConfigManager, err error) {
 	if configPath == "" {
 		err = errConfigFileEmpty
 		return
 	}
 
+	// 检查配置文件
+	if _, err = os.Stat(configPath); err != nil {
+		if !os.IsNotExist(err) {
+			err = fmt.Errorf("failed to check config file: %w", err)
+		}
+		return
+	}
+	return NewSDKConfigManagerWithSources(&FileSource{Path: configPath})
+}
+
+// NewSDKConfigManagerWithSources 从多个配置来源创建SDK配置管理器，来源按顺序合并，后面的覆盖前面的
+func NewSDKConfigManagerWithSources(sources ...Source) (manager *SDKConfigManager, err error) {
 	manager = &SDKConfigManager{
-		configPath: configPath,
+		sources: slices.Clone(sources),
 		config: SDKConfig{
 			Providers: make(map[string]ProviderConfig),
 		},
 	}
-	// 尝试加载配置
-	if _, err = os.Stat(configPath); err == nil {
-		if err = manager.Load(); err != nil {
-			err = fmt.Errorf("failed to load config: %w", err)
-			return
-		}
+	// 加载配置
+	if err = manager.Load(); err != nil {
+		err = fmt.Errorf("failed to load config: %w", err)
 		return
 	}
+	return
+}
+
+// Load 从配置来源加载配置，配置无效时返回错误并保留当前配置
+func (m *SDKConfigManager) Load() (err error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	_, err = m.load()
+	return
+}
 
-	if !os.IsNotExist(err) {
-		err = fmt.Errorf("failed to check config file: %w", err)
+// Reload 重新加载所有配置来源，配置无效时返回错误并保留当前配置；提供商配置有变化时依次调用变化回调
+func (m *SDKConfigManager) Reload() (changes []ProviderChange, err error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if changes, err = m.load(); err != nil || len(changes) == 0 {
 		return
 	}
+	m.mu.RLock()
+	handlers := slices.Clone(m.handlers)
+	m.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(changes)
+	}
 	return
 }
 
-// Load 从文件加载配置
-func (m *SDKConfigManager) Load() (err error) {
-	var data []byte
-	if data, err = os.ReadFile(m.configPath); err != nil {
-		err = fmt.Errorf("failed to read config file: %w", err)
+// OnChange 注册配置变化回调，在 Reload 检测到提供商配置变化时调用
+func (m *SDKConfigManager) OnChange(handler ChangeHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append(m.handlers, handler)
+}
+
+// load 加载、合并配置来源，解析密钥引用并校验，成功后替换当前配置并返回提供商配置的变化
+func (m *SDKConfigManager) load() (changes []ProviderChange, err error) {
+	var config SDKConfig
+	if config, err = LoadSources(m.sources...); err != nil {
+		return
+	}
+	if config, err = ResolveSecrets(context.Background(), config); err != nil {
 		return
 	}
 
-	if err = json.Unmarshal(data, &m.config); err != nil {
-		err = fmt.Errorf("failed to unmarshal config: %w", err)
+	if err = config.Validate(); err != nil {
+		err = fmt.Errorf("invalid config: %w", err)
 		return
 	}
+
+	// 脱敏当前配置中的全部密钥，明文密钥同样会出现在请求头和错误信息中；不再配置的密钥随旧配置一起释放
+	secrets := redact.Register(config.Secrets()...)
+
+	m.mu.Lock()
+	old, oldSecrets := m.config, m.secrets
+	m.config, m.secrets = config, secrets
+	m.mu.Unlock()
+	oldSecrets.Release()
+	return diffConfig(old, config), nil
+}
+
+// Close 释放当前配置中密钥的脱敏注册，关闭后配置仍可读取
+func (m *SDKConfigManager) Close() {
+	m.mu.Lock()
+	secrets := m.secrets
+	m.secrets = nil
+	m.mu.Unlock()
+	secrets.Release()
+}
+
+// diffConfig 比较两份配置，返回按提供商名称排序的变化
+func diffConfig(old, new SDKConfig) (changes []ProviderChange) {
+	names := slices.Sorted(maps.Keys(old.Providers))
+	for name := range new.Providers {
+		if _, ok := old.Providers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		oldConfig, hasOld := old.Providers[name]
+		newConfig, hasNew := new.Providers[name]
+		if hasOld && hasNew && reflect.DeepEqual(oldConfig, newConfig) {
+			continue
+		}
+		change := ProviderChange{Provider: name}
+		if hasOld {
+			c := cloneProviderConfig(oldConfig)
+			change.Old = &c
+		}
+		if hasNew {
+			c := cloneProviderConfig(newConfig)
+			change.New = &c
+		}
+		changes = append(changes, change)
+	}
+	return
+}
+
+// Validate 校验配置，以下配置会被拒绝（以前的版本忽略这些问题，升级后原本能加载的配置可能加载失败）：
+//   - 未知的负载均衡策略
+//   - 请求数为负数，或设置了请求数但时间窗口不为正数的速率限制
+//   - 不是 http(s) 绝对地址的基础URL
+//   - 不是 http、https、socks5、socks5h 地址的代理，只设置了客户端证书或私钥之一的 mTLS 配置
+//   - 为空或重复的API密钥
+func (c SDKConfig) Validate() (err error) {
+	for name, provider := range c.Providers {
+		if _, err = loadbalancer.NewStrategy(provider.LoadBalancer.Strategy); err != nil {
+			return fmt.Errorf("provider [%s]: %w", name, err)
+		}
+		if rl := provider.LoadBalancer.RateLimit; rl.Requests < 0 || (rl.Requests > 0 && rl.Window <= 0) {
+			return fmt.Errorf("provider [%s]: %w", name, errInvalidRateLimit)
+		}
+		if provider.BaseURL != "" {
+			if u, e := url.Parse(provider.BaseURL); e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("provider [%s]: %w", name, errInvalidBaseURL)
+			}
+		}
+		if err = provider.HTTP.validate(); err != nil {
+			return fmt.Errorf("provider [%s]: http: %w", name, err)
+		}
+		// 错误信息中只包含密钥序号，不包含密钥本身
+		seen := make(map[string]bool, len(provider.APIKeys))
+		for i, key := range provider.APIKeys {
+			if key == "" {
+				return fmt.Errorf("provider [%s]: api_keys[%d]: %w", name, i, errEmptyAPIKey)
+			}
+			if seen[key] {
+				return fmt.Errorf("provider [%s]: api_keys[%d]: %w", name, i, errDuplicateAPIKey)
+			}
+			seen[key] = true
+		}
+	}
+	return
+}
+
+// validate 校验 HTTP 传输层配置，证书文件在创建传输层时读取
+func (c HTTPConfig) validate() (err error) {
+	if c.Proxy != "" {
+		if u, e := url.Parse(c.Proxy); e != nil || !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+			return errInvalidProxy
+		}
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return errIncompleteClient
+	}
 	return
 }
 
@@ -94,6 +304,9 @@ func (m *SDKConfigManager) GetConfig() (configCopy SDKConfig) {
 		Providers: make(map[string]ProviderConfig),
 	}
 
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for k, v := range m.config.Providers {
 		configCopy.Providers[k] = cloneProviderConfig(v)
 	}
@@ -102,6 +315,9 @@ func (m *SDKConfigManager) GetConfig() (configCopy SDKConfig) {
 
 // GetProviderConfig 获取提供商配置
 func (m *SDKConfigManager) GetProviderConfig(provider fmt.Stringer) (config ProviderConfig) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	if cfg, ok := m.config.Providers[provider.String()]; ok {
 		config = cloneProviderConfig(cfg)
 		return
@@ -113,19 +329,12 @@ func (m *SDKConfigManager) GetProviderConfig(provider fmt.Stringer) (config Prov
 
 // cloneProviderConfig 深拷贝 ProviderConfig
 func cloneProviderConfig(source ProviderConfig) (dest ProviderConfig) {
-	var extraCopy map[string]string
-	if source.Extra != nil {
-		extraCopy = make(map[string]string)
-		maps.Copy(extraCopy, source.Extra)
-	}
-
-	dest = ProviderConfig{
-		BaseURL:          source.BaseURL,
-		APIKeys:          slices.Clone(source.APIKeys),
-		OrgID:            source.OrgID,
-		APIVersion:       source.APIVersion,
-		AssistantVersion: source.AssistantVersion,
-		Extra:            extraCopy,
+	dest = source
+	dest.APIKeys = slices.Clone(source.APIKeys)
+	dest.Extra = maps.Clone(source.Extra)
+	if source.LoadBalancer.Redis != nil {
+		redisCopy := *source.LoadBalancer.Redis
+		dest.LoadBalancer.Redis = &redisCopy
 	}
 	return
 }
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 19:11:05
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-25 10:55:15
+ * @LastEditTime: 2025-07-28 17:19:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -10,13 +10,18 @@
 package conf_test
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/liusuxian/go-aisdk/conf"
 	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/internal/redact"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSDKConfigManager(t *testing.T) {
@@ -153,4 +158,324 @@ func TestSDKConfigManager(t *testing.T) {
 	if providerConfig.BaseURL != "" {
 		t.Error("GetProviderConfig should return an empty config for AliBL provider")
 	}
+	// 12. Test Load method with unknown load balancing strategy
+	invalidStrategyPath := filepath.Join(tempDir, "invalid-strategy.json")
+	if err := os.WriteFile(invalidStrategyPath, []byte(`{"providers":{"openai":{"load_balancer":{"strategy":"unknown"}}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write invalid strategy config file: %v", err)
+	}
+	_, err = conf.NewSDKConfigManager(invalidStrategyPath)
+	if err == nil {
+		t.Error("NewSDKConfigManager should return an error for unknown load balancing strategy")
+	}
+	// 13. Test load balancer config with durations and invalid rate limit
+	lbConfigPath := filepath.Join(tempDir, "lb-config.json")
+	lbConfig := `{"providers":{"openai":{"load_balancer":{"rate_limit":{"requests":60,"window":"1m"},"sync_interval":0.5,"redis":{"addr":"127.0.0.1:6379"}}}}}`
+	if err := os.WriteFile(lbConfigPath, []byte(lbConfig), 0644); err != nil {
+		t.Fatalf("Failed to write load balancer config file: %v", err)
+	}
+	manager3, err := conf.NewSDKConfigManager(lbConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to create config manager with load balancer config: %v", err)
+	}
+	lbOpenAI := manager3.GetProviderConfig(consts.OpenAI).LoadBalancer
+	if lbOpenAI.RateLimit.Window.Std() != time.Minute || lbOpenAI.SyncInterval.Std() != 500*time.Millisecond || lbOpenAI.Redis.Addr != "127.0.0.1:6379" {
+		t.Errorf("Unexpected load balancer config: %+v", lbOpenAI)
+	}
+	if err := os.WriteFile(lbConfigPath, []byte(`{"providers":{"openai":{"load_balancer":{"rate_limit":{"requests":60}}}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write load balancer config file: %v", err)
+	}
+	if _, err = conf.NewSDKConfigManager(lbConfigPath); err == nil {
+		t.Error("NewSDKConfigManager should return an error for rate limit without window")
+	}
+	// 14. Test Reload with change callbacks and rejected configs
+	reloadPath := filepath.Join(tempDir, "reload-config.json")
+	if err := os.WriteFile(reloadPath, []byte(`{"providers":{"openai":{"api_keys":["sk-1"]},"deepseek":{"api_keys":["sk-2"]}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write reload config file: %v", err)
+	}
+	manager4, err := conf.NewSDKConfigManager(reloadPath)
+	if err != nil {
+		t.Fatalf("Failed to create config manager for reload: %v", err)
+	}
+	var notified []conf.ProviderChange
+	manager4.OnChange(func(changes []conf.ProviderChange) { notified = changes })
+	if err := os.WriteFile(reloadPath, []byte(`{"providers":{"openai":{"api_keys":["sk-1","sk-3"]},"alibl":{"api_keys":["sk-4"]}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write reload config file: %v", err)
+	}
+	changes, err := manager4.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(changes) != 3 || len(notified) != 3 {
+		t.Fatalf("Expected 3 changes, got %d (notified %d)", len(changes), len(notified))
+	}
+	// 变化按提供商名称排序：alibl 新增、deepseek 移除、openai 修改
+	if changes[0].Provider != "alibl" || changes[0].Old != nil || changes[0].New == nil ||
+		changes[1].Provider != "deepseek" || changes[1].Old == nil || changes[1].New != nil ||
+		changes[2].Provider != "openai" || len(changes[2].Old.APIKeys) != 1 || len(changes[2].New.APIKeys) != 2 {
+		t.Errorf("Unexpected changes: %+v", changes)
+	}
+	notified = nil
+	if changes, err = manager4.Reload(); err != nil || len(changes) != 0 || notified != nil {
+		t.Errorf("Reload without changes should not notify, got %v, %v", changes, err)
+	}
+	for _, invalid := range []string{
+		`invalid json`,
+		`{"providers":{"openai":{"api_keys":["sk-1",""]}}}`,
+		`{"providers":{"openai":{"api_keys":["sk-1","sk-1"]}}}`,
+		`{"providers":{"openai":{"base_url":"api.openai.com/v1"}}}`,
+	} {
+		if err := os.WriteFile(reloadPath, []byte(invalid), 0644); err != nil {
+			t.Fatalf("Failed to write reload config file: %v", err)
+		}
+		if _, err = manager4.Reload(); err == nil {
+			t.Errorf("Reload should reject invalid config: %s", invalid)
+		}
+		if strings.Contains(fmt.Sprint(err), "sk-1") {
+			t.Errorf("Reload error should not contain API keys: %v", err)
+		}
+	}
+	if keys := manager4.GetProviderConfig(consts.OpenAI).APIKeys; len(keys) != 2 || notified != nil {
+		t.Errorf("Rejected configs should keep the running config, got %v", keys)
+	}
+	// 15. Test Watch reloading on file changes
+	watched := make(chan []conf.ProviderChange, 1)
+	manager4.OnChange(func(changes []conf.ProviderChange) { watched <- changes })
+	stop := manager4.Watch(10*time.Millisecond, nil)
+	defer stop()
+	if err := os.WriteFile(reloadPath, []byte(`{"providers":{"openai":{"api_keys":["sk-5"]},"alibl":{"api_keys":["sk-4"]}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write reload config file: %v", err)
+	}
+	select {
+	case changes := <-watched:
+		if len(changes) != 1 || changes[0].Provider != "openai" || changes[0].New.APIKeys[0] != "sk-5" {
+			t.Errorf("Unexpected watched changes: %+v", changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Watch should reload the config after the file changes")
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	tempDir := t.TempDir()
+	// 1. Test YAML and TOML config files use the same field names as JSON
+	yamlPath := filepath.Join(tempDir, "config.yaml")
+	yamlConfig := "providers:\n  openai:\n    base_url: https://yaml.example.com/v1\n    api_keys: [sk-yaml]\n    extra:\n      a: yaml\n    load_balancer:\n      rate_limit:\n        requests: 10\n        window: 1m\n"
+	if err := os.WriteFile(yamlPath, []byte(yamlConfig), 0644); err != nil {
+		t.Fatalf("Failed to write YAML config file: %v", err)
+	}
+	tomlPath := filepath.Join(tempDir, "config.toml")
+	tomlConfig := "[providers.openai]\norg_id = \"org-toml\"\n\n[providers.openai.extra]\nb = \"toml\"\n\n[providers.deepseek]\napi_keys = [\"sk-toml\"]\n"
+	if err := os.WriteFile(tomlPath, []byte(tomlConfig), 0644); err != nil {
+		t.Fatalf("Failed to write TOML config file: %v", err)
+	}
+	yamlLayer, err := (&conf.FileSource{Path: yamlPath}).Load()
+	if err != nil {
+		t.Fatalf("Failed to load YAML config: %v", err)
+	}
+	if openai := yamlLayer.Providers["openai"]; openai.BaseURL != "https://yaml.example.com/v1" || openai.LoadBalancer.RateLimit.Window.Std() != time.Minute {
+		t.Errorf("Unexpected YAML config: %+v", openai)
+	}
+	// 2. Test missing files
+	if _, err = (&conf.FileSource{Path: filepath.Join(tempDir, "missing.json")}).Load(); err == nil {
+		t.Error("FileSource should return an error for a missing file")
+	}
+	if _, err = (&conf.FileSource{Path: filepath.Join(tempDir, "missing.json"), Optional: true}).Load(); err != nil {
+		t.Errorf("Optional FileSource should ignore a missing file: %v", err)
+	}
+	// 3. Test merge order: defaults < files < env < code
+	t.Setenv("AISDK_OPENAI_API_KEYS", "sk-env1, sk-env2,")
+	t.Setenv("AISDK_DEEPSEEK_BASE_URL", "https://env.example.com")
+	t.Setenv("AISDK_OPENAI_API_VERSION", "2025-01-01")
+	t.Setenv("AISDK_OPENAI_ASSISTANT_VERSION", "v2")
+	defaults := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"openai":   {BaseURL: "https://default.example.com", OrgID: "org-default"},
+		"deepseek": {BaseURL: "https://default.example.com", LoadBalancer: conf.LoadBalancerConfig{Strategy: "round_robin"}},
+	}}}
+	code := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"deepseek": {APIKeys: []string{"sk-code"}},
+	}}}
+	manager, err := conf.NewSDKConfigManagerWithSources(defaults, &conf.FileSource{Path: yamlPath}, &conf.FileSource{Path: tomlPath}, &conf.EnvSource{}, code)
+	if err != nil {
+		t.Fatalf("Failed to create config manager with sources: %v", err)
+	}
+	expectedOpenAI := conf.ProviderConfig{
+		BaseURL:          "https://yaml.example.com/v1",
+		APIKeys:          []string{"sk-env1", "sk-env2"},
+		OrgID:            "org-toml",
+		APIVersion:       "2025-01-01",
+		AssistantVersion: "v2",
+		Extra:            map[string]string{"a": "yaml", "b": "toml"},
+		LoadBalancer:     conf.LoadBalancerConfig{RateLimit: conf.RateLimitConfig{Requests: 10, Window: conf.Duration(time.Minute)}},
+	}
+	if openai := manager.GetProviderConfig(consts.OpenAI); !reflect.DeepEqual(openai, expectedOpenAI) {
+		t.Errorf("Unexpected merged OpenAI config:\ngot  %+v\nwant %+v", openai, expectedOpenAI)
+	}
+	expectedDeepSeek := conf.ProviderConfig{
+		BaseURL:      "https://env.example.com",
+		APIKeys:      []string{"sk-code"},
+		LoadBalancer: conf.LoadBalancerConfig{Strategy: "round_robin"},
+	}
+	if deepseek := manager.GetProviderConfig(consts.DeepSeek); !reflect.DeepEqual(deepseek, expectedDeepSeek) {
+		t.Errorf("Unexpected merged DeepSeek config:\ngot  %+v\nwant %+v", deepseek, expectedDeepSeek)
+	}
+	// 4. Test the merged config is validated
+	t.Setenv("AISDK_DEEPSEEK_LOAD_BALANCER_STRATEGY", "unknown")
+	if _, err = manager.Reload(); err == nil {
+		t.Error("Reload should reject an unknown strategy from the environment")
+	}
+	if strategy := manager.GetProviderConfig(consts.DeepSeek).LoadBalancer.Strategy; strategy != "round_robin" {
+		t.Errorf("Rejected config should keep the running config, got strategy %q", strategy)
+	}
+	// 5. Test the loaded config does not share memory with the static source
+	code.Config.Providers["deepseek"].APIKeys[0] = "sk-changed"
+	if keys := manager.GetProviderConfig(consts.DeepSeek).APIKeys; keys[0] != "sk-code" {
+		t.Errorf("Loaded config should not share memory with the source, got %v", keys)
+	}
+	// 6. Test HTTP transport config is merged field by field and validated
+	httpBase := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"openai": {HTTP: conf.HTTPConfig{Timeout: conf.Duration(time.Minute), Proxy: "http://file-proxy:8080", DisableHTTP2: true}},
+	}}}
+	t.Setenv("AISDK_DEEPSEEK_LOAD_BALANCER_STRATEGY", "")
+	t.Setenv("AISDK_OPENAI_HTTP_PROXY", "socks5://env-proxy:1080")
+	if manager, err = conf.NewSDKConfigManagerWithSources(httpBase, &conf.EnvSource{}); err != nil {
+		t.Fatalf("Failed to create config manager with http config: %v", err)
+	}
+	expectedHTTP := conf.HTTPConfig{Timeout: conf.Duration(time.Minute), Proxy: "socks5://env-proxy:1080", DisableHTTP2: true}
+	if httpConfig := manager.GetProviderConfig(consts.OpenAI).HTTP; httpConfig != expectedHTTP {
+		t.Errorf("Unexpected merged http config: %+v", httpConfig)
+	}
+	for _, httpConfig := range []conf.HTTPConfig{{Proxy: "ftp://proxy:21"}, {ClientKeyFile: "client.key"}} {
+		invalid := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{"openai": {HTTP: httpConfig}}}}
+		if _, err = conf.NewSDKConfigManagerWithSources(invalid); err == nil {
+			t.Errorf("NewSDKConfigManagerWithSources should reject http config %+v", httpConfig)
+		}
+	}
+	// 7. Test explicitly set fields override lower layers even with zero values
+	explicitPath := filepath.Join(tempDir, "explicit.yaml")
+	explicitConfig := "providers:\n  openai:\n    org_id: org-file\n    http:\n      proxy: http://file-proxy:8080\n      disable_http2: true\n      insecure_skip_verify: true\n"
+	if err = os.WriteFile(explicitPath, []byte(explicitConfig), 0644); err != nil {
+		t.Fatalf("Failed to write explicit config file: %v", err)
+	}
+	t.Setenv("AISDK_OPENAI_HTTP_PROXY", "")
+	t.Setenv("AISDK_OPENAI_HTTP_DISABLE_HTTP2", "false")
+	clearProxy := &conf.StaticSource{
+		Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{"openai": {}}},
+		Fields: conf.FieldSet{"openai": {"http.proxy": true}},
+	}
+	if manager, err = conf.NewSDKConfigManagerWithSources(&conf.FileSource{Path: explicitPath}, &conf.EnvSource{}, clearProxy); err != nil {
+		t.Fatalf("Failed to create config manager with explicit fields: %v", err)
+	}
+	openai := manager.GetProviderConfig(consts.OpenAI)
+	if expected := (conf.HTTPConfig{InsecureSkipVerify: true}); openai.HTTP != expected || openai.OrgID != "org-file" {
+		t.Errorf("Unexpected explicitly merged config: %+v", openai)
+	}
+	t.Setenv("AISDK_OPENAI_HTTP_DISABLE_HTTP2", "maybe")
+	if _, err = manager.Reload(); err == nil {
+		t.Error("Reload should reject an invalid boolean environment variable")
+	}
+	// 8. Test NewSDKConfigManager only reads the config file
+	t.Setenv("AISDK_OPENAI_ORG_ID", "org-env")
+	if manager, err = conf.NewSDKConfigManager(explicitPath); err != nil {
+		t.Fatalf("NewSDKConfigManager should ignore environment variables: %v", err)
+	}
+	if orgID := manager.GetProviderConfig(consts.OpenAI).OrgID; orgID != "org-file" {
+		t.Errorf("NewSDKConfigManager should not merge environment variables, got org id %q", orgID)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	// 1. Test the encrypted keystore
+	keystorePath := filepath.Join(tempDir, "keys.json")
+	keystore := conf.NewKeystore(keystorePath, "passphrase")
+	if err := keystore.Set("openai", "sk-from-keystore"); err != nil {
+		t.Fatalf("Failed to set keystore secret: %v", err)
+	}
+	if data, _ := os.ReadFile(keystorePath); strings.Contains(string(data), "sk-from-keystore") {
+		t.Error("Keystore file should not contain plaintext secrets")
+	}
+	if secret, err := conf.NewKeystore(keystorePath, "passphrase").Get("openai"); err != nil || secret != "sk-from-keystore" {
+		t.Errorf("Unexpected keystore secret: %q, %v", secret, err)
+	}
+	if _, err := conf.NewKeystore(keystorePath, "wrong").Get("openai"); err == nil {
+		t.Error("Keystore should reject a wrong passphrase")
+	}
+	// 2. Test references resolved at load time
+	secretFile := filepath.Join(tempDir, "deepseek.key")
+	if err := os.WriteFile(secretFile, []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	t.Setenv("TEST_OPENAI_KEY", "sk-from-env")
+	t.Setenv("AISDK_KEYSTORE_FILE", keystorePath)
+	t.Setenv("AISDK_KEYSTORE_PASSPHRASE", "passphrase")
+	conf.RegisterSecretResolver("vault", conf.SecretResolverFunc(func(ctx context.Context, ref string) (secret string, err error) {
+		return "sk-from-vault-" + ref, nil
+	}))
+	defer conf.RegisterSecretResolver("vault", nil)
+	code := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"openai":   {APIKeys: []string{"env:TEST_OPENAI_KEY", "keystore:openai", "exec:echo sk-from-exec", "vault:openai", "sk-plain"}},
+		"deepseek": {APIKeys: []string{"file:" + secretFile}},
+	}}}
+	manager, err := conf.NewSDKConfigManagerWithSources(code)
+	if err != nil {
+		t.Fatalf("Failed to create config manager with secret references: %v", err)
+	}
+	expectedKeys := []string{"sk-from-env", "sk-from-keystore", "sk-from-exec", "sk-from-vault-openai", "sk-plain"}
+	if keys := manager.GetProviderConfig(consts.OpenAI).APIKeys; !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("Unexpected resolved keys: %v", keys)
+	}
+	if keys := manager.GetProviderConfig(consts.DeepSeek).APIKeys; len(keys) != 1 || keys[0] != "sk-from-file" {
+		t.Errorf("Unexpected resolved file key: %v", keys)
+	}
+	// 3. Test secrets are re-resolved on reload and failures keep the running config
+	if err := os.WriteFile(secretFile, []byte("sk-rotated"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	if changes, err := manager.Reload(); err != nil || len(changes) != 1 || changes[0].New.APIKeys[0] != "sk-rotated" {
+		t.Errorf("Reload should pick up the rotated secret, got %+v, %v", changes, err)
+	}
+	os.Remove(secretFile)
+	if _, err = manager.Reload(); err == nil {
+		t.Error("Reload should fail when a secret cannot be resolved")
+	}
+	if keys := manager.GetProviderConfig(consts.DeepSeek).APIKeys; keys[0] != "sk-rotated" {
+		t.Errorf("Failed reload should keep the running config, got %v", keys)
+	}
+	// 4. Test resolution errors do not contain secrets
+	if _, err = conf.ResolveSecret(ctx, "env:TEST_MISSING_KEY"); err == nil {
+		t.Error("ResolveSecret should fail for a missing environment variable")
+	}
+	if _, err = conf.ResolveSecret(ctx, "exec:sh -c echo${IFS}sk-leaked>&2;exit${IFS}1"); err == nil || strings.Contains(err.Error(), "sk-leaked") {
+		t.Errorf("Command errors should not contain its output, got %v", err)
+	}
+	if value, err := conf.ResolveSecret(ctx, "https://not-a-reference"); err != nil || value != "https://not-a-reference" {
+		t.Errorf("Unregistered schemes should be returned as is, got %q, %v", value, err)
+	}
+}
+
+func TestSecretsRedaction(t *testing.T) {
+	source := &conf.StaticSource{Config: conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		"openai": {APIKeys: []string{"sk-first-generation"}},
+	}}}
+	manager, err := conf.NewSDKConfigManagerWithSources(source)
+	if err != nil {
+		t.Fatalf("Failed to create config manager: %v", err)
+	}
+	if redacted := redact.String("key sk-first-generation"); redacted != "key "+redact.Mask {
+		t.Errorf("Configured keys should be redacted, got %q", redacted)
+	}
+	// 重新加载后不再配置的密钥不再脱敏
+	source.Config.Providers["openai"] = conf.ProviderConfig{APIKeys: []string{"sk-second-generation"}}
+	if _, err = manager.Reload(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if redacted := redact.String("sk-first-generation sk-second-generation"); redacted != "sk-first-generation "+redact.Mask {
+		t.Errorf("Only the current keys should be redacted, got %q", redacted)
+	}
+	// 关闭后释放全部密钥
+	manager.Close()
+	if redacted := redact.String("sk-second-generation"); redacted != "sk-second-generation" {
+		t.Errorf("Closed manager should release its keys, got %q", redacted)
+	}
 }
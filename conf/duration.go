@@ -0,0 +1,52 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 14:05:37
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 14:05:37
+ * @Description: 配置文件中的时长
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration 配置文件中的时长，JSON 中使用 "1m30s" 格式的字符串或秒数
+type Duration time.Duration
+
+// MarshalJSON 序列化为 "1m30s" 格式的字符串
+func (d Duration) MarshalJSON() (b []byte, err error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON 从字符串或秒数反序列化
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
+	var v any
+	if err = json.Unmarshal(b, &v); err != nil {
+		return
+	}
+	switch value := v.(type) {
+	case string:
+		var duration time.Duration
+		if duration, err = time.ParseDuration(value); err != nil {
+			return
+		}
+		*d = Duration(duration)
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+	return
+}
+
+// Std 转换为 time.Duration
+func (d Duration) Std() (duration time.Duration) {
+	return time.Duration(d)
+}
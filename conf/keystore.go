@@ -0,0 +1,225 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-27 11:14:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-27 11:14:52
+ * @Description: 加密的本地密钥库
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package conf
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	keystoreVersion    = 1       // 密钥库文件格式版本
+	keystoreIterations = 600_000 // PBKDF2 迭代次数
+	keystoreKeyLength  = 32      // AES-256 密钥长度
+	keystoreSaltLength = 16      // 盐长度
+)
+
+var (
+	errKeystoreVersion    = errors.New("unsupported keystore version")                                   // 不支持的密钥库版本
+	errKeystorePassphrase = errors.New("failed to decrypt keystore: wrong passphrase or corrupted file") // 解密失败
+	errKeystoreCorrupted  = errors.New("keystore file is corrupted")                                     // 密钥库文件损坏
+)
+
+// keystoreFile 密钥库文件，密钥以 AES-256-GCM 加密保存，加密密钥由口令经 PBKDF2-SHA256 派生
+type keystoreFile struct {
+	Version    int    `json:"version"`    // 文件格式版本
+	Iterations int    `json:"iterations"` // PBKDF2 迭代次数
+	Salt       []byte `json:"salt"`       // 盐
+	Nonce      []byte `json:"nonce"`      // GCM 随机数
+	Ciphertext []byte `json:"ciphertext"` // 加密后的密钥表
+}
+
+// Keystore 加密的本地密钥库（并发安全），可作为 keystore 协议的密钥解析器注册
+type Keystore struct {
+	path       string            // 文件路径
+	passphrase string            // 口令
+	secrets    map[string]string // 解密后的密钥表缓存
+	version    fileVersion       // 缓存对应的文件版本
+	mu         sync.Mutex        // 互斥锁
+}
+
+// NewKeystore 创建密钥库，文件不存在时在第一次 Set 时创建
+func NewKeystore(path, passphrase string) (ks *Keystore) {
+	return &Keystore{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+// Resolve 解析密钥，实现 SecretResolver
+func (ks *Keystore) Resolve(ctx context.Context, ref string) (secret string, err error) {
+	return ks.Get(ref)
+}
+
+// Get 获取密钥
+func (ks *Keystore) Get(name string) (secret string, err error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var secrets map[string]string
+	if secrets, err = ks.load(); err != nil {
+		return
+	}
+	var ok bool
+	if secret, ok = secrets[name]; !ok {
+		return "", fmt.Errorf("%w: %s", errSecretNotFound, name)
+	}
+	return
+}
+
+// Set 设置密钥并保存到文件
+func (ks *Keystore) Set(name, secret string) (err error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var secrets map[string]string
+	if secrets, err = ks.load(); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	updated := maps.Clone(secrets)
+	if updated == nil {
+		updated = make(map[string]string)
+	}
+	updated[name] = secret
+	return ks.save(updated)
+}
+
+// Delete 删除密钥并保存到文件
+func (ks *Keystore) Delete(name string) (err error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var secrets map[string]string
+	if secrets, err = ks.load(); err != nil {
+		return
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%w: %s", errSecretNotFound, name)
+	}
+	updated := maps.Clone(secrets)
+	delete(updated, name)
+	return ks.save(updated)
+}
+
+// load 读取并解密密钥库，文件未变化时使用缓存
+func (ks *Keystore) load() (secrets map[string]string, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(ks.path); err != nil {
+		return
+	}
+	version := fileVersion{modTime: info.ModTime(), size: info.Size()}
+	if ks.secrets != nil && version == ks.version {
+		return ks.secrets, nil
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(ks.path); err != nil {
+		return
+	}
+	var file keystoreFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keystore: %w", err)
+	}
+	if file.Version != keystoreVersion {
+		return nil, fmt.Errorf("%w: %d", errKeystoreVersion, file.Version)
+	}
+	if file.Iterations <= 0 {
+		return nil, errKeystoreCorrupted
+	}
+
+	var gcm cipher.AEAD
+	if gcm, err = ks.cipher(file.Salt, file.Iterations); err != nil {
+		return
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, errKeystoreCorrupted
+	}
+	var plaintext []byte
+	if plaintext, err = gcm.Open(nil, file.Nonce, file.Ciphertext, nil); err != nil {
+		return nil, errKeystorePassphrase
+	}
+	if err = json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keystore secrets: %w", err)
+	}
+	ks.secrets, ks.version = secrets, version
+	return
+}
+
+// save 加密并保存密钥库，先写入临时文件再重命名，避免写入中途失败损坏密钥库
+func (ks *Keystore) save(secrets map[string]string) (err error) {
+	file := keystoreFile{
+		Version:    keystoreVersion,
+		Iterations: keystoreIterations,
+		Salt:       make([]byte, keystoreSaltLength),
+	}
+	if _, err = rand.Read(file.Salt); err != nil {
+		return
+	}
+	var gcm cipher.AEAD
+	if gcm, err = ks.cipher(file.Salt, file.Iterations); err != nil {
+		return
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(file.Nonce); err != nil {
+		return
+	}
+	var plaintext []byte
+	if plaintext, err = json.Marshal(secrets); err != nil {
+		return
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	var data []byte
+	if data, err = json.Marshal(file); err != nil {
+		return
+	}
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(ks.path), filepath.Base(ks.path)+".tmp*"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp.Name(), ks.path); err != nil {
+		return
+	}
+	// 清空缓存，下次读取时重新加载
+	ks.secrets, ks.version = nil, fileVersion{}
+	return
+}
+
+// cipher 由口令派生加密密钥并创建 AES-GCM
+func (ks *Keystore) cipher(salt []byte, iterations int) (gcm cipher.AEAD, err error) {
+	var key []byte
+	if key, err = pbkdf2.Key(sha256.New, ks.passphrase, salt, iterations, keystoreKeyLength); err != nil {
+		return
+	}
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
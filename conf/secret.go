@@ -0,0 +1,213 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-27 10:31:06
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-27 10:31:06
+ * @Description: 密钥引用解析
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package conf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SecretSchemeEnv      = "env"      // 从环境变量读取，如 env:OPENAI_API_KEY
+	SecretSchemeFile     = "file"     // 从文件读取，如 file:/run/secrets/openai
+	SecretSchemeExec     = "exec"     // 执行命令并读取标准输出，如 exec:vault kv get -field=key secret/openai
+	SecretSchemeKeystore = "keystore" // 从加密的本地密钥库读取，如 keystore:openai
+)
+
+const (
+	defaultExecTimeout = 10 * time.Second // 执行命令的默认超时时间
+)
+
+var (
+	errSecretNotFound = errors.New("secret not found")           // 密钥不存在
+	errSecretEmpty    = errors.New("secret is empty")            // 密钥为空
+	errKeystoreNotSet = errors.New("keystore is not configured") // 未配置密钥库
+)
+
+// SecretResolver 密钥解析器，将密钥引用（不含协议前缀）解析为密钥
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (secret string, err error)
+}
+
+// SecretResolverFunc 函数形式的密钥解析器
+type SecretResolverFunc func(ctx context.Context, ref string) (secret string, err error)
+
+// Resolve 解析密钥
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (secret string, err error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretResolvers = map[string]SecretResolver{
+		SecretSchemeEnv:      SecretResolverFunc(resolveEnv),
+		SecretSchemeFile:     SecretResolverFunc(resolveFile),
+		SecretSchemeExec:     SecretResolverFunc(resolveExec),
+		SecretSchemeKeystore: &envKeystore{},
+	}
+	secretResolversMu sync.RWMutex
+)
+
+// RegisterSecretResolver 注册密钥解析器，配置中以 "<scheme>:" 开头的API密钥和 Redis 密码在加载时由对应的解析器解析，
+// 已注册的协议会被替换，resolver 为 nil 时移除该协议
+//
+// 内置协议：env、file、exec、keystore，keystore 默认使用环境变量 AISDK_KEYSTORE_FILE 和 AISDK_KEYSTORE_PASSPHRASE 指定的密钥库
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+
+	if resolver == nil {
+		delete(secretResolvers, scheme)
+		return
+	}
+	secretResolvers[scheme] = resolver
+}
+
+// ResolveSecret 解析密钥引用，value 不是已注册协议的引用时原样返回
+func ResolveSecret(ctx context.Context, value string) (secret string, err error) {
+	return resolveSecret(ctx, value, nil)
+}
+
+// resolveSecret 解析密钥引用，schemes 不为 nil 时只解析其中的协议，其他值原样返回
+func resolveSecret(ctx context.Context, value string, schemes []string) (secret string, err error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok || (schemes != nil && !slices.Contains(schemes, scheme)) {
+		return value, nil
+	}
+	secretResolversMu.RLock()
+	resolver, ok := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	if secret, err = resolver.Resolve(ctx, ref); err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret: %w", scheme, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("failed to resolve %s secret: %w", scheme, errSecretEmpty)
+	}
+	return
+}
+
+// ResolveSecrets 解析配置中的API密钥和 Redis 密码引用，返回新的配置
+//
+// 错误信息中只包含引用的位置，不包含密钥本身；SDKConfigManager 加载的配置中的密钥会在日志中脱敏
+func ResolveSecrets(ctx context.Context, config SDKConfig) (resolved SDKConfig, err error) {
+	return resolveSecrets(ctx, config, nil)
+}
+
+// ResolveSecretsWithSchemes 只使用 schemes 中协议的解析器解析配置中的API密钥和 Redis 密码引用，其他值原样保留，
+// 用于解析不可信来源（如租户）提供的配置，schemes 为空时不解析任何引用
+//
+// 不可信的配置不要允许 exec、file、env 协议，否则可以在本机执行命令或读取本机的文件和环境变量
+func ResolveSecretsWithSchemes(ctx context.Context, config SDKConfig, schemes ...string) (resolved SDKConfig, err error) {
+	if schemes == nil {
+		schemes = []string{}
+	}
+	return resolveSecrets(ctx, config, schemes)
+}
+
+// resolveSecrets 解析配置中的密钥引用，schemes 不为 nil 时只解析其中的协议
+func resolveSecrets(ctx context.Context, config SDKConfig, schemes []string) (resolved SDKConfig, err error) {
+	resolved = SDKConfig{Providers: make(map[string]ProviderConfig, len(config.Providers))}
+	for name, provider := range config.Providers {
+		provider = cloneProviderConfig(provider)
+		for i, key := range provider.APIKeys {
+			if provider.APIKeys[i], err = resolveSecret(ctx, key, schemes); err != nil {
+				return SDKConfig{}, fmt.Errorf("provider [%s]: api_keys[%d]: %w", name, i, err)
+			}
+		}
+		if redis := provider.LoadBalancer.Redis; redis != nil && redis.Password != "" {
+			if redis.Password, err = resolveSecret(ctx, redis.Password, schemes); err != nil {
+				return SDKConfig{}, fmt.Errorf("provider [%s]: redis password: %w", name, err)
+			}
+		}
+		resolved.Providers[name] = provider
+	}
+	return
+}
+
+// Secrets 获取配置中的API密钥和 Redis 密码
+func (c SDKConfig) Secrets() (secrets []string) {
+	for _, provider := range c.Providers {
+		secrets = append(secrets, provider.APIKeys...)
+		if redis := provider.LoadBalancer.Redis; redis != nil && redis.Password != "" {
+			secrets = append(secrets, redis.Password)
+		}
+	}
+	return
+}
+
+// resolveEnv 从环境变量读取密钥
+func resolveEnv(ctx context.Context, ref string) (secret string, err error) {
+	var ok bool
+	if secret, ok = os.LookupEnv(ref); !ok {
+		return "", fmt.Errorf("%w: environment variable %s", errSecretNotFound, ref)
+	}
+	return
+}
+
+// resolveFile 从文件读取密钥，忽略首尾空白
+func resolveFile(ctx context.Context, ref string) (secret string, err error) {
+	var data []byte
+	if data, err = os.ReadFile(ref); err != nil {
+		return
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
+
+// resolveExec 执行命令并读取标准输出作为密钥，忽略首尾空白；命令不经过 shell，参数以空白分隔
+func resolveExec(ctx context.Context, ref string) (secret string, err error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", errors.New("empty command")
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultExecTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	// 命令的错误输出可能包含密钥，不放入错误信息
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("command %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// envKeystore 使用环境变量 AISDK_KEYSTORE_FILE 和 AISDK_KEYSTORE_PASSPHRASE 指定的密钥库
+type envKeystore struct {
+	keystore *Keystore
+	mu       sync.Mutex
+}
+
+// Resolve 解析密钥
+func (k *envKeystore) Resolve(ctx context.Context, ref string) (secret string, err error) {
+	path, passphrase := os.Getenv(DefaultEnvPrefix+"KEYSTORE_FILE"), os.Getenv(DefaultEnvPrefix+"KEYSTORE_PASSPHRASE")
+	if path == "" || passphrase == "" {
+		return "", errKeystoreNotSet
+	}
+
+	k.mu.Lock()
+	if k.keystore == nil || k.keystore.path != path || k.keystore.passphrase != passphrase {
+		k.keystore = NewKeystore(path, passphrase)
+	}
+	keystore := k.keystore
+	k.mu.Unlock()
+	return keystore.Resolve(ctx, ref)
+}
@@ -0,0 +1,382 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-26 10:42:19
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-28 17:19:52
+ * @Description: 分层配置来源
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultEnvPrefix = "AISDK_" // 默认环境变量前缀
+)
+
+var (
+	errUnknownFormat = errors.New("unknown config file format") // 未知的配置文件格式
+)
+
+// Source 配置来源，多个来源按顺序合并，后面来源中设置的字段覆盖前面来源中的字段
+//
+// 推荐的合并顺序：默认值 < 配置文件 < 环境变量 < 代码
+type Source interface {
+	Load() (config SDKConfig, err error) // 加载配置
+}
+
+// FieldSet 配置来源显式设置的字段，键为提供商名称，值为字段路径的集合
+//
+// 字段路径为 JSON 字段名，负载均衡和 HTTP 传输层配置的字段以 "." 连接，如 base_url、load_balancer.redis、http.disable_http2
+type FieldSet map[string]map[string]bool
+
+// ExplicitSource 能报告显式设置了哪些字段的配置来源，显式设置的字段即使是零值（如 false、空字符串）也会覆盖前面来源中的字段；
+// 未实现该接口的来源或 FieldSet 中没有的提供商只覆盖非零值的字段
+type ExplicitSource interface {
+	Source
+	LoadExplicit() (config SDKConfig, fields FieldSet, err error) // 加载配置及显式设置的字段
+}
+
+// StaticSource 代码中直接给出的配置
+type StaticSource struct {
+	Config SDKConfig // 配置
+	Fields FieldSet  // 显式设置的字段，为空时只覆盖非零值的字段
+}
+
+// Load 加载配置
+func (s *StaticSource) Load() (config SDKConfig, err error) {
+	config, _, err = s.LoadExplicit()
+	return
+}
+
+// LoadExplicit 加载配置及显式设置的字段
+func (s *StaticSource) LoadExplicit() (config SDKConfig, fields FieldSet, err error) {
+	config = SDKConfig{Providers: make(map[string]ProviderConfig, len(s.Config.Providers))}
+	for name, provider := range s.Config.Providers {
+		config.Providers[name] = cloneProviderConfig(provider)
+	}
+	if s.Fields != nil {
+		fields = make(FieldSet, len(s.Fields))
+		for name, set := range s.Fields {
+			fields[name] = maps.Clone(set)
+		}
+	}
+	return
+}
+
+// FileSource 配置文件，支持 JSON、YAML 和 TOML 格式
+type FileSource struct {
+	Path     string // 文件路径
+	Format   string // 文件格式：json、yaml、toml，为空时根据扩展名判断，未知扩展名按 JSON 解析
+	Optional bool   // 文件不存在时是否返回空配置，否则返回错误
+}
+
+// Load 加载配置
+func (s *FileSource) Load() (config SDKConfig, err error) {
+	config, _, err = s.LoadExplicit()
+	return
+}
+
+// LoadExplicit 加载配置及文件中出现的字段
+func (s *FileSource) LoadExplicit() (config SDKConfig, fields FieldSet, err error) {
+	config = SDKConfig{Providers: make(map[string]ProviderConfig)}
+	fields = make(FieldSet)
+
+	var data []byte
+	if data, err = os.ReadFile(s.Path); err != nil {
+		if s.Optional && os.IsNotExist(err) {
+			return config, fields, nil
+		}
+		err = fmt.Errorf("failed to read config file: %w", err)
+		return
+	}
+
+	var raw map[string]any
+	if raw, err = unmarshalConfig(data, s.format(), &config); err != nil {
+		err = fmt.Errorf("failed to unmarshal config: %w", err)
+		return
+	}
+	if config.Providers == nil {
+		config.Providers = make(map[string]ProviderConfig)
+	}
+	fields = explicitFields(raw)
+	return
+}
+
+// format 获取文件格式
+func (s *FileSource) format() (format string) {
+	if s.Format != "" {
+		return strings.ToLower(s.Format)
+	}
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// unmarshalConfig 解析配置并返回通用结构，YAML 和 TOML 先解析为通用结构再按 JSON 字段名解码，与 JSON 配置使用相同的字段名
+func unmarshalConfig(data []byte, format string, config *SDKConfig) (raw map[string]any, err error) {
+	switch format {
+	case "json":
+		if err = json.Unmarshal(data, config); err != nil {
+			return
+		}
+		err = json.Unmarshal(data, &raw)
+		return
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &raw)
+	case "toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownFormat, format)
+	}
+	if err != nil {
+		return
+	}
+
+	var b []byte
+	if b, err = json.Marshal(raw); err != nil {
+		return
+	}
+	err = json.Unmarshal(b, config)
+	return
+}
+
+// explicitFields 获取配置文件中出现的字段，负载均衡和 HTTP 传输层配置按字段记录，为 null 时记录整个配置
+func explicitFields(raw map[string]any) (fields FieldSet) {
+	fields = make(FieldSet)
+	providers, _ := lookupKey(raw, "providers").(map[string]any)
+	for name, value := range providers {
+		set := make(map[string]bool)
+		provider, _ := value.(map[string]any)
+		for key, fieldValue := range provider {
+			key = strings.ToLower(key)
+			nested, ok := fieldValue.(map[string]any)
+			if !ok || (key != "load_balancer" && key != "http") {
+				set[key] = true
+				continue
+			}
+			for nestedKey := range nested {
+				set[key+"."+strings.ToLower(nestedKey)] = true
+			}
+		}
+		fields[name] = set
+	}
+	return
+}
+
+// lookupKey 不区分大小写地查找键，与 JSON 解码的字段匹配规则一致
+func lookupKey(m map[string]any, key string) (value any) {
+	if value, ok := m[key]; ok {
+		return value
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// EnvSource 环境变量配置，变量名格式为 <前缀><提供商>_<字段>，如 AISDK_OPENAI_API_KEYS、AISDK_DEEPSEEK_BASE_URL
+//
+// 支持的字段：API_KEYS（逗号分隔）、BASE_URL、ORG_ID、API_VERSION、ASSISTANT_VERSION、LOAD_BALANCER_STRATEGY、HTTP_PROXY、
+// HTTP_DISABLE_HTTP2、HTTP_INSECURE_SKIP_VERIFY（true 或 false）；设置了的变量即使为 false 也会覆盖前面来源中的字段
+type EnvSource struct {
+	Prefix string // 环境变量前缀，默认 AISDK_
+}
+
+// envFields 环境变量字段、对应的字段路径及其设置方法
+var envFields = []struct {
+	suffix string
+	path   string
+	set    func(config *ProviderConfig, value string) (err error)
+}{
+	{"_API_KEYS", "api_keys", func(c *ProviderConfig, v string) (err error) { c.APIKeys = splitList(v); return }},
+	{"_BASE_URL", "base_url", func(c *ProviderConfig, v string) (err error) { c.BaseURL = v; return }},
+	{"_ORG_ID", "org_id", func(c *ProviderConfig, v string) (err error) { c.OrgID = v; return }},
+	{"_ASSISTANT_VERSION", "assistant_version", func(c *ProviderConfig, v string) (err error) { c.AssistantVersion = v; return }},
+	{"_API_VERSION", "api_version", func(c *ProviderConfig, v string) (err error) { c.APIVersion = v; return }},
+	{"_LOAD_BALANCER_STRATEGY", "load_balancer.strategy", func(c *ProviderConfig, v string) (err error) { c.LoadBalancer.Strategy = v; return }},
+	{"_HTTP_PROXY", "http.proxy", func(c *ProviderConfig, v string) (err error) { c.HTTP.Proxy = v; return }},
+	{"_HTTP_DISABLE_HTTP2", "http.disable_http2", func(c *ProviderConfig, v string) (err error) {
+		c.HTTP.DisableHTTP2, err = strconv.ParseBool(v)
+		return
+	}},
+	{"_HTTP_INSECURE_SKIP_VERIFY", "http.insecure_skip_verify", func(c *ProviderConfig, v string) (err error) {
+		c.HTTP.InsecureSkipVerify, err = strconv.ParseBool(v)
+		return
+	}},
+}
+
+// Load 加载配置
+func (s *EnvSource) Load() (config SDKConfig, err error) {
+	config, _, err = s.LoadExplicit()
+	return
+}
+
+// LoadExplicit 加载配置及设置了的环境变量对应的字段
+func (s *EnvSource) LoadExplicit() (config SDKConfig, fields FieldSet, err error) {
+	config = SDKConfig{Providers: make(map[string]ProviderConfig)}
+	fields = make(FieldSet)
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, prefix) || value == "" {
+			continue
+		}
+		key := strings.TrimPrefix(name, prefix)
+		for _, field := range envFields {
+			if provider, ok := strings.CutSuffix(key, field.suffix); ok && provider != "" {
+				provider = strings.ToLower(provider)
+				providerConfig := config.Providers[provider]
+				if err = field.set(&providerConfig, value); err != nil {
+					return SDKConfig{}, nil, fmt.Errorf("environment variable %s: %w", name, err)
+				}
+				config.Providers[provider] = providerConfig
+				if fields[provider] == nil {
+					fields[provider] = make(map[string]bool)
+				}
+				fields[provider][field.path] = true
+				break
+			}
+		}
+	}
+	return
+}
+
+// splitList 拆分逗号分隔的列表，忽略空白项
+func splitList(value string) (list []string) {
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return
+}
+
+// LoadSources 按顺序加载并合并配置来源，实现了 ExplicitSource 接口的来源中显式设置的字段即使是零值也会覆盖前面来源中的字段
+func LoadSources(sources ...Source) (config SDKConfig, err error) {
+	config = SDKConfig{Providers: make(map[string]ProviderConfig)}
+	for _, source := range sources {
+		var (
+			layer  SDKConfig
+			fields FieldSet
+		)
+		if explicit, ok := source.(ExplicitSource); ok {
+			layer, fields, err = explicit.LoadExplicit()
+		} else {
+			layer, err = source.Load()
+		}
+		if err != nil {
+			return
+		}
+		config = mergeConfig(config, layer, fields)
+	}
+	return
+}
+
+// MergeConfig 将 override 合并到 base，返回新的配置；override 中非零值的字段覆盖 base 中的字段，Extra 按键合并
+func MergeConfig(base, override SDKConfig) (merged SDKConfig) {
+	return mergeConfig(base, override, nil)
+}
+
+// mergeConfig 将 override 合并到 base，fields 中有的提供商只覆盖显式设置的字段，其他提供商覆盖非零值的字段
+func mergeConfig(base, override SDKConfig, fields FieldSet) (merged SDKConfig) {
+	merged = SDKConfig{Providers: make(map[string]ProviderConfig, len(base.Providers))}
+	for name, provider := range base.Providers {
+		merged.Providers[name] = cloneProviderConfig(provider)
+	}
+	for name, provider := range override.Providers {
+		merged.Providers[name] = mergeProviderConfig(merged.Providers[name], cloneProviderConfig(provider), fields[name])
+	}
+	return
+}
+
+// mergeProviderConfig 合并提供商配置，set 为 nil 时覆盖非零值的字段，否则只覆盖 set 中的字段
+func mergeProviderConfig(base, override ProviderConfig, set map[string]bool) (merged ProviderConfig) {
+	merged = base
+	overrideField(&merged.BaseURL, override.BaseURL, set, "base_url")
+	if isSet(set, "api_keys", len(override.APIKeys) > 0) {
+		merged.APIKeys = slices.Clone(override.APIKeys)
+	}
+	overrideField(&merged.OrgID, override.OrgID, set, "org_id")
+	overrideField(&merged.APIVersion, override.APIVersion, set, "api_version")
+	overrideField(&merged.AssistantVersion, override.AssistantVersion, set, "assistant_version")
+	if isSet(set, "extra", len(override.Extra) > 0) {
+		// 显式设置为空时清空，否则按键合并
+		if len(override.Extra) == 0 {
+			merged.Extra = nil
+		} else {
+			if merged.Extra == nil {
+				merged.Extra = make(map[string]string, len(override.Extra))
+			}
+			maps.Copy(merged.Extra, override.Extra)
+		}
+	}
+	// 负载均衡配置，显式设置为 null 时整体覆盖
+	if set["load_balancer"] {
+		merged.LoadBalancer = override.LoadBalancer
+	}
+	lb := override.LoadBalancer
+	overrideField(&merged.LoadBalancer.Strategy, lb.Strategy, set, "load_balancer.strategy")
+	overrideField(&merged.LoadBalancer.RateLimit, lb.RateLimit, set, "load_balancer.rate_limit")
+	overrideField(&merged.LoadBalancer.Redis, lb.Redis, set, "load_balancer.redis")
+	overrideField(&merged.LoadBalancer.SyncInterval, lb.SyncInterval, set, "load_balancer.sync_interval")
+	// HTTP 传输层配置，显式设置为 null 时整体覆盖
+	if set["http"] {
+		merged.HTTP = override.HTTP
+	}
+	h := override.HTTP
+	overrideField(&merged.HTTP.Timeout, h.Timeout, set, "http.timeout")
+	overrideField(&merged.HTTP.Proxy, h.Proxy, set, "http.proxy")
+	overrideField(&merged.HTTP.CACertFile, h.CACertFile, set, "http.ca_cert_file")
+	overrideField(&merged.HTTP.ClientCertFile, h.ClientCertFile, set, "http.client_cert_file")
+	overrideField(&merged.HTTP.ClientKeyFile, h.ClientKeyFile, set, "http.client_key_file")
+	overrideField(&merged.HTTP.InsecureSkipVerify, h.InsecureSkipVerify, set, "http.insecure_skip_verify")
+	overrideField(&merged.HTTP.DialTimeout, h.DialTimeout, set, "http.dial_timeout")
+	overrideField(&merged.HTTP.TLSHandshakeTimeout, h.TLSHandshakeTimeout, set, "http.tls_handshake_timeout")
+	overrideField(&merged.HTTP.ResponseHeaderTimeout, h.ResponseHeaderTimeout, set, "http.response_header_timeout")
+	overrideField(&merged.HTTP.IdleConnTimeout, h.IdleConnTimeout, set, "http.idle_conn_timeout")
+	overrideField(&merged.HTTP.MaxIdleConns, h.MaxIdleConns, set, "http.max_idle_conns")
+	overrideField(&merged.HTTP.MaxIdleConnsPerHost, h.MaxIdleConnsPerHost, set, "http.max_idle_conns_per_host")
+	overrideField(&merged.HTTP.MaxConnsPerHost, h.MaxConnsPerHost, set, "http.max_conns_per_host")
+	overrideField(&merged.HTTP.DisableHTTP2, h.DisableHTTP2, set, "http.disable_http2")
+	return
+}
+
+// overrideField 字段被覆盖时将 value 写入 dest
+func overrideField[T comparable](dest *T, value T, set map[string]bool, path string) {
+	var zero T
+	if isSet(set, path, value != zero) {
+		*dest = value
+	}
+}
+
+// isSet 判断字段是否覆盖前面来源中的字段，set 为 nil 时按 nonZero 判断
+func isSet(set map[string]bool, path string, nonZero bool) (ok bool) {
+	if set == nil {
+		return nonZero
+	}
+	return set[path]
+}
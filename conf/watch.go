@@ -0,0 +1,98 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 14:32:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-26 15:12:04
+ * @Description: 配置文件监听
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package conf
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 2 * time.Second // 默认检查间隔
+)
+
+// fileVersion 配置文件的版本标识
+type fileVersion struct {
+	modTime time.Time // 修改时间
+	size    int64     // 文件大小
+}
+
+// Watch 定期检查配置文件的修改时间和大小，任一文件发生变化时调用 Reload，返回停止监听的函数
+//
+// 只监听 FileSource 配置来源，没有配置文件时不启动监听；interval 小于等于0时使用默认间隔2s；onError 接收检查或重新加载失败的错误，可为 nil，失败时继续使用当前配置
+func (m *SDKConfigManager) Watch(interval time.Duration, onError func(err error)) (stop func()) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	var files []*FileSource
+	for _, source := range m.sources {
+		if file, ok := source.(*FileSource); ok {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return func() {}
+	}
+
+	var (
+		done     = make(chan struct{})
+		finished = make(chan struct{})
+		once     sync.Once
+		last, _  = statFiles(files)
+	)
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			current, err := statFiles(files)
+			if err == nil {
+				if slices.Equal(current, last) {
+					continue
+				}
+				last = current
+				_, err = m.Reload()
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// statFiles 获取配置文件的版本标识，可选的配置文件不存在时版本标识为零值
+func statFiles(files []*FileSource) (versions []fileVersion, err error) {
+	versions = make([]fileVersion, len(files))
+	for i, file := range files {
+		var info os.FileInfo
+		if info, err = os.Stat(file.Path); err != nil {
+			if file.Optional && os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+		versions[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return
+}
@@ -0,0 +1,53 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 15:08:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 15:02:47
+ * @Description: 配置热更新
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/core"
+	"time"
+)
+
+// ReloadConfig 重新加载配置文件并原地更新提供商的API密钥和基础URL，配置无效时返回错误并保留当前配置
+func (c *SDKClient) ReloadConfig() (err error) {
+	_, err = c.configManager.Reload()
+	return
+}
+
+// WatchConfig 监听配置文件变化并自动重新加载，返回停止监听的函数，客户端关闭时自动停止
+//
+// interval 为检查间隔，小于等于0时使用默认间隔；onError 接收重新加载失败的错误，可为 nil
+func (c *SDKClient) WatchConfig(interval time.Duration, onError func(err error)) (stop func()) {
+	stop = c.configManager.Watch(interval, onError)
+	// 客户端关闭时自动停止监听
+	c.lifecycle.addStop(stop)
+	return
+}
+
+// OnConfigChange 注册配置变化回调，在提供商更新完成后调用
+func (c *SDKClient) OnConfigChange(handler conf.ChangeHandler) {
+	c.configManager.OnChange(handler)
+}
+
+// applyConfigChanges 将配置变化应用到提供商，移除的提供商使用空配置
+func (c *SDKClient) applyConfigChanges(changes []conf.ProviderChange) {
+	for _, change := range changes {
+		var ps core.ProviderService
+		if ps = c.providers[consts.Provider(change.Provider)]; ps == nil {
+			continue
+		}
+		if change.New == nil {
+			ps.UpdateProviderConfig(&conf.ProviderConfig{})
+			continue
+		}
+		ps.UpdateProviderConfig(change.New)
+	}
+}
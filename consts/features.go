@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-07-09 15:34:23
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 17:10:41
+ * @LastEditTime: 2025-07-20 16:55:12
  * @Description: 模型特性位掩码定义
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -27,6 +27,10 @@ const (
 	ModelFeatureReasoning ModelFeature = 1 << iota // 0b00000010 = 2
 	// 第3位 (bit 2): 是否支持仅流式传输 (不支持阻塞式调用)
 	ModelFeatureStreamingOnly ModelFeature = 1 << iota // 0b00000100 = 4
+	// 第4位 (bit 3): 是否支持 JSON Schema 结构化输出 (response_format 为 json_schema)
+	ModelFeatureJSONSchema ModelFeature = 1 << iota // 0b00001000 = 8
+	// 第5位 (bit 4): 是否支持 JSON 模式 (response_format 为 json_object)
+	ModelFeatureJSONObject ModelFeature = 1 << iota // 0b00010000 = 16
 	// 更多特性位可以继续扩展...
 )
 
@@ -62,6 +66,16 @@ func (f ModelFeature) IsStreamingOnly() (ok bool) {
 	return f.HasFeature(ModelFeatureStreamingOnly)
 }
 
+// SupportsJSONSchema 检查是否支持 JSON Schema 结构化输出
+func (f ModelFeature) SupportsJSONSchema() (ok bool) {
+	return f.HasFeature(ModelFeatureJSONSchema)
+}
+
+// SupportsJSONObject 检查是否支持 JSON 模式
+func (f ModelFeature) SupportsJSONObject() (ok bool) {
+	return f.HasFeature(ModelFeatureJSONObject)
+}
+
 // String 返回特性的可读描述
 func (f ModelFeature) String() (str string) {
 	var features []string
@@ -75,6 +89,12 @@ func (f ModelFeature) String() (str string) {
 	if f.IsStreamingOnly() {
 		features = append(features, "streaming-only")
 	}
+	if f.SupportsJSONSchema() {
+		features = append(features, "json-schema")
+	}
+	if f.SupportsJSONObject() {
+		features = append(features, "json-object")
+	}
 
 	if len(features) == 0 {
 		return "none"
@@ -2,50 +2,63 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:45:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:23:31
- * @Description: 提供AI服务的核心功能，包括提供商工厂和相关接口，采用单例模式实现，通过包级函数直接访问功能
+ * @LastEditTime: 2025-07-29 16:08:44
+ * @Description: 提供AI服务的核心功能，包括提供商工厂和相关接口，提供商包注册工厂函数，每个SDK客户端通过工厂函数创建自己的提供商实例
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
  */
 package core
 
-import "github.com/liusuxian/go-aisdk/consts"
+import (
+	"github.com/liusuxian/go-aisdk/consts"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ProviderFactory 提供商工厂函数，每次调用创建一个新的提供商实例
+type ProviderFactory func() (service ProviderService)
 
-// providerFactory 管理所有AI服务提供商的工厂
+// providerFactory 管理所有AI服务提供商的工厂函数（并发安全）
 type providerFactory struct {
-	providers map[consts.Provider]ProviderService // 所有提供商
+	factories map[consts.Provider]ProviderFactory // 所有提供商的工厂函数
+	mu        sync.RWMutex                        // 读写锁
 }
 
 var (
-	factory *providerFactory // 全局工厂单例实例(非导出)
+	factory = &providerFactory{
+		factories: make(map[consts.Provider]ProviderFactory),
+	} // 全局工厂实例(非导出)
 )
 
-// init 包初始化时创建 providerFactory 单例
-// 确保在导入包时自动初始化工厂
-func init() {
-	factory = &providerFactory{
-		providers: make(map[consts.Provider]ProviderService),
+// RegisterProvider 注册提供商的工厂函数，通常在提供商包初始化时调用，重复注册时替换，factory 为 nil 时移除该提供商
+func RegisterProvider(provider consts.Provider, providerFactory ProviderFactory) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+
+	if providerFactory == nil {
+		delete(factory.factories, provider)
+		return
 	}
+	factory.factories[provider] = providerFactory
 }
 
-// RegisterProvider 注册提供商
-func RegisterProvider(provider consts.Provider, service ProviderService) {
-	factory.providers[provider] = service
-}
+// NewProvider 创建提供商实例，提供商未注册时返回 nil
+func NewProvider(provider consts.Provider) (service ProviderService) {
+	factory.mu.RLock()
+	providerFactory, ok := factory.factories[provider]
+	factory.mu.RUnlock()
 
-// GetProvider 获取提供商
-func GetProvider(provider consts.Provider) (service ProviderService) {
-	if p, ok := factory.providers[provider]; ok {
-		return p
+	if !ok {
+		return nil
 	}
-	return nil
+	return providerFactory()
 }
 
-// ListProviders 列出所有注册的提供商
+// ListProviders 列出所有注册的提供商，按名称排序
 func ListProviders() (providers []consts.Provider) {
-	providers = make([]consts.Provider, 0, len(factory.providers))
-	for p := range factory.providers {
-		providers = append(providers, p)
-	}
-	return
+	factory.mu.RLock()
+	defer factory.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(factory.factories))
 }
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:45:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 15:49:29
+ * @LastEditTime: 2025-07-25 16:21:37
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -24,6 +24,8 @@ type ProviderService interface {
 	GetSupportedModels() (supportedModels map[consts.ModelType]map[string]consts.ModelFeature)
 	// 初始化提供商配置
 	InitializeProviderConfig(config *conf.ProviderConfig)
+	// 热更新提供商配置，原地更新API密钥负载均衡器和基础URL
+	UpdateProviderConfig(config *conf.ProviderConfig)
 
 	// 模型相关
 	ListModels(ctx context.Context, provider consts.Provider, opts ...httpclient.HTTPClientOption) (response models.ListModelsResponse, err error) // 列出模型
@@ -36,6 +38,9 @@ type ProviderService interface {
 	CreateImageEdit(ctx context.Context, request models.ImageEditRequest, opts ...httpclient.HTTPClientOption) (response models.ImageResponse, err error)           // 编辑图像
 	CreateImageVariation(ctx context.Context, request models.ImageVariationRequest, opts ...httpclient.HTTPClientOption) (response models.ImageResponse, err error) // 变换图像
 
+	// 嵌入相关
+	CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) // 创建嵌入向量
+
 	// TODO 视频相关
 
 	// TODO 音频相关
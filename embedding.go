@@ -0,0 +1,60 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 10:26:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-11 10:26:40
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/cache"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/core"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+)
+
+// CreateEmbeddings 创建嵌入向量
+func (c *SDKClient) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	// 定义处理函数
+	handler := func(ctx context.Context, ps core.ProviderService, req any) (resp any, err error) {
+		embeddingReq := req.(models.EmbeddingRequest)
+		// 创建嵌入向量
+		return ps.CreateEmbeddings(ctx, embeddingReq, opts...)
+	}
+	// 处理请求
+	var resp any
+	if resp, err = c.handlerRequest(ctx, models.ModelInfo{
+		Provider:  request.Provider,
+		ModelType: consts.EmbedModel,
+		Model:     request.Model,
+	}, request.UserInfo, "CreateEmbeddings", request, handler); err != nil {
+		return
+	}
+	// 返回结果
+	response = resp.(models.EmbeddingResponse)
+	return
+}
+
+// NewEmbedder 使用指定的嵌入模型创建向量化函数，用于语义缓存等场景
+func (c *SDKClient) NewEmbedder(provider consts.Provider, model string, opts ...httpclient.HTTPClientOption) (embedder cache.Embedder) {
+	return func(ctx context.Context, text string) (vector []float32, err error) {
+		var response models.EmbeddingResponse
+		if response, err = c.CreateEmbeddings(ctx, models.EmbeddingRequest{
+			Provider: provider,
+			Input:    []string{text},
+			Model:    model,
+		}, opts...); err != nil {
+			return
+		}
+		if len(response.Data) == 0 {
+			return nil, fmt.Errorf("empty embedding response from %s", provider)
+		}
+		return response.Data[0].Embedding, nil
+	}
+}
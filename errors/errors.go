@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-07-07 21:01:34
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 23:16:15
+ * @LastEditTime: 2025-07-31 15:02:47
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -27,6 +27,7 @@ var (
 	ErrCompletionStreamNotSupported = errors.New("streaming is not supported with this method, please use CreateChatCompletionStream") // 流式传输不支持
 	ErrTooManyEmptyStreamMessages   = httpclient.ErrTooManyEmptyStreamMessages                                                         // 流式传输发送了太多空消息
 	ErrStreamReturnIntervalTimeout  = httpclient.ErrStreamReturnIntervalTimeout                                                        // 流式传输返回间隔超时
+	ErrClientClosed                 = errors.New("sdk client is closed")                                                               // SDK客户端已关闭
 )
 
 // WrapFailedToCreateConfigManager 包装创建配置管理器失败错误
@@ -112,6 +113,11 @@ func IsStreamReturnIntervalTimeoutError(err error) (is bool) {
 	return errors.Is(err, ErrStreamReturnIntervalTimeout)
 }
 
+// IsClientClosedError 判断是否是SDK客户端已关闭错误
+func IsClientClosedError(err error) (is bool) {
+	return errors.Is(err, ErrClientClosed)
+}
+
 // IsCanceledError 判断是否是取消错误
 func IsCanceledError(err error) (is bool) {
 	return errors.Is(err, context.Canceled)
@@ -139,6 +145,11 @@ func (e *SDKError) Error() (errStr string) {
 	return fmt.Sprintf("request_id: %s, error: %v", e.RequestID, e.Err)
 }
 
+// Unwrap 返回原始错误，以便 errors.Is 和 errors.As 判断原始错误
+func (e *SDKError) Unwrap() (err error) {
+	return e.Err
+}
+
 // RequestID 获取请求ID
 func RequestID(err error) (requestId string) {
 	if err == nil {
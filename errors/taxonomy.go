@@ -0,0 +1,258 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 10:14:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 16:02:41
+ * @Description: 与提供商无关的错误分类，各提供商将自身的错误码映射到统一的错误分类
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 错误分类，提供商返回的错误会被包装为 *ProviderError，可通过 errors.Is 判断所属分类
+var (
+	ErrAuthentication        = errors.New("authentication failed")   // 认证失败，如 API 密钥无效
+	ErrPermissionDenied      = errors.New("permission denied")       // 无权访问，如模型或地区不可用
+	ErrRateLimited           = errors.New("rate limited")            // 触发速率限制
+	ErrQuotaExceeded         = errors.New("quota exceeded")          // 额度用尽或账户欠费
+	ErrContextLengthExceeded = errors.New("context length exceeded") // 超出模型上下文长度
+	ErrContentFiltered       = errors.New("content filtered")        // 输入或输出内容被安全策略拦截
+	ErrInvalidRequest        = errors.New("invalid request")         // 请求参数错误
+	ErrServerError           = errors.New("server error")            // 提供商服务端错误
+	ErrOverloaded            = errors.New("service overloaded")      // 提供商服务过载或暂不可用
+)
+
+// ErrorInfo 提供商错误信息，用于错误分类
+type ErrorInfo struct {
+	StatusCode int    // HTTP 状态码
+	Code       string // 提供商错误码
+	Type       string // 提供商错误类型
+	Message    string // 错误信息
+}
+
+// Classifier 错误分类函数，返回上述错误分类之一，无法分类时返回 nil
+type Classifier func(info ErrorInfo) (kind error)
+
+var (
+	classifiers   = make(map[consts.Provider]Classifier) // 提供商错误分类函数
+	classifiersMu sync.RWMutex
+)
+
+// RegisterClassifier 注册提供商的错误分类函数，通常在提供商包初始化时调用
+func RegisterClassifier(provider consts.Provider, classifier Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+
+	classifiers[provider] = classifier
+}
+
+// ProviderError 提供商返回的错误
+type ProviderError struct {
+	Provider   consts.Provider // 提供商
+	Kind       error           // 错误分类，无法分类时为 nil
+	StatusCode int             // HTTP 状态码
+	Code       string          // 提供商错误码
+	Type       string          // 提供商错误类型
+	Message    string          // 错误信息
+	RetryAfter time.Duration   // 响应头 Retry-After 指定的重试等待时间，未指定时为0
+	Err        error           // 原始错误，*httpclient.APIError 或 *httpclient.RequestError
+}
+
+// Error 错误信息
+func (e *ProviderError) Error() (errStr string) {
+	if e.Kind == nil {
+		return fmt.Sprintf("provider [%s]: %v", e.Provider, e.Err)
+	}
+	return fmt.Sprintf("provider [%s]: %s: %v", e.Provider, e.Kind, e.Err)
+}
+
+// Is 支持 errors.Is(err, ErrRateLimited) 等错误分类判断
+func (e *ProviderError) Is(target error) (ok bool) {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap 返回原始错误
+func (e *ProviderError) Unwrap() (err error) {
+	return e.Err
+}
+
+// Classify 将提供商返回的 API 错误或请求错误包装为 *ProviderError，其他错误原样返回
+//
+// 优先使用提供商注册的错误分类函数，无法分类时按 HTTP 状态码分类
+func Classify(provider consts.Provider, err error) (classified error) {
+	if err == nil {
+		return nil
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return err
+	}
+	var (
+		info   ErrorInfo
+		header http.Header
+		apiErr *httpclient.APIError
+		reqErr *httpclient.RequestError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		info = ErrorInfo{
+			StatusCode: apiErr.HTTPStatusCode,
+			Type:       apiErr.Type,
+			Message:    apiErr.Message,
+		}
+		if apiErr.Code != nil {
+			info.Code = fmt.Sprint(apiErr.Code)
+		}
+		if info.Code == "" && apiErr.InnerError != nil {
+			info.Code = apiErr.InnerError.Code
+		}
+		header = apiErr.HTTPHeader
+	case errors.As(err, &reqErr):
+		info = ErrorInfo{
+			StatusCode: reqErr.HTTPStatusCode,
+			Message:    string(reqErr.Body),
+		}
+		header = reqErr.HTTPHeader
+	default:
+		return err
+	}
+
+	classifiersMu.RLock()
+	classifier := classifiers[provider]
+	classifiersMu.RUnlock()
+
+	var kind error
+	if classifier != nil {
+		kind = classifier(info)
+	}
+	if kind == nil {
+		kind = ClassifyStatus(info.StatusCode)
+	}
+	return &ProviderError{
+		Provider:   provider,
+		Kind:       kind,
+		StatusCode: info.StatusCode,
+		Code:       info.Code,
+		Type:       info.Type,
+		Message:    info.Message,
+		RetryAfter: parseRetryAfter(header.Get("Retry-After")),
+		Err:        err,
+	}
+}
+
+// RetryAfter 获取错误中响应头 Retry-After 指定的重试等待时间，未指定时返回0
+func RetryAfter(err error) (d time.Duration) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) (d time.Duration) {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// ClassifyStatus 按 HTTP 状态码分类错误，无法分类时返回 nil
+func ClassifyStatus(statusCode int) (kind error) {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ErrAuthentication
+	case statusCode == http.StatusPaymentRequired:
+		return ErrQuotaExceeded
+	case statusCode == http.StatusForbidden:
+		return ErrPermissionDenied
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrContextLengthExceeded
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusServiceUnavailable, statusCode == 529:
+		return ErrOverloaded
+	case statusCode >= http.StatusInternalServerError:
+		return ErrServerError
+	case statusCode >= http.StatusBadRequest:
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// MessageContains 判断错误信息是否包含任一关键字（不区分大小写），供错误分类函数使用
+func MessageContains(message string, keywords ...string) (ok bool) {
+	message = strings.ToLower(message)
+	for _, keyword := range keywords {
+		if strings.Contains(message, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthenticationError 判断是否是认证失败错误
+func IsAuthenticationError(err error) (is bool) {
+	return errors.Is(err, ErrAuthentication)
+}
+
+// IsPermissionDeniedError 判断是否是无权访问错误
+func IsPermissionDeniedError(err error) (is bool) {
+	return errors.Is(err, ErrPermissionDenied)
+}
+
+// IsRateLimitedError 判断是否是速率限制错误
+func IsRateLimitedError(err error) (is bool) {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsQuotaExceededError 判断是否是额度用尽错误
+func IsQuotaExceededError(err error) (is bool) {
+	return errors.Is(err, ErrQuotaExceeded)
+}
+
+// IsContextLengthExceededError 判断是否是超出上下文长度错误
+func IsContextLengthExceededError(err error) (is bool) {
+	return errors.Is(err, ErrContextLengthExceeded)
+}
+
+// IsContentFilteredError 判断是否是内容被拦截错误
+func IsContentFilteredError(err error) (is bool) {
+	return errors.Is(err, ErrContentFiltered)
+}
+
+// IsInvalidRequestError 判断是否是请求参数错误
+func IsInvalidRequestError(err error) (is bool) {
+	return errors.Is(err, ErrInvalidRequest)
+}
+
+// IsServerError 判断是否是提供商服务端错误
+func IsServerError(err error) (is bool) {
+	return errors.Is(err, ErrServerError)
+}
+
+// IsOverloadedError 判断是否是提供商服务过载错误
+func IsOverloadedError(err error) (is bool) {
+	return errors.Is(err, ErrOverloaded)
+}
@@ -0,0 +1,100 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 14:20:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 14:20:08
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package errors_test
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	_ "github.com/liusuxian/go-aisdk/providers"
+	"github.com/liusuxian/go-aisdk/tokenizer"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		provider consts.Provider
+		err      error
+		want     error
+	}{
+		// OpenAI
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 401, Code: "invalid_api_key", Type: "invalid_request_error"}, errors.ErrAuthentication},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 403, Code: "unsupported_country_region_territory"}, errors.ErrPermissionDenied},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 429, Code: "rate_limit_exceeded"}, errors.ErrRateLimited},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 429, Code: "insufficient_quota"}, errors.ErrQuotaExceeded},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 400, Code: "context_length_exceeded"}, errors.ErrContextLengthExceeded},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 400, Code: "content_policy_violation"}, errors.ErrContentFiltered},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 400, Type: "invalid_request_error", Message: "Unknown parameter"}, errors.ErrInvalidRequest},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 500, Type: "server_error"}, errors.ErrServerError},
+		{consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 503, Message: "The engine is currently overloaded"}, errors.ErrOverloaded},
+		// DeepSeek
+		{consts.DeepSeek, &httpclient.APIError{HTTPStatusCode: 401, Type: "authentication_error", Message: "Authentication Fails"}, errors.ErrAuthentication},
+		{consts.DeepSeek, &httpclient.APIError{HTTPStatusCode: 402, Message: "Insufficient Balance"}, errors.ErrQuotaExceeded},
+		{consts.DeepSeek, &httpclient.APIError{HTTPStatusCode: 400, Message: "This model's maximum context length is 65536 tokens"}, errors.ErrContextLengthExceeded},
+		{consts.DeepSeek, &httpclient.APIError{HTTPStatusCode: 422, Message: "Invalid parameters"}, errors.ErrInvalidRequest},
+		{consts.DeepSeek, &httpclient.APIError{HTTPStatusCode: 503, Message: "Server Overloaded"}, errors.ErrOverloaded},
+		// 阿里百炼
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 401, Code: "InvalidApiKey"}, errors.ErrAuthentication},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 403, Code: "Model.AccessDenied"}, errors.ErrPermissionDenied},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 400, Code: "Arrearage"}, errors.ErrQuotaExceeded},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 429, Code: "Throttling.RateQuota"}, errors.ErrRateLimited},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 400, Code: "data_inspection_failed"}, errors.ErrContentFiltered},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 400, Code: "InvalidParameter", Message: "Range of input length should be [1, 30720]"}, errors.ErrContextLengthExceeded},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 400, Code: "invalid_parameter_error"}, errors.ErrInvalidRequest},
+		{consts.AliBL, &httpclient.APIError{HTTPStatusCode: 500, Code: "InternalError.Algo"}, errors.ErrServerError},
+		// 无法解析的错误响应按状态码分类
+		{consts.AliBL, &httpclient.RequestError{HTTPStatusCode: 502, Body: []byte("bad gateway")}, errors.ErrServerError},
+	}
+	for _, tt := range tests {
+		// 经过 SDKError 包装后仍可判断错误分类，且保留原始错误
+		err := &errors.SDKError{RequestID: "1", Err: fmt.Errorf("call: %w", errors.Classify(tt.provider, tt.err))}
+		if !goerrors.Is(err, tt.want) {
+			t.Errorf("%s %v: expected %v, got %v", tt.provider, tt.err, tt.want, err)
+		}
+		if !goerrors.Is(err, tt.err) {
+			t.Errorf("%s %v: original error lost", tt.provider, tt.err)
+		}
+	}
+}
+
+func TestClassifyPassthrough(t *testing.T) {
+	if err := errors.Classify(consts.OpenAI, nil); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if err := errors.Classify(consts.OpenAI, context.Canceled); err != context.Canceled {
+		t.Fatalf("expected non-API errors to be returned as-is, got %v", err)
+	}
+	// 不重复包装
+	err := errors.Classify(consts.OpenAI, &httpclient.APIError{HTTPStatusCode: 429})
+	if again := errors.Classify(consts.OpenAI, err); again != err {
+		t.Fatalf("expected classified error to be returned as-is")
+	}
+	var providerErr *errors.ProviderError
+	if !goerrors.As(err, &providerErr) || providerErr.StatusCode != 429 || !errors.IsRateLimitedError(err) {
+		t.Fatalf("unexpected provider error: %v", err)
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	// 本地上下文窗口检查的错误同样属于超出上下文长度分类
+	err := &tokenizer.ContextWindowExceededError{Model: "gpt-4o", ContextWindow: 10, PromptTokens: 20}
+	if !errors.IsContextLengthExceededError(err) {
+		t.Fatal("expected local context window error to be a context length error")
+	}
+	if errors.IsServerError(err) || errors.IsOverloadedError(err) {
+		t.Fatal("unexpected error class")
+	}
+	if !errors.IsModelNotSupportedError(&errors.SDKError{Err: errors.ErrModelNotSupported}) {
+		t.Fatal("expected SDKError to unwrap to the original error")
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-25 13:01:00
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-11 18:54:28
+ * @LastEditTime: 2025-08-02 16:48:27
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -16,6 +16,7 @@ import (
 	"github.com/liusuxian/go-aisdk/consts"
 	"github.com/liusuxian/go-aisdk/errors"
 	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/httpclient/cassette"
 	"github.com/liusuxian/go-aisdk/models"
 	"log"
 	"os"
@@ -36,6 +37,29 @@ func getApiKeys(envKey string) (apiKeys string) {
 	return
 }
 
+// withCassette 设置了环境变量 AISDK_CASSETTE 时通过磁带录制或回放请求，用于在 CI 中离线运行示例
+//
+//	AISDK_CASSETTE_MODE 为 replay（默认）、record 或 replay_or_record，示例按固定顺序发送请求，只按请求方法和地址匹配
+func withCassette() (opt aisdk.SDKClientOption, err error) {
+	path := os.Getenv("AISDK_CASSETTE")
+	if path == "" {
+		return aisdk.WithHTTPClientOptions(), nil
+	}
+	var mode cassette.Mode
+	if mode, err = cassette.ParseMode(os.Getenv("AISDK_CASSETTE_MODE")); err != nil {
+		return
+	}
+	var c *cassette.Cassette
+	if c, err = cassette.New(cassette.Config{
+		Path:    path,
+		Mode:    mode,
+		Matcher: cassette.MatchAll(cassette.MatchMethod, cassette.MatchURL),
+	}); err != nil {
+		return
+	}
+	return aisdk.WithHTTPClientOptions(c.HTTPClientOption()), nil
+}
+
 func isError(err error) {
 	if err != nil {
 		originalErr := errors.Unwrap(err)
@@ -194,7 +218,12 @@ func main() {
 		return
 	}
 
-	client, err := aisdk.NewSDKClient(configPath, aisdk.WithDefaultMiddlewares())
+	cassetteOpt, err := withCassette()
+	if err != nil {
+		log.Printf("withCassette() error = %v", err)
+		return
+	}
+	client, err := aisdk.NewSDKClient(configPath, aisdk.WithDefaultMiddlewares(), cassetteOpt)
 	if err != nil {
 		log.Printf("NewSDKClient() error = %v", err)
 		return
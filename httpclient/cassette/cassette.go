@@ -0,0 +1,424 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 10:26:14
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 10:26:14
+ * @Description: 录制和回放 HTTP 交互的磁带
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/internal/redact"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Mode 磁带模式
+type Mode int
+
+const (
+	ModeReplay         Mode = iota // 只回放，匹配的交互按录制顺序回放，全部回放过后重复最后一个，没有匹配的交互时返回 ErrInteractionNotFound
+	ModeRecord                     // 总是发送真实请求并录制，覆盖磁带文件中已有的交互
+	ModeReplayOrRecord             // 有未回放过的匹配交互时回放，否则发送真实请求并追加录制
+)
+
+const (
+	fileVersion    = 1        // 磁带文件格式版本
+	encodingBase64 = "base64" // 非 UTF-8 内容的编码方式
+)
+
+var (
+	ErrInteractionNotFound = errors.New("cassette: no recorded interaction matches the request") // 没有匹配的交互
+	errInvalidMode         = errors.New("cassette: invalid mode")                                // 磁带模式无效
+	errPathRequired        = errors.New("cassette: path is required")                            // 未设置磁带文件路径
+)
+
+// DefaultRedactHeaders 默认脱敏的请求头和响应头
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Api-Key", "X-Api-Key", "Cookie", "Set-Cookie"}
+
+// ParseMode 解析磁带模式，支持 replay、record、replay_or_record
+func ParseMode(s string) (mode Mode, err error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "replay":
+		return ModeReplay, nil
+	case "record":
+		return ModeRecord, nil
+	case "replay_or_record":
+		return ModeReplayOrRecord, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", errInvalidMode, s)
+	}
+}
+
+// Request 录制的请求
+type Request struct {
+	Method   string      `json:"method"`             // 请求方法
+	URL      string      `json:"url"`                // 请求地址
+	Header   http.Header `json:"header,omitempty"`   // 请求头
+	Body     string      `json:"body,omitempty"`     // 请求体
+	Encoding string      `json:"encoding,omitempty"` // 请求体编码，非 UTF-8 内容为 base64
+}
+
+// Response 录制的响应，流式响应按数据块录制
+type Response struct {
+	StatusCode int         `json:"status_code"`        // 状态码
+	Header     http.Header `json:"header,omitempty"`   // 响应头
+	Body       string      `json:"body,omitempty"`     // 响应体，流式响应为空
+	Encoding   string      `json:"encoding,omitempty"` // 响应体编码，非 UTF-8 内容为 base64
+	Chunks     []Chunk     `json:"chunks,omitempty"`   // 流式响应的数据块
+}
+
+// Chunk 流式响应的数据块，通常是一个完整的 SSE 事件
+type Chunk struct {
+	Data    string `json:"data"`     // 数据
+	DelayMs int64  `json:"delay_ms"` // 距离上一个数据块（第一个数据块为收到响应头）的间隔毫秒数
+}
+
+// Interaction 一次 HTTP 交互
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// file 磁带文件
+type file struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Config 磁带配置
+type Config struct {
+	Path          string               // 磁带文件路径（JSON），必须设置
+	Mode          Mode                 // 磁带模式，默认 ModeReplay
+	Matcher       Matcher              // 请求匹配规则，默认 DefaultMatcher
+	ReplaySpeed   float64              // 流式数据块的回放速度倍率，1 表示按录制时的间隔回放，2 表示两倍速，0 表示不等待
+	RedactHeaders []string             // 录制时脱敏的请求头和响应头，默认 DefaultRedactHeaders
+	BeforeSave    func(i *Interaction) // 保存交互前调用，可进一步脱敏或清理数据
+	Doer          httpclient.HTTPDoer  // 直接作为 HTTPDoer 使用时发送真实请求的执行器，默认无超时限制的 httpclient.DefaultHTTPDoer
+}
+
+// Cassette 磁带，录制真实的 HTTP 交互到文件，并在测试中按匹配规则确定性地回放（并发安全）
+//
+//	可直接作为 httpclient.HTTPDoer 使用，也可以通过 HTTPClientOption 包装提供商原有的请求执行器，
+//	录制时使用提供商配置的代理、TLS 等传输层设置。API 密钥等请求头在写入文件前脱敏，
+//	已通过 conf 注册的密钥也会从地址、请求体和响应体中移除
+type Cassette struct {
+	config       Config
+	redactHeader map[string]bool // 脱敏的请求头，键为规范化的名称
+	interactions []*Interaction
+	used         []bool // 交互是否已被回放
+	mu           sync.Mutex
+}
+
+// New 新建磁带，回放模式下磁带文件必须存在
+func New(config Config) (c *Cassette, err error) {
+	if config.Path == "" {
+		return nil, errPathRequired
+	}
+	if config.Mode < ModeReplay || config.Mode > ModeReplayOrRecord {
+		return nil, fmt.Errorf("%w: %d", errInvalidMode, config.Mode)
+	}
+	if config.Matcher == nil {
+		config.Matcher = DefaultMatcher
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = DefaultRedactHeaders
+	}
+	if config.Doer == nil {
+		config.Doer = httpclient.NewDefaultHTTPDoer(0)
+	}
+	c = &Cassette{
+		config:       config,
+		redactHeader: make(map[string]bool, len(config.RedactHeaders)),
+	}
+	for _, name := range config.RedactHeaders {
+		c.redactHeader[http.CanonicalHeaderKey(name)] = true
+	}
+	// 录制模式覆盖已有的交互
+	if config.Mode == ModeRecord {
+		return
+	}
+	if err = c.load(); err != nil && (config.Mode == ModeReplay || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetTimeout 设置 Config.Doer 的请求超时时间（非并发安全）
+func (c *Cassette) SetTimeout(timeout time.Duration) {
+	c.config.Doer.SetTimeout(timeout)
+}
+
+// Do 发送请求，回放匹配的交互或通过 Config.Doer 发送真实请求并录制
+func (c *Cassette) Do(req *http.Request) (resp *http.Response, err error) {
+	return c.do(c.config.Doer, req)
+}
+
+// HTTPClientOption 获取使用磁带的 HTTP 客户端选项，录制时通过 HTTP 客户端原有的请求执行器发送真实请求
+//
+//	可用于单次调用，也可以通过 aisdk.WithHTTPClientOptions 用于客户端的所有请求
+func (c *Cassette) HTTPClientOption() (opt httpclient.HTTPClientOption) {
+	return httpclient.WrapHTTPDoer(func(doer httpclient.HTTPDoer) httpclient.HTTPDoer {
+		return &wrappedDoer{cassette: c, next: doer}
+	})
+}
+
+// Interactions 获取磁带中的所有交互
+func (c *Cassette) Interactions() (interactions []Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	interactions = make([]Interaction, 0, len(c.interactions))
+	for _, i := range c.interactions {
+		interactions = append(interactions, *i)
+	}
+	return
+}
+
+// wrappedDoer 包装原有请求执行器的磁带
+type wrappedDoer struct {
+	cassette *Cassette
+	next     httpclient.HTTPDoer
+}
+
+// SetTimeout 设置原有请求执行器的请求超时时间
+func (d *wrappedDoer) SetTimeout(timeout time.Duration) {
+	d.next.SetTimeout(timeout)
+}
+
+// Do 发送请求
+func (d *wrappedDoer) Do(req *http.Request) (resp *http.Response, err error) {
+	return d.cassette.do(d.next, req)
+}
+
+// do 回放匹配的交互，没有匹配的交互时按模式发送真实请求并录制
+func (c *Cassette) do(next httpclient.HTTPDoer, req *http.Request) (resp *http.Response, err error) {
+	// 读取请求体，发送真实请求时重新设置
+	var body []byte
+	if req.Body != nil {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := c.newRequest(req, body)
+	if c.config.Mode != ModeRecord {
+		// 录制或回放模式下，匹配的交互都已回放过时重新录制
+		if i := c.match(recorded, c.config.Mode == ModeReplay); i != nil {
+			return c.replay(req, i)
+		}
+		if c.config.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+		}
+	}
+	return c.record(next, req, recorded)
+}
+
+// match 查找匹配的交互，优先使用未回放过的交互，全部回放过时 repeat 为 true 则重复使用最后一个匹配的交互
+func (c *Cassette) match(req Request, repeat bool) (i *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := -1
+	for idx, recorded := range c.interactions {
+		if !c.config.Matcher(req, recorded.Request) {
+			continue
+		}
+		if !c.used[idx] {
+			c.used[idx] = true
+			return recorded
+		}
+		last = idx
+	}
+	if repeat && last >= 0 {
+		return c.interactions[last]
+	}
+	return nil
+}
+
+// replay 回放交互
+func (c *Cassette) replay(req *http.Request, i *Interaction) (resp *http.Response, err error) {
+	resp = &http.Response{
+		Status:     fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode: i.Response.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     i.Response.Header.Clone(),
+		Request:    req,
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	if i.Response.Chunks != nil {
+		resp.ContentLength = -1
+		resp.Body = newReplayBody(req.Context(), i.Response.Chunks, c.config.ReplaySpeed)
+		return
+	}
+	var body []byte
+	if body, err = decodeBody(i.Response.Body, i.Response.Encoding); err != nil {
+		return nil, err
+	}
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return
+}
+
+// record 发送真实请求并录制，流式响应在读取完或关闭时录制
+func (c *Cassette) record(next httpclient.HTTPDoer, req *http.Request, recorded Request) (resp *http.Response, err error) {
+	if resp, err = next.Do(req); err != nil {
+		return
+	}
+	i := &Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     c.redactHeaders(resp.Header),
+		},
+	}
+	if isEventStream(resp) {
+		resp.Body = newRecordingBody(resp.Body, func(chunks []Chunk) error {
+			for idx := range chunks {
+				chunks[idx].Data = redact.String(chunks[idx].Data)
+			}
+			i.Response.Chunks = chunks
+			return c.add(i)
+		})
+		return
+	}
+	var body []byte
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	i.Response.Body, i.Response.Encoding = encodeBody([]byte(redact.String(string(body))))
+	if err = c.add(i); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return
+}
+
+// add 添加交互并保存磁带文件
+func (c *Cassette) add(i *Interaction) (err error) {
+	if c.config.BeforeSave != nil {
+		c.config.BeforeSave(i)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, i)
+	// 录制的交互已经用于本次请求
+	c.used = append(c.used, true)
+	return c.save()
+}
+
+// newRequest 根据真实请求新建脱敏后的录制请求
+func (c *Cassette) newRequest(req *http.Request, body []byte) (recorded Request) {
+	recorded = Request{
+		Method: req.Method,
+		URL:    redact.String(req.URL.String()),
+		Header: c.redactHeaders(req.Header),
+	}
+	recorded.Body, recorded.Encoding = encodeBody([]byte(redact.String(string(body))))
+	return
+}
+
+// redactHeaders 复制并脱敏请求头或响应头
+func (c *Cassette) redactHeaders(header http.Header) (redacted http.Header) {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted = make(http.Header, len(header))
+	for name, values := range header {
+		masked := make([]string, len(values))
+		for idx, value := range values {
+			if c.redactHeader[http.CanonicalHeaderKey(name)] {
+				masked[idx] = redact.Mask
+			} else {
+				masked[idx] = redact.String(value)
+			}
+		}
+		redacted[name] = masked
+	}
+	return
+}
+
+// load 加载磁带文件
+func (c *Cassette) load() (err error) {
+	var data []byte
+	if data, err = os.ReadFile(c.config.Path); err != nil {
+		return
+	}
+	var f file
+	if err = json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("cassette: failed to parse %s: %w", c.config.Path, err)
+	}
+	c.interactions = f.Interactions
+	c.used = make([]bool, len(f.Interactions))
+	return
+}
+
+// save 保存磁带文件，先写入临时文件再重命名，调用方需持有锁
+func (c *Cassette) save() (err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(file{Version: fileVersion, Interactions: c.interactions}, "", "  "); err != nil {
+		return
+	}
+	dir := filepath.Dir(c.config.Path)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(dir, filepath.Base(c.config.Path)+".*.tmp"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), c.config.Path)
+}
+
+// isEventStream 判断是否为 SSE 流式响应
+func isEventStream(resp *http.Response) (ok bool) {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// encodeBody 编码请求体或响应体，非 UTF-8 内容使用 base64 编码
+func encodeBody(body []byte) (s, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), encodingBase64
+}
+
+// decodeBody 解码请求体或响应体
+func decodeBody(s, encoding string) (body []byte, err error) {
+	if encoding == encodingBase64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,225 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 14:37:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 14:37:52
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "sk-cassette-secret"
+
+// newTestServer 创建模拟提供商的服务器，/chat 返回 JSON，/stream 返回 SSE 流
+func newTestServer(t *testing.T) (server *httptest.Server, hits *atomic.Int32) {
+	hits = &atomic.Int32{}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if r.Header.Get("Authorization") != "Bearer "+testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/chat":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"chat-%d"}`, n)
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, event := range []string{`{"delta":"Hel"}`, `{"delta":"lo"}`, "[DONE]"} {
+				fmt.Fprintf(w, "data: %s\n\n", event)
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return
+}
+
+// send 发送请求并读取响应体
+func send(t *testing.T, c *Cassette, method, url, body string) (resp *http.Response, data string, err error) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	if resp, err = c.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return resp, string(b), err
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var (
+		server, hits = newTestServer(t)
+		path         = filepath.Join(t.TempDir(), "testdata", "chat.json")
+	)
+	// 录制
+	recorder, err := New(Config{Path: path, Mode: ModeRecord})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, chat, err := send(t, recorder, http.MethodPost, server.URL+"/chat", `{"model":"gpt-4o","stream":false}`)
+	if err != nil || chat != `{"id":"chat-1"}` {
+		t.Fatalf("unexpected recorded response %q: %v", chat, err)
+	}
+	_, stream, err := send(t, recorder, http.MethodPost, server.URL+"/stream", `{"model":"gpt-4o","stream":true}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// API 密钥已脱敏
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), testAPIKey) {
+		t.Errorf("expected the api key to be redacted, got %s", data)
+	}
+	interactions := recorder.Interactions()
+	if len(interactions) != 2 || interactions[0].Request.Header.Get("Authorization") != "***" {
+		t.Fatalf("unexpected interactions: %+v", interactions)
+	}
+	chunks := interactions[1].Response.Chunks
+	if len(chunks) != 3 || chunks[0].Data != "data: {\"delta\":\"Hel\"}\n\n" || chunks[1].DelayMs < 10 {
+		t.Errorf("expected one chunk per sse event with timing, got %+v", chunks)
+	}
+
+	// 回放，不再访问服务器，JSON 字段顺序不影响匹配
+	server.Close()
+	player, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, replayed, err := send(t, player, http.MethodPost, server.URL+"/chat", `{"stream":false, "model":"gpt-4o"}`)
+	if err != nil || replayed != chat || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected replayed response %q: %v", replayed, err)
+	}
+	if _, replayed, err = send(t, player, http.MethodPost, server.URL+"/stream", `{"model":"gpt-4o","stream":true}`); err != nil || replayed != stream {
+		t.Errorf("expected the stream to be replayed, got %q: %v", replayed, err)
+	}
+	if _, _, err = send(t, player, http.MethodPost, server.URL+"/chat", `{"model":"gpt-4o-mini"}`); !errors.Is(err, ErrInteractionNotFound) {
+		t.Errorf("expected ErrInteractionNotFound, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 real requests, got %d", hits.Load())
+	}
+	// 回放模式下磁带文件必须存在
+	if _, err = New(Config{Path: filepath.Join(t.TempDir(), "missing.json")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing cassette error, got %v", err)
+	}
+}
+
+func TestReplayOrRecord(t *testing.T) {
+	var (
+		server, hits = newTestServer(t)
+		path         = filepath.Join(t.TempDir(), "chat.json")
+	)
+	c, err := New(Config{Path: path, Mode: ModeReplayOrRecord})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 相同的请求依次录制
+	for i, want := range []string{`{"id":"chat-1"}`, `{"id":"chat-2"}`} {
+		if _, got, _ := send(t, c, http.MethodPost, server.URL+"/chat", `{}`); got != want {
+			t.Fatalf("request %d: expected %s, got %s", i, want, got)
+		}
+	}
+	// 按录制顺序回放，匹配的交互都回放过后继续录制
+	c, _ = New(Config{Path: path, Mode: ModeReplayOrRecord})
+	for _, want := range []string{`{"id":"chat-1"}`, `{"id":"chat-2"}`, `{"id":"chat-3"}`} {
+		if _, got, _ := send(t, c, http.MethodPost, server.URL+"/chat", `{}`); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 real requests, got %d", hits.Load())
+	}
+	// 只回放时全部回放过后重复最后一个
+	c, _ = New(Config{Path: path})
+	for _, want := range []string{`{"id":"chat-1"}`, `{"id":"chat-2"}`, `{"id":"chat-3"}`, `{"id":"chat-3"}`} {
+		if _, got, _ := send(t, c, http.MethodPost, server.URL+"/chat", `{}`); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	os.WriteFile(path, []byte(`{"version":1,"interactions":[{
+		"request":{"method":"POST","url":"https://api.example.com/stream"},
+		"response":{"status_code":200,"header":{"Content-Type":["text/event-stream"]},"chunks":[
+			{"data":"data: 1\n\n","delay_ms":0},{"data":"data: 2\n\n","delay_ms":60},{"data":"data: [DONE]\n\n","delay_ms":60}
+		]}}]}`), 0644)
+
+	for _, tt := range []struct {
+		speed    float64
+		min, max time.Duration
+	}{
+		{speed: 0, max: 50 * time.Millisecond},
+		{speed: 1, min: 120 * time.Millisecond},
+		{speed: 4, min: 30 * time.Millisecond, max: 100 * time.Millisecond},
+	} {
+		c, err := New(Config{Path: path, ReplaySpeed: tt.speed})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		start := time.Now()
+		_, got, err := send(t, c, http.MethodPost, "https://api.example.com/stream", "")
+		elapsed := time.Since(start)
+		if err != nil || got != "data: 1\n\ndata: 2\n\ndata: [DONE]\n\n" {
+			t.Errorf("speed %v: unexpected stream %q: %v", tt.speed, got, err)
+		}
+		if elapsed < tt.min || (tt.max > 0 && elapsed > tt.max) {
+			t.Errorf("speed %v: expected replay to take between %v and %v, took %v", tt.speed, tt.min, tt.max, elapsed)
+		}
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	var (
+		req      = Request{Method: "POST", URL: "https://a.example.com/v1/chat?b=2&a=1", Header: http.Header{"X-Tag": {"x"}}, Body: `{"model":"m","user":"u1"}`}
+		recorded = Request{Method: "POST", URL: "https://a.example.com/v1/chat?a=1&b=2", Header: http.Header{"X-Tag": {"x"}}, Body: `{"user":"u2","model":"m"}`}
+	)
+	tests := []struct {
+		name    string
+		matcher Matcher
+		req     Request
+		want    bool
+	}{
+		{name: "default", matcher: DefaultMatcher, req: req, want: false},
+		{name: "ignore fields", matcher: IgnoreJSONFields(DefaultMatcher, "user"), req: req, want: true},
+		{name: "url query order", matcher: MatchURL, req: req, want: true},
+		{name: "path", matcher: MatchPath, req: Request{URL: "http://127.0.0.1:8080/v1/chat"}, want: true},
+		{name: "header", matcher: MatchHeader("x-tag"), req: req, want: true},
+		{name: "header mismatch", matcher: MatchHeader("X-Tag"), req: Request{Header: http.Header{"X-Tag": {"y"}}}, want: false},
+		{name: "method", matcher: MatchMethod, req: Request{Method: "GET"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.matcher(tt.req, recorded); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for s, want := range map[string]Mode{"": ModeReplay, "replay": ModeReplay, "RECORD": ModeRecord, "replay_or_record": ModeReplayOrRecord} {
+		if got, err := ParseMode(s); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseMode("live"); err == nil {
+		t.Error("expected an error for an invalid mode")
+	}
+}
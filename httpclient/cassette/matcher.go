@@ -0,0 +1,110 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 11:05:38
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 11:05:38
+ * @Description: 请求匹配规则
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cassette
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// Matcher 请求匹配规则，判断真实请求是否与录制的请求匹配，两者都已按相同规则脱敏
+type Matcher func(req, recorded Request) (ok bool)
+
+// DefaultMatcher 默认匹配规则：请求方法、地址和请求体都相同
+var DefaultMatcher = MatchAll(MatchMethod, MatchURL, MatchJSONBody)
+
+// MatchAll 所有匹配规则都匹配时才匹配
+func MatchAll(matchers ...Matcher) (matcher Matcher) {
+	return func(req, recorded Request) (ok bool) {
+		for _, m := range matchers {
+			if !m(req, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod 匹配请求方法
+func MatchMethod(req, recorded Request) (ok bool) {
+	return req.Method == recorded.Method
+}
+
+// MatchURL 匹配完整的请求地址，查询参数的顺序不影响匹配
+func MatchURL(req, recorded Request) (ok bool) {
+	u1, err1 := url.Parse(req.URL)
+	u2, err2 := url.Parse(recorded.URL)
+	if err1 != nil || err2 != nil {
+		return req.URL == recorded.URL
+	}
+	return u1.Scheme == u2.Scheme && u1.Host == u2.Host && u1.Path == u2.Path && reflect.DeepEqual(u1.Query(), u2.Query())
+}
+
+// MatchPath 只匹配请求路径，忽略协议、主机和查询参数，用于录制和回放时 BaseURL 不同的场景
+func MatchPath(req, recorded Request) (ok bool) {
+	u1, err1 := url.Parse(req.URL)
+	u2, err2 := url.Parse(recorded.URL)
+	if err1 != nil || err2 != nil {
+		return req.URL == recorded.URL
+	}
+	return u1.Path == u2.Path
+}
+
+// MatchBody 匹配请求体，逐字节比较
+func MatchBody(req, recorded Request) (ok bool) {
+	return req.Body == recorded.Body && req.Encoding == recorded.Encoding
+}
+
+// MatchJSONBody 匹配请求体，都是 JSON 时忽略字段顺序和空白，否则逐字节比较
+func MatchJSONBody(req, recorded Request) (ok bool) {
+	if MatchBody(req, recorded) {
+		return true
+	}
+	var v1, v2 any
+	if json.Unmarshal([]byte(req.Body), &v1) != nil || json.Unmarshal([]byte(recorded.Body), &v2) != nil {
+		return false
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+// MatchHeader 匹配指定的请求头，脱敏的请求头录制后都是脱敏占位符，不应用于匹配
+func MatchHeader(names ...string) (matcher Matcher) {
+	return func(req, recorded Request) (ok bool) {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(name)
+			if !reflect.DeepEqual(req.Header.Values(name), recorded.Header.Values(name)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// IgnoreJSONFields 比较 JSON 请求体前移除指定的顶层字段，如每次请求都不同的 "seed"、"user"
+func IgnoreJSONFields(matcher Matcher, fields ...string) (m Matcher) {
+	strip := func(r Request) Request {
+		var v map[string]any
+		if json.Unmarshal([]byte(r.Body), &v) != nil {
+			return r
+		}
+		for _, field := range fields {
+			delete(v, field)
+		}
+		if b, err := json.Marshal(v); err == nil {
+			r.Body = string(b)
+		}
+		return r
+	}
+	return func(req, recorded Request) (ok bool) {
+		return matcher(strip(req), strip(recorded))
+	}
+}
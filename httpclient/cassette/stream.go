@@ -0,0 +1,156 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 11:42:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 11:42:09
+ * @Description: 流式响应的录制和回放
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// recordingBody 录制流式响应的响应体，按 SSE 事件切分数据块并记录间隔，读取完或关闭时回调一次
+type recordingBody struct {
+	body    io.ReadCloser
+	pending []byte  // 未凑成完整事件的数据
+	chunks  []Chunk // 已录制的数据块
+	last    time.Time
+	done    func(chunks []Chunk) (err error)
+	once    sync.Once
+	err     error
+}
+
+// newRecordingBody 新建录制流式响应的响应体
+func newRecordingBody(body io.ReadCloser, done func(chunks []Chunk) (err error)) (rb *recordingBody) {
+	return &recordingBody{
+		body:   body,
+		chunks: []Chunk{},
+		last:   time.Now(),
+		done:   done,
+	}
+}
+
+// Read 读取数据并录制
+func (rb *recordingBody) Read(p []byte) (n int, err error) {
+	n, err = rb.body.Read(p)
+	if n > 0 {
+		rb.pending = append(rb.pending, p[:n]...)
+		// 每个完整的 SSE 事件作为一个数据块
+		for {
+			idx := bytes.Index(rb.pending, []byte("\n\n"))
+			if idx < 0 {
+				break
+			}
+			rb.addChunk(rb.pending[:idx+2])
+			rb.pending = rb.pending[idx+2:]
+		}
+	}
+	if err != nil {
+		rb.finish()
+	}
+	return
+}
+
+// Close 关闭响应体并录制已读取的数据
+func (rb *recordingBody) Close() (err error) {
+	err = rb.body.Close()
+	if e := rb.finish(); e != nil {
+		return e
+	}
+	return
+}
+
+// addChunk 添加数据块
+func (rb *recordingBody) addChunk(data []byte) {
+	now := time.Now()
+	rb.chunks = append(rb.chunks, Chunk{Data: string(data), DelayMs: now.Sub(rb.last).Milliseconds()})
+	rb.last = now
+}
+
+// finish 录制剩余数据并回调，只执行一次
+func (rb *recordingBody) finish() (err error) {
+	rb.once.Do(func() {
+		if len(rb.pending) > 0 {
+			rb.addChunk(rb.pending)
+			rb.pending = nil
+		}
+		rb.err = rb.done(rb.chunks)
+	})
+	return rb.err
+}
+
+// replayBody 回放流式响应的响应体，按录制的间隔和回放速度倍率返回数据块
+type replayBody struct {
+	ctx     context.Context
+	chunks  []Chunk
+	speed   float64
+	next    int    // 下一个数据块的位置
+	pending []byte // 当前数据块未读取的数据
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// newReplayBody 新建回放流式响应的响应体
+func newReplayBody(ctx context.Context, chunks []Chunk, speed float64) (rb *replayBody) {
+	return &replayBody{
+		ctx:    ctx,
+		chunks: chunks,
+		speed:  speed,
+		closed: make(chan struct{}),
+	}
+}
+
+// Read 读取数据，当前数据块读取完后等待下一个数据块的间隔
+func (rb *replayBody) Read(p []byte) (n int, err error) {
+	for len(rb.pending) == 0 {
+		if rb.next >= len(rb.chunks) {
+			return 0, io.EOF
+		}
+		chunk := rb.chunks[rb.next]
+		if err = rb.wait(chunk.DelayMs); err != nil {
+			return
+		}
+		rb.pending = []byte(chunk.Data)
+		rb.next++
+	}
+	n = copy(p, rb.pending)
+	rb.pending = rb.pending[n:]
+	return
+}
+
+// Close 关闭响应体，等待中的读取立即返回
+func (rb *replayBody) Close() (err error) {
+	rb.once.Do(func() { close(rb.closed) })
+	return
+}
+
+// wait 按回放速度倍率等待数据块的间隔，上下文被取消或响应体关闭时提前返回
+func (rb *replayBody) wait(delayMs int64) (err error) {
+	select {
+	case <-rb.closed:
+		return errors.New("cassette: read on closed response body")
+	default:
+	}
+	if rb.speed <= 0 || delayMs <= 0 {
+		return rb.ctx.Err()
+	}
+	timer := time.NewTimer(time.Duration(float64(time.Duration(delayMs)*time.Millisecond) / rb.speed))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return
+	case <-rb.ctx.Done():
+		return rb.ctx.Err()
+	case <-rb.closed:
+		return errors.New("cassette: read on closed response body")
+	}
+}
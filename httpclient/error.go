@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 17:56:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:22:27
+ * @LastEditTime: 2025-07-22 16:02:41
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -13,12 +13,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 )
 
 var (
 	ErrTooManyEmptyStreamMessages  = errors.New("stream has sent too many empty messages") // 流式传输发送了太多空消息
 	ErrStreamReturnIntervalTimeout = errors.New("stream return interval timeout")          // 流式传输返回间隔超时
+	ErrStreamClosed                = errors.New("stream closed before finished")           // 流式传输在结束前被关闭
 )
 
 // APIError API错误信息
@@ -30,6 +32,7 @@ type APIError struct {
 	Type           string      `json:"type"`
 	HTTPStatus     string      `json:"-"`
 	HTTPStatusCode int         `json:"-"`
+	HTTPHeader     http.Header `json:"-"`
 	InnerError     *InnerError `json:"innererror,omitempty"`
 }
 
@@ -87,10 +90,11 @@ type ContentFilterResults struct {
 
 // RequestError 请求错误
 type RequestError struct {
-	HTTPStatus     string // HTTP 状态描述
-	HTTPStatusCode int    // HTTP 状态码
-	Err            error  // 错误信息
-	Body           []byte // 响应体
+	HTTPStatus     string      // HTTP 状态描述
+	HTTPStatusCode int         // HTTP 状态码
+	HTTPHeader     http.Header // 响应头
+	Err            error       // 错误信息
+	Body           []byte      // 响应体
 }
 
 // ErrorResponse 错误响应
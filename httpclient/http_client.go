@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 17:56:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 16:42:12
+ * @LastEditTime: 2025-08-02 16:48:27
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -154,6 +154,14 @@ func (h *HttpHeader) RequestID() (requestID string) {
 	return h.Header().Get(requestIdHeaderKey)
 }
 
+// SetRequestID 设置请求ID
+func (h *HttpHeader) SetRequestID(requestID string) {
+	if *h == nil {
+		*h = make(HttpHeader)
+	}
+	h.Header().Set(requestIdHeaderKey, requestID)
+}
+
 // RawResponse 原始响应
 type RawResponse struct {
 	io.ReadCloser
@@ -223,6 +231,38 @@ func WithKeyValue(key, value string) (reqOpt RequestOption) {
 	}
 }
 
+// HeaderInjector 请求头注入函数，在请求发送前向请求头写入追踪上下文等信息
+type HeaderInjector func(ctx context.Context, header http.Header)
+
+// WithHeaderInjector 添加请求头注入函数到上下文，通过该上下文创建的请求都会调用注入函数
+func WithHeaderInjector(ctx context.Context, injector HeaderInjector) (newCtx context.Context) {
+	injectors, _ := ctx.Value(headerInjectorKey).([]HeaderInjector)
+	// 复制切片，避免修改父上下文中的注入函数列表
+	newInjectors := make([]HeaderInjector, 0, len(injectors)+1)
+	newInjectors = append(newInjectors, injectors...)
+	newInjectors = append(newInjectors, injector)
+	return context.WithValue(ctx, headerInjectorKey, newInjectors)
+}
+
+// WithClientOptions 添加 HTTP 客户端选项到上下文，提供商通过该上下文新建 HTTP 客户端时先应用这些选项，再应用本次调用的选项
+func WithClientOptions(ctx context.Context, opts ...HTTPClientOption) (newCtx context.Context) {
+	if len(opts) == 0 {
+		return ctx
+	}
+	clientOpts, _ := ctx.Value(clientOptionsKey).([]HTTPClientOption)
+	// 复制切片，避免修改父上下文中的选项列表
+	newOpts := make([]HTTPClientOption, 0, len(clientOpts)+len(opts))
+	newOpts = append(newOpts, clientOpts...)
+	newOpts = append(newOpts, opts...)
+	return context.WithValue(ctx, clientOptionsKey, newOpts)
+}
+
+// ClientOptionsFromContext 从上下文中获取 HTTP 客户端选项
+func ClientOptionsFromContext(ctx context.Context) (opts []HTTPClientOption) {
+	opts, _ = ctx.Value(clientOptionsKey).([]HTTPClientOption)
+	return
+}
+
 // GetFormBuilder 获取表单构建器
 func (c *HTTPClient) GetFormBuilder(body io.Writer) (builder FormBuilder) {
 	return c.createFormBuilder(body)
@@ -241,6 +281,12 @@ func (c *HTTPClient) NewRequest(ctx context.Context, method, url string, setters
 	if req, err = c.requestBuilder.Build(ctx, method, url, reqOpts.body, reqOpts.header); err != nil {
 		return
 	}
+	// 注入请求头
+	if injectors, ok := ctx.Value(headerInjectorKey).([]HeaderInjector); ok {
+		for _, inject := range injectors {
+			inject(ctx, req.Header)
+		}
+	}
 	return
 }
 
@@ -364,6 +410,7 @@ func (c *HTTPClient) handleErrorResp(resp *http.Response) (err error) {
 	if err = json.Unmarshal(body, &errRes); err == nil && errRes.Error != nil {
 		errRes.Error.HTTPStatus = resp.Status
 		errRes.Error.HTTPStatusCode = resp.StatusCode
+		errRes.Error.HTTPHeader = resp.Header
 		return errRes.Error
 	}
 	// 尝试解析为 APIError
@@ -371,12 +418,14 @@ func (c *HTTPClient) handleErrorResp(resp *http.Response) (err error) {
 	if err = json.Unmarshal(body, &apiErr); err == nil && apiErr != nil {
 		apiErr.HTTPStatus = resp.Status
 		apiErr.HTTPStatusCode = resp.StatusCode
+		apiErr.HTTPHeader = resp.Header
 		return apiErr
 	}
 	// 如果都解析失败，返回包含解析错误的 RequestError
 	return &RequestError{
 		HTTPStatus:     resp.Status,
 		HTTPStatusCode: resp.StatusCode,
+		HTTPHeader:     resp.Header,
 		Err:            fmt.Errorf("failed to parse error response"),
 		Body:           body,
 	}
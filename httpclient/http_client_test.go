@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 17:56:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 16:46:08
+ * @LastEditTime: 2025-07-12 11:45:18
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -290,6 +290,32 @@ func TestNewRequest(t *testing.T) {
 	}
 }
 
+func TestNewRequestWithHeaderInjector(t *testing.T) {
+	client := NewHTTPClient("https://api.example.com")
+	parent := WithHeaderInjector(context.Background(), func(ctx context.Context, header http.Header) {
+		header.Set("X-First", "1")
+	})
+	ctx := WithHeaderInjector(parent, func(ctx context.Context, header http.Header) {
+		header.Set("X-Second", "2")
+	})
+
+	req, err := client.NewRequest(ctx, "GET", "https://api.example.com/v1/models")
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if req.Header.Get("X-First") != "1" || req.Header.Get("X-Second") != "2" {
+		t.Errorf("Expected injected headers, got %v", req.Header)
+	}
+
+	// 父上下文不受子上下文的影响
+	if req, err = client.NewRequest(parent, "GET", "https://api.example.com/v1/models"); err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if req.Header.Get("X-Second") != "" {
+		t.Errorf("Expected parent context not to inject X-Second, got %s", req.Header.Get("X-Second"))
+	}
+}
+
 func TestIsFailureStatusCode(t *testing.T) {
 	tests := []struct {
 		name           string
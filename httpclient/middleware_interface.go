@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-30 15:14:05
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 00:27:50
+ * @LastEditTime: 2025-08-02 16:48:27
  * @Description: 中间件接口定义
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -11,6 +11,7 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -25,6 +26,11 @@ type Middleware interface {
 	Priority() (priority int)                                                           // 返回中间件优先级，数值越小优先级越高
 }
 
+// Flusher 持有缓冲数据的中间件（如指标、用量、审计记录）可实现该接口，SDK 客户端关闭时在所有请求结束后调用
+type Flusher interface {
+	Flush(ctx context.Context) (err error) // 刷新缓冲数据
+}
+
 // Chain 中间件链
 type Chain struct {
 	middlewares []Middleware
@@ -61,6 +67,19 @@ func (c *Chain) GetMiddlewares() (middlewares []Middleware) {
 	return c.middlewares
 }
 
+// Flush 按顺序刷新所有实现了 Flusher 接口的中间件，返回所有失败的错误
+func (c *Chain) Flush(ctx context.Context) (err error) {
+	var errs []error
+	for _, mw := range c.middlewares {
+		if flusher, ok := mw.(Flusher); ok {
+			if e := flusher.Flush(ctx); e != nil {
+				errs = append(errs, fmt.Errorf("failed to flush %s middleware: %w", mw.Name(), e))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // RequestInfo 请求信息
 type RequestInfo struct {
 	Provider        string    `json:"provider"`          // 提供商
@@ -74,6 +93,7 @@ type RequestInfo struct {
 	Error           error     `json:"error"`             // 最后一次的错误信息（重试过程中会更新）
 	RequestID       string    `json:"request_id"`        // 请求ID
 	User            string    `json:"user"`              // 代表你的终端用户的唯一标识符
+	Tenant          string    `json:"tenant,omitempty"`  // 租户ID
 	Attempt         int       `json:"attempt"`           // 第几次重试
 	MaxAttempts     int       `json:"max_attempts"`      // 最大重试次数
 }
@@ -82,7 +102,9 @@ type RequestInfo struct {
 type ContextKey string
 
 const (
-	RequestInfoKey ContextKey = "go_aisdk_middleware_request_info" // 请求信息在上下文中的键
+	RequestInfoKey    ContextKey = "go_aisdk_middleware_request_info" // 请求信息在上下文中的键
+	headerInjectorKey ContextKey = "go_aisdk_header_injector"         // 请求头注入函数在上下文中的键
+	clientOptionsKey  ContextKey = "go_aisdk_client_options"          // HTTP 客户端选项在上下文中的键
 )
 
 // GetRequestInfo 从上下文中获取请求信息
@@ -123,6 +145,7 @@ func deepCopyRequestInfo(original *RequestInfo) (requestInfo *RequestInfo) {
 		IsSuccess:       original.IsSuccess,
 		RequestID:       original.RequestID,
 		User:            original.User,
+		Tenant:          original.Tenant,
 		Attempt:         original.Attempt,
 		MaxAttempts:     original.MaxAttempts,
 	}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-30 15:14:39
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 18:30:23
+ * @LastEditTime: 2025-07-27 16:03:48
  * @Description: 日志中间件
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -12,8 +12,11 @@ package httpclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/liusuxian/go-aisdk/internal/redact"
 	"log"
+	"log/slog"
 	"os"
 	"runtime"
 	"strings"
@@ -133,8 +136,13 @@ func NewLoggingMiddleware(config LoggingMiddlewareConfig) (lm *LoggingMiddleware
 func (m *LoggingMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
 	// 从上下文中获取请求信息
 	requestInfo := GetRequestInfo(ctx)
+	structuredLogger, structured := m.config.Logger.(StructuredLogger)
 	// 记录请求开始日志
-	m.logRequestStart(ctx, request, requestInfo)
+	if structured {
+		m.logStructuredStart(ctx, structuredLogger, request, requestInfo)
+	} else {
+		m.logRequestStart(ctx, request, requestInfo)
+	}
 	// 执行下一个处理器
 	processingStartTime := time.Now()
 	response, err = next(ctx, request)
@@ -144,7 +152,11 @@ func (m *LoggingMiddleware) Process(ctx context.Context, request any, next MWHan
 	requestInfo.IsSuccess = err == nil
 	requestInfo.Error = err
 	// 记录请求结束日志
-	m.logRequestEnd(ctx, processingStartTime, response, err, requestInfo)
+	if structured {
+		m.logStructuredEnd(ctx, structuredLogger, processingStartTime, response, err, requestInfo)
+	} else {
+		m.logRequestEnd(ctx, processingStartTime, response, err, requestInfo)
+	}
 	return
 }
 
@@ -183,7 +195,7 @@ func (m *LoggingMiddleware) logRequestStart(ctx context.Context, request any, re
 		if reqData := m.sanitizeData(request); reqData != nil {
 			startTemp.Request = reqData
 		}
-		m.config.Logger.Info(ctx, "request started: %s", MustString(startTemp))
+		m.config.Logger.Info(ctx, "request started: %s", redact.String(MustString(startTemp)))
 	}
 }
 
@@ -205,7 +217,7 @@ func (m *LoggingMiddleware) logRequestEnd(ctx context.Context, processingStartTi
 				Error:           requestInfo.Error.Error(),
 				Alias:           Alias(*requestInfo),
 			}
-			m.config.Logger.Error(ctx, "request failed: %s", MustString(endTemp))
+			m.config.Logger.Error(ctx, "request failed: %s", redact.String(MustString(endTemp)))
 		}
 	} else {
 		// 是否跳过成功请求的日志
@@ -232,11 +244,133 @@ func (m *LoggingMiddleware) logRequestEnd(ctx context.Context, processingStartTi
 					endTemp.Response = &respData
 				}
 			}
-			m.config.Logger.Info(ctx, "request completed: %s", MustString(endTemp))
+			m.config.Logger.Info(ctx, "request completed: %s", redact.String(MustString(endTemp)))
 		}
 	}
 }
 
+// logStructuredStart 记录结构化的请求开始日志
+func (m *LoggingMiddleware) logStructuredStart(ctx context.Context, logger StructuredLogger, request any, requestInfo *RequestInfo) {
+	// 是否记录请求
+	if !m.config.LogRequest {
+		return
+	}
+	attrs := m.requestAttrs(requestInfo)
+	if reqData := m.sanitizeData(request); reqData != nil {
+		attrs = append(attrs, slog.Any("request", reqData))
+	}
+	logger.LogAttrs(ctx, LogLevelInfo, "request started", m.redactAttrs(attrs, 0)...)
+}
+
+// logStructuredEnd 记录结构化的请求结束日志
+func (m *LoggingMiddleware) logStructuredEnd(ctx context.Context, logger StructuredLogger, processingStartTime time.Time, response any, err error, requestInfo *RequestInfo) {
+	attrs := append(m.requestAttrs(requestInfo),
+		slog.Int64("duration_ms", requestInfo.EndTime.Sub(processingStartTime).Milliseconds()),
+		slog.Int64("total_duration_ms", requestInfo.TotalDurationMs),
+	)
+	if err != nil {
+		// 是否记录错误
+		if m.config.LogError {
+			attrs = append(attrs, slog.String("error", redact.String(err.Error())), slog.String("error_class", classifyError(err)))
+			logger.LogAttrs(ctx, LogLevelError, "request failed", m.redactAttrs(attrs, 0)...)
+		}
+		return
+	}
+	// 是否跳过成功请求的日志
+	if m.config.SkipSuccessLog {
+		return
+	}
+	switch resp := response.(type) {
+	case TokenUsageReporter:
+		if usage, ok := resp.GetTokenUsage(); ok {
+			attrs = append(attrs, usageAttr(usage))
+		}
+	case StreamObservable:
+		// 流式传输的token使用量在传输结束后记录
+		streamAttrs := m.requestAttrs(requestInfo)
+		ObserveStreamUsage(resp, func(usage TokenUsage, ok bool, streamErr error) {
+			attrs := append(streamAttrs, slog.Int64("stream_duration_ms", time.Since(processingStartTime).Milliseconds()))
+			if ok {
+				attrs = append(attrs, usageAttr(usage))
+			}
+			if streamErr != nil && !errors.Is(streamErr, ErrStreamClosed) {
+				if m.config.LogError {
+					attrs = append(attrs, slog.String("error", redact.String(streamErr.Error())), slog.String("error_class", classifyError(streamErr)))
+					logger.LogAttrs(ctx, LogLevelError, "stream failed", m.redactAttrs(attrs, 0)...)
+				}
+				return
+			}
+			logger.LogAttrs(ctx, LogLevelInfo, "stream completed", m.redactAttrs(attrs, 0)...)
+		})
+	}
+	// 是否记录响应
+	if m.config.LogResponse {
+		if respData := m.sanitizeData(response); respData != nil {
+			attrs = append(attrs, slog.Any("response", respData))
+		}
+	}
+	logger.LogAttrs(ctx, LogLevelInfo, "request completed", m.redactAttrs(attrs, 0)...)
+}
+
+// requestAttrs 获取请求信息的结构化属性
+func (m *LoggingMiddleware) requestAttrs(requestInfo *RequestInfo) (attrs []slog.Attr) {
+	attrs = []slog.Attr{
+		slog.String("request_id", requestInfo.RequestID),
+		slog.String("provider", requestInfo.Provider),
+		slog.String("model_type", requestInfo.ModelType),
+		slog.String("model", requestInfo.Model),
+		slog.String("method", requestInfo.Method),
+		slog.Int("attempt", requestInfo.Attempt),
+	}
+	if requestInfo.MaxAttempts > 0 {
+		attrs = append(attrs, slog.Int("max_attempts", requestInfo.MaxAttempts))
+	}
+	if requestInfo.User != "" {
+		attrs = append(attrs, slog.String("user", requestInfo.User))
+	}
+	return
+}
+
+// usageAttr 获取token使用量的结构化属性
+func usageAttr(usage TokenUsage) (attr slog.Attr) {
+	return slog.Group("usage",
+		slog.Int("input_tokens", usage.InputTokens),
+		slog.Int("cached_input_tokens", usage.CachedInputTokens),
+		slog.Int("output_tokens", usage.OutputTokens),
+		slog.Int("reasoning_tokens", usage.ReasoningTokens),
+		slog.Int("total_tokens", usage.TotalTokens),
+	)
+}
+
+// redactAttrs 脱敏结构化属性，属性值中的嵌套数据已在 sanitizeData 中处理
+func (m *LoggingMiddleware) redactAttrs(attrs []slog.Attr, depth int) (newAttrs []slog.Attr) {
+	if len(m.config.SensitiveFields) == 0 || depth > maxSanitizeDepth {
+		return attrs
+	}
+	newAttrs = make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		switch {
+		case m.isSensitiveField(attr.Key):
+			newAttrs = append(newAttrs, slog.String(attr.Key, "***"))
+		case attr.Value.Kind() == slog.KindGroup:
+			newAttrs = append(newAttrs, slog.Attr{Key: attr.Key, Value: slog.GroupValue(m.redactAttrs(attr.Value.Group(), depth+1)...)})
+		default:
+			newAttrs = append(newAttrs, attr)
+		}
+	}
+	return
+}
+
+// isSensitiveField 判断是否为敏感字段
+func (m *LoggingMiddleware) isSensitiveField(key string) (ok bool) {
+	for _, field := range m.config.SensitiveFields {
+		if strings.Contains(strings.ToLower(key), strings.ToLower(field)) {
+			return true
+		}
+	}
+	return false
+}
+
 // sanitizeData 脱敏数据
 func (m *LoggingMiddleware) sanitizeData(data any) (newData any) {
 	if data == nil {
@@ -252,7 +386,7 @@ func (m *LoggingMiddleware) sanitizeData(data any) (newData any) {
 	}
 	var result any
 	if err = json.Unmarshal(jsonData, &result); err != nil {
-		return string(jsonData) // 如果无法解析，直接返回字符串
+		return redact.String(string(jsonData)) // 如果无法解析，直接返回字符串
 	}
 	// 递归脱敏，从深度0开始
 	return m.sanitizeValue(result, 0)
@@ -270,14 +404,7 @@ func (m *LoggingMiddleware) sanitizeValue(value any, depth int) (newValue any) {
 		result := make(map[string]any)
 		for key, val := range v {
 			// 检查是否为敏感字段
-			isSensitive := false
-			for _, field := range m.config.SensitiveFields {
-				if strings.Contains(strings.ToLower(key), strings.ToLower(field)) {
-					isSensitive = true
-					break
-				}
-			}
-			if isSensitive {
+			if m.isSensitiveField(key) {
 				result[key] = "***"
 			} else {
 				result[key] = m.sanitizeValue(val, depth+1) // 递归处理，深度+1
@@ -290,6 +417,8 @@ func (m *LoggingMiddleware) sanitizeValue(value any, depth int) (newValue any) {
 			result[i] = m.sanitizeValue(val, depth+1) // 递归处理数组元素，深度+1
 		}
 		return result
+	case string:
+		return redact.String(v) // 替换已知的API密钥等密钥
 	default:
 		return v // 基本类型直接返回
 	}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-17 18:24:31
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-19 11:22:45
+ * @LastEditTime: 2025-07-31 15:02:47
  * @Description: 监控中间件
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -12,7 +12,6 @@ package httpclient
 import (
 	"context"
 	"errors"
-	"fmt"
 	"maps"
 	"net"
 	"slices"
@@ -41,6 +40,13 @@ type MetricsCollector interface {
 	Reset()
 }
 
+// TenantMetricsCollector 支持按租户区分指标的收集器，请求带有租户ID时监控中间件使用 ForTenant 返回的收集器记录指标
+type TenantMetricsCollector interface {
+	MetricsCollector
+	// 获取记录指定租户指标的收集器
+	ForTenant(tenant string) (collector MetricsCollector)
+}
+
 // DefaultMetricsCollector 默认指标收集器
 type DefaultMetricsCollector struct {
 	mu sync.RWMutex
@@ -76,20 +82,43 @@ func NewDefaultMetricsCollector() (metricsCollector *DefaultMetricsCollector) {
 
 // RecordRequestStart 记录请求开始
 func (c *DefaultMetricsCollector) RecordRequestStart(provider, modelType, model, method string) {
+	c.recordRequestStart(c.getKey(provider, modelType, model, method))
+}
+
+// RecordRequestComplete 记录请求完成
+func (c *DefaultMetricsCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+	c.recordRequestComplete(c.getKey(provider, modelType, model, method), durationMs, success)
+}
+
+// RecordError 记录错误
+func (c *DefaultMetricsCollector) RecordError(provider, modelType, model, method string, errorType string) {
+	c.recordError(c.getKey(provider, modelType, model, method, errorType))
+}
+
+// RecordRetry 记录重试
+func (c *DefaultMetricsCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+	c.recordRetry(c.getKey(provider, modelType, model, method), retryCount)
+}
+
+// ForTenant 获取记录指定租户指标的收集器，租户ID追加在指标键值的最后
+func (c *DefaultMetricsCollector) ForTenant(tenant string) (collector MetricsCollector) {
+	return &tenantMetricsCollector{DefaultMetricsCollector: c, tenant: tenant}
+}
+
+// recordRequestStart 记录请求开始
+func (c *DefaultMetricsCollector) recordRequestStart(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := c.getKey(provider, modelType, model, method)
 	c.totalRequests[key]++
 	c.activeRequests[key]++
 }
 
-// RecordRequestComplete 记录请求完成
-func (c *DefaultMetricsCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+// recordRequestComplete 记录请求完成
+func (c *DefaultMetricsCollector) recordRequestComplete(key string, durationMs int64, success bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := c.getKey(provider, modelType, model, method)
 	if _, exists := c.responseTimes[key]; !exists {
 		c.responseTimes[key] = make([]int64, 0, maxResponseTimeRecords)
 	}
@@ -112,21 +141,19 @@ func (c *DefaultMetricsCollector) RecordRequestComplete(provider, modelType, mod
 	}
 }
 
-// RecordError 记录错误
-func (c *DefaultMetricsCollector) RecordError(provider, modelType, model, method string, errorType string) {
+// recordError 记录错误
+func (c *DefaultMetricsCollector) recordError(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := fmt.Sprintf("%s:%s", c.getKey(provider, modelType, model, method), errorType)
 	c.errorCounts[key]++
 }
 
-// RecordRetry 记录重试
-func (c *DefaultMetricsCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+// recordRetry 记录重试
+func (c *DefaultMetricsCollector) recordRetry(key string, retryCount int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := c.getKey(provider, modelType, model, method)
 	c.retryCounts[key] += int64(retryCount)
 }
 
@@ -216,6 +243,32 @@ func (c *DefaultMetricsCollector) getKey(subKeys ...string) (key string) {
 	return strings.Join(subKeyList, ":")
 }
 
+// tenantMetricsCollector 记录指定租户指标的默认指标收集器
+type tenantMetricsCollector struct {
+	*DefaultMetricsCollector
+	tenant string // 租户ID
+}
+
+// RecordRequestStart 记录请求开始
+func (c *tenantMetricsCollector) RecordRequestStart(provider, modelType, model, method string) {
+	c.recordRequestStart(c.getKey(provider, modelType, model, method, c.tenant))
+}
+
+// RecordRequestComplete 记录请求完成
+func (c *tenantMetricsCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+	c.recordRequestComplete(c.getKey(provider, modelType, model, method, c.tenant), durationMs, success)
+}
+
+// RecordError 记录错误
+func (c *tenantMetricsCollector) RecordError(provider, modelType, model, method string, errorType string) {
+	c.recordError(c.getKey(provider, modelType, model, method, errorType, c.tenant))
+}
+
+// RecordRetry 记录重试
+func (c *tenantMetricsCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+	c.recordRetry(c.getKey(provider, modelType, model, method, c.tenant), retryCount)
+}
+
 // MetricsMiddlewareConfig 监控中间件配置
 type MetricsMiddlewareConfig struct {
 	Collector MetricsCollector // 指标收集器
@@ -241,46 +294,98 @@ func NewMetricsMiddleware(config MetricsMiddlewareConfig) (mm *MetricsMiddleware
 func (m *MetricsMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
 	// 从上下文中获取请求信息
 	requestInfo := GetRequestInfo(ctx)
+	collector := m.collector(requestInfo)
 	// 记录请求开始
-	m.config.Collector.RecordRequestStart(
+	collector.RecordRequestStart(
 		requestInfo.Provider,
 		requestInfo.ModelType,
 		requestInfo.Model,
 		requestInfo.Method,
 	)
+	startTime := time.Now()
 	// 执行下一个处理器
 	response, err = next(ctx, request)
-	// 记录请求完成
-	m.config.Collector.RecordRequestComplete(
+	// 记录重试次数
+	if requestInfo.Attempt > 0 {
+		collector.RecordRetry(
+			requestInfo.Provider,
+			requestInfo.ModelType,
+			requestInfo.Model,
+			requestInfo.Method,
+			requestInfo.Attempt,
+		)
+	}
+	// 流式传输在请求返回后才结束，在传输结束时记录请求完成、错误和token使用量，传输期间仍计为活跃请求
+	if stream, ok := response.(StreamObservable); ok && err == nil {
+		ObserveStreamUsage(stream, func(usage TokenUsage, ok bool, streamErr error) {
+			// 调用方提前关闭流式传输不计为失败
+			if errors.Is(streamErr, ErrStreamClosed) {
+				streamErr = nil
+			}
+			m.recordComplete(collector, requestInfo, streamDurationMs(requestInfo, startTime), streamErr)
+			if ok {
+				m.recordTokenUsage(collector, requestInfo, usage)
+			}
+		})
+		return
+	}
+	// 未启用日志中间件时，请求耗时不会被更新
+	durationMs := requestInfo.TotalDurationMs
+	if durationMs <= 0 && !requestInfo.StartTime.IsZero() {
+		durationMs = time.Since(requestInfo.StartTime).Milliseconds()
+	}
+	m.recordComplete(collector, requestInfo, durationMs, err)
+	// 记录token使用量
+	if reporter, ok := response.(TokenUsageReporter); ok && err == nil {
+		if usage, ok := reporter.GetTokenUsage(); ok {
+			m.recordTokenUsage(collector, requestInfo, usage)
+		}
+	}
+	return
+}
+
+// recordComplete 记录请求完成，失败时按错误类型记录错误
+func (m *MetricsMiddleware) recordComplete(collector MetricsCollector, requestInfo *RequestInfo, durationMs int64, err error) {
+	collector.RecordRequestComplete(
 		requestInfo.Provider,
 		requestInfo.ModelType,
 		requestInfo.Model,
 		requestInfo.Method,
-		requestInfo.TotalDurationMs,
-		requestInfo.IsSuccess,
+		durationMs,
+		err == nil,
 	)
-	// 记录错误
 	if err != nil {
-		errorType := m.classifyError(err)
-		m.config.Collector.RecordError(
+		collector.RecordError(
 			requestInfo.Provider,
 			requestInfo.ModelType,
 			requestInfo.Model,
 			requestInfo.Method,
-			errorType,
+			m.classifyError(err),
 		)
 	}
-	// 记录重试次数
-	if requestInfo.Attempt > 0 {
-		m.config.Collector.RecordRetry(
-			requestInfo.Provider,
-			requestInfo.ModelType,
-			requestInfo.Model,
-			requestInfo.Method,
-			requestInfo.Attempt,
-		)
+}
+
+// recordTokenUsage 记录token使用量，指标收集器未实现 TokenUsageRecorder 接口时无操作
+func (m *MetricsMiddleware) recordTokenUsage(collector MetricsCollector, requestInfo *RequestInfo, usage TokenUsage) {
+	if recorder, ok := collector.(TokenUsageRecorder); ok {
+		recorder.RecordTokenUsage(requestInfo.Provider, requestInfo.ModelType, requestInfo.Model, requestInfo.Method, usage)
 	}
-	return
+}
+
+// streamDurationMs 获取流式传输从请求开始到传输结束的耗时
+func streamDurationMs(requestInfo *RequestInfo, startTime time.Time) (durationMs int64) {
+	if !requestInfo.StartTime.IsZero() {
+		startTime = requestInfo.StartTime
+	}
+	return time.Since(startTime).Milliseconds()
+}
+
+// collector 获取记录本次请求指标的收集器，请求带有租户ID且收集器支持按租户区分时返回该租户的收集器
+func (m *MetricsMiddleware) collector(requestInfo *RequestInfo) (collector MetricsCollector) {
+	if tc, ok := m.config.Collector.(TenantMetricsCollector); ok && requestInfo.Tenant != "" {
+		return tc.ForTenant(requestInfo.Tenant)
+	}
+	return m.config.Collector
 }
 
 // Name 返回中间件名称
@@ -293,6 +398,14 @@ func (m *MetricsMiddleware) Priority() (priority int) {
 	return 10 // 监控中间件优先级较高，尽早执行
 }
 
+// Flush 刷新指标收集器中缓冲的指标，指标收集器未实现 Flusher 接口时无操作
+func (m *MetricsMiddleware) Flush(ctx context.Context) (err error) {
+	if flusher, ok := m.config.Collector.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return
+}
+
 // GetMetrics 获取指标数据
 func (m *MetricsMiddleware) GetMetrics() (metrics map[string]any) {
 	return m.config.Collector.GetMetrics()
@@ -300,6 +413,11 @@ func (m *MetricsMiddleware) GetMetrics() (metrics map[string]any) {
 
 // classifyError 分类错误类型
 func (m *MetricsMiddleware) classifyError(err error) (errorType string) {
+	return classifyError(err)
+}
+
+// classifyError 分类错误类型
+func classifyError(err error) (errorType string) {
 	if err == nil {
 		return "none"
 	}
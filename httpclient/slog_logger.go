@@ -0,0 +1,84 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-14 09:58:20
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-14 09:58:20
+ * @Description: 基于 log/slog 的结构化日志
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// StructuredLogger 结构化日志接口，日志器实现该接口时，日志中间件以结构化属性输出日志
+type StructuredLogger interface {
+	LogAttrs(ctx context.Context, level LogLevel, msg string, attrs ...slog.Attr) // 记录结构化日志
+}
+
+// SlogLogger 基于 log/slog 的日志实现，同时实现 Logger 和 StructuredLogger 接口
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger 创建基于 log/slog 的日志器，logger 为空时使用 slog.Default()
+func NewSlogLogger(logger *slog.Logger) (l *SlogLogger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+// Debug 调试日志
+func (l *SlogLogger) Debug(ctx context.Context, format string, args ...any) {
+	l.logf(ctx, LogLevelDebug, format, args...)
+}
+
+// Info 信息日志
+func (l *SlogLogger) Info(ctx context.Context, format string, args ...any) {
+	l.logf(ctx, LogLevelInfo, format, args...)
+}
+
+// Warn 警告日志
+func (l *SlogLogger) Warn(ctx context.Context, format string, args ...any) {
+	l.logf(ctx, LogLevelWarn, format, args...)
+}
+
+// Error 错误日志
+func (l *SlogLogger) Error(ctx context.Context, format string, args ...any) {
+	l.logf(ctx, LogLevelError, format, args...)
+}
+
+// LogAttrs 记录结构化日志
+func (l *SlogLogger) LogAttrs(ctx context.Context, level LogLevel, msg string, attrs ...slog.Attr) {
+	l.logger.LogAttrs(ctx, level.slogLevel(), msg, attrs...)
+}
+
+// logf 记录格式化日志
+func (l *SlogLogger) logf(ctx context.Context, level LogLevel, format string, args ...any) {
+	slogLevel := level.slogLevel()
+	if !l.logger.Enabled(ctx, slogLevel) {
+		return
+	}
+	l.logger.LogAttrs(ctx, slogLevel, fmt.Sprintf(format, args...))
+}
+
+// slogLevel 转换为 slog 日志级别
+func (level LogLevel) slogLevel() (slogLevel slog.Level) {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-14 10:46:13
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-27 16:03:48
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/liusuxian/go-aisdk/internal/redact"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testUsageResponse 实现 TokenUsageReporter 接口
+type testUsageResponse struct {
+	Content string `json:"content"`
+}
+
+func (r testUsageResponse) GetTokenUsage() (usage TokenUsage, ok bool) {
+	return TokenUsage{InputTokens: 3, OutputTokens: 5, TotalTokens: 8}, true
+}
+
+// decodeLogLines 解析 JSON 格式的日志
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) (lines []map[string]any) {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode log line %q: %v", line, err)
+		}
+		lines = append(lines, record)
+	}
+	return
+}
+
+func TestLoggingMiddleware_Slog(t *testing.T) {
+	var (
+		buf = &bytes.Buffer{}
+		m   = NewLoggingMiddleware(LoggingMiddlewareConfig{
+			Logger:          NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			LogRequest:      true,
+			LogResponse:     true,
+			LogError:        true,
+			SensitiveFields: []string{"api_key", "user"},
+		})
+		ctx = SetRequestInfo(context.Background(), &RequestInfo{
+			Provider:  "openai",
+			ModelType: "chat",
+			Model:     "gpt-4o",
+			Method:    "CreateChatCompletion",
+			StartTime: time.Now(),
+			RequestID: "test-request-id",
+			User:      "alice",
+			Attempt:   1,
+		})
+	)
+
+	request := map[string]any{"api_key": "sk-secret", "messages": []any{map[string]any{"content": "hi"}}}
+	if _, err := m.Process(ctx, request, func(ctx context.Context, request any) (response any, err error) {
+		return testUsageResponse{Content: "hello"}, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := decodeLogLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d", len(lines))
+	}
+	start, end := lines[0], lines[1]
+	if start["msg"] != "request started" || end["msg"] != "request completed" {
+		t.Errorf("unexpected messages: %v, %v", start["msg"], end["msg"])
+	}
+	for key, want := range map[string]any{
+		"request_id": "test-request-id",
+		"provider":   "openai",
+		"model":      "gpt-4o",
+		"method":     "CreateChatCompletion",
+		"attempt":    float64(1),
+		"user":       "***",
+	} {
+		if end[key] != want {
+			t.Errorf("expected %s to be %v, got %v", key, want, end[key])
+		}
+	}
+	if _, ok := end["duration_ms"]; !ok {
+		t.Error("expected duration_ms attribute")
+	}
+	if req := start["request"].(map[string]any); req["api_key"] != "***" {
+		t.Errorf("expected api_key to be redacted, got %v", req["api_key"])
+	}
+	if usage, ok := end["usage"].(map[string]any); !ok || usage["input_tokens"] != float64(3) || usage["output_tokens"] != float64(5) {
+		t.Errorf("unexpected usage attribute: %v", end["usage"])
+	}
+	if resp := end["response"].(map[string]any); resp["content"] != "hello" {
+		t.Errorf("unexpected response attribute: %v", end["response"])
+	}
+
+	// 错误日志
+	buf.Reset()
+	m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, &APIError{Message: "bad request"}
+	})
+	lines = decodeLogLines(t, buf)
+	failed := lines[len(lines)-1]
+	if failed["level"] != "ERROR" || failed["msg"] != "request failed" || failed["error_class"] != "api_error" {
+		t.Errorf("unexpected error log: %v", failed)
+	}
+}
+
+func TestLoggingMiddleware_RedactsSecrets(t *testing.T) {
+	const secret = "sk-registered-secret"
+	defer redact.Register(secret).Release()
+	var (
+		buf = &bytes.Buffer{}
+		m   = NewLoggingMiddleware(LoggingMiddlewareConfig{
+			Logger:      NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+			LogRequest:  true,
+			LogResponse: true,
+			LogError:    true,
+		})
+		ctx = SetRequestInfo(context.Background(), &RequestInfo{RequestID: "test-request-id"})
+	)
+
+	// 已注册的密钥出现在请求、响应和错误中时都会被替换
+	m.Process(ctx, map[string]any{"prompt": "key " + secret}, func(ctx context.Context, request any) (response any, err error) {
+		return testUsageResponse{Content: secret}, nil
+	})
+	m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, &APIError{Message: "Incorrect API key provided: " + secret}
+	})
+	// 非结构化日志
+	text := &bytes.Buffer{}
+	m = NewLoggingMiddleware(LoggingMiddlewareConfig{
+		Logger:   &DefaultLogger{logger: log.New(text, "", 0), level: LogLevelInfo},
+		LogError: true,
+	})
+	m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, errors.New("invalid key " + secret)
+	})
+
+	for _, output := range []string{buf.String(), text.String()} {
+		if output == "" || strings.Contains(output, secret) {
+			t.Errorf("expected the secret to be redacted, got %s", output)
+		}
+	}
+}
+
+func TestLoggingMiddleware_SlogStream(t *testing.T) {
+	var (
+		buf = &bytes.Buffer{}
+		m   = NewLoggingMiddleware(LoggingMiddlewareConfig{
+			Logger:   NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+			LogError: true,
+		})
+		ctx = SetRequestInfo(context.Background(), &RequestInfo{Method: "CreateChatCompletionStream", RequestID: "test-request-id"})
+	)
+
+	resp, err := m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		body := strings.NewReader("data: {\"content\":\"hi\"}\n\ndata: [DONE]\n\n")
+		return NewStreamReader[testUsageResponse](io.NopCloser(body), nil, nil), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = resp.(*StreamReader[testUsageResponse]).ForEach(func(chunk testUsageResponse, isFinished bool) (err error) {
+		return
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := decodeLogLines(t, buf)
+	last := lines[len(lines)-1]
+	if last["msg"] != "stream completed" {
+		t.Fatalf("expected stream completed log, got %v", last["msg"])
+	}
+	if usage, ok := last["usage"].(map[string]any); !ok || usage["total_tokens"] != float64(8) {
+		t.Errorf("unexpected usage attribute: %v", last["usage"])
+	}
+}
+
+func TestSlogLogger_Printf(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	l.Info(context.Background(), "ignored %d", 1)
+	l.Error(context.Background(), "failed: %v", errors.New("boom"))
+	if out := buf.String(); strings.Contains(out, "ignored") || !strings.Contains(out, "failed: boom") {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 18:00:38
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:13:49
+ * @LastEditTime: 2025-07-13 11:36:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -31,6 +32,14 @@ type Streamable any
 // StreamDataHandler 流式数据处理函数
 type StreamDataHandler[T Streamable] func(response T, isFinished bool) (err error)
 
+// StreamHook 流式传输钩子，用于在不消费数据的情况下观察流式传输过程
+//
+//	OnChunk 与 OnClose 可能在不同的 goroutine 中被调用，实现需要保证并发安全
+type StreamHook[T Streamable] struct {
+	OnChunk func(chunk T)   // 每成功接收一个数据块时调用
+	OnClose func(err error) // 流式传输结束时调用（仅调用一次），正常结束时 err 为 nil，提前关闭时 err 为 ErrStreamClosed
+}
+
 // StreamReader 流读取器
 type StreamReader[T Streamable] struct {
 	emptyMessagesLimit          uint
@@ -44,10 +53,53 @@ type StreamReader[T Streamable] struct {
 	// 统计字段
 	startTime  time.Time
 	chunkCount int
+	// 钩子
+	hooks     []StreamHook[T]
+	closeOnce sync.Once
 	// 响应头
 	HttpHeader
 }
 
+// NewStreamReader 通过 SSE 格式的数据流新建流读取器，用于缓存回放、测试等不经过 HTTP 请求的场景
+func NewStreamReader[T Streamable](body io.ReadCloser, header http.Header, decoder ResponseDecoder) (stream *StreamReader[T]) {
+	if header == nil {
+		header = make(http.Header)
+	}
+	if decoder == nil {
+		decoder = &DefaultResponseDecoder{}
+	}
+	return &StreamReader[T]{
+		emptyMessagesLimit: defaultEmptyMessagesLimit,
+		reader:             bufio.NewReader(body),
+		response: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       body,
+		},
+		streamReturnIntervalTimeout: defaultStreamReturnIntervalTimeout,
+		errAccumulator:              NewErrorAccumulator(),
+		responseDecoder:             decoder,
+		startTime:                   time.Now(),
+		HttpHeader:                  HttpHeader(header),
+	}
+}
+
+// AddHook 添加流式传输钩子（非并发安全，需在开始读取数据前调用）
+func (stream *StreamReader[T]) AddHook(hook StreamHook[T]) {
+	stream.hooks = append(stream.hooks, hook)
+}
+
+// AddObserver 添加不依赖数据类型的流式传输观察者（非并发安全，需在开始读取数据前调用）
+func (stream *StreamReader[T]) AddObserver(onChunk func(chunk any), onClose func(err error)) {
+	hook := StreamHook[T]{OnClose: onClose}
+	if onChunk != nil {
+		hook.OnChunk = func(chunk T) {
+			onChunk(chunk)
+		}
+	}
+	stream.AddHook(hook)
+}
+
 // StreamStatsReceiver 流式传输统计信息接收器
 type StreamStatsReceiver interface {
 	SetStreamStats(stats StreamStats) // 设置流式传输统计信息
@@ -102,6 +154,12 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 		case <-stream.streamReturnIntervalTimer.C:
 			return ErrStreamReturnIntervalTimeout
 		case err = <-errChan:
+			// 数据先于结束信号发送，select 可能先选中结束信号，需要先处理尚未处理的数据
+			for len(lineChan) > 0 {
+				if e := handler(<-lineChan, false); e != nil {
+					return e
+				}
+			}
 			if err == nil {
 				var empty T
 				return handler(empty, true)
@@ -127,10 +185,12 @@ func (stream *StreamReader[T]) Recv() (response T, isFinished bool, err error) {
 			isFinished = true
 			err = nil
 		}
+		stream.notifyClose(err)
 		return
 	}
 	// 解析数据
 	if err = stream.responseDecoder.Decode(bytes.NewReader(rawLine), &response); err != nil {
+		stream.notifyClose(err)
 		return
 	}
 	// 更新统计信息
@@ -146,6 +206,12 @@ func (stream *StreamReader[T]) Recv() (response T, isFinished bool, err error) {
 		}
 		statsReceiver.SetStreamStats(stats)
 	}
+	// 通知钩子
+	for _, hook := range stream.hooks {
+		if hook.OnChunk != nil {
+			hook.OnChunk(response)
+		}
+	}
 	return
 }
 
@@ -234,5 +300,17 @@ func (stream *StreamReader[T]) unmarshalError() (errResp map[string]any) {
 
 // Close 关闭流
 func (stream *StreamReader[T]) Close() (err error) {
+	stream.notifyClose(ErrStreamClosed)
 	return stream.response.Body.Close()
 }
+
+// notifyClose 通知钩子流式传输已结束（仅通知一次）
+func (stream *StreamReader[T]) notifyClose(err error) {
+	stream.closeOnce.Do(func() {
+		for _, hook := range stream.hooks {
+			if hook.OnClose != nil {
+				hook.OnClose(err)
+			}
+		}
+	})
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 18:00:38
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 16:56:36
+ * @LastEditTime: 2025-07-10 16:31:05
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -163,3 +163,55 @@ func TestStreamReader_ErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamReader_Hooks(t *testing.T) {
+	var (
+		body   = io.NopCloser(strings.NewReader("data: {\"text\":\"Hello\"}\n\ndata: {\"text\":\"World\"}\n\ndata: [DONE]\n\n"))
+		stream = NewStreamReader[map[string]any](body, nil, nil)
+		chunks []string
+		closed int
+		result error
+	)
+	stream.AddHook(StreamHook[map[string]any]{
+		OnChunk: func(chunk map[string]any) {
+			chunks = append(chunks, chunk["text"].(string))
+		},
+		OnClose: func(err error) {
+			closed++
+			result = err
+		},
+	})
+
+	if err := stream.ForEach(func(response map[string]any, isFinished bool) (err error) {
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(chunks, " ") != "Hello World" {
+		t.Errorf("Expected chunks [Hello World], got %v", chunks)
+	}
+	if closed != 1 || result != nil {
+		t.Errorf("Expected OnClose to be called once with nil, got %d calls with %v", closed, result)
+	}
+}
+
+func TestStreamReader_HooksClosedEarly(t *testing.T) {
+	var (
+		body   = io.NopCloser(strings.NewReader("data: {\"text\":\"Hello\"}\n\ndata: [DONE]\n\n"))
+		stream = NewStreamReader[map[string]any](body, nil, nil)
+		result error
+	)
+	stream.AddHook(StreamHook[map[string]any]{
+		OnClose: func(err error) {
+			result = err
+		},
+	})
+
+	if _, _, err := stream.Recv(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stream.Close()
+	if !errors.Is(result, ErrStreamClosed) {
+		t.Errorf("Expected ErrStreamClosed, got %v", result)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 14:21:10
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 14:21:10
+ * @Description: 收到的请求的断言函数
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package fakeserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Request 获取第 i 个收到的请求，i 为负数时从末尾计数（-1 为最后一个请求），不存在时测试立即失败
+func (s *Server) Request(t testing.TB, i int) (req Request) {
+	t.Helper()
+	requests := s.Requests()
+	idx := i
+	if idx < 0 {
+		idx += len(requests)
+	}
+	if idx < 0 || idx >= len(requests) {
+		t.Fatalf("fakeserver: request %d not found, got %d requests", i, len(requests))
+	}
+	return requests[idx]
+}
+
+// AssertRequestCount 断言收到的请求数
+func (s *Server) AssertRequestCount(t testing.TB, want int) {
+	t.Helper()
+	if got := len(s.Requests()); got != want {
+		t.Errorf("fakeserver: expected %d requests, got %d", want, got)
+	}
+}
+
+// AssertAPIKeysUsed 断言每个API密钥都至少被使用过一次
+func (s *Server) AssertAPIKeysUsed(t testing.TB, keys ...string) {
+	t.Helper()
+	var used []string
+	for _, req := range s.Requests() {
+		used = append(used, req.APIKey)
+	}
+	for _, key := range keys {
+		if !slices.Contains(used, key) {
+			t.Errorf("fakeserver: expected api key %q to be used, got %v", key, used)
+		}
+	}
+}
+
+// AssertHeader 断言第 i 个请求的请求头，i 为负数时从末尾计数
+func (s *Server) AssertHeader(t testing.TB, i int, name, want string) {
+	t.Helper()
+	if got := s.Request(t, i).Header.Get(name); got != want {
+		t.Errorf("fakeserver: request %d: expected header %s = %q, got %q", i, name, want, got)
+	}
+}
+
+// AssertBodyField 断言第 i 个请求 JSON 请求体的字段，i 为负数时从末尾计数，字段路径用 "." 分隔，如 "parameters.incremental_output"
+//
+//	want 序列化为 JSON 后与请求体中的字段比较，因此 1 与 1.0、结构体与对应的对象都视为相等
+func (s *Server) AssertBodyField(t testing.TB, i int, path string, want any) {
+	t.Helper()
+	req := s.Request(t, i)
+	got, ok := lookup(req.JSON, path)
+	if !ok {
+		t.Errorf("fakeserver: request %d: body field %s not found in %s", i, path, req.Body)
+		return
+	}
+	var normalized any
+	if data, err := json.Marshal(want); err != nil || json.Unmarshal(data, &normalized) != nil {
+		t.Fatalf("fakeserver: failed to normalize %v", want)
+	}
+	if !reflect.DeepEqual(got, normalized) {
+		t.Errorf("fakeserver: request %d: expected body field %s = %v, got %v", i, path, normalized, got)
+	}
+}
+
+// lookup 按 "." 分隔的路径查找 JSON 对象中的字段
+func lookup(v map[string]any, path string) (field any, ok bool) {
+	field = v
+	for _, key := range strings.Split(path, ".") {
+		var m map[string]any
+		if m, ok = field.(map[string]any); !ok {
+			return nil, false
+		}
+		if field, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return field, true
+}
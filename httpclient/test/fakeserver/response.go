@@ -0,0 +1,408 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 11:02:58
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 11:02:58
+ * @Description: 按提供商接口格式生成的脚本化响应
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package fakeserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response 脚本化的响应，按提供商的接口格式返回
+type Response struct {
+	Status       int           // HTTP 状态码，默认200，设置了 Error 时默认500
+	Header       http.Header   // 额外的响应头
+	Delay        time.Duration // 返回响应头前的延迟，请求被取消时提前结束
+	RetryAfter   time.Duration // Retry-After 响应头，按秒向上取整
+	Content      string        // 聊天回复内容，流式请求未设置 Chunks 时作为一个数据块返回
+	Chunks       []string      // 流式请求的增量内容，非流式请求未设置 Content 时合并为回复内容
+	ChunkDelay   time.Duration // 流式数据块之间的间隔
+	FinishReason string        // 结束原因，默认 "stop"
+	Usage        *Usage        // token 用量，默认输入10个 token，每个数据块输出1个 token
+	Embedding    []float64     // 每个输入的嵌入向量，默认 [0.1, 0.2, 0.3]
+	StreamError  *Error        // 发送完数据块后以提供商格式返回的流中错误
+	Abort        bool          // 发送完数据块后直接断开连接，模拟网络中断
+	Error        *Error        // 以提供商格式返回的错误，字段为空时使用该状态码对应的提供商默认值
+	Body         string        // 原样返回的 JSON 响应体，设置后忽略其他内容字段
+}
+
+// Usage token 用量
+type Usage struct {
+	PromptTokens     int // 输入 token 数
+	CompletionTokens int // 输出 token 数
+}
+
+// Error 提供商错误
+type Error struct {
+	Status  int    // 流中错误的 HTTP 状态码，默认500，只用于 DashScope 的错误事件
+	Code    string // 错误码，如 OpenAI 的 rate_limit_exceeded、DashScope 的 Throttling.RateQuota
+	Type    string // 错误类型，DashScope 没有该字段
+	Message string // 错误信息
+}
+
+// Reply 返回聊天回复，流式请求时作为一个数据块返回
+func Reply(content string) (resp Response) {
+	return Response{Content: content}
+}
+
+// Stream 返回流式聊天回复，每个参数是一个数据块的增量内容
+func Stream(chunks ...string) (resp Response) {
+	return Response{Chunks: chunks}
+}
+
+// Unauthorized 返回401认证失败
+func Unauthorized() (resp Response) {
+	return Response{Status: http.StatusUnauthorized, Error: &Error{}}
+}
+
+// RateLimited 返回429速率限制，retryAfter 大于0时设置 Retry-After 响应头
+func RateLimited(retryAfter time.Duration) (resp Response) {
+	return Response{Status: http.StatusTooManyRequests, RetryAfter: retryAfter, Error: &Error{}}
+}
+
+// ServerError 返回5xx服务器错误，retryAfter 大于0时设置 Retry-After 响应头
+func ServerError(status int, retryAfter time.Duration) (resp Response) {
+	return Response{Status: status, RetryAfter: retryAfter, Error: &Error{}}
+}
+
+// formatter 按提供商接口格式写入响应
+type formatter struct {
+	provider consts.Provider
+	models   []string // 模型列表接口返回的模型
+	id       int      // 响应ID序号
+}
+
+// write 写入响应
+func (f *formatter) write(w http.ResponseWriter, r *http.Request, req Request, resp Response) {
+	// 延迟返回响应头
+	if !sleep(r.Context(), resp.Delay) {
+		return
+	}
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(resp.RetryAfter.Seconds()))))
+	}
+	switch {
+	case resp.Body != "":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusOr(resp.Status, http.StatusOK))
+		fmt.Fprint(w, resp.Body)
+	case resp.Error != nil:
+		f.writeError(w, resp)
+	case req.Path == pathModels:
+		f.writeJSON(w, resp.Status, f.modelList())
+	case req.Path == pathEmbeddings:
+		f.writeJSON(w, resp.Status, f.embeddings(req, resp))
+	case req.Stream:
+		f.writeStream(w, r, req, resp)
+	default:
+		f.writeJSON(w, resp.Status, f.chat(req, resp, resp.content(), true))
+	}
+}
+
+// writeJSON 写入 JSON 响应
+func (f *formatter) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusOr(status, http.StatusOK))
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 按提供商格式写入错误响应
+func (f *formatter) writeError(w http.ResponseWriter, resp Response) {
+	status := statusOr(resp.Status, http.StatusInternalServerError)
+	f.writeJSON(w, status, f.errorBody(f.fillError(status, resp.Error)))
+}
+
+// writeStream 按提供商格式写入 SSE 流式响应
+func (f *formatter) writeStream(w http.ResponseWriter, r *http.Request, req Request, resp Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(statusOr(resp.Status, http.StatusOK))
+	flusher, _ := w.(http.Flusher)
+	event := 0
+	send := func(v any) {
+		data, _ := json.Marshal(v)
+		event++
+		if f.provider == consts.AliBL {
+			fmt.Fprintf(w, "id:%d\nevent:result\n:HTTP_STATUS/200\ndata:%s\n\n", event, data)
+		} else {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	chunks := resp.chunks()
+	for i, chunk := range chunks {
+		if i > 0 && !sleep(r.Context(), resp.ChunkDelay) {
+			return
+		}
+		// DashScope 的最后一个数据块带有结束原因和用量
+		if f.provider == consts.AliBL && i == len(chunks)-1 && resp.StreamError == nil && !resp.Abort {
+			send(f.chat(req, resp, chunk, true))
+			return
+		}
+		send(f.chat(req, resp, chunk, false))
+	}
+	if !sleep(r.Context(), resp.ChunkDelay) {
+		return
+	}
+	// 网络中断
+	if resp.Abort {
+		panic(http.ErrAbortHandler)
+	}
+	// 流中错误
+	if resp.StreamError != nil {
+		status := statusOr(resp.StreamError.Status, http.StatusInternalServerError)
+		data, _ := json.Marshal(f.errorBody(f.fillError(status, resp.StreamError)))
+		if f.provider == consts.AliBL {
+			fmt.Fprintf(w, "id:%d\nevent:error\n:HTTP_STATUS/%d\ndata:%s\n\n", event+1, status, data)
+		} else {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		return
+	}
+	if f.provider == consts.AliBL {
+		return
+	}
+	// OpenAI 格式的最后一个数据块带有结束原因和用量，之后发送 [DONE]
+	send(f.chat(req, resp, "", true))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// chat 生成聊天响应，流式请求时为一个数据块，final 表示是否带有结束原因和用量
+func (f *formatter) chat(req Request, resp Response, content string, final bool) (v any) {
+	var (
+		finishReason any = "stop"
+		usage            = resp.usage()
+	)
+	if resp.FinishReason != "" {
+		finishReason = resp.FinishReason
+	}
+	if f.provider == consts.AliBL {
+		if !final {
+			finishReason = "null"
+		}
+		message := map[string]any{"role": "assistant", "content": content}
+		if req.Path == pathMultimodal {
+			message["content"] = []map[string]any{{"text": content}}
+		}
+		return map[string]any{
+			"output": map[string]any{
+				"choices": []map[string]any{{"finish_reason": finishReason, "message": message}},
+			},
+			"usage": map[string]any{
+				"input_tokens":  usage.PromptTokens,
+				"output_tokens": usage.CompletionTokens,
+				"total_tokens":  usage.PromptTokens + usage.CompletionTokens,
+			},
+			"request_id": f.requestID(),
+		}
+	}
+
+	body := map[string]any{
+		"id":      fmt.Sprintf("chatcmpl-fake-%d", f.id),
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+	}
+	choice := map[string]any{"index": 0}
+	switch {
+	case !req.Stream:
+		body["object"] = "chat.completion"
+		choice["message"] = map[string]any{"role": "assistant", "content": content}
+		choice["finish_reason"] = finishReason
+	case final:
+		body["object"] = "chat.completion.chunk"
+		choice["delta"] = map[string]any{}
+		choice["finish_reason"] = finishReason
+	default:
+		body["object"] = "chat.completion.chunk"
+		choice["delta"] = map[string]any{"role": "assistant", "content": content}
+		choice["finish_reason"] = nil
+	}
+	body["choices"] = []any{choice}
+	if final {
+		body["usage"] = map[string]any{
+			"prompt_tokens":     usage.PromptTokens,
+			"completion_tokens": usage.CompletionTokens,
+			"total_tokens":      usage.PromptTokens + usage.CompletionTokens,
+		}
+	}
+	return body
+}
+
+// modelList 生成模型列表响应
+func (f *formatter) modelList() (v any) {
+	data := make([]map[string]any, len(f.models))
+	for i, model := range f.models {
+		data[i] = map[string]any{"id": model, "object": "model", "created": 0, "owned_by": "fakeserver"}
+	}
+	return map[string]any{"object": "list", "data": data}
+}
+
+// embeddings 生成嵌入响应
+func (f *formatter) embeddings(req Request, resp Response) (v any) {
+	inputs := 1
+	if list, ok := req.JSON["input"].([]any); ok {
+		inputs = len(list)
+	}
+	embedding := resp.Embedding
+	if embedding == nil {
+		embedding = []float64{0.1, 0.2, 0.3}
+	}
+	data := make([]map[string]any, inputs)
+	for i := range data {
+		data[i] = map[string]any{"object": "embedding", "index": i, "embedding": embedding}
+	}
+	usage := resp.usage()
+	return map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]any{"prompt_tokens": usage.PromptTokens, "total_tokens": usage.PromptTokens},
+	}
+}
+
+// errorBody 生成错误响应体，DashScope 为 {"code","message","request_id"}，其他提供商为 {"error":{...}}
+func (f *formatter) errorBody(e Error) (v any) {
+	if f.provider == consts.AliBL {
+		return map[string]any{"code": e.Code, "message": e.Message, "request_id": f.requestID()}
+	}
+	var code any
+	if e.Code != "" {
+		code = e.Code
+	}
+	return map[string]any{"error": map[string]any{"message": e.Message, "type": e.Type, "param": nil, "code": code}}
+}
+
+// fillError 使用状态码对应的提供商默认值填充错误的空字段
+func (f *formatter) fillError(status int, e *Error) (filled Error) {
+	if e != nil {
+		filled = *e
+	}
+	def := f.defaultError(status)
+	if filled.Code == "" && filled.Type == "" {
+		filled.Code, filled.Type = def.Code, def.Type
+	}
+	if filled.Message == "" {
+		filled.Message = def.Message
+	}
+	return
+}
+
+// defaultError 获取状态码对应的提供商默认错误
+func (f *formatter) defaultError(status int) (e Error) {
+	e.Message = strings.ToLower(http.StatusText(status))
+	switch f.provider {
+	case consts.AliBL:
+		switch {
+		case status == http.StatusUnauthorized:
+			e.Code = "InvalidApiKey"
+		case status == http.StatusTooManyRequests:
+			e.Code = "Throttling.RateQuota"
+		case status == http.StatusServiceUnavailable:
+			e.Code = "ServiceUnavailable"
+		case status == http.StatusNotFound:
+			e.Code = "NotFound"
+		case status >= 500:
+			e.Code = "InternalError"
+		default:
+			e.Code = "InvalidParameter"
+		}
+	case consts.DeepSeek:
+		switch {
+		case status == http.StatusUnauthorized:
+			e.Code, e.Type = "invalid_api_key", "authentication_error"
+		case status == http.StatusTooManyRequests:
+			e.Code, e.Type = "rate_limit_reached", "rate_limit_error"
+		case status >= 500:
+			e.Type = "server_error"
+		default:
+			e.Code, e.Type = "invalid_request_error", "invalid_request_error"
+		}
+	default:
+		switch {
+		case status == http.StatusUnauthorized:
+			e.Code, e.Type = "invalid_api_key", "invalid_request_error"
+		case status == http.StatusTooManyRequests:
+			e.Code, e.Type = "rate_limit_exceeded", "requests"
+		case status == http.StatusServiceUnavailable:
+			e.Code, e.Type = "server_overloaded", "server_error"
+		case status >= 500:
+			e.Type = "server_error"
+		case status == http.StatusNotFound:
+			e.Code, e.Type = "unknown_url", "invalid_request_error"
+		default:
+			e.Type = "invalid_request_error"
+		}
+	}
+	return
+}
+
+// requestID 获取 DashScope 格式的请求ID
+func (f *formatter) requestID() (id string) {
+	return fmt.Sprintf("fake-request-%d", f.id)
+}
+
+// content 获取非流式响应的回复内容
+func (r Response) content() (content string) {
+	if r.Content == "" {
+		return strings.Join(r.Chunks, "")
+	}
+	return r.Content
+}
+
+// chunks 获取流式响应的增量内容
+func (r Response) chunks() (chunks []string) {
+	if len(r.Chunks) == 0 {
+		return []string{r.Content}
+	}
+	return r.Chunks
+}
+
+// usage 获取 token 用量
+func (r Response) usage() (usage Usage) {
+	if r.Usage != nil {
+		return *r.Usage
+	}
+	return Usage{PromptTokens: 10, CompletionTokens: len(r.chunks())}
+}
+
+// sleep 等待指定时间，请求被取消时返回 false
+func sleep(ctx context.Context, d time.Duration) (ok bool) {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// statusOr 零值时返回默认状态码
+func statusOr(status, def int) (s int) {
+	if status == 0 {
+		return def
+	}
+	return status
+}
@@ -0,0 +1,217 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 10:14:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 10:14:36
+ * @Description: 进程内模拟提供商服务器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package fakeserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/liusuxian/go-aisdk/consts"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 各提供商的接口路径，相对于 BaseURL
+const (
+	pathModels          = "/models"
+	pathChatCompletions = "/chat/completions"
+	pathEmbeddings      = "/embeddings"
+	pathTextGeneration  = "/services/aigc/text-generation/generation"
+	pathMultimodal      = "/services/aigc/multimodal-generation/generation"
+)
+
+// defaultModels 各提供商模型列表接口默认返回的模型
+var defaultModels = map[consts.Provider][]string{
+	consts.OpenAI:   {"gpt-4o", "gpt-4o-mini", "text-embedding-3-small"},
+	consts.DeepSeek: {"deepseek-chat", "deepseek-reasoner"},
+}
+
+// Config 模拟服务器配置
+type Config struct {
+	Provider consts.Provider // 模拟的提供商接口格式，支持 consts.OpenAI、consts.DeepSeek、consts.AliBL（DashScope），默认 consts.OpenAI
+	APIKeys  []string        // 允许的API密钥，其他密钥返回401，为空时接受任意密钥
+	Models   []string        // 模型列表接口返回的模型，默认为该提供商的常用模型
+}
+
+// Handler 动态响应函数，脚本队列为空时调用
+type Handler func(req Request) (resp Response)
+
+// Server 进程内模拟提供商服务器，按提供商的接口格式返回脚本化的响应，并记录收到的请求（并发安全）
+//
+//	每个通过认证的请求依次使用 Enqueue 添加的响应，队列为空时调用 SetHandler 设置的函数，都没有时返回 Reply("ok")
+type Server struct {
+	*httptest.Server
+	config   Config
+	basePath string     // BaseURL 的路径部分
+	script   []Response // 待使用的响应
+	handler  Handler
+	requests []Request
+	seq      int // 响应ID序号
+	mu       sync.Mutex
+}
+
+// New 新建并启动模拟提供商服务器，使用完后调用 Close 关闭
+func New(config Config) (s *Server) {
+	if config.Provider == "" {
+		config.Provider = consts.OpenAI
+	}
+	if config.Models == nil {
+		config.Models = defaultModels[config.Provider]
+	}
+	s = &Server{config: config}
+	switch config.Provider {
+	case consts.OpenAI:
+		s.basePath = "/v1"
+	case consts.AliBL:
+		s.basePath = "/api/v1"
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return
+}
+
+// BaseURL 获取提供商配置使用的基础URL
+func (s *Server) BaseURL() (baseURL string) {
+	return s.URL + s.basePath
+}
+
+// Enqueue 按顺序添加后续请求使用的响应
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script = append(s.script, responses...)
+}
+
+// SetHandler 设置脚本队列为空时使用的动态响应函数，如按API密钥返回不同的响应
+func (s *Server) SetHandler(handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handler = handler
+}
+
+// Requests 获取收到的所有请求，包括认证失败的请求
+func (s *Server) Requests() (requests []Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// Reset 清空脚本队列、动态响应函数和收到的请求
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script, s.handler, s.requests = nil, nil, nil
+}
+
+// serveHTTP 处理请求
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := newRequest(r, body, s.basePath)
+	req.Stream = s.isStream(r, req)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	f := &formatter{provider: s.config.Provider, models: s.config.Models}
+	// 认证
+	if req.APIKey == "" || (len(s.config.APIKeys) > 0 && !slices.Contains(s.config.APIKeys, req.APIKey)) {
+		f.writeError(w, Unauthorized())
+		return
+	}
+	if !s.hasRoute(r.Method, req.Path) {
+		f.writeError(w, Response{Status: http.StatusNotFound, Error: &Error{Code: "not_found", Message: "unknown path " + req.Path}})
+		return
+	}
+	resp := s.next(req)
+	f.id = s.nextID()
+	f.write(w, r, req, resp)
+}
+
+// next 获取下一个响应
+func (s *Server) next(req Request) (resp Response) {
+	s.mu.Lock()
+	if len(s.script) > 0 {
+		resp = s.script[0]
+		s.script = s.script[1:]
+		s.mu.Unlock()
+		return
+	}
+	handler := s.handler
+	s.mu.Unlock()
+	if handler != nil {
+		return handler(req)
+	}
+	return Reply("ok")
+}
+
+// nextID 获取下一个响应ID序号
+func (s *Server) nextID() (id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	return s.seq
+}
+
+// hasRoute 判断提供商是否有该接口
+func (s *Server) hasRoute(method, path string) (ok bool) {
+	switch s.config.Provider {
+	case consts.AliBL:
+		return method == http.MethodPost && (path == pathTextGeneration || path == pathMultimodal)
+	case consts.DeepSeek:
+		return (method == http.MethodGet && path == pathModels) || (method == http.MethodPost && path == pathChatCompletions)
+	default:
+		return (method == http.MethodGet && path == pathModels) ||
+			(method == http.MethodPost && (path == pathChatCompletions || path == pathEmbeddings))
+	}
+}
+
+// isStream 判断是否为流式请求，DashScope 通过请求头开启，其他提供商通过请求体的 stream 字段开启
+func (s *Server) isStream(r *http.Request, req Request) (ok bool) {
+	if s.config.Provider == consts.AliBL {
+		return r.Header.Get("X-DashScope-SSE") == "enable"
+	}
+	stream, _ := req.JSON["stream"].(bool)
+	return stream
+}
+
+// Request 模拟服务器收到的请求
+type Request struct {
+	Method string         // 请求方法
+	Path   string         // 相对于 BaseURL 的请求路径
+	Header http.Header    // 请求头
+	Body   []byte         // 请求体
+	JSON   map[string]any // JSON 请求体，不是 JSON 对象时为 nil
+	APIKey string         // Authorization 请求头中的API密钥
+	Model  string         // 请求的模型
+	Stream bool           // 是否为流式请求
+}
+
+// newRequest 新建收到的请求
+func newRequest(r *http.Request, body []byte, basePath string) (req Request) {
+	req = Request{
+		Method: r.Method,
+		Path:   strings.TrimPrefix(r.URL.Path, basePath),
+		Header: r.Header.Clone(),
+		Body:   body,
+		APIKey: strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+	}
+	if len(bytes.TrimSpace(body)) > 0 && json.Unmarshal(body, &req.JSON) == nil {
+		req.Model, _ = req.JSON["model"].(string)
+	}
+	return
+}
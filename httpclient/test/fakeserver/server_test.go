@@ -0,0 +1,137 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 15:06:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 15:06:44
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package fakeserver
+
+import (
+	"encoding/json"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient/test/checks"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// post 发送 POST 请求，返回状态码、响应头和响应体
+func post(t *testing.T, url, apiKey, body string, header ...string) (resp *http.Response, data string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	checks.NoErrorF(t, err)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err = http.DefaultClient.Do(req)
+	checks.NoErrorF(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	checks.NoError(t, err)
+	return resp, string(b)
+}
+
+func TestServer_OpenAIFormat(t *testing.T) {
+	s := New(Config{APIKeys: []string{"sk-1"}})
+	defer s.Close()
+
+	s.Enqueue(Reply("hello"), Stream("Hel", "lo"))
+	resp, body := post(t, s.BaseURL()+"/chat/completions", "sk-1", `{"model":"gpt-4o"}`)
+	var chat struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message      struct{ Content string } `json:"message"`
+			FinishReason string                   `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	checks.NoErrorF(t, json.Unmarshal([]byte(body), &chat))
+	if resp.StatusCode != http.StatusOK || chat.Object != "chat.completion" || chat.Model != "gpt-4o" ||
+		chat.Choices[0].Message.Content != "hello" || chat.Choices[0].FinishReason != "stop" || chat.Usage.TotalTokens != 11 {
+		t.Errorf("unexpected chat response: %s", body)
+	}
+	// 流式响应
+	resp, body = post(t, s.BaseURL()+"/chat/completions", "sk-1", `{"model":"gpt-4o","stream":true}`)
+	if resp.Header.Get("Content-Type") != "text/event-stream" || strings.Count(body, "data: ") != 4 ||
+		!strings.Contains(body, `"content":"Hel"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("unexpected stream response: %s", body)
+	}
+	// 未知的API密钥
+	if resp, body = post(t, s.BaseURL()+"/chat/completions", "sk-2", `{}`); resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, `"code":"invalid_api_key"`) {
+		t.Errorf("expected 401, got %d %s", resp.StatusCode, body)
+	}
+
+	s.AssertRequestCount(t, 3)
+	s.AssertAPIKeysUsed(t, "sk-1", "sk-2")
+	s.AssertBodyField(t, 1, "stream", true)
+	s.AssertHeader(t, -1, "Authorization", "Bearer sk-2")
+}
+
+func TestServer_Errors(t *testing.T) {
+	s := New(Config{Provider: consts.DeepSeek})
+	defer s.Close()
+
+	s.Enqueue(RateLimited(1500*time.Millisecond), ServerError(http.StatusServiceUnavailable, 0), Response{
+		Chunks:      []string{"partial"},
+		StreamError: &Error{Message: "boom"},
+	}, Response{Chunks: []string{"partial"}, Abort: true})
+	resp, body := post(t, s.BaseURL()+"/chat/completions", "sk", `{}`)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" || !strings.Contains(body, `"type":"rate_limit_error"`) {
+		t.Errorf("unexpected rate limit response: %d %v %s", resp.StatusCode, resp.Header, body)
+	}
+	if resp, _ = post(t, s.BaseURL()+"/chat/completions", "sk", `{}`); resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "" {
+		t.Errorf("unexpected server error response: %d %v", resp.StatusCode, resp.Header)
+	}
+	// 流中错误
+	_, body = post(t, s.BaseURL()+"/chat/completions", "sk", `{"stream":true}`)
+	if !strings.Contains(body, `"content":"partial"`) || !strings.HasSuffix(body, `data: {"error":{"code":null,"message":"boom","param":null,"type":"server_error"}}`+"\n\n") {
+		t.Errorf("unexpected stream error response: %s", body)
+	}
+	// 网络中断
+	req, _ := http.NewRequest(http.MethodPost, s.BaseURL()+"/chat/completions", strings.NewReader(`{"stream":true}`))
+	req.Header.Set("Authorization", "Bearer sk")
+	resp, err := http.DefaultClient.Do(req)
+	checks.NoErrorF(t, err)
+	defer resp.Body.Close()
+	if _, err = io.ReadAll(resp.Body); err == nil {
+		t.Error("expected the aborted stream to fail")
+	}
+	// 未知的接口
+	if resp, _ = post(t, s.BaseURL()+"/embeddings", "sk", `{}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected deepseek to have no embeddings api, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_DashScopeFormat(t *testing.T) {
+	s := New(Config{Provider: consts.AliBL})
+	defer s.Close()
+
+	s.SetHandler(func(req Request) (resp Response) {
+		if req.Model == "qwen-max" {
+			return RateLimited(0)
+		}
+		return Response{Chunks: []string{"你", "好"}, ChunkDelay: 10 * time.Millisecond}
+	})
+	_, body := post(t, s.BaseURL()+"/services/aigc/text-generation/generation", "sk", `{"model":"qwen-plus"}`)
+	if !strings.Contains(body, `"content":"你好"`) || !strings.Contains(body, `"output_tokens":2`) || !strings.Contains(body, `"request_id"`) {
+		t.Errorf("unexpected dashscope response: %s", body)
+	}
+	start := time.Now()
+	_, body = post(t, s.BaseURL()+"/services/aigc/text-generation/generation", "sk", `{"model":"qwen-plus"}`, "X-DashScope-SSE", "enable")
+	if time.Since(start) < 10*time.Millisecond || strings.Count(body, "event:result") != 2 ||
+		!strings.Contains(body, `"finish_reason":"null"`) || !strings.Contains(body, `"finish_reason":"stop"`) || strings.Contains(body, "[DONE]") {
+		t.Errorf("unexpected dashscope stream: %s", body)
+	}
+	if _, body = post(t, s.BaseURL()+"/services/aigc/text-generation/generation", "sk", `{"model":"qwen-max"}`); !strings.Contains(body, `"code":"Throttling.RateQuota"`) {
+		t.Errorf("unexpected dashscope error: %s", body)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 10:12:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 16:48:27
+ * @Description: 可配置的长连接 HTTP 传输层
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	defaultDialTimeout         time.Duration = 30 * time.Second // 默认建立连接超时时间
+	defaultKeepAlive           time.Duration = 30 * time.Second // 默认 TCP 保活间隔
+	defaultTLSHandshakeTimeout time.Duration = 10 * time.Second // 默认 TLS 握手超时时间
+	defaultIdleConnTimeout     time.Duration = 90 * time.Second // 默认空闲连接超时时间
+	defaultMaxIdleConns        int           = 100              // 默认最大空闲连接数
+	defaultMaxIdleConnsPerHost int           = 10               // 默认每个主机的最大空闲连接数
+)
+
+var (
+	errInvalidProxy     = errors.New("proxy must be an http, https, socks5 or socks5h url") // 代理地址无效
+	errInvalidCACert    = errors.New("no valid certificates found in ca cert file")         // CA 证书无效
+	errIncompleteClient = errors.New("client cert file and key file must be set together")  // 客户端证书配置不完整
+)
+
+// TransportConfig HTTP 传输层配置，零值字段使用默认值
+type TransportConfig struct {
+	Proxy                 string        // 代理地址，支持 http、https、socks5、socks5h，为空时使用环境变量 HTTP_PROXY、HTTPS_PROXY、NO_PROXY
+	CACertFile            string        // 自定义 CA 证书文件（PEM），追加到系统证书池
+	ClientCertFile        string        // mTLS 客户端证书文件（PEM）
+	ClientKeyFile         string        // mTLS 客户端私钥文件（PEM）
+	InsecureSkipVerify    bool          // 是否跳过服务端证书校验，仅用于测试
+	DialTimeout           time.Duration // 建立连接超时时间，默认30s
+	TLSHandshakeTimeout   time.Duration // TLS 握手超时时间，默认10s
+	ResponseHeaderTimeout time.Duration // 等待响应头超时时间，默认不限制
+	IdleConnTimeout       time.Duration // 空闲连接超时时间，默认90s
+	MaxIdleConns          int           // 最大空闲连接数，默认100
+	MaxIdleConnsPerHost   int           // 每个主机的最大空闲连接数，默认10
+	MaxConnsPerHost       int           // 每个主机的最大连接数，默认不限制
+	DisableHTTP2          bool          // 是否禁用 HTTP/2，默认启用
+}
+
+// NewTransport 根据配置新建 HTTP 传输层，应长期持有以复用连接
+func NewTransport(config TransportConfig) (transport *http.Transport, err error) {
+	transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   durationOr(config.DialTimeout, defaultDialTimeout),
+			KeepAlive: defaultKeepAlive,
+		}).DialContext,
+		TLSHandshakeTimeout:   durationOr(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       durationOr(config.IdleConnTimeout, defaultIdleConnTimeout),
+		MaxIdleConns:          intOr(config.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOr(config.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+		Protocols:             new(http.Protocols),
+	}
+	transport.Protocols.SetHTTP1(true)
+	transport.Protocols.SetHTTP2(!config.DisableHTTP2)
+	// 代理
+	if config.Proxy != "" {
+		var proxyURL *url.URL
+		if proxyURL, err = ParseProxyURL(config.Proxy); err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	// TLS
+	if transport.TLSClientConfig, err = newTLSConfig(config); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ParseProxyURL 解析并校验代理地址
+func ParseProxyURL(proxy string) (proxyURL *url.URL, err error) {
+	if proxyURL, err = url.Parse(proxy); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidProxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, errInvalidProxy
+	}
+	if proxyURL.Host == "" {
+		return nil, errInvalidProxy
+	}
+	return
+}
+
+// newTLSConfig 新建 TLS 配置，没有自定义项时返回 nil 使用默认配置
+func newTLSConfig(config TransportConfig) (tlsConfig *tls.Config, err error) {
+	if config.CACertFile == "" && config.ClientCertFile == "" && config.ClientKeyFile == "" && !config.InsecureSkipVerify {
+		return
+	}
+	tlsConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	// 自定义 CA 证书
+	if config.CACertFile != "" {
+		var pem []byte
+		if pem, err = os.ReadFile(config.CACertFile); err != nil {
+			return nil, fmt.Errorf("failed to read ca cert file: %w", err)
+		}
+		var pool *x509.CertPool
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errInvalidCACert
+		}
+		tlsConfig.RootCAs = pool
+	}
+	// mTLS 客户端证书
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		if config.ClientCertFile == "" || config.ClientKeyFile == "" {
+			return nil, errIncompleteClient
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to load client cert: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// NewHTTPDoerWithTransport 新建使用指定传输层的 HTTP 请求执行器，多个执行器共享同一个传输层时复用连接
+//
+//	如果 timeout 为 0，则表示无超时限制
+func NewHTTPDoerWithTransport(transport http.RoundTripper, timeout time.Duration) (doer *DefaultHTTPDoer) {
+	doer = NewDefaultHTTPDoer(timeout)
+	doer.client.Transport = transport
+	return
+}
+
+// SetTransport 设置传输层，为 nil 时使用 http.DefaultTransport（非并发安全）
+func (doer *DefaultHTTPDoer) SetTransport(transport http.RoundTripper) {
+	doer.client.Transport = transport
+}
+
+// WithTransport 设置本次调用使用的传输层，HTTP 请求执行器不支持设置传输层时忽略（非并发安全）
+func WithTransport(transport http.RoundTripper) (opt HTTPClientOption) {
+	return func(c *HTTPClient) {
+		if setter, ok := c.config.HTTPClient.(interface{ SetTransport(http.RoundTripper) }); ok {
+			setter.SetTransport(transport)
+		}
+	}
+}
+
+// WithHTTPDoer 设置本次调用使用的 HTTP 请求执行器（非并发安全）
+func WithHTTPDoer(doer HTTPDoer) (opt HTTPClientOption) {
+	return func(c *HTTPClient) {
+		c.config.HTTPClient = doer
+	}
+}
+
+// WrapHTTPDoer 包装本次调用使用的 HTTP 请求执行器，如录制或回放请求（非并发安全）
+func WrapHTTPDoer(wrap func(doer HTTPDoer) HTTPDoer) (opt HTTPClientOption) {
+	return func(c *HTTPClient) {
+		c.config.HTTPClient = wrap(c.config.HTTPClient)
+	}
+}
+
+// durationOr 零值时返回默认值
+func durationOr(d, def time.Duration) (v time.Duration) {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// intOr 零值时返回默认值
+func intOr(n, def int) (v int) {
+	if n == 0 {
+		return def
+	}
+	return n
+}
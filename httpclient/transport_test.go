@@ -0,0 +1,142 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 15:40:13
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-28 15:40:13
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	// 默认配置
+	transport, err := NewTransport(TransportConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transport.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost || transport.IdleConnTimeout != defaultIdleConnTimeout {
+		t.Errorf("unexpected defaults: %d, %v", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	if !transport.Protocols.HTTP2() || transport.TLSClientConfig != nil {
+		t.Error("expected HTTP/2 enabled and the default TLS config")
+	}
+	// 禁用 HTTP/2
+	if transport, err = NewTransport(TransportConfig{DisableHTTP2: true, MaxConnsPerHost: 4}); err != nil || transport.Protocols.HTTP2() || transport.MaxConnsPerHost != 4 {
+		t.Errorf("expected HTTP/2 disabled, got %v, %v", transport, err)
+	}
+	// 无效配置
+	for _, config := range []TransportConfig{
+		{Proxy: "ftp://proxy.example.com"},
+		{Proxy: "socks5://"},
+		{ClientCertFile: "cert.pem"},
+		{CACertFile: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err = NewTransport(config); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+	if _, err = NewTransport(TransportConfig{Proxy: "socks5h://127.0.0.1:1080"}); err != nil {
+		t.Errorf("unexpected error for a SOCKS proxy: %v", err)
+	}
+}
+
+func TestTransportCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	defer server.Close()
+
+	// 未信任测试服务器的证书
+	transport, _ := NewTransport(TransportConfig{})
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Fatal("expected a certificate error")
+	}
+	// 信任自定义 CA 证书
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("failed to write ca cert: %v", err)
+	}
+	transport, err := NewTransport(TransportConfig{CACertFile: caFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "HTTP/1.1" {
+		t.Errorf("unexpected protocol: %s", body)
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 通过代理的请求使用绝对 URL
+		if r.URL.Host == "api.example.com" {
+			proxied.Add(1)
+		}
+		w.Write([]byte(`{"message":"via proxy"}`))
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(TransportConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := NewHTTPClient("http://api.example.com")
+	WithTransport(transport)(client)
+	for range 2 {
+		req, _ := client.NewRequest(context.Background(), http.MethodGet, client.FullURL("/test"))
+		var resp testResponse
+		if err = client.SendRequest(req, &resp); err != nil || resp.Message != "via proxy" {
+			t.Fatalf("unexpected response: %+v, %v", resp, err)
+		}
+	}
+	if proxied.Load() != 2 {
+		t.Errorf("expected 2 proxied requests, got %d", proxied.Load())
+	}
+}
+
+// stubDoer 返回固定错误的 HTTP 请求执行器
+type stubDoer struct{ err error }
+
+func (d *stubDoer) SetTimeout(timeout time.Duration) {}
+
+func (d *stubDoer) Do(req *http.Request) (resp *http.Response, err error) { return nil, d.err }
+
+func TestPerCallTransportOptions(t *testing.T) {
+	transport, _ := NewTransport(TransportConfig{})
+	shared := NewHTTPDoerWithTransport(transport, time.Second)
+	// 调用选项只影响本次调用的执行器
+	client := NewHTTPClientWithConfig(HTTPClientConfig{HTTPClient: NewHTTPDoerWithTransport(transport, time.Second)})
+	WithTimeout(time.Minute)(client)
+	if shared.client.Timeout != time.Second || client.config.HTTPClient.(*DefaultHTTPDoer).client.Timeout != time.Minute {
+		t.Error("expected the timeout option to only affect this call")
+	}
+	// 替换执行器
+	errStub := errors.New("stub")
+	WithHTTPDoer(&stubDoer{err: errStub})(client)
+	req, _ := client.NewRequest(context.Background(), http.MethodGet, "http://api.example.com")
+	if err := client.SendRequest(req, nil); !errors.Is(err, errStub) {
+		t.Errorf("expected the stub error, got %v", err)
+	}
+	// 不支持设置传输层的执行器忽略该选项
+	WithTransport(transport)(client)
+}
@@ -0,0 +1,59 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 09:42:11
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 09:42:11
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import "sync"
+
+// TokenUsage token使用量
+type TokenUsage struct {
+	InputTokens       int `json:"input_tokens"`        // 输入token数
+	CachedInputTokens int `json:"cached_input_tokens"` // 输入中命中缓存的token数
+	OutputTokens      int `json:"output_tokens"`       // 输出token数
+	ReasoningTokens   int `json:"reasoning_tokens"`    // 输出中用于推理的token数
+	TotalTokens       int `json:"total_tokens"`        // 总token数
+}
+
+// TokenUsageReporter 可以报告token使用量的响应
+type TokenUsageReporter interface {
+	GetTokenUsage() (usage TokenUsage, ok bool) // 获取token使用量，响应中没有使用量信息时 ok 为 false
+}
+
+// TokenUsageRecorder token使用量记录器，指标收集器可选实现该接口
+type TokenUsageRecorder interface {
+	RecordTokenUsage(provider, modelType, model, method string, usage TokenUsage) // 记录token使用量
+}
+
+// StreamObservable 可观察的流式传输，用于在不依赖数据类型的情况下观察流式传输过程
+type StreamObservable interface {
+	AddObserver(onChunk func(chunk any), onClose func(err error)) // 添加观察者
+}
+
+// ObserveStreamUsage 观察流式传输的token使用量，流式传输结束时回调最后一次报告的使用量
+func ObserveStreamUsage(stream StreamObservable, onFinish func(usage TokenUsage, ok bool, err error)) {
+	var (
+		mu       sync.Mutex
+		usage    TokenUsage
+		hasUsage bool
+	)
+	stream.AddObserver(func(chunk any) {
+		if reporter, ok := chunk.(TokenUsageReporter); ok {
+			if u, ok := reporter.GetTokenUsage(); ok {
+				mu.Lock()
+				usage, hasUsage = u, true
+				mu.Unlock()
+			}
+		}
+	}, func(err error) {
+		mu.Lock()
+		u, ok := usage, hasUsage
+		mu.Unlock()
+		onFinish(u, ok, err)
+	})
+}
@@ -0,0 +1,158 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 16:12:25
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 16:12:25
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/flake"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/httpclient/test/checks"
+	"github.com/liusuxian/go-aisdk/httpclient/test/fakeserver"
+	"github.com/liusuxian/go-aisdk/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeClient 创建请求模拟提供商服务器的客户端
+func newFakeClient(t *testing.T, server *fakeserver.Server, provider consts.Provider, apiKeys []string, opts ...SDKClientOption) (client *SDKClient) {
+	t.Helper()
+	// 测试环境可能没有私有IP地址，使用固定的机器ID
+	opts = append([]SDKClientOption{WithFlakeSettings(flake.Settings{
+		MachineID: func() (n int, err error) { return 1, nil },
+	})}, opts...)
+	client, err := NewSDKClientWithConfig(conf.SDKConfig{Providers: map[string]conf.ProviderConfig{
+		string(provider): {BaseURL: server.BaseURL(), APIKeys: apiKeys},
+	}}, opts...)
+	checks.NoErrorF(t, err)
+	t.Cleanup(func() { client.Close() })
+	return
+}
+
+// chatRequest 创建聊天请求
+func chatRequest(provider consts.Provider, model string) (request models.ChatRequest) {
+	return models.ChatRequest{
+		UserInfo: models.UserInfo{User: "tester"},
+		Provider: provider,
+		Model:    model,
+		Messages: []models.ChatMessage{&models.UserMessage{Content: "hi"}},
+	}
+}
+
+// readStream 读取流式聊天的全部增量内容
+func readStream(stream models.ChatResponseStream) (content string, err error) {
+	var sb strings.Builder
+	err = stream.ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil {
+				sb.WriteString(choice.Delta.Content)
+			}
+		}
+		return
+	})
+	return sb.String(), err
+}
+
+func TestFakeServer_RetryAfterErrors(t *testing.T) {
+	server := fakeserver.New(fakeserver.Config{})
+	defer server.Close()
+	client := newFakeClient(t, server, consts.OpenAI, []string{"sk-test"}, WithRetry(httpclient.RetryMiddlewareConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}))
+
+	server.Enqueue(fakeserver.ServerError(http.StatusInternalServerError, 0), fakeserver.ServerError(http.StatusBadGateway, 0), fakeserver.Reply("hello"))
+	response, err := client.CreateChatCompletion(context.Background(), chatRequest(consts.OpenAI, consts.OpenAIGPT4o))
+	checks.NoErrorF(t, err)
+	if content := response.Choices[0].Message.Content; content != "hello" {
+		t.Errorf("expected hello, got %q", content)
+	}
+	server.AssertRequestCount(t, 3)
+	server.AssertBodyField(t, -1, "model", consts.OpenAIGPT4o)
+	server.AssertBodyField(t, -1, "messages", []map[string]string{{"role": "user", "content": "hi"}})
+
+	// 速率限制的错误分类和 Retry-After，不重试避免API密钥冷却后没有可用的密钥
+	server.Reset()
+	server.Enqueue(fakeserver.RateLimited(2 * time.Second))
+	client = newFakeClient(t, server, consts.OpenAI, []string{"sk-test"})
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(consts.OpenAI, consts.OpenAIGPT4o))
+	if !errors.IsRateLimitedError(err) || errors.RetryAfter(err) != 2*time.Second {
+		t.Errorf("expected a rate limited error with retry after, got %v", err)
+	}
+}
+
+func TestFakeServer_KeyBalancing(t *testing.T) {
+	server := fakeserver.New(fakeserver.Config{Provider: consts.DeepSeek, APIKeys: []string{"sk-1", "sk-2"}})
+	defer server.Close()
+	client := newFakeClient(t, server, consts.DeepSeek, []string{"sk-1", "sk-2"}, WithRetry(httpclient.RetryMiddlewareConfig{
+		MaxAttempts: 2,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}))
+	// sk-1 被限流后冷却，之后的请求都使用 sk-2
+	server.SetHandler(func(req fakeserver.Request) (resp fakeserver.Response) {
+		if req.APIKey == "sk-1" {
+			return fakeserver.RateLimited(time.Minute)
+		}
+		return fakeserver.Reply("ok")
+	})
+	for range 6 {
+		_, err := client.CreateChatCompletion(context.Background(), chatRequest(consts.DeepSeek, consts.DeepSeekChat))
+		checks.NoError(t, err)
+	}
+	server.AssertAPIKeysUsed(t, "sk-1", "sk-2")
+	var limited int
+	for _, req := range server.Requests() {
+		if req.APIKey == "sk-1" {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("expected the rate limited key to be used once, got %d", limited)
+	}
+}
+
+func TestFakeServer_Streaming(t *testing.T) {
+	// DashScope 格式的流式传输
+	dashscope := fakeserver.New(fakeserver.Config{Provider: consts.AliBL})
+	defer dashscope.Close()
+	client := newFakeClient(t, dashscope, consts.AliBL, []string{"sk-test"})
+
+	dashscope.Enqueue(fakeserver.Response{Chunks: []string{"你", "好", "！"}, ChunkDelay: 5 * time.Millisecond})
+	stream, err := client.CreateChatCompletionStream(context.Background(), chatRequest(consts.AliBL, consts.AliBLQwenPlus))
+	checks.NoErrorF(t, err)
+	if content, err := readStream(stream); err != nil || content != "你好！" {
+		t.Errorf("unexpected dashscope stream %q: %v", content, err)
+	}
+	dashscope.AssertHeader(t, -1, "X-DashScope-SSE", "enable")
+
+	// 流中错误和网络中断
+	openai := fakeserver.New(fakeserver.Config{})
+	defer openai.Close()
+	client = newFakeClient(t, openai, consts.OpenAI, []string{"sk-test"})
+
+	openai.Enqueue(
+		fakeserver.Response{Chunks: []string{"partial"}, StreamError: &fakeserver.Error{Code: "server_error", Message: "stream failed"}},
+		fakeserver.Response{Chunks: []string{"partial"}, Abort: true},
+	)
+	for _, name := range []string{"stream error", "abort"} {
+		stream, err = client.CreateChatCompletionStream(context.Background(), chatRequest(consts.OpenAI, consts.OpenAIGPT4o))
+		checks.NoErrorF(t, err)
+		if content, err := readStream(stream); err == nil || content != "partial" {
+			t.Errorf("%s: expected an error after the partial content, got %q: %v", name, content, err)
+		}
+	}
+	openai.AssertBodyField(t, -1, "stream", true)
+}
@@ -0,0 +1,109 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-27 10:05:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 10:12:36
+ * @Description: 已知密钥的脱敏
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package redact
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	Mask      = "***" // 脱敏后的占位符
+	minLength = 8     // 注册密钥的最小长度，过短的值容易误伤普通文本
+)
+
+var (
+	secrets  = make(map[string]int)           // 已注册的密钥及持有它的注册数
+	mu       sync.Mutex                       // 串行化注册和重建替换器
+	dirty    atomic.Bool                      // 已注册的密钥是否变化，变化后在下次脱敏时重建替换器
+	replacer atomic.Pointer[strings.Replacer] // 替换已注册密钥的替换器
+)
+
+// Registration 一组已注册的密钥，由配置的一个版本或一个租户持有，不再使用时调用 Release
+type Registration struct {
+	values []string
+	once   sync.Once
+}
+
+// Register 注册需要脱敏的密钥，长度小于8的值会被忽略；同一密钥可被多次注册，所有注册都释放后才不再脱敏
+func Register(values ...string) (r *Registration) {
+	r = &Registration{}
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, value := range values {
+		if len(value) < minLength || slices.Contains(r.values, value) {
+			continue
+		}
+		r.values = append(r.values, value)
+		if secrets[value]++; secrets[value] == 1 {
+			dirty.Store(true)
+		}
+	}
+	return
+}
+
+// Release 释放注册的密钥，重复调用无效果
+func (r *Registration) Release() {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, value := range r.values {
+			if secrets[value]--; secrets[value] <= 0 {
+				delete(secrets, value)
+				dirty.Store(true)
+			}
+		}
+	})
+}
+
+// String 将 s 中已注册的密钥替换为占位符
+func String(s string) (redacted string) {
+	if r := load(); r != nil {
+		return r.Replace(s)
+	}
+	return s
+}
+
+// load 获取替换器，已注册的密钥变化后重建
+func load() (r *strings.Replacer) {
+	if !dirty.Load() {
+		return replacer.Load()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dirty.Load() {
+		replacer.Store(build())
+		dirty.Store(false)
+	}
+	return replacer.Load()
+}
+
+// build 创建替换已注册密钥的替换器，没有已注册的密钥时返回 nil，调用方需持有锁
+func build() (r *strings.Replacer) {
+	if len(secrets) == 0 {
+		return nil
+	}
+	// 较长的密钥优先替换，避免只替换掉包含它的密钥的一部分
+	sorted := slices.SortedFunc(maps.Keys(secrets), func(a, b string) int { return len(b) - len(a) })
+	oldnew := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		oldnew = append(oldnew, value, Mask)
+	}
+	return strings.NewReplacer(oldnew...)
+}
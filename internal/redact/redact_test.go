@@ -0,0 +1,37 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-04 10:20:14
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 10:20:14
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package redact
+
+import (
+	"testing"
+)
+
+func TestRegistration(t *testing.T) {
+	var (
+		first  = Register("sk-shared-secret", "sk-first-secret", "short")
+		second = Register("sk-shared-secret")
+	)
+	if got := String("sk-shared-secret sk-first-secret short"); got != Mask+" "+Mask+" short" {
+		t.Errorf("unexpected redacted string: %q", got)
+	}
+	// 仍被其他注册持有的密钥继续脱敏
+	first.Release()
+	first.Release()
+	if got := String("sk-shared-secret sk-first-secret"); got != Mask+" sk-first-secret" {
+		t.Errorf("unexpected redacted string after release: %q", got)
+	}
+	second.Release()
+	if got := String("sk-shared-secret"); got != "sk-shared-secret" {
+		t.Errorf("expected all secrets to be released, got %q", got)
+	}
+	if len(secrets) != 0 {
+		t.Errorf("expected the registry to be empty, got %d secrets", len(secrets))
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 14:31:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-19 14:31:09
+ * @Description: 根据 Go 结构体生成工具定义和响应格式
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package jsonschema
+
+import (
+	"github.com/liusuxian/go-aisdk/models"
+)
+
+// Tool 根据参数类型 T 生成函数工具定义，strict 为 true 时生成 OpenAI 严格模式的 Schema
+func Tool[T any](name, description string, strict bool) (tool models.ChatTool, err error) {
+	var schema map[string]any
+	if schema, err = GenerateFor[T](Config{Strict: strict}); err != nil {
+		return
+	}
+	tool = models.ChatTool{
+		Type: models.ToolTypeFunction,
+		Function: &models.ChatToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  schema,
+		},
+	}
+	if strict {
+		tool.Function.Strict = models.Bool(true)
+	}
+	return
+}
+
+// ResponseFormat 根据类型 T 生成 json_schema 类型的响应格式，strict 为 true 时生成 OpenAI 严格模式的 Schema
+func ResponseFormat[T any](name, description string, strict bool) (format models.ChatResponseFormat, err error) {
+	var schema map[string]any
+	if schema, err = GenerateFor[T](Config{Strict: strict}); err != nil {
+		return
+	}
+	format = models.ChatResponseFormat{
+		Type: models.ChatResponseFormatTypeJSONSchema,
+		JSONSchema: &models.ChatResponseFormatJSONSchema{
+			Name:        name,
+			Description: description,
+			Schema:      schema,
+		},
+	}
+	if strict {
+		format.JSONSchema.Strict = models.Bool(true)
+	}
+	return
+}
@@ -0,0 +1,496 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 10:05:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-19 10:05:42
+ * @Description: 根据 Go 结构体生成 JSON Schema
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 类型名称
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+var (
+	errNilType           = errors.New("cannot generate schema for nil type")             // 类型为空
+	errUnsupportedType   = errors.New("unsupported type")                                // 不支持的类型
+	errRootNotObject     = errors.New("root schema must be an object")                   // 根节点必须是对象
+	errStrictMap         = errors.New("maps are not supported in strict mode")           // 严格模式不支持 map
+	errStrictAny         = errors.New("untyped values are not supported in strict mode") // 严格模式不支持无类型的值
+	errInvalidTag        = errors.New("invalid jsonschema tag")                          // jsonschema 标签无效
+	errSchemaerNilResult = errors.New("JSONSchema method returned nil")                  // JSONSchema 方法返回空
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()       // time.Time 类型
+	rawMessageType    = reflect.TypeFor[json.RawMessage]() // json.RawMessage 类型
+	schemaerType      = reflect.TypeFor[Schemaer]()        // Schemaer 接口类型
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()  // json.Marshaler 接口类型
+)
+
+// Schemaer 自定义 JSON Schema 的类型实现此接口后，生成器直接使用其返回的 Schema
+type Schemaer interface {
+	JSONSchema() (schema map[string]any)
+}
+
+// Config 生成配置
+type Config struct {
+	// 是否生成 OpenAI 严格模式的 Schema：所有对象禁止额外属性，所有字段均为必填，可选字段（指针）允许为 null
+	Strict bool
+}
+
+// Generate 根据 v 的类型生成 JSON Schema，v 必须是结构体或结构体指针
+//
+// 字段名称遵循 json 标签，json:"-" 的字段会被忽略，指针字段为可选字段，其余字段为必填字段。
+// jsonschema 标签使用逗号分隔的选项，值中的逗号需转义为 "\,"：
+//
+//	description=字段描述
+//	title=字段标题
+//	enum=可选值，可重复出现
+//	format=字符串格式，如 date-time、email
+//	required / optional 强制字段为必填或可选
+func Generate(v any, config Config) (schema map[string]any, err error) {
+	if v == nil {
+		return nil, errNilType
+	}
+	return GenerateType(reflect.TypeOf(v), config)
+}
+
+// GenerateFor 根据类型 T 生成 JSON Schema
+func GenerateFor[T any](config Config) (schema map[string]any, err error) {
+	return GenerateType(reflect.TypeFor[T](), config)
+}
+
+// GenerateType 根据类型 t 生成 JSON Schema
+func GenerateType(t reflect.Type, config Config) (schema map[string]any, err error) {
+	if t == nil {
+		return nil, errNilType
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("%w: %s", errRootNotObject, t)
+	}
+	g := &generator{
+		config:    config,
+		root:      t,
+		defs:      make(map[string]any),
+		names:     make(map[reflect.Type]string),
+		inProcess: make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+	}
+	if schema, err = g.schemaOf(t); err != nil {
+		return nil, err
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return
+}
+
+// generator Schema 生成器
+type generator struct {
+	config    Config
+	root      reflect.Type            // 根类型
+	defs      map[string]any          // 递归类型的定义
+	names     map[reflect.Type]string // 递归类型在 $defs 中的名称
+	inProcess map[reflect.Type]bool   // 正在生成的结构体类型，用于检测递归
+	recursive map[reflect.Type]bool   // 递归引用的结构体类型
+}
+
+// schemaOf 生成类型的 Schema
+func (g *generator) schemaOf(t reflect.Type) (schema map[string]any, err error) {
+	if t.Implements(schemaerType) {
+		return g.custom(reflect.Zero(t))
+	}
+	if reflect.PointerTo(t).Implements(schemaerType) {
+		return g.custom(reflect.New(t))
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": TypeString, "format": "date-time"}, nil
+	case t == rawMessageType:
+		return g.anySchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": TypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": TypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": TypeNumber}, nil
+	case reflect.String:
+		return map[string]any{"type": TypeString}, nil
+	case reflect.Interface:
+		return g.anySchema()
+	case reflect.Slice, reflect.Array:
+		// []byte 会被 encoding/json 编码为 base64 字符串
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && !t.Implements(jsonMarshalerType) {
+			return map[string]any{"type": TypeString, "contentEncoding": "base64"}, nil
+		}
+		var items map[string]any
+		if items, err = g.schemaOf(t.Elem()); err != nil {
+			return
+		}
+		schema = map[string]any{"type": TypeArray, "items": items}
+		if t.Kind() == reflect.Array && !g.config.Strict {
+			schema["minItems"] = t.Len()
+			schema["maxItems"] = t.Len()
+		}
+		return
+	case reflect.Map:
+		if g.config.Strict {
+			return nil, fmt.Errorf("%w: %s", errStrictMap, t)
+		}
+		if k := t.Key().Kind(); k != reflect.String && !(k >= reflect.Int && k <= reflect.Uint64) {
+			return nil, fmt.Errorf("%w: map key %s", errUnsupportedType, t.Key())
+		}
+		var values map[string]any
+		if values, err = g.schemaOf(t.Elem()); err != nil {
+			return
+		}
+		return map[string]any{"type": TypeObject, "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedType, t)
+}
+
+// custom 使用 Schemaer 接口返回的 Schema
+func (g *generator) custom(v reflect.Value) (schema map[string]any, err error) {
+	if schema = v.Interface().(Schemaer).JSONSchema(); schema == nil {
+		return nil, fmt.Errorf("%w: %s", errSchemaerNilResult, v.Type())
+	}
+	return
+}
+
+// anySchema 任意类型的 Schema
+func (g *generator) anySchema() (schema map[string]any, err error) {
+	if g.config.Strict {
+		return nil, errStrictAny
+	}
+	return map[string]any{}, nil
+}
+
+// structSchema 生成结构体的 Schema，递归引用的结构体放入 $defs
+func (g *generator) structSchema(t reflect.Type) (schema map[string]any, err error) {
+	if g.inProcess[t] {
+		g.recursive[t] = true
+		return map[string]any{"$ref": g.ref(t)}, nil
+	}
+	g.inProcess[t] = true
+	defer delete(g.inProcess, t)
+
+	var (
+		properties = make(map[string]any)
+		required   = make([]string, 0)
+	)
+	if err = g.fields(t, properties, &required, map[string]bool{}); err != nil {
+		return
+	}
+	schema = map[string]any{
+		"type":       TypeObject,
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if g.config.Strict {
+		schema["additionalProperties"] = false
+	}
+	if g.recursive[t] && t != g.root {
+		name := g.defName(t)
+		g.defs[name] = schema
+		return map[string]any{"$ref": g.ref(t)}, nil
+	}
+	return
+}
+
+// fields 收集结构体字段，匿名结构体字段按 encoding/json 的规则展开
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string, seen map[string]bool) (err error) {
+	var embeddeds []reflect.Type
+	for i := range t.NumField() {
+		field := t.Field(i)
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(jsonTag, ",")
+		fieldType := field.Type
+		// 未命名的匿名结构体字段展开到当前对象
+		if field.Anonymous && name == "" {
+			embedded := fieldType
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				embeddeds = append(embeddeds, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var opts tagOptions
+		if opts, err = parseTag(field.Tag.Get("jsonschema")); err != nil {
+			return fmt.Errorf("%s.%s: %w", t, field.Name, err)
+		}
+		var prop map[string]any
+		if prop, err = g.schemaOf(fieldType); err != nil {
+			return fmt.Errorf("%s.%s: %w", t, field.Name, err)
+		}
+		if prop, err = opts.apply(prop, fieldType); err != nil {
+			return fmt.Errorf("%s.%s: %w", t, field.Name, err)
+		}
+		optional := fieldType.Kind() == reflect.Pointer
+		if opts.required {
+			optional = false
+		} else if opts.optional {
+			optional = true
+		}
+		if g.config.Strict {
+			// 严格模式要求所有字段必填，可选字段通过允许 null 表示
+			if optional {
+				prop = nullable(prop)
+			}
+			*required = append(*required, name)
+		} else if !optional {
+			*required = append(*required, name)
+		}
+		properties[name] = prop
+	}
+	// 外层字段优先于展开的匿名字段，因此匿名字段最后处理
+	for _, embedded := range embeddeds {
+		if err = g.fields(embedded, properties, required, seen); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ref 递归类型的引用路径
+func (g *generator) ref(t reflect.Type) (ref string) {
+	if t == g.root {
+		return "#"
+	}
+	return "#/$defs/" + g.defName(t)
+}
+
+// defName 递归类型在 $defs 中的名称，名称冲突时追加序号
+func (g *generator) defName(t reflect.Type) (name string) {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	base := t.Name()
+	if base == "" {
+		base = "def"
+	}
+	name = base
+	for i := 2; ; i++ {
+		if !g.nameTaken(name) {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	g.names[t] = name
+	return
+}
+
+// nameTaken 名称是否已被使用
+func (g *generator) nameTaken(name string) (ok bool) {
+	for _, n := range g.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nullable 允许 Schema 的值为 null
+func nullable(schema map[string]any) (result map[string]any) {
+	switch typ := schema["type"].(type) {
+	case string:
+		result = make(map[string]any, len(schema))
+		for k, v := range schema {
+			result[k] = v
+		}
+		result["type"] = []string{typ, TypeNull}
+		if enum, ok := schema["enum"].([]any); ok {
+			result["enum"] = append(append([]any{}, enum...), nil)
+		}
+		return
+	default:
+		// $ref 或自定义 Schema 无法直接添加 null 类型
+		return map[string]any{"anyOf": []any{schema, map[string]any{"type": TypeNull}}}
+	}
+}
+
+// tagOptions jsonschema 标签选项
+type tagOptions struct {
+	description string
+	title       string
+	format      string
+	enum        []string
+	required    bool
+	optional    bool
+}
+
+// parseTag 解析 jsonschema 标签
+func parseTag(tag string) (opts tagOptions, err error) {
+	if tag == "" {
+		return
+	}
+	for _, part := range splitTag(tag) {
+		key, value, hasValue := strings.Cut(part, "=")
+		switch strings.TrimSpace(key) {
+		case "description":
+			opts.description = value
+		case "title":
+			opts.title = value
+		case "format":
+			opts.format = value
+		case "enum":
+			opts.enum = append(opts.enum, value)
+		case "required":
+			opts.required = true
+		case "optional":
+			opts.optional = true
+		case "":
+			continue
+		default:
+			return opts, fmt.Errorf("%w: unknown option %q", errInvalidTag, key)
+		}
+		if !hasValue && key != "required" && key != "optional" {
+			return opts, fmt.Errorf("%w: option %q requires a value", errInvalidTag, key)
+		}
+	}
+	if opts.required && opts.optional {
+		err = fmt.Errorf("%w: required and optional are mutually exclusive", errInvalidTag)
+	}
+	return
+}
+
+// splitTag 按未转义的逗号拆分标签
+func splitTag(tag string) (parts []string) {
+	var sb strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			sb.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(tag[i])
+		}
+	}
+	return append(parts, sb.String())
+}
+
+// apply 将标签选项应用到 Schema
+func (opts tagOptions) apply(schema map[string]any, t reflect.Type) (result map[string]any, err error) {
+	if opts.description == "" && opts.title == "" && opts.format == "" && len(opts.enum) == 0 {
+		return schema, nil
+	}
+	if _, ok := schema["$ref"]; ok {
+		// $ref 不能与其他关键字并列，使用 allOf 包装
+		schema = map[string]any{"allOf": []any{schema}}
+	} else {
+		result = make(map[string]any, len(schema)+3)
+		for k, v := range schema {
+			result[k] = v
+		}
+		schema = result
+	}
+	if opts.description != "" {
+		schema["description"] = opts.description
+	}
+	if opts.title != "" {
+		schema["title"] = opts.title
+	}
+	if opts.format != "" {
+		schema["format"] = opts.format
+	}
+	if len(opts.enum) > 0 {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		// 切片字段的枚举值作用于元素
+		target := schema
+		if items, ok := schema["items"].(map[string]any); ok && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			target = make(map[string]any, len(items)+1)
+			for k, v := range items {
+				target[k] = v
+			}
+			schema["items"] = target
+			for t = t.Elem(); t.Kind() == reflect.Pointer; {
+				t = t.Elem()
+			}
+		}
+		enum := make([]any, 0, len(opts.enum))
+		for _, s := range opts.enum {
+			var value any
+			if value, err = parseEnumValue(s, t); err != nil {
+				return
+			}
+			enum = append(enum, value)
+		}
+		target["enum"] = enum
+	}
+	return schema, nil
+}
+
+// parseEnumValue 按字段类型解析枚举值
+func parseEnumValue(s string, t reflect.Type) (value any, err error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: enum value %q is not an integer", errInvalidTag, s)
+		}
+	case reflect.Float32, reflect.Float64:
+		if value, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("%w: enum value %q is not a number", errInvalidTag, s)
+		}
+	case reflect.Bool:
+		if value, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("%w: enum value %q is not a boolean", errInvalidTag, s)
+		}
+	default:
+		value = s
+	}
+	return
+}
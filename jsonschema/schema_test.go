@@ -0,0 +1,169 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 15:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-19 15:12:36
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/liusuxian/go-aisdk/models"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string  `json:"city" jsonschema:"description=City name\\, e.g. Paris"`
+	Zip  *string `json:"zip,omitempty"`
+}
+
+type base struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type person struct {
+	base
+	Name     string            `json:"name" jsonschema:"title=Full name"`
+	Age      int               `json:"age" jsonschema:"enum=18,enum=30"`
+	Unit     string            `json:"unit" jsonschema:"enum=celsius,enum=fahrenheit"`
+	Tags     []string          `json:"tags" jsonschema:"enum=a,enum=b"`
+	Address  *address          `json:"address,omitempty"`
+	Homes    []address         `json:"homes"`
+	Meta     map[string]int    `json:"meta,omitempty" jsonschema:"optional"`
+	Birthday time.Time         `json:"birthday"`
+	Score    *float64          `json:"score" jsonschema:"required"`
+	Ignored  string            `json:"-"`
+	internal string            // 未导出字段会被忽略
+	Labels   map[string]string `json:"-"`
+}
+
+type node struct {
+	Value    string  `json:"value"`
+	Children []*node `json:"children"`
+}
+
+type tree struct {
+	Root *node `json:"root"`
+}
+
+// marshal 序列化 Schema，map 的键按字母顺序排列
+func marshal(t *testing.T, schema any) (s string) {
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(b)
+}
+
+func TestGenerate(t *testing.T) {
+	schema, err := Generate(person{}, Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"properties":{` +
+		`"address":{"properties":{"city":{"description":"City name, e.g. Paris","type":"string"},"zip":{"type":"string"}},"required":["city"],"type":"object"},` +
+		`"age":{"enum":[18,30],"type":"integer"},` +
+		`"birthday":{"format":"date-time","type":"string"},` +
+		`"homes":{"items":{"properties":{"city":{"description":"City name, e.g. Paris","type":"string"},"zip":{"type":"string"}},"required":["city"],"type":"object"},"type":"array"},` +
+		`"id":{"type":"string"},` +
+		`"meta":{"additionalProperties":{"type":"integer"},"type":"object"},` +
+		`"name":{"title":"Full name","type":"string"},` +
+		`"score":{"type":"number"},` +
+		`"tags":{"items":{"enum":["a","b"],"type":"string"},"type":"array"},` +
+		`"unit":{"enum":["celsius","fahrenheit"],"type":"string"}},` +
+		`"required":["name","age","unit","tags","homes","birthday","score","id"],"type":"object"}`
+	if got := marshal(t, schema); got != want {
+		t.Fatalf("unexpected schema:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestGenerateStrict(t *testing.T) {
+	if _, err := Generate(&person{}, Config{Strict: true}); !errors.Is(err, errStrictMap) {
+		t.Fatalf("expected errStrictMap, got %v", err)
+	}
+
+	schema, err := GenerateFor[address](Config{Strict: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"additionalProperties":false,"properties":{"city":{"description":"City name, e.g. Paris","type":"string"},"zip":{"type":["string","null"]}},"required":["city","zip"],"type":"object"}`
+	if got := marshal(t, schema); got != want {
+		t.Fatalf("unexpected schema:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestGenerateRecursive(t *testing.T) {
+	schema, err := GenerateFor[tree](Config{Strict: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"$defs":{"node":{"additionalProperties":false,"properties":{"children":{"items":{"$ref":"#/$defs/node"},"type":"array"},"value":{"type":"string"}},"required":["value","children"],"type":"object"}},` +
+		`"additionalProperties":false,"properties":{"root":{"anyOf":[{"$ref":"#/$defs/node"},{"type":"null"}]}},"required":["root"],"type":"object"}`
+	if got := marshal(t, schema); got != want {
+		t.Fatalf("unexpected schema:\n got: %s\nwant: %s", got, want)
+	}
+
+	// 根类型递归引用自身
+	if schema, err = GenerateFor[node](Config{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := marshal(t, schema["properties"].(map[string]any)["children"]); got != `{"items":{"$ref":"#"},"type":"array"}` {
+		t.Fatalf("unexpected children schema: %s", got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	type badEnum struct {
+		N int `json:"n" jsonschema:"enum=x"`
+	}
+	type badTag struct {
+		S string `json:"s" jsonschema:"minimum=1"`
+	}
+	type untyped struct {
+		V any `json:"v"`
+	}
+	tests := []struct {
+		v      any
+		config Config
+		want   error
+	}{
+		{nil, Config{}, errNilType},
+		{"string", Config{}, errRootNotObject},
+		{badEnum{}, Config{}, errInvalidTag},
+		{badTag{}, Config{}, errInvalidTag},
+		{untyped{}, Config{Strict: true}, errStrictAny},
+	}
+	for _, tt := range tests {
+		if _, err := Generate(tt.v, tt.config); !errors.Is(err, tt.want) {
+			t.Errorf("%T: expected %v, got %v", tt.v, tt.want, err)
+		}
+	}
+	if _, err := Generate(untyped{}, Config{}); err != nil {
+		t.Errorf("untyped values should be allowed outside strict mode: %v", err)
+	}
+}
+
+func TestToolAndResponseFormat(t *testing.T) {
+	tool, err := Tool[address]("lookup_address", "Look up an address", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tool.Type != models.ToolTypeFunction || tool.Function.Name != "lookup_address" || !models.BoolValue(tool.Function.Strict) ||
+		tool.Function.Parameters["additionalProperties"] != false {
+		t.Fatalf("unexpected tool: %+v", tool.Function)
+	}
+
+	format, err := ResponseFormat[address]("address", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format.Type != models.ChatResponseFormatTypeJSONSchema || format.JSONSchema.Strict != nil || format.JSONSchema.Schema["type"] != TypeObject {
+		t.Fatalf("unexpected response format: %+v", format.JSONSchema)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 10:22:47
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-20 10:22:47
+ * @Description: 按 JSON Schema 校验 JSON 数据
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// ErrValidation JSON 数据不符合 Schema，可通过 errors.Is 判断
+var ErrValidation = errors.New("json does not match schema")
+
+var (
+	errTrailingData = errors.New("unexpected data after top-level JSON value") // JSON 值之后有多余数据
+)
+
+// ValidationError 校验失败时返回的错误
+type ValidationError struct {
+	Errors []string // 校验错误列表，每项以 JSON 路径开头，如 "$.items[0].name: expected string, got number"
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() (s string) {
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(e.Errors, "; "))
+}
+
+// Is 支持 errors.Is(err, ErrValidation)
+func (e *ValidationError) Is(target error) (ok bool) {
+	return target == ErrValidation
+}
+
+// Validate 校验 JSON 数据是否符合 Schema，数据不是合法 JSON 时返回解析错误，不符合 Schema 时返回 *ValidationError
+//
+// 支持的关键字：type、properties、required、additionalProperties、items、minItems、maxItems、enum、anyOf、allOf 以及指向 "#" 或 "#/$defs/" 的 $ref
+func Validate(schema map[string]any, data []byte) (err error) {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+	if decoder.More() {
+		return errTrailingData
+	}
+	return ValidateValue(schema, value)
+}
+
+// ValidateValue 校验已解析的 JSON 值是否符合 Schema，数字可以是 json.Number 或 Go 数值类型
+func ValidateValue(schema map[string]any, value any) (err error) {
+	v := &validator{root: schema}
+	v.validate(schema, value, "$")
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return
+}
+
+// validator 校验器
+type validator struct {
+	root  map[string]any
+	errs  []string
+	depth int
+}
+
+// maxRefDepth $ref 的最大解析深度，防止 Schema 自引用导致死循环
+const maxRefDepth = 64
+
+// addError 添加校验错误
+func (v *validator) addError(path, format string, args ...any) {
+	v.errs = append(v.errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validate 校验值
+func (v *validator) validate(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, ok := v.resolve(ref)
+		if !ok {
+			v.addError(path, "unresolvable $ref %q", ref)
+			return
+		}
+		if v.depth >= maxRefDepth {
+			v.addError(path, "$ref nesting too deep")
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+		return
+	}
+	if subs, ok := schemaList(schema["allOf"]); ok {
+		for _, sub := range subs {
+			v.validate(sub, value, path)
+		}
+	}
+	if subs, ok := schemaList(schema["anyOf"]); ok && !v.anyOf(subs, value, path) {
+		return
+	}
+	if types := stringList(schema["type"]); len(types) > 0 {
+		actual := typeOf(value)
+		if !slices.ContainsFunc(types, func(t string) (ok bool) { return matchesType(t, actual, value) }) {
+			v.addError(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(e any) (ok bool) { return equal(e, value) }) {
+			v.addError(path, "value %s is not one of %s", jsonString(value), jsonString(enum))
+		}
+	}
+	switch val := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, val, path)
+	case []any:
+		v.validateArray(schema, val, path)
+	}
+}
+
+// anyOf 校验值是否符合任一子 Schema
+func (v *validator) anyOf(subs []map[string]any, value any, path string) (ok bool) {
+	var errs []string
+	for _, sub := range subs {
+		sv := &validator{root: v.root, depth: v.depth}
+		sv.validate(sub, value, path)
+		if len(sv.errs) == 0 {
+			return true
+		}
+		errs = append(errs, sv.errs...)
+	}
+	v.addError(path, "value does not match any allowed schema (%s)", strings.Join(errs, "; "))
+	return false
+}
+
+// validateObject 校验对象
+func (v *validator) validateObject(schema map[string]any, obj map[string]any, path string) {
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			v.addError(path, "missing required property %q", name)
+		}
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if prop, ok := properties[key].(map[string]any); ok {
+			v.validate(prop, obj[key], childPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.addError(path, "unexpected property %q", key)
+			}
+		case map[string]any:
+			v.validate(additional, obj[key], childPath)
+		}
+	}
+}
+
+// validateArray 校验数组
+func (v *validator) validateArray(schema map[string]any, arr []any, path string) {
+	if n, ok := toFloat(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.addError(path, "expected at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := toFloat(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.addError(path, "expected at most %v items, got %d", n, len(arr))
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// resolve 解析 $ref
+func (v *validator) resolve(ref string) (schema map[string]any, ok bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	name, found := strings.CutPrefix(ref, "#/$defs/")
+	if !found {
+		return nil, false
+	}
+	defs, _ := v.root["$defs"].(map[string]any)
+	schema, ok = defs[name].(map[string]any)
+	return
+}
+
+// typeOf 获取 JSON 值的类型名称
+func typeOf(value any) (t string) {
+	switch value.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case string:
+		return TypeString
+	case map[string]any:
+		return TypeObject
+	case []any:
+		return TypeArray
+	}
+	if _, ok := toFloat(value); ok {
+		return TypeNumber
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// matchesType 判断值是否符合 Schema 类型，整数也符合 number 类型
+func matchesType(want, actual string, value any) (ok bool) {
+	if want == TypeInteger && actual == TypeNumber {
+		f, _ := toFloat(value)
+		return f == math.Trunc(f)
+	}
+	return want == actual
+}
+
+// equal 判断两个 JSON 值是否相等，数值按大小比较
+func equal(a, b any) (ok bool) {
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum || bNum {
+		return aNum && bNum && fa == fb
+	}
+	return jsonString(a) == jsonString(b)
+}
+
+// toFloat 将数值转换为 float64
+func toFloat(value any) (f float64, ok bool) {
+	switch n := value.(type) {
+	case json.Number:
+		var err error
+		f, err = n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// stringList 将 []string 或 []any 转换为字符串列表，单个字符串视为只有一项的列表
+func stringList(value any) (list []string) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	return
+}
+
+// schemaList 将 []any 或 []map[string]any 转换为 Schema 列表
+func schemaList(value any) (list []map[string]any, ok bool) {
+	switch v := value.(type) {
+	case []map[string]any:
+		return v, true
+	case []any:
+		for _, item := range v {
+			if sub, ok := item.(map[string]any); ok {
+				list = append(list, sub)
+			}
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// jsonString 将值序列化为 JSON 字符串，用于错误信息
+func jsonString(value any) (s string) {
+	b, _ := json.Marshal(value)
+	return string(b)
+}
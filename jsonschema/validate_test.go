@@ -0,0 +1,82 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 11:36:02
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-20 11:36:02
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package jsonschema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema, err := GenerateFor[person](Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	valid := `{"id":"1","name":"Alice","age":30,"unit":"celsius","tags":["a"],"homes":[],"birthday":"2000-01-01T00:00:00Z","score":1.5,"meta":{"x":1}}`
+	if err = Validate(schema, []byte(valid)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"id":"1","name":"Alice","age":31,"unit":"celsius","tags":[],"homes":[],"birthday":"","score":1}`, `$.age: value 31 is not one of [18,30]`},
+		{`{"id":"1","name":"Alice","age":30.5,"unit":"celsius","tags":[],"homes":[],"birthday":"","score":1}`, `$.age: expected integer, got number`},
+		{`{"id":"1","name":"Alice","age":30,"unit":"celsius","tags":["c"],"homes":[],"birthday":"","score":1}`, `$.tags[0]: value "c" is not one of ["a","b"]`},
+		{`{"id":"1","name":"Alice","age":30,"unit":"celsius","tags":[],"homes":[{"zip":"1"}],"birthday":"","score":1}`, `$.homes[0]: missing required property "city"`},
+		{`{"id":"1","age":30,"unit":"celsius","tags":[],"homes":[],"birthday":"","score":1}`, `$: missing required property "name"`},
+		{`{"id":"1","name":"Alice","age":30,"unit":"celsius","tags":[],"homes":[],"birthday":"","score":1,"meta":{"x":"y"}}`, `$.meta.x: expected integer, got string`},
+		{`[]`, `$: expected object, got array`},
+	}
+	for _, tt := range tests {
+		err := Validate(schema, []byte(tt.data))
+		if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.data, tt.want, err)
+		}
+	}
+
+	if err = Validate(schema, []byte(`{"id":`)); err == nil || errors.Is(err, ErrValidation) {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+	if err = Validate(schema, []byte(valid+` {}`)); !errors.Is(err, errTrailingData) {
+		t.Errorf("expected errTrailingData, got %v", err)
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	schema, err := GenerateFor[tree](Config{Strict: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"root":null}`, ""},
+		{`{"root":{"value":"a","children":[{"value":"b","children":[]}]}}`, ""},
+		{`{}`, `$: missing required property "root"`},
+		{`{"root":null,"extra":1}`, `$: unexpected property "extra"`},
+		{`{"root":{"value":"a","children":[{"value":1,"children":[]}]}}`, `$.root.children[0].value: expected string, got number`},
+	}
+	for _, tt := range tests {
+		err := Validate(schema, []byte(tt.data))
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.data, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-31 10:06:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 10:06:52
+ * @Description: 客户端生命周期管理，支持优雅关闭
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushTimeout = 5 * time.Second // 等待请求结束超时后，等待被取消的请求退出以及刷新缓冲数据的超时时间
+)
+
+// lifecycle 客户端生命周期，跟踪进行中的请求和后台任务（并发安全）
+type lifecycle struct {
+	ctx      context.Context    // 客户端强制关闭时取消，用于取消进行中的请求
+	cancel   context.CancelFunc // 取消进行中的请求
+	inflight sync.WaitGroup     // 进行中的请求，流式传输在关闭后才结束
+	stops    []func()           // 后台任务的停止函数
+	closed   bool               // 是否已关闭，关闭后拒绝新请求
+	done     chan struct{}      // 关闭完成后关闭
+	mu       sync.Mutex         // 互斥锁
+}
+
+// newLifecycle 创建客户端生命周期
+func newLifecycle() (l *lifecycle) {
+	l = &lifecycle{done: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return
+}
+
+// begin 登记进行中的请求，返回在客户端强制关闭时取消的上下文，请求处理完成后必须调用 end
+//
+//	end 的参数为流式传输时，请求在流式传输结束或被关闭后才结束
+func (l *lifecycle) begin(ctx context.Context) (reqCtx context.Context, end func(resp any), err error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ctx, nil, errors.ErrClientClosed
+	}
+	l.inflight.Add(1)
+	l.mu.Unlock()
+
+	var (
+		cancel context.CancelFunc
+		once   sync.Once
+	)
+	reqCtx, cancel = context.WithCancel(ctx)
+	stop := context.AfterFunc(l.ctx, cancel)
+	finish := func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			l.inflight.Done()
+		})
+	}
+	end = func(resp any) {
+		afterResponse(resp, finish)
+	}
+	return
+}
+
+// afterResponse 在响应结束后调用 fn，流式传输在结束或被关闭后才调用
+func afterResponse(resp any, fn func()) {
+	if stream, ok := resp.(httpclient.StreamObservable); ok {
+		stream.AddObserver(nil, func(err error) { fn() })
+		return
+	}
+	fn()
+}
+
+// addStop 登记后台任务的停止函数，客户端已关闭时立即停止
+func (l *lifecycle) addStop(stop func()) {
+	l.mu.Lock()
+	if !l.closed {
+		l.stops = append(l.stops, stop)
+		l.mu.Unlock()
+		return
+	}
+	l.mu.Unlock()
+	stop()
+}
+
+// Shutdown 优雅关闭客户端
+//
+//  1. 拒绝新请求，新请求返回 errors.ErrClientClosed
+//  2. 停止配置监听等后台任务
+//  3. 等待进行中的请求和未关闭的流式传输结束，ctx 到期时取消它们并返回 ctx 的错误，取消后最多再等待 defaultFlushTimeout 让它们退出
+//  4. 刷新中间件中缓冲的指标、用量、审计等数据（实现了 httpclient.Flusher 接口的中间件）
+//  5. 关闭提供商的 Redis 共享状态和 HTTP 传输层的空闲连接，释放密钥的脱敏注册
+//
+// 重复调用时等待第一次关闭完成并返回 nil
+func (c *SDKClient) Shutdown(ctx context.Context) (err error) {
+	return c.shutdown(ctx, false)
+}
+
+// Close 立即关闭客户端，取消进行中的请求和流式传输，然后刷新缓冲数据并释放资源
+func (c *SDKClient) Close() (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return c.shutdown(ctx, true)
+}
+
+// shutdown 关闭客户端，force 为 true 时不等待进行中的请求
+func (c *SDKClient) shutdown(ctx context.Context, force bool) (err error) {
+	l := c.lifecycle
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+		}
+		return
+	}
+	l.closed = true
+	stops := l.stops
+	l.stops = nil
+	l.mu.Unlock()
+	defer close(l.done)
+
+	var errs []error
+	// 停止后台任务
+	for _, stop := range stops {
+		stop()
+	}
+	// 等待进行中的请求结束
+	drained := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		if !force {
+			errs = append(errs, ctx.Err())
+		}
+	}
+	l.cancel()
+	// 刷新缓冲数据，等待超时后仍尝试刷新
+	flushCtx := ctx
+	if ctx.Err() != nil {
+		// 被取消的请求仍在退出，等待它们记录用量、释放 API 密钥后再刷新和关闭提供商
+		timer := time.NewTimer(defaultFlushTimeout)
+		select {
+		case <-drained:
+		case <-timer.C:
+		}
+		timer.Stop()
+		var cancel context.CancelFunc
+		flushCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), defaultFlushTimeout)
+		defer cancel()
+	}
+	if e := c.middlewareChain.Flush(flushCtx); e != nil {
+		errs = append(errs, e)
+	}
+	// 关闭提供商
+	for provider, ps := range c.providers {
+		if closer, ok := ps.(io.Closer); ok {
+			if e := closer.Close(); e != nil {
+				errs = append(errs, fmt.Errorf("failed to close provider %s: %w", provider, e))
+			}
+		}
+	}
+	if c.tenantPool != nil {
+		if e := c.tenantPool.close(); e != nil {
+			errs = append(errs, e)
+		}
+	}
+	// 释放配置中密钥的脱敏注册
+	if c.configManager != nil {
+		c.configManager.Close()
+	}
+	return goerrors.Join(errs...)
+}
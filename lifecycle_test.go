@@ -0,0 +1,187 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-31 14:18:05
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 14:18:05
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	goerrors "errors"
+	"github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flushMiddleware 记录刷新次数的中间件
+type flushMiddleware struct {
+	flushed atomic.Int32
+	onFlush func() // 刷新时的回调
+}
+
+func (m *flushMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	return next(ctx, request)
+}
+
+func (m *flushMiddleware) Name() (name string) { return "flush" }
+
+func (m *flushMiddleware) Priority() (priority int) { return 0 }
+
+func (m *flushMiddleware) Flush(ctx context.Context) (err error) {
+	m.flushed.Add(1)
+	if m.onFlush != nil {
+		m.onFlush()
+	}
+	return
+}
+
+// newTestLifecycleClient 创建用于测试生命周期的客户端
+func newTestLifecycleClient(t *testing.T) (client *SDKClient, flusher *flushMiddleware) {
+	flusher = &flushMiddleware{}
+	return &SDKClient{
+		providers:       newTestProviders(t, "sk-test"),
+		middlewareChain: httpclient.NewChain(flusher),
+		lifecycle:       newLifecycle(),
+	}, flusher
+}
+
+// shutdownAsync 在后台关闭客户端，返回接收关闭结果的通道
+func shutdownAsync(client *SDKClient, ctx context.Context) (result chan error) {
+	result = make(chan error, 1)
+	go func() {
+		result <- client.Shutdown(ctx)
+	}()
+	return
+}
+
+// waitCanceled 等待请求上下文被取消
+func waitCanceled(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("expected the in-flight request to be canceled")
+	}
+}
+
+// endOnCancel 请求上下文被取消后稍作延迟再结束请求，模拟请求退出时记录用量、释放密钥
+func endOnCancel(reqCtx context.Context, end func(resp any)) (ended *atomic.Bool) {
+	ended = &atomic.Bool{}
+	go func() {
+		<-reqCtx.Done()
+		time.Sleep(20 * time.Millisecond)
+		ended.Store(true)
+		end(nil)
+	}()
+	return
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	client, flusher := newTestLifecycleClient(t)
+	// 进行中的阻塞式请求
+	_, endRequest, err := client.lifecycle.begin(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 进行中的流式传输
+	pr, pw := io.Pipe()
+	_, endStream, _ := client.lifecycle.begin(context.Background())
+	stream := models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](pr, nil, nil)}
+	endStream(stream)
+	// 停止监听配置的函数
+	var stopped atomic.Bool
+	client.lifecycle.addStop(func() { stopped.Store(true) })
+
+	result := shutdownAsync(client, context.Background())
+	time.Sleep(20 * time.Millisecond)
+	// 关闭后拒绝新请求
+	if _, _, err = client.lifecycle.begin(context.Background()); !errors.IsClientClosedError(err) {
+		t.Errorf("expected a client closed error, got %v", err)
+	}
+	if !stopped.Load() {
+		t.Error("expected background tasks to be stopped")
+	}
+	// 请求结束后流式传输仍在进行
+	endRequest(nil)
+	select {
+	case err = <-result:
+		t.Fatalf("shutdown returned before the stream finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// 流式传输结束后完成关闭
+	go func() {
+		pw.Write([]byte("data: {\"choices\":[]}\n\ndata: [DONE]\n\n"))
+		pw.Close()
+	}()
+	if err = stream.ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) { return }); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Errorf("unexpected shutdown error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not finish after the stream finished")
+	}
+	if flusher.flushed.Load() != 1 {
+		t.Errorf("expected middlewares to be flushed once, got %d", flusher.flushed.Load())
+	}
+	// 重复关闭
+	if err = client.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error on second shutdown: %v", err)
+	}
+	// 关闭后登记的后台任务立即停止
+	stopped.Store(false)
+	client.lifecycle.addStop(func() { stopped.Store(true) })
+	if !stopped.Load() {
+		t.Error("expected the background task to be stopped immediately")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	client, flusher := newTestLifecycleClient(t)
+	reqCtx, end, err := client.lifecycle.begin(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ended := endOnCancel(reqCtx, end)
+	var endedBeforeFlush atomic.Bool
+	flusher.onFlush = func() { endedBeforeFlush.Store(ended.Load()) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err = client.Shutdown(ctx); !goerrors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+	// 超时后取消进行中的请求，等待它们退出后仍然刷新缓冲数据
+	waitCanceled(t, reqCtx)
+	if flusher.flushed.Load() != 1 {
+		t.Errorf("expected middlewares to be flushed, got %d", flusher.flushed.Load())
+	}
+	if !endedBeforeFlush.Load() {
+		t.Error("expected canceled requests to finish before flushing")
+	}
+}
+
+func TestClose(t *testing.T) {
+	client, _ := newTestLifecycleClient(t)
+	reqCtx, end, _ := client.lifecycle.begin(context.Background())
+	ended := endOnCancel(reqCtx, end)
+
+	if err := client.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	waitCanceled(t, reqCtx)
+	if !ended.Load() {
+		t.Error("expected Close to wait for canceled requests to finish")
+	}
+}
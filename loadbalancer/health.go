@@ -0,0 +1,196 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 10:08:53
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 16:47:13
+ * @Description: API密钥健康状态管理
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome 请求结果
+type Outcome int
+
+const (
+	OutcomeSuccess       Outcome = iota // 请求成功
+	OutcomeError                        // 与密钥无关的错误（如参数错误、服务端错误），只计入错误次数
+	OutcomeAuthFailed                   // 认证失败，密钥被禁用，按指数退避重新探测
+	OutcomeQuotaExceeded                // 额度用尽，密钥进入冷却，连续失败时冷却时间指数增长
+	OutcomeRateLimited                  // 触发速率限制，密钥进入冷却
+)
+
+// KeyState API密钥健康状态
+type KeyState string
+
+const (
+	KeyStateActive   KeyState = "active"   // 正常
+	KeyStateCooldown KeyState = "cooldown" // 冷却中，冷却结束后自动恢复
+	KeyStateDisabled KeyState = "disabled" // 已禁用，到达探测时间后允许一个请求重新探测，成功后恢复
+)
+
+// HealthConfig 健康状态管理配置
+type HealthConfig struct {
+	RateLimitCooldown time.Duration // 限流冷却时间，响应未指定 Retry-After 时使用，默认 30s
+	QuotaCooldown     time.Duration // 额度用尽的冷却时间，默认 10m
+	ProbeInterval     time.Duration // 禁用密钥首次重新探测的等待时间，默认 1m
+	MaxBackoff        time.Duration // 冷却时间和探测间隔的上限，默认 1h
+}
+
+// DefaultHealthConfig 默认健康状态管理配置
+func DefaultHealthConfig() (config HealthConfig) {
+	return HealthConfig{
+		RateLimitCooldown: 30 * time.Second,
+		QuotaCooldown:     10 * time.Minute,
+		ProbeInterval:     time.Minute,
+		MaxBackoff:        time.Hour,
+	}
+}
+
+// normalize 使用默认值填充未设置的配置项
+func (c HealthConfig) normalize() (config HealthConfig) {
+	def := DefaultHealthConfig()
+	if c.RateLimitCooldown <= 0 {
+		c.RateLimitCooldown = def.RateLimitCooldown
+	}
+	if c.QuotaCooldown <= 0 {
+		c.QuotaCooldown = def.QuotaCooldown
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = def.ProbeInterval
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = def.MaxBackoff
+	}
+	return c
+}
+
+// backoff 计算第 failures 次连续失败后的等待时间：base * 2^(failures-1)，不超过 MaxBackoff
+func (c HealthConfig) backoff(base time.Duration, failures int) (d time.Duration) {
+	d = base
+	for i := 1; i < failures && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxBackoff)
+}
+
+// keyHealth API密钥健康状态
+type keyHealth struct {
+	state     KeyState  // 健康状态
+	until     time.Time // 冷却结束时间或下一次探测时间
+	failures  int       // 连续失败次数
+	successes uint64    // 成功次数
+	errors    uint64    // 失败次数
+}
+
+// selectable 密钥当前是否可以被选中
+func (h *keyHealth) selectable(now time.Time) (ok bool) {
+	return h.state == "" || h.state == KeyStateActive || !now.Before(h.until)
+}
+
+// currentState 当前健康状态，冷却结束的密钥视为正常
+func (h *keyHealth) currentState(now time.Time) (state KeyState) {
+	if h.state == "" || (h.state == KeyStateCooldown && !now.Before(h.until)) {
+		return KeyStateActive
+	}
+	return h.state
+}
+
+// throttle 冷却到指定时间（不计入连续失败次数），已禁用或冷却更久的密钥保持不变
+func (h *keyHealth) throttle(until time.Time) {
+	if h.state == KeyStateDisabled || (h.state == KeyStateCooldown && h.until.After(until)) {
+		return
+	}
+	h.state = KeyStateCooldown
+	h.until = until
+}
+
+// reset 重置健康状态，保留成功和失败次数
+func (h *keyHealth) reset() {
+	h.state = KeyStateActive
+	h.until = time.Time{}
+	h.failures = 0
+}
+
+// ReportResult 反馈使用指定APIKey的请求结果：认证失败时禁用密钥并按指数退避重新探测，额度用尽或限流时进入冷却，成功时恢复正常
+//
+// retryAfter 为响应头 Retry-After 指定的等待时间，限流时优先使用；设置了共享状态时，冷却状态同步给其他实例
+func (lb *LoadBalancer) ReportResult(key string, outcome Outcome, retryAfter time.Duration) (err error) {
+	lb.mu.Lock()
+	apiKey := lb.find(key)
+	if apiKey == nil {
+		lb.mu.Unlock()
+		return errAPIKeyNotFound
+	}
+	var (
+		h   = &apiKey.health
+		now = lb.now()
+	)
+	switch outcome {
+	case OutcomeSuccess:
+		h.successes++
+		h.reset()
+	case OutcomeAuthFailed:
+		h.errors++
+		h.failures++
+		h.state = KeyStateDisabled
+		h.until = now.Add(lb.health.backoff(lb.health.ProbeInterval, h.failures))
+	case OutcomeQuotaExceeded:
+		h.errors++
+		h.failures++
+		h.state = KeyStateCooldown
+		h.until = now.Add(lb.health.backoff(lb.health.QuotaCooldown, h.failures))
+	case OutcomeRateLimited:
+		h.errors++
+		h.failures++
+		h.state = KeyStateCooldown
+		if retryAfter > 0 {
+			h.until = now.Add(min(retryAfter, lb.health.MaxBackoff))
+		} else {
+			h.until = now.Add(lb.health.backoff(lb.health.RateLimitCooldown, h.failures))
+		}
+	default:
+		h.errors++
+	}
+	var (
+		share = lb.syncShared && (outcome == OutcomeAuthFailed || outcome == OutcomeQuotaExceeded || outcome == OutcomeRateLimited)
+		until = h.until
+	)
+	lb.mu.Unlock()
+
+	// 将冷却状态同步给其他实例
+	if share {
+		if e := lb.shared.SetCooldown(context.Background(), apiKey.id, until); e != nil {
+			lb.mu.Lock()
+			lb.sharedErrors++
+			lb.mu.Unlock()
+		}
+	}
+	return
+}
+
+// ResetHealth 将指定APIKey恢复为正常状态
+func (lb *LoadBalancer) ResetHealth(key string) (err error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	apiKey := lb.find(key)
+	if apiKey == nil {
+		return errAPIKeyNotFound
+	}
+	apiKey.health.reset()
+	return
+}
+
+// maskKey 隐藏密钥中间部分，用于统计信息展示
+func maskKey(key string) (masked string) {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "****" + key[len(key)-4:]
+}
@@ -0,0 +1,171 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 14:31:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 14:31:17
+ * @Description: API密钥健康状态管理测试
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock replaces the load balancer clock and returns a function to advance it
+func fakeClock(lb *LoadBalancer) (advance func(d time.Duration)) {
+	now := time.Date(2025, 7, 22, 0, 0, 0, 0, time.UTC)
+	lb.now = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// TestReportResult tests API key health management
+func TestReportResult(t *testing.T) {
+	t.Run("rate limited key cools down", func(t *testing.T) {
+		lb := NewLoadBalancer([]string{"key1", "key2"})
+		advance := fakeClock(lb)
+
+		if err := lb.ReportResult("key1", OutcomeRateLimited, 5*time.Second); err != nil {
+			t.Fatalf("failed to report result: %v", err)
+		}
+		for range 3 {
+			apiKey, err := lb.GetAPIKey()
+			if err != nil || apiKey.Key != "key2" {
+				t.Fatalf("expected key2 while key1 cools down, got %v, %v", apiKey, err)
+			}
+		}
+		stats := lb.GetStats()
+		if stats["cooldown_api_key"] != 1 || stats["available_api_key"] != 1 {
+			t.Errorf("unexpected stats: %v", stats)
+		}
+
+		// Cooldown ends after Retry-After
+		advance(5 * time.Second)
+		apiKey, err := lb.GetAPIKey()
+		if err != nil || apiKey.Key != "key1" {
+			t.Fatalf("expected key1 after cooldown, got %v, %v", apiKey, err)
+		}
+	})
+
+	t.Run("cooldown grows on repeated throttling", func(t *testing.T) {
+		lb := NewLoadBalancerWithConfig([]string{"key1"}, Config{Health: HealthConfig{QuotaCooldown: time.Minute, MaxBackoff: 3 * time.Minute}})
+		advance := fakeClock(lb)
+
+		wants := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}
+		for i, want := range wants {
+			lb.ReportResult("key1", OutcomeQuotaExceeded, 0)
+			advance(want - time.Second)
+			if _, err := lb.GetAPIKey(); err != errNoAPIKeyAvailable {
+				t.Fatalf("failure %d: expected key to cool down for %v, got %v", i+1, want, err)
+			}
+			advance(time.Second)
+			if _, err := lb.GetAPIKey(); err != nil {
+				t.Fatalf("failure %d: expected key to be available after %v, got %v", i+1, want, err)
+			}
+		}
+
+		// Success resets the backoff
+		lb.ReportResult("key1", OutcomeSuccess, 0)
+		lb.ReportResult("key1", OutcomeQuotaExceeded, 0)
+		advance(time.Minute)
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("expected backoff to be reset after success, got %v", err)
+		}
+	})
+
+	t.Run("auth failure disables key and probes with backoff", func(t *testing.T) {
+		lb := NewLoadBalancerWithConfig([]string{"key1"}, Config{Health: HealthConfig{ProbeInterval: time.Minute}})
+		advance := fakeClock(lb)
+
+		lb.ReportResult("key1", OutcomeAuthFailed, 0)
+		if _, err := lb.GetAPIKey(); err != errNoAPIKeyAvailable {
+			t.Fatalf("expected disabled key to be skipped, got %v", err)
+		}
+		if stats := lb.GetStats(); stats["disabled_api_key"] != 1 {
+			t.Errorf("expected 1 disabled key, got %v", stats["disabled_api_key"])
+		}
+
+		// Only one probe request is allowed once the probe is due
+		advance(time.Minute)
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("expected probe request, got %v", err)
+		}
+		if _, err := lb.GetAPIKey(); err != errNoAPIKeyAvailable {
+			t.Fatalf("expected only one probe request, got %v", err)
+		}
+
+		// Failed probe doubles the interval
+		lb.ReportResult("key1", OutcomeAuthFailed, 0)
+		advance(time.Minute)
+		if _, err := lb.GetAPIKey(); err != errNoAPIKeyAvailable {
+			t.Fatalf("expected probe interval to double, got %v", err)
+		}
+		advance(time.Minute)
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("expected probe request, got %v", err)
+		}
+
+		// Successful probe restores the key
+		lb.ReportResult("key1", OutcomeSuccess, 0)
+		for range 2 {
+			if _, err := lb.GetAPIKey(); err != nil {
+				t.Fatalf("expected key to be restored, got %v", err)
+			}
+		}
+	})
+
+	t.Run("other errors do not affect availability", func(t *testing.T) {
+		lb := NewLoadBalancer([]string{"key1"})
+
+		lb.ReportResult("key1", OutcomeError, 0)
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("expected key to stay available, got %v", err)
+		}
+	})
+
+	t.Run("re-enabling key resets health", func(t *testing.T) {
+		lb := NewLoadBalancer([]string{"key1"})
+
+		lb.ReportResult("key1", OutcomeAuthFailed, 0)
+		lb.SetAvailability("key1", true)
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("expected key to be restored, got %v", err)
+		}
+	})
+
+	t.Run("report result for non-existent API key", func(t *testing.T) {
+		lb := NewLoadBalancer([]string{"key1"})
+
+		if err := lb.ReportResult("nonexistent", OutcomeSuccess, 0); err != errAPIKeyNotFound {
+			t.Errorf("expected errAPIKeyNotFound, got %v", err)
+		}
+	})
+}
+
+// TestGetStatsPerKey tests per-key statistics
+func TestGetStatsPerKey(t *testing.T) {
+	lb := NewLoadBalancer([]string{"sk-1234567890abcdef"})
+	fakeClock(lb)
+
+	lb.GetAPIKey()
+	lb.ReportResult("sk-1234567890abcdef", OutcomeSuccess, 0)
+	lb.GetAPIKey()
+	lb.ReportResult("sk-1234567890abcdef", OutcomeRateLimited, 0)
+
+	apiKeys := lb.GetStats()["api_keys"].([]map[string]any)
+	if len(apiKeys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(apiKeys))
+	}
+	stats := apiKeys[0]
+	if stats["key"] != "sk-****cdef" {
+		t.Errorf("expected masked key, got %v", stats["key"])
+	}
+	if stats["requests"] != uint32(2) || stats["successes"] != uint64(1) || stats["errors"] != uint64(1) {
+		t.Errorf("unexpected counters: %v", stats)
+	}
+	if stats["state"] != KeyStateCooldown || stats["until"] != lb.now().Add(30*time.Second) {
+		t.Errorf("unexpected cooldown state: %v", stats)
+	}
+}
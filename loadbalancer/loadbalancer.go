@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-27 15:03:40
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:10:54
+ * @LastEditTime: 2025-07-31 15:02:47
  * @Description: 负载均衡器
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -10,9 +10,10 @@
 package loadbalancer
 
 import (
+	"context"
 	"errors"
-	"math"
-	"math/rand/v2"
+	"io"
+	"log/slog"
 	"slices"
 	"sync"
 	"time"
@@ -28,66 +29,354 @@ var (
 
 // APIKey API密钥
 type APIKey struct {
-	Key       string // 密钥
-	Times     uint32 // 请求次数
-	Available bool   // 是否可用
-	Weight    uint32 // 权重
+	Key       string    // 密钥
+	Times     uint32    // 请求次数
+	Available bool      // 是否可用
+	Weight    uint32    // 权重
+	id        string    // 共享状态中的标识（密钥哈希）
+	health    keyHealth // 健康状态
+	inFlight  int64     // 并发请求数
+	latency   float64   // 指数加权移动平均延迟（纳秒），0 表示尚无数据
+}
+
+// InFlight 并发请求数
+func (k *APIKey) InFlight() (n int64) {
+	return k.inFlight
+}
+
+// Latency 指数加权移动平均延迟，0 表示尚无数据
+func (k *APIKey) Latency() (d time.Duration) {
+	return time.Duration(k.latency)
+}
+
+// String 返回隐藏了中间部分的密钥，避免打印或记录日志时泄露密钥
+func (k *APIKey) String() (s string) {
+	return maskKey(k.Key)
+}
+
+// LogValue 实现 slog.LogValuer，结构化日志中只记录隐藏了中间部分的密钥
+func (k *APIKey) LogValue() (v slog.Value) {
+	return slog.StringValue(maskKey(k.Key))
+}
+
+// ewmaAlpha 延迟指数加权移动平均的平滑系数
+const ewmaAlpha = 0.3
+
+// defaultSyncInterval 默认从共享状态同步的间隔
+const defaultSyncInterval = time.Second
+
+// Config 负载均衡器配置
+type Config struct {
+	Strategy     Strategy      // 负载均衡策略，默认为最少使用策略
+	Health       HealthConfig  // 健康状态管理配置
+	Shared       SharedState   // 多实例共享状态存储，设置后各实例共享使用次数、冷却状态和速率限制，为 nil 时只使用本地状态
+	SyncInterval time.Duration // 从共享状态同步使用次数和冷却状态的间隔，默认1s
+	RateLimit    RateLimit     // 每个APIKey的速率限制，设置了共享状态时所有实例共同遵守
 }
 
 // LoadBalancer 负载均衡器
 type LoadBalancer struct {
-	apiKeyList []*APIKey    // API密钥列表
-	rng        *rand.Rand   // 随机数生成器
-	mu         sync.RWMutex // 读写锁
+	apiKeyList   []*APIKey        // API密钥列表
+	strategy     Strategy         // 负载均衡策略
+	health       HealthConfig     // 健康状态管理配置
+	shared       SharedState      // 共享状态存储
+	syncShared   bool             // 是否从共享状态同步
+	syncInterval time.Duration    // 同步间隔
+	lastSync     time.Time        // 上次同步时间
+	sharedErrors uint64           // 访问共享状态失败的次数
+	rateLimit    RateLimit        // 速率限制
+	now          func() time.Time // 当前时间，便于测试
+	candidates   []*APIKey        // 候选APIKey缓冲区，复用以减少内存分配
+	mu           sync.RWMutex     // 读写锁
 }
 
 // NewLoadBalancer 创建负载均衡器
 func NewLoadBalancer(keyList []string) (lb *LoadBalancer) {
-	now := time.Now().UnixNano()
+	return NewLoadBalancerWithConfig(keyList, Config{})
+}
+
+// NewLoadBalancerWithConfig 使用指定配置创建负载均衡器，未设置的配置项使用默认值
+func NewLoadBalancerWithConfig(keyList []string, config Config) (lb *LoadBalancer) {
 	lb = &LoadBalancer{
-		rng: rand.New(rand.NewPCG(uint64(now), uint64(now>>32))),
+		strategy:     config.Strategy,
+		health:       config.Health.normalize(),
+		shared:       config.Shared,
+		syncShared:   config.Shared != nil,
+		syncInterval: config.SyncInterval,
+		rateLimit:    config.RateLimit,
+		now:          time.Now,
+	}
+	if lb.strategy == nil {
+		lb.strategy = LeastUsed()
+	}
+	if lb.syncInterval <= 0 {
+		lb.syncInterval = defaultSyncInterval
+	}
+	// 未设置共享状态时，速率限制只在本实例内生效
+	if lb.shared == nil && lb.rateLimit.Requests > 0 && lb.rateLimit.Window > 0 {
+		lb.shared = NewMemoryState()
 	}
 	// 初始化API密钥列表
 	for _, key := range keyList {
-		lb.apiKeyList = append(lb.apiKeyList, &APIKey{
-			Key:       key,
-			Available: true,
-			Weight:    1, // 默认权重为1
-		})
+		lb.apiKeyList = append(lb.apiKeyList, newAPIKey(key))
 	}
 	return
 }
 
-// GetAPIKey 获取一个APIKey，使用最少连接算法
+// Close 关闭共享状态存储（如 Redis 连接），关闭后不能再使用共享状态
+func (lb *LoadBalancer) Close() (err error) {
+	lb.mu.RLock()
+	shared := lb.shared
+	lb.mu.RUnlock()
+
+	if closer, ok := shared.(io.Closer); ok {
+		return closer.Close()
+	}
+	return
+}
+
+// newAPIKey 创建API密钥
+func newAPIKey(key string) (apiKey *APIKey) {
+	return &APIKey{
+		Key:       key,
+		Available: true,
+		Weight:    1, // 默认权重为1
+		id:        keyID(key),
+	}
+}
+
+// GetAPIKey 按负载均衡策略获取一个APIKey，跳过冷却中、超出速率限制和未到探测时间的已禁用APIKey
 func (lb *LoadBalancer) GetAPIKey() (apiKey *APIKey, err error) {
-	if len(lb.apiKeyList) == 0 {
-		return nil, errEmptyAPIKeyList
+	return lb.acquire(context.Background(), false)
+}
+
+// Acquire 按负载均衡策略获取一个APIKey并计入并发请求数，请求结束后必须调用 Release
+//
+// 按用户粘滞策略从上下文中获取用户标识，参见 WithUser
+func (lb *LoadBalancer) Acquire(ctx context.Context) (apiKey *APIKey, err error) {
+	return lb.acquire(ctx, true)
+}
+
+// acquire 获取APIKey，track 为 true 时计入并发请求数
+func (lb *LoadBalancer) acquire(ctx context.Context, track bool) (apiKey *APIKey, err error) {
+	lb.syncFromShared(ctx)
+	for {
+		lb.mu.Lock()
+		if apiKey, err = lb.selectAPIKey(ctx); err != nil {
+			lb.mu.Unlock()
+			return
+		}
+		if track {
+			apiKey.inFlight++
+		}
+		shared, rateLimit := lb.shared, lb.rateLimit
+		lb.mu.Unlock()
+		// 检查速率限制，超出限制的APIKey冷却到当前窗口结束后重新选择
+		if lb.allow(ctx, apiKey, track, shared, rateLimit) {
+			break
+		}
 	}
-	// 选择使用次数最少的APIKey
-	lb.mu.RLock()
+	if lb.syncShared {
+		// 累加所有实例的使用次数，使最少使用等策略基于全局数据选择
+		total, e := lb.shared.IncrUsage(ctx, apiKey.id, 1)
+		lb.mu.Lock()
+		if e != nil {
+			lb.sharedErrors++
+		} else {
+			apiKey.Times = uint32(total)
+		}
+		lb.mu.Unlock()
+	}
+	return
+}
+
+// allow 检查APIKey的速率限制，超出限制时将其冷却到当前窗口结束，访问共享状态失败时放行
+func (lb *LoadBalancer) allow(ctx context.Context, apiKey *APIKey, track bool, shared SharedState, rateLimit RateLimit) (ok bool) {
+	if shared == nil || rateLimit.Requests <= 0 || rateLimit.Window <= 0 {
+		return true
+	}
+	ok, retryAfter, err := shared.Allow(ctx, apiKey.id, rateLimit.Requests, rateLimit.Window)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if err != nil {
+		lb.sharedErrors++
+		return true
+	}
+	if ok {
+		return true
+	}
+	// 撤销本次选择
+	if apiKey.Times > 0 {
+		apiKey.Times--
+	}
+	if track && apiKey.inFlight > 0 {
+		apiKey.inFlight--
+	}
+	apiKey.health.throttle(lb.now().Add(retryAfter))
+	return false
+}
+
+// syncFromShared 按同步间隔从共享状态拉取所有APIKey的使用次数和冷却状态
+func (lb *LoadBalancer) syncFromShared(ctx context.Context) {
+	if !lb.syncShared {
+		return
+	}
+	lb.mu.Lock()
+	now := lb.now()
+	if now.Sub(lb.lastSync) < lb.syncInterval {
+		lb.mu.Unlock()
+		return
+	}
+	lb.lastSync = now
 	var (
-		selectedAPIKey *APIKey
-		minScore       = math.MaxFloat64
+		apiKeys = slices.Clone(lb.apiKeyList)
+		ids     = make([]string, len(apiKeys))
 	)
-	for _, v := range lb.apiKeyList {
-		if v.Available {
-			score := float64(v.Times) / float64(v.Weight)
-			if score < minScore {
-				selectedAPIKey = v
-				minScore = score
+	for i, apiKey := range apiKeys {
+		ids[i] = apiKey.id
+	}
+	lb.mu.Unlock()
+
+	usage, usageErr := lb.shared.Usage(ctx, ids)
+	cooldowns, cooldownErr := lb.shared.Cooldowns(ctx, ids)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	// 同步期间注销的APIKey不在列表中，更新其状态没有影响
+	if usageErr != nil {
+		lb.sharedErrors++
+	} else {
+		for i, apiKey := range apiKeys {
+			apiKey.Times = uint32(usage[i])
+		}
+	}
+	if cooldownErr != nil {
+		lb.sharedErrors++
+	} else {
+		for i, apiKey := range apiKeys {
+			if cooldowns[i].After(now) {
+				apiKey.health.throttle(cooldowns[i])
 			}
 		}
 	}
-	lb.mu.RUnlock()
+}
+
+// Release 请求结束，减少指定APIKey的并发请求数
+func (lb *LoadBalancer) Release(key string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if apiKey := lb.find(key); apiKey != nil && apiKey.inFlight > 0 {
+		apiKey.inFlight--
+	}
+}
+
+// ObserveLatency 记录使用指定APIKey的请求延迟，用于延迟感知策略
+func (lb *LoadBalancer) ObserveLatency(key string, latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	apiKey := lb.find(key)
+	if apiKey == nil {
+		return
+	}
+	if apiKey.latency == 0 {
+		apiKey.latency = float64(latency)
+		return
+	}
+	apiKey.latency = ewmaAlpha*float64(latency) + (1-ewmaAlpha)*apiKey.latency
+}
+
+// selectAPIKey 按负载均衡策略选择APIKey并增加使用次数，调用方需持有写锁
+func (lb *LoadBalancer) selectAPIKey(ctx context.Context) (apiKey *APIKey, err error) {
+	if len(lb.apiKeyList) == 0 {
+		return nil, errEmptyAPIKeyList
+	}
+	// 筛选可用的APIKey
+	now := lb.now()
+	lb.candidates = lb.candidates[:0]
+	for _, v := range lb.apiKeyList {
+		if v.Available && v.health.selectable(now) {
+			lb.candidates = append(lb.candidates, v)
+		}
+	}
 	// 如果未找到可用的APIKey，则返回错误
-	if selectedAPIKey == nil {
+	if len(lb.candidates) == 0 {
 		return nil, errNoAPIKeyAvailable
 	}
-	// 增加使用次数（需要写锁）
+	apiKey = lb.strategy.Select(ctx, lb.candidates)
+	clear(lb.candidates)
+	// 已禁用的APIKey只放行一个探测请求，推迟下一次探测时间
+	if h := &apiKey.health; h.state == KeyStateDisabled {
+		h.until = now.Add(lb.health.backoff(lb.health.ProbeInterval, h.failures))
+	}
+	// 增加使用次数
+	apiKey.Times++
+	return
+}
+
+// UpdateAPIKeys 将APIKey列表更新为 keyList，保留未变化APIKey的使用次数、权重和健康状态，返回新增和移除的数量
+func (lb *LoadBalancer) UpdateAPIKeys(keyList []string) (added, removed int) {
 	lb.mu.Lock()
-	selectedAPIKey.Times++
-	lb.mu.Unlock()
-	return selectedAPIKey, nil
+	defer lb.mu.Unlock()
+
+	existing := make(map[string]*APIKey, len(lb.apiKeyList))
+	for _, apiKey := range lb.apiKeyList {
+		existing[apiKey.Key] = apiKey
+	}
+	apiKeyList := make([]*APIKey, 0, len(keyList))
+	for _, key := range keyList {
+		if apiKey, ok := existing[key]; ok {
+			apiKeyList = append(apiKeyList, apiKey)
+			delete(existing, key)
+			continue
+		}
+		if slices.ContainsFunc(apiKeyList, func(apiKey *APIKey) bool { return apiKey.Key == key }) {
+			continue
+		}
+		apiKeyList = append(apiKeyList, newAPIKey(key))
+		added++
+	}
+	lb.apiKeyList = apiKeyList
+	return added, len(existing)
+}
+
+// Strategy 获取负载均衡策略
+func (lb *LoadBalancer) Strategy() (strategy Strategy) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	return lb.strategy
+}
+
+// SetStrategy 设置负载均衡策略，为 nil 时使用最少使用策略
+func (lb *LoadBalancer) SetStrategy(strategy Strategy) {
+	if strategy == nil {
+		strategy = LeastUsed()
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.strategy = strategy
+}
+
+// SetRateLimit 设置每个APIKey的速率限制
+func (lb *LoadBalancer) SetRateLimit(rateLimit RateLimit) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.rateLimit = rateLimit
+	if lb.shared == nil && rateLimit.Requests > 0 && rateLimit.Window > 0 {
+		lb.shared = NewMemoryState()
+	}
 }
 
 // SetAvailability 设置指定APIKey的可用性
@@ -95,16 +384,17 @@ func (lb *LoadBalancer) SetAvailability(key string, available bool) (err error)
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	// 获取APIKey的索引
-	index := slices.IndexFunc(lb.apiKeyList, func(apiKey *APIKey) bool {
-		return apiKey.Key == key
-	})
+	// 获取APIKey
+	apiKey := lb.find(key)
 	// 如果APIKey不存在，则返回错误
-	if index == -1 {
+	if apiKey == nil {
 		return errAPIKeyNotFound
 	}
-	// 设置APIKey的可用性
-	lb.apiKeyList[index].Available = available
+	// 设置APIKey的可用性，重新启用时同时恢复健康状态
+	apiKey.Available = available
+	if available {
+		apiKey.health.reset()
+	}
 	return
 }
 
@@ -124,11 +414,7 @@ func (lb *LoadBalancer) RegisterAPIKey(key string) (err error) {
 		lb.apiKeyList = make([]*APIKey, 0)
 	}
 
-	lb.apiKeyList = append(lb.apiKeyList, &APIKey{
-		Key:       key,
-		Available: true,
-		Weight:    1, // 默认权重为1
-	})
+	lb.apiKeyList = append(lb.apiKeyList, newAPIKey(key))
 	return
 }
 
@@ -179,6 +465,9 @@ func (lb *LoadBalancer) SetAvailabilityForAll(available bool) {
 
 	for _, apiKey := range lb.apiKeyList {
 		apiKey.Available = available
+		if available {
+			apiKey.health.reset()
+		}
 	}
 }
 
@@ -195,6 +484,9 @@ func (lb *LoadBalancer) GetAPIKeyList() (apiKeyList []*APIKey) {
 			Times:     apiKey.Times,
 			Available: apiKey.Available,
 			Weight:    apiKey.Weight,
+			id:        apiKey.id,
+			inFlight:  apiKey.inFlight,
+			latency:   apiKey.latency,
 		}
 	}
 	return
@@ -209,18 +501,63 @@ func (lb *LoadBalancer) GetStats() (stats map[string]any) {
 	var (
 		totalAPIKey     = len(lb.apiKeyList)
 		availableAPIKey = 0
+		cooldownAPIKey  = 0
+		disabledAPIKey  = 0
 		totalRequests   = uint32(0)
+		apiKeys         = make([]map[string]any, 0, totalAPIKey)
+		now             = lb.now()
 	)
 
 	for _, apiKey := range lb.apiKeyList {
-		if apiKey.Available {
+		state := apiKey.health.currentState(now)
+		switch {
+		case state == KeyStateCooldown:
+			cooldownAPIKey++
+		case state == KeyStateDisabled:
+			disabledAPIKey++
+		case apiKey.Available:
 			availableAPIKey++
 		}
 		totalRequests += apiKey.Times
+		// 单个APIKey的统计信息，密钥中间部分被隐藏
+		keyStats := map[string]any{
+			"key":                  maskKey(apiKey.Key),
+			"available":            apiKey.Available,
+			"weight":               apiKey.Weight,
+			"requests":             apiKey.Times,
+			"successes":            apiKey.health.successes,
+			"errors":               apiKey.health.errors,
+			"state":                state,
+			"consecutive_failures": apiKey.health.failures,
+			"in_flight":            apiKey.inFlight,
+			"latency":              time.Duration(apiKey.latency),
+		}
+		if state != KeyStateActive {
+			keyStats["until"] = apiKey.health.until
+		}
+		apiKeys = append(apiKeys, keyStats)
 	}
 
+	stats["strategy"] = lb.strategy.Name()
 	stats["total_api_key"] = totalAPIKey
 	stats["available_api_key"] = availableAPIKey
+	stats["cooldown_api_key"] = cooldownAPIKey
+	stats["disabled_api_key"] = disabledAPIKey
 	stats["total_requests"] = totalRequests
+	if lb.syncShared {
+		stats["shared_state_errors"] = lb.sharedErrors
+	}
+	stats["api_keys"] = apiKeys
 	return stats
 }
+
+// find 查找指定APIKey，调用方需持有锁
+func (lb *LoadBalancer) find(key string) (apiKey *APIKey) {
+	index := slices.IndexFunc(lb.apiKeyList, func(apiKey *APIKey) bool {
+		return apiKey.Key == key
+	})
+	if index == -1 {
+		return nil
+	}
+	return lb.apiKeyList[index]
+}
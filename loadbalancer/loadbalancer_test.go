@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-27 21:30:00
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:12:45
+ * @LastEditTime: 2025-07-25 16:21:37
  * @Description: 负载均衡器测试
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -276,6 +276,44 @@ func TestUnregisterAPIKey(t *testing.T) {
 	})
 }
 
+// TestUpdateAPIKeys tests replacing the API key list in place
+func TestUpdateAPIKeys(t *testing.T) {
+	lb := NewLoadBalancer([]string{"key1", "key2", "key3"})
+	for range 4 {
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := lb.SetWeight("key1", 5); err != nil {
+		t.Fatalf("failed to set weight: %v", err)
+	}
+	if err := lb.ReportResult("key2", OutcomeAuthFailed, 0); err != nil {
+		t.Fatalf("failed to report result: %v", err)
+	}
+
+	added, removed := lb.UpdateAPIKeys([]string{"key2", "key1", "key4", "key4"})
+	if added != 1 || removed != 1 {
+		t.Fatalf("expected 1 added and 1 removed, got %d and %d", added, removed)
+	}
+	apiKeys := make(map[string]*APIKey)
+	for _, apiKey := range lb.GetAPIKeyList() {
+		apiKeys[apiKey.Key] = apiKey
+	}
+	if len(apiKeys) != 3 || apiKeys["key3"] != nil {
+		t.Fatalf("unexpected API keys: %v", apiKeys)
+	}
+	// Unchanged keys keep their counters, weight and health
+	if apiKeys["key1"].Times != 2 || apiKeys["key1"].Weight != 5 {
+		t.Errorf("expected key1 to keep its state, got %+v", apiKeys["key1"])
+	}
+	if disabled := lb.GetStats()["disabled_api_key"]; disabled != 1 {
+		t.Errorf("expected key2 to stay disabled, got %v disabled keys", disabled)
+	}
+	if apiKeys["key4"].Times != 0 || !apiKeys["key4"].Available {
+		t.Errorf("expected key4 to be new, got %+v", apiKeys["key4"])
+	}
+}
+
 // TestSetWeight tests setting weight
 func TestSetWeight(t *testing.T) {
 	t.Run("set valid weight", func(t *testing.T) {
@@ -0,0 +1,420 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 11:02:19
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 11:02:19
+ * @Description: 基于 Redis 协议（RESP）的共享状态存储，兼容 Redis、Valkey、KeyDB 等
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisAddr        = "127.0.0.1:6379"  // 默认 Redis 地址
+	defaultRedisKeyPrefix   = "aisdk:lb:"       // 默认键前缀
+	defaultRedisPoolSize    = 10                // 默认最大空闲连接数
+	defaultRedisDialTimeout = 5 * time.Second   // 默认连接超时时间
+	defaultRedisIOTimeout   = 3 * time.Second   // 默认读写超时时间
+	redisMaxBulkLength      = 512 * 1024 * 1024 // 批量字符串最大长度
+	redisMaxArrayLength     = 1024 * 1024       // 数组最大长度
+)
+
+var (
+	errRedisStateClosed = errors.New("redis state is closed")     // Redis 共享状态存储已关闭
+	errRedisProtocol    = errors.New("redis protocol error")      // Redis 协议错误
+	errRedisUnexpected  = errors.New("unexpected redis response") // Redis 响应类型不符合预期
+)
+
+// RedisError Redis 服务端返回的错误
+type RedisError string
+
+// Error 错误信息
+func (e RedisError) Error() (errStr string) {
+	return "redis: " + string(e)
+}
+
+// RedisConfig Redis 共享状态存储配置
+type RedisConfig struct {
+	Addr        string        // 地址，默认 127.0.0.1:6379
+	Username    string        // 用户名，Redis 6 ACL 使用
+	Password    string        // 密码
+	DB          int           // 数据库编号
+	KeyPrefix   string        // 键前缀，默认 aisdk:lb:
+	PoolSize    int           // 最大空闲连接数，默认10
+	DialTimeout time.Duration // 连接超时时间，默认5s
+	IOTimeout   time.Duration // 读写超时时间，默认3s，上下文设置了更早的截止时间时以上下文为准
+}
+
+// RedisState 基于 Redis 协议的共享状态存储，使用 INCRBY/MGET/SET PX/PEXPIRE 命令实现，不依赖 Lua 脚本
+//
+// 速率限制的时间窗口按 Unix 时间对齐，各实例的时钟需保持同步
+type RedisState struct {
+	config    RedisConfig     // 配置
+	pool      chan *redisConn // 空闲连接池
+	done      chan struct{}   // 关闭信号
+	closeOnce sync.Once       // 确保只关闭一次
+}
+
+// NewRedisState 创建 Redis 共享状态存储，连接在首次使用时建立
+func NewRedisState(config RedisConfig) (state *RedisState) {
+	if config.Addr == "" {
+		config.Addr = defaultRedisAddr
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultRedisKeyPrefix
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = defaultRedisPoolSize
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultRedisDialTimeout
+	}
+	if config.IOTimeout <= 0 {
+		config.IOTimeout = defaultRedisIOTimeout
+	}
+	return &RedisState{
+		config: config,
+		pool:   make(chan *redisConn, config.PoolSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// IncrUsage 增加使用次数
+func (s *RedisState) IncrUsage(ctx context.Context, id string, delta int64) (total int64, err error) {
+	var replies []any
+	if replies, err = s.do(ctx, []string{"INCRBY", s.key("usage", id), strconv.FormatInt(delta, 10)}); err != nil {
+		return
+	}
+	return replyInt(replies[0])
+}
+
+// Usage 批量获取使用次数
+func (s *RedisState) Usage(ctx context.Context, ids []string) (totals []int64, err error) {
+	var values []string
+	if values, err = s.mget(ctx, "usage", ids); err != nil {
+		return
+	}
+	totals = make([]int64, len(ids))
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		if totals[i], err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid usage %q", errRedisUnexpected, v)
+		}
+	}
+	return
+}
+
+// SetCooldown 设置冷却截止时间，冷却截止后键自动过期
+func (s *RedisState) SetCooldown(ctx context.Context, id string, until time.Time) (err error) {
+	ttl := time.Until(until).Milliseconds()
+	if ttl <= 0 {
+		return
+	}
+	_, err = s.do(ctx, []string{"SET", s.key("cooldown", id), strconv.FormatInt(until.UnixMilli(), 10), "PX", strconv.FormatInt(ttl, 10)})
+	return
+}
+
+// Cooldowns 批量获取冷却截止时间
+func (s *RedisState) Cooldowns(ctx context.Context, ids []string) (until []time.Time, err error) {
+	var values []string
+	if values, err = s.mget(ctx, "cooldown", ids); err != nil {
+		return
+	}
+	until = make([]time.Time, len(ids))
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		var ms int64
+		if ms, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid cooldown %q", errRedisUnexpected, v)
+		}
+		until[i] = time.UnixMilli(ms)
+	}
+	return
+}
+
+// Allow 按固定时间窗口计入一次请求，每个窗口使用独立的计数键并在窗口结束后过期
+func (s *RedisState) Allow(ctx context.Context, id string, limit int64, window time.Duration) (ok bool, retryAfter time.Duration, err error) {
+	var (
+		now   = time.Now()
+		start = windowStart(now, window)
+		key   = s.key("rate", id, strconv.FormatInt(start.UnixMilli(), 10))
+	)
+	var replies []any
+	if replies, err = s.do(ctx,
+		[]string{"INCR", key},
+		[]string{"PEXPIRE", key, strconv.FormatInt(window.Milliseconds()+1000, 10)},
+	); err != nil {
+		return
+	}
+	var count int64
+	if count, err = replyInt(replies[0]); err != nil {
+		return
+	}
+	if count <= limit {
+		return true, 0, nil
+	}
+	return false, start.Add(window).Sub(now), nil
+}
+
+// Close 关闭所有空闲连接，关闭后不能再使用
+func (s *RedisState) Close() (err error) {
+	s.closeOnce.Do(func() { close(s.done) })
+	for {
+		select {
+		case conn := <-s.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// key 生成带前缀的键
+func (s *RedisState) key(parts ...string) (key string) {
+	key = s.config.KeyPrefix
+	for i, part := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		key += part
+	}
+	return
+}
+
+// mget 批量获取值，不存在的键为空字符串
+func (s *RedisState) mget(ctx context.Context, kind string, ids []string) (values []string, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	args := make([]string, 0, len(ids)+1)
+	args = append(args, "MGET")
+	for _, id := range ids {
+		args = append(args, s.key(kind, id))
+	}
+	var replies []any
+	if replies, err = s.do(ctx, args); err != nil {
+		return
+	}
+	items, ok := replies[0].([]any)
+	if !ok || len(items) != len(ids) {
+		return nil, fmt.Errorf("%w: MGET returned %T", errRedisUnexpected, replies[0])
+	}
+	values = make([]string, len(ids))
+	for i, item := range items {
+		values[i], _ = item.(string)
+	}
+	return
+}
+
+// do 以流水线方式执行命令，返回与命令一一对应的响应，任一命令返回错误时返回该错误
+func (s *RedisState) do(ctx context.Context, cmds ...[]string) (replies []any, err error) {
+	var conn *redisConn
+	if conn, err = s.get(ctx); err != nil {
+		return
+	}
+	if replies, err = conn.do(ctx, s.config.IOTimeout, cmds...); err != nil {
+		var redisErr RedisError
+		if !errors.As(err, &redisErr) {
+			// 网络或协议错误时连接状态未知，丢弃连接
+			conn.Close()
+			return
+		}
+	}
+	s.put(conn)
+	return
+}
+
+// get 从连接池获取连接，没有空闲连接时新建连接
+func (s *RedisState) get(ctx context.Context) (conn *redisConn, err error) {
+	select {
+	case <-s.done:
+		return nil, errRedisStateClosed
+	case conn = <-s.pool:
+		return
+	default:
+	}
+	dialer := net.Dialer{Timeout: s.config.DialTimeout}
+	var c net.Conn
+	if c, err = dialer.DialContext(ctx, "tcp", s.config.Addr); err != nil {
+		return
+	}
+	conn = &redisConn{
+		Conn: c,
+		r:    bufio.NewReader(c),
+		w:    bufio.NewWriter(c),
+	}
+	// 认证并选择数据库
+	var cmds [][]string
+	if s.config.Password != "" {
+		if s.config.Username != "" {
+			cmds = append(cmds, []string{"AUTH", s.config.Username, s.config.Password})
+		} else {
+			cmds = append(cmds, []string{"AUTH", s.config.Password})
+		}
+	}
+	if s.config.DB != 0 {
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(s.config.DB)})
+	}
+	if len(cmds) > 0 {
+		if _, err = conn.do(ctx, s.config.IOTimeout, cmds...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return
+}
+
+// put 将连接放回连接池，连接池已满或已关闭时关闭连接
+func (s *RedisState) put(conn *redisConn) {
+	select {
+	case <-s.done:
+		conn.Close()
+		return
+	default:
+	}
+	select {
+	case s.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// redisConn Redis 连接
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do 发送命令并读取响应
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, cmds ...[]string) (replies []any, err error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err = c.SetDeadline(deadline); err != nil {
+		return
+	}
+	for _, cmd := range cmds {
+		writeCommand(c.w, cmd)
+	}
+	if err = c.w.Flush(); err != nil {
+		return
+	}
+	replies = make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		if replies[i], err = readReply(c.r); err != nil {
+			var redisErr RedisError
+			if !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			// 读取完所有响应以保持连接可用
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return replies, firstErr
+}
+
+// writeCommand 按 RESP 数组格式写入命令
+func writeCommand(w *bufio.Writer, args []string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		w.WriteString(arg)
+		w.WriteString("\r\n")
+	}
+}
+
+// readReply 读取一个 RESP 响应：简单字符串和批量字符串返回 string，整数返回 int64，数组返回 []any，空值返回 nil
+func readReply(r *bufio.Reader) (reply any, err error) {
+	var line string
+	if line, err = readLine(r); err != nil {
+		return
+	}
+	if line == "" {
+		return nil, errRedisProtocol
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		var n int
+		if n, err = strconv.Atoi(line[1:]); err != nil || n > redisMaxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", errRedisProtocol, line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return
+		}
+		return string(buf[:n]), nil
+	case '*':
+		var n int
+		if n, err = strconv.Atoi(line[1:]); err != nil || n > redisMaxArrayLength {
+			return nil, fmt.Errorf("%w: invalid array length %q", errRedisProtocol, line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			// 数组中的错误作为元素返回，避免连接中残留未读取的数据
+			if items[i], err = readReply(r); err != nil {
+				var redisErr RedisError
+				if !errors.As(err, &redisErr) {
+					return
+				}
+				items[i], err = redisErr, nil
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected reply %q", errRedisProtocol, line)
+}
+
+// readLine 读取一行并去除结尾的 \r\n
+func readLine(r *bufio.Reader) (line string, err error) {
+	if line, err = r.ReadString('\n'); err != nil {
+		return
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("%w: invalid line %q", errRedisProtocol, line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// replyInt 将响应转换为整数
+func replyInt(reply any) (n int64, err error) {
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("%w: expected integer, got %T", errRedisUnexpected, reply)
+}
@@ -0,0 +1,144 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 09:35:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 09:35:42
+ * @Description: 多实例共享的API密钥状态存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SharedState 多个SDK实例间共享的API密钥状态存储，用于协调使用次数、冷却状态和速率限制，实现需并发安全
+//
+// 存储中只使用密钥的哈希标识，不保存密钥本身
+type SharedState interface {
+	// IncrUsage 增加API密钥的使用次数，返回所有实例累计的使用次数
+	IncrUsage(ctx context.Context, id string, delta int64) (total int64, err error)
+	// Usage 批量获取API密钥累计的使用次数，不存在时为0
+	Usage(ctx context.Context, ids []string) (totals []int64, err error)
+	// SetCooldown 设置API密钥的冷却截止时间，所有实例在截止前都不会选择该API密钥
+	SetCooldown(ctx context.Context, id string, until time.Time) (err error)
+	// Cooldowns 批量获取API密钥的冷却截止时间，未冷却时为零值
+	Cooldowns(ctx context.Context, ids []string) (until []time.Time, err error)
+	// Allow 按固定时间窗口的速率限制为API密钥计入一次请求，超出限制时返回 false 及当前窗口的剩余时间
+	Allow(ctx context.Context, id string, limit int64, window time.Duration) (ok bool, retryAfter time.Duration, err error)
+}
+
+// RateLimit 速率限制
+type RateLimit struct {
+	Requests int64         // 每个时间窗口内允许的请求数，小于等于0时不限制
+	Window   time.Duration // 时间窗口
+}
+
+// keyID 计算API密钥在共享状态中的标识
+func keyID(key string) (id string) {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// windowStart 计算 now 所在固定时间窗口的起始时间，所有实例按 Unix 时间对齐
+func windowStart(now time.Time, window time.Duration) (start time.Time) {
+	return time.Unix(0, now.UnixNano()-now.UnixNano()%int64(window))
+}
+
+// rateWindow 速率限制窗口
+type rateWindow struct {
+	start time.Time // 窗口起始时间
+	count int64     // 窗口内请求数
+}
+
+// MemoryState 内存共享状态存储，用于同一进程内多个负载均衡器共享状态
+type MemoryState struct {
+	usage     map[string]int64       // 使用次数
+	cooldowns map[string]time.Time   // 冷却截止时间
+	windows   map[string]*rateWindow // 速率限制窗口
+	now       func() time.Time       // 当前时间，便于测试
+	mu        sync.Mutex             // 互斥锁
+}
+
+// NewMemoryState 创建内存共享状态存储
+func NewMemoryState() (state *MemoryState) {
+	return &MemoryState{
+		usage:     make(map[string]int64),
+		cooldowns: make(map[string]time.Time),
+		windows:   make(map[string]*rateWindow),
+		now:       time.Now,
+	}
+}
+
+// IncrUsage 增加使用次数
+func (s *MemoryState) IncrUsage(ctx context.Context, id string, delta int64) (total int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.usage[id] += delta
+	return s.usage[id], nil
+}
+
+// Usage 批量获取使用次数
+func (s *MemoryState) Usage(ctx context.Context, ids []string) (totals []int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totals = make([]int64, len(ids))
+	for i, id := range ids {
+		totals[i] = s.usage[id]
+	}
+	return
+}
+
+// SetCooldown 设置冷却截止时间
+func (s *MemoryState) SetCooldown(ctx context.Context, id string, until time.Time) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cooldowns[id] = until
+	return
+}
+
+// Cooldowns 批量获取冷却截止时间
+func (s *MemoryState) Cooldowns(ctx context.Context, ids []string) (until []time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	until = make([]time.Time, len(ids))
+	for i, id := range ids {
+		if t, ok := s.cooldowns[id]; ok {
+			if !now.Before(t) {
+				delete(s.cooldowns, id)
+				continue
+			}
+			until[i] = t
+		}
+	}
+	return
+}
+
+// Allow 按固定时间窗口计入一次请求
+func (s *MemoryState) Allow(ctx context.Context, id string, limit int64, window time.Duration) (ok bool, retryAfter time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	start := windowStart(now, window)
+	w, exists := s.windows[id]
+	if !exists || !w.start.Equal(start) {
+		w = &rateWindow{start: start}
+		s.windows[id] = w
+	}
+	w.count++
+	if w.count <= limit {
+		return true, 0, nil
+	}
+	return false, start.Add(window).Sub(now), nil
+}
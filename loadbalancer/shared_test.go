@@ -0,0 +1,332 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 15:21:48
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 15:21:48
+ * @Description: 多实例共享状态测试
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a minimal in-process server speaking the Redis protocol
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	data     map[string]string
+	expireAt map[string]time.Time
+	mu       sync.Mutex
+}
+
+// newFakeRedis starts a fake Redis server that is closed when the test ends
+func newFakeRedis(t *testing.T, password string) (s *fakeRedis) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s = &fakeRedis{
+		ln:       ln,
+		password: password,
+		data:     make(map[string]string),
+		expireAt: make(map[string]time.Time),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return
+}
+
+// addr returns the server address
+func (s *fakeRedis) addr() (addr string) {
+	return s.ln.Addr().String()
+}
+
+// serve handles commands on a connection
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	var (
+		r      = bufio.NewReader(conn)
+		w      = bufio.NewWriter(conn)
+		authed = s.password == ""
+	)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "AUTH":
+			if args[len(args)-1] != s.password {
+				w.WriteString("-WRONGPASS invalid password\r\n")
+			} else {
+				authed = true
+				w.WriteString("+OK\r\n")
+			}
+		case !authed:
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		default:
+			w.WriteString(s.exec(cmd, args[1:]))
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec executes a command and returns the encoded reply
+func (s *fakeRedis) exec(cmd string, args []string) (reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range s.expireAt {
+		if !time.Now().Before(t) {
+			delete(s.data, key)
+			delete(s.expireAt, key)
+		}
+	}
+	switch cmd {
+	case "SELECT":
+		return "+OK\r\n"
+	case "INCR", "INCRBY":
+		delta := int64(1)
+		if cmd == "INCRBY" {
+			delta, _ = strconv.ParseInt(args[1], 10, 64)
+		}
+		n, err := strconv.ParseInt(s.getOrZero(args[0]), 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		n += delta
+		s.data[args[0]] = strconv.FormatInt(n, 10)
+		return ":" + strconv.FormatInt(n, 10) + "\r\n"
+	case "MGET":
+		reply = "*" + strconv.Itoa(len(args)) + "\r\n"
+		for _, key := range args {
+			if v, ok := s.data[key]; ok {
+				reply += "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+		return
+	case "SET":
+		s.data[args[0]] = args[1]
+		delete(s.expireAt, args[0])
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			ms, _ := strconv.ParseInt(args[3], 10, 64)
+			s.expireAt[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "PEXPIRE":
+		if _, ok := s.data[args[0]]; !ok {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.ParseInt(args[1], 10, 64)
+		s.expireAt[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+// getOrZero returns the value of key, or "0" if it does not exist
+func (s *fakeRedis) getOrZero(key string) (value string) {
+	if v, ok := s.data[key]; ok {
+		return v
+	}
+	return "0"
+}
+
+// TestRedisState tests the Redis-protocol shared state against a fake server
+func TestRedisState(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	state := NewRedisState(RedisConfig{Addr: server.addr(), Password: "secret", DB: 1, PoolSize: 2})
+	defer state.Close()
+	ctx := context.Background()
+
+	t.Run("usage counters", func(t *testing.T) {
+		if total, err := state.IncrUsage(ctx, "a", 2); err != nil || total != 2 {
+			t.Fatalf("expected 2, got %d, %v", total, err)
+		}
+		if total, err := state.IncrUsage(ctx, "a", 1); err != nil || total != 3 {
+			t.Fatalf("expected 3, got %d, %v", total, err)
+		}
+		totals, err := state.Usage(ctx, []string{"a", "b"})
+		if err != nil || len(totals) != 2 || totals[0] != 3 || totals[1] != 0 {
+			t.Fatalf("unexpected usage: %v, %v", totals, err)
+		}
+	})
+
+	t.Run("cooldowns", func(t *testing.T) {
+		until := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		if err := state.SetCooldown(ctx, "a", until); err != nil {
+			t.Fatalf("failed to set cooldown: %v", err)
+		}
+		cooldowns, err := state.Cooldowns(ctx, []string{"a", "b"})
+		if err != nil || !cooldowns[0].Equal(until) || !cooldowns[1].IsZero() {
+			t.Fatalf("unexpected cooldowns: %v, %v", cooldowns, err)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		for i := range 3 {
+			ok, retryAfter, err := state.Allow(ctx, "a", 2, time.Hour)
+			if err != nil {
+				t.Fatalf("failed to check rate limit: %v", err)
+			}
+			if want := i < 2; ok != want || (!ok && (retryAfter <= 0 || retryAfter > time.Hour)) {
+				t.Fatalf("request %d: expected %v, got %v (retry after %v)", i+1, want, ok, retryAfter)
+			}
+		}
+	})
+
+	t.Run("server errors keep the connection usable", func(t *testing.T) {
+		server.mu.Lock()
+		server.data[state.key("usage", "bad")] = "x"
+		server.mu.Unlock()
+
+		var redisErr RedisError
+		if _, err := state.IncrUsage(ctx, "bad", 1); !errors.As(err, &redisErr) {
+			t.Fatalf("expected RedisError, got %v", err)
+		}
+		if _, err := state.IncrUsage(ctx, "a", 1); err != nil {
+			t.Fatalf("unexpected error after server error: %v", err)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		bad := NewRedisState(RedisConfig{Addr: server.addr(), Password: "wrong"})
+		defer bad.Close()
+		if _, err := bad.IncrUsage(ctx, "a", 1); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Fatalf("expected WRONGPASS error, got %v", err)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		closed := NewRedisState(RedisConfig{Addr: server.addr(), Password: "secret"})
+		closed.Close()
+		if _, err := closed.IncrUsage(ctx, "a", 1); !errors.Is(err, errRedisStateClosed) {
+			t.Fatalf("expected errRedisStateClosed, got %v", err)
+		}
+	})
+}
+
+// TestSharedLoadBalancers tests coordination of load balancers sharing state
+func TestSharedLoadBalancers(t *testing.T) {
+	server := newFakeRedis(t, "")
+	backends := []struct {
+		name     string
+		newState func() (state SharedState)
+	}{
+		{"memory", func() SharedState { return NewMemoryState() }},
+		{"redis", func() SharedState {
+			state := NewRedisState(RedisConfig{Addr: server.addr(), KeyPrefix: "test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"})
+			t.Cleanup(func() { state.Close() })
+			return state
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			// Replicas share one state backend
+			state := backend.newState()
+			newReplica := func(config Config) (lb *LoadBalancer) {
+				config.Shared = state
+				config.SyncInterval = time.Nanosecond
+				return NewLoadBalancerWithConfig([]string{"key1", "key2"}, config)
+			}
+
+			t.Run("least used across replicas", func(t *testing.T) {
+				a, b := newReplica(Config{}), newReplica(Config{})
+				for _, want := range []string{"key1", "key2", "key1"} {
+					if apiKey, err := a.GetAPIKey(); err != nil || apiKey.Key != want {
+						t.Fatalf("replica a: expected %s, got %v, %v", want, apiKey, err)
+					}
+				}
+				// Replica b sees key1 used twice and key2 once
+				if apiKey, err := b.GetAPIKey(); err != nil || apiKey.Key != "key2" {
+					t.Fatalf("replica b: expected key2, got %v, %v", apiKey, err)
+				}
+				if stats := b.GetStats(); stats["total_requests"] != uint32(4) || stats["shared_state_errors"] != uint64(0) {
+					t.Fatalf("unexpected stats: %v", stats)
+				}
+			})
+
+			t.Run("cooldown shared across replicas", func(t *testing.T) {
+				a, b := newReplica(Config{}), newReplica(Config{})
+				if err := a.ReportResult("key1", OutcomeRateLimited, time.Minute); err != nil {
+					t.Fatalf("failed to report result: %v", err)
+				}
+				for range 3 {
+					if apiKey, err := b.GetAPIKey(); err != nil || apiKey.Key != "key2" {
+						t.Fatalf("replica b: expected key2 while key1 cools down, got %v, %v", apiKey, err)
+					}
+				}
+			})
+
+			t.Run("rate limit shared across replicas", func(t *testing.T) {
+				// Use fresh keys so the usage above does not matter
+				config := Config{Shared: state, SyncInterval: time.Nanosecond, RateLimit: RateLimit{Requests: 2, Window: time.Hour}}
+				a := NewLoadBalancerWithConfig([]string{"limited"}, config)
+				b := NewLoadBalancerWithConfig([]string{"limited"}, config)
+				ctx := context.Background()
+				if _, err := a.Acquire(ctx); err != nil {
+					t.Fatalf("replica a: %v", err)
+				}
+				if _, err := b.Acquire(ctx); err != nil {
+					t.Fatalf("replica b: %v", err)
+				}
+				if _, err := a.Acquire(ctx); err != errNoAPIKeyAvailable {
+					t.Fatalf("expected the shared limit to be exceeded, got %v", err)
+				}
+				// Rejected requests are not counted as in flight
+				if apiKey := a.GetAPIKeyList()[0]; apiKey.InFlight() != 1 {
+					t.Fatalf("expected 1 in-flight request, got %d", apiKey.InFlight())
+				}
+			})
+		})
+	}
+}
+
+// TestLocalRateLimit tests the rate limit without shared state
+func TestLocalRateLimit(t *testing.T) {
+	lb := NewLoadBalancerWithConfig([]string{"key1", "key2"}, Config{RateLimit: RateLimit{Requests: 1, Window: time.Hour}})
+	for range 2 {
+		if _, err := lb.GetAPIKey(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := lb.GetAPIKey(); err != errNoAPIKeyAvailable {
+		t.Fatalf("expected errNoAPIKeyAvailable, got %v", err)
+	}
+	if _, ok := lb.GetStats()["shared_state_errors"]; ok {
+		t.Fatal("unexpected shared state errors in stats without shared state")
+	}
+}
@@ -0,0 +1,254 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 09:42:15
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-23 09:42:15
+ * @Description: 负载均衡策略
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// 负载均衡策略名称
+const (
+	StrategyLeastUsed      = "least_used"      // 最少使用（按权重），默认策略
+	StrategyRoundRobin     = "round_robin"     // 轮询
+	StrategyWeightedRandom = "weighted_random" // 加权随机
+	StrategyLeastInFlight  = "least_in_flight" // 最少并发请求（按权重）
+	StrategyEWMALatency    = "ewma_latency"    // 延迟感知，按指数加权移动平均延迟选择
+	StrategyStickyUser     = "sticky_user"     // 按用户粘滞，同一用户固定使用同一APIKey
+)
+
+var (
+	errUnknownStrategy = errors.New("unknown load balancing strategy") // 未知的负载均衡策略
+)
+
+// Strategy 负载均衡策略，实现需并发安全
+type Strategy interface {
+	// Name 策略名称
+	Name() (name string)
+	// Select 从候选APIKey中选择一个，candidates 非空且只包含当前可用的APIKey，实现不能保留 candidates
+	Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey)
+}
+
+// NewStrategy 根据名称创建负载均衡策略，名称为空时使用默认策略
+func NewStrategy(name string) (strategy Strategy, err error) {
+	switch name {
+	case "", StrategyLeastUsed:
+		return LeastUsed(), nil
+	case StrategyRoundRobin:
+		return RoundRobin(), nil
+	case StrategyWeightedRandom:
+		return WeightedRandom(), nil
+	case StrategyLeastInFlight:
+		return LeastInFlight(), nil
+	case StrategyEWMALatency:
+		return EWMALatency(), nil
+	case StrategyStickyUser:
+		return StickyUser(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", errUnknownStrategy, name)
+}
+
+// userKey 上下文中用户标识的键
+type userKey struct{}
+
+// WithUser 将终端用户标识设置到上下文，供按用户粘滞策略使用
+func WithUser(ctx context.Context, user string) (newCtx context.Context) {
+	if user == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext 从上下文获取终端用户标识
+func UserFromContext(ctx context.Context) (user string) {
+	user, _ = ctx.Value(userKey{}).(string)
+	return
+}
+
+// leastUsed 最少使用策略
+type leastUsed struct{}
+
+// LeastUsed 最少使用策略，选择 请求次数/权重 最小的APIKey
+func LeastUsed() (strategy Strategy) {
+	return leastUsed{}
+}
+
+// Name 策略名称
+func (leastUsed) Name() (name string) {
+	return StrategyLeastUsed
+}
+
+// Select 选择APIKey
+func (leastUsed) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	return minBy(candidates, func(k *APIKey) float64 {
+		return float64(k.Times) / float64(k.Weight)
+	})
+}
+
+// roundRobin 轮询策略
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+// RoundRobin 轮询策略，依次选择候选APIKey，不考虑权重
+func RoundRobin() (strategy Strategy) {
+	return &roundRobin{}
+}
+
+// Name 策略名称
+func (*roundRobin) Name() (name string) {
+	return StrategyRoundRobin
+}
+
+// Select 选择APIKey
+func (s *roundRobin) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	n := s.next.Add(1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// weightedRandom 加权随机策略
+type weightedRandom struct{}
+
+// WeightedRandom 加权随机策略，按权重比例随机选择APIKey
+func WeightedRandom() (strategy Strategy) {
+	return weightedRandom{}
+}
+
+// Name 策略名称
+func (weightedRandom) Name() (name string) {
+	return StrategyWeightedRandom
+}
+
+// Select 选择APIKey
+func (weightedRandom) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	var total uint64
+	for _, k := range candidates {
+		total += uint64(k.Weight)
+	}
+	n := rand.N(total)
+	for _, k := range candidates {
+		if n < uint64(k.Weight) {
+			return k
+		}
+		n -= uint64(k.Weight)
+	}
+	return candidates[len(candidates)-1]
+}
+
+// leastInFlight 最少并发请求策略
+type leastInFlight struct{}
+
+// LeastInFlight 最少并发请求策略，选择 并发请求数/权重 最小的APIKey，相同时选择使用次数最少的
+//
+// 并发请求数通过 LoadBalancer.Acquire 和 LoadBalancer.Release 统计
+func LeastInFlight() (strategy Strategy) {
+	return leastInFlight{}
+}
+
+// Name 策略名称
+func (leastInFlight) Name() (name string) {
+	return StrategyLeastInFlight
+}
+
+// Select 选择APIKey
+func (leastInFlight) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	var (
+		minInFlight = math.MaxFloat64
+		minTimes    = math.MaxFloat64
+	)
+	for _, k := range candidates {
+		inFlight := float64(k.inFlight) / float64(k.Weight)
+		times := float64(k.Times) / float64(k.Weight)
+		if inFlight < minInFlight || (inFlight == minInFlight && times < minTimes) {
+			apiKey, minInFlight, minTimes = k, inFlight, times
+		}
+	}
+	return
+}
+
+// ewmaLatency 延迟感知策略
+type ewmaLatency struct{}
+
+// EWMALatency 延迟感知策略，选择 指数加权移动平均延迟*(并发请求数+1)/权重 最小的APIKey，尚无延迟数据的APIKey优先
+//
+// 延迟通过 LoadBalancer.ObserveLatency 统计
+func EWMALatency() (strategy Strategy) {
+	return ewmaLatency{}
+}
+
+// Name 策略名称
+func (ewmaLatency) Name() (name string) {
+	return StrategyEWMALatency
+}
+
+// Select 选择APIKey
+func (ewmaLatency) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	// 优先探测尚无延迟数据的APIKey
+	if apiKey = minBy(candidates, func(k *APIKey) float64 {
+		if k.latency > 0 {
+			return math.Inf(1)
+		}
+		return float64(k.Times) / float64(k.Weight)
+	}); apiKey.latency == 0 {
+		return
+	}
+	return minBy(candidates, func(k *APIKey) float64 {
+		return k.latency * float64(k.inFlight+1) / float64(k.Weight)
+	})
+}
+
+// stickyUser 按用户粘滞策略
+type stickyUser struct{}
+
+// StickyUser 按用户粘滞策略，使用加权最高随机权重哈希（rendezvous hashing）将同一用户固定到同一APIKey，
+// 候选APIKey变化时只有原APIKey不可用的用户会被重新分配；上下文中没有用户标识时使用最少使用策略
+//
+// 用户标识通过 WithUser 设置到上下文
+func StickyUser() (strategy Strategy) {
+	return stickyUser{}
+}
+
+// Name 策略名称
+func (stickyUser) Name() (name string) {
+	return StrategyStickyUser
+}
+
+// Select 选择APIKey
+func (stickyUser) Select(ctx context.Context, candidates []*APIKey) (apiKey *APIKey) {
+	user := UserFromContext(ctx)
+	if user == "" {
+		return leastUsed{}.Select(ctx, candidates)
+	}
+	return minBy(candidates, func(k *APIKey) float64 {
+		h := fnv.New64a()
+		h.Write([]byte(user))
+		h.Write([]byte{0})
+		h.Write([]byte(k.Key))
+		// 将哈希值映射到 (0,1)，得分越小越优先
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		return -math.Log(u) / float64(k.Weight)
+	})
+}
+
+// minBy 选择得分最小的APIKey，得分相同时选择靠前的
+func minBy(candidates []*APIKey, score func(k *APIKey) float64) (apiKey *APIKey) {
+	minScore := math.Inf(1)
+	for _, k := range candidates {
+		if s := score(k); apiKey == nil || s < minScore {
+			apiKey, minScore = k, s
+		}
+	}
+	return
+}
@@ -0,0 +1,190 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 14:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-23 14:12:36
+ * @Description: 负载均衡策略测试
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newStrategyLB creates a load balancer with the named strategy
+func newStrategyLB(t *testing.T, name string, keys ...string) (lb *LoadBalancer) {
+	t.Helper()
+	strategy, err := NewStrategy(name)
+	if err != nil {
+		t.Fatalf("failed to create strategy: %v", err)
+	}
+	if strategy.Name() != name {
+		t.Fatalf("expected strategy %q, got %q", name, strategy.Name())
+	}
+	return NewLoadBalancerWithConfig(keys, Config{Strategy: strategy})
+}
+
+// TestNewStrategy tests creating strategies by name
+func TestNewStrategy(t *testing.T) {
+	t.Run("default strategy", func(t *testing.T) {
+		strategy, err := NewStrategy("")
+		if err != nil || strategy.Name() != StrategyLeastUsed {
+			t.Errorf("expected least used strategy, got %v, %v", strategy, err)
+		}
+		if stats := NewLoadBalancer(nil).GetStats(); stats["strategy"] != StrategyLeastUsed {
+			t.Errorf("expected least used strategy by default, got %v", stats["strategy"])
+		}
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		if _, err := NewStrategy("random"); !errors.Is(err, errUnknownStrategy) {
+			t.Errorf("expected errUnknownStrategy, got %v", err)
+		}
+	})
+}
+
+// TestRoundRobin tests the round-robin strategy
+func TestRoundRobin(t *testing.T) {
+	lb := newStrategyLB(t, StrategyRoundRobin, "key1", "key2", "key3")
+	lb.SetWeight("key1", 10)
+
+	var got []string
+	for range 6 {
+		apiKey, err := lb.GetAPIKey()
+		if err != nil {
+			t.Fatalf("failed to get API key: %v", err)
+		}
+		got = append(got, apiKey.Key)
+	}
+	if fmt.Sprint(got) != "[key1 key2 key3 key1 key2 key3]" {
+		t.Errorf("unexpected order: %v", got)
+	}
+}
+
+// TestWeightedRandom tests the weighted random strategy
+func TestWeightedRandom(t *testing.T) {
+	lb := newStrategyLB(t, StrategyWeightedRandom, "key1", "key2")
+	lb.SetWeight("key1", 9)
+
+	counts := make(map[string]int)
+	for range 10000 {
+		apiKey, _ := lb.GetAPIKey()
+		counts[apiKey.Key]++
+	}
+	// key1 is expected to be selected about 90% of the time
+	if counts["key1"] < 8500 || counts["key1"] > 9500 {
+		t.Errorf("unexpected distribution: %v", counts)
+	}
+}
+
+// TestLeastInFlight tests the least-in-flight strategy
+func TestLeastInFlight(t *testing.T) {
+	lb := newStrategyLB(t, StrategyLeastInFlight, "key1", "key2")
+	ctx := context.Background()
+
+	first, _ := lb.Acquire(ctx)
+	second, _ := lb.Acquire(ctx)
+	if first.Key == second.Key {
+		t.Fatalf("expected different keys, got %s twice", first.Key)
+	}
+	// Releasing key2 makes it the only key without in-flight requests
+	lb.Release("key2")
+	for range 2 {
+		apiKey, _ := lb.Acquire(ctx)
+		if apiKey.Key != "key2" {
+			t.Fatalf("expected key2, got %s", apiKey.Key)
+		}
+		if apiKey.InFlight() != 1 {
+			t.Fatalf("expected 1 in-flight request, got %d", apiKey.InFlight())
+		}
+		lb.Release("key2")
+	}
+
+	// GetAPIKey does not count in-flight requests
+	lb.GetAPIKey()
+	for _, apiKey := range lb.GetAPIKeyList() {
+		if want := map[string]int64{"key1": 1, "key2": 0}[apiKey.Key]; apiKey.InFlight() != want {
+			t.Errorf("%s: expected %d in-flight requests, got %d", apiKey.Key, want, apiKey.InFlight())
+		}
+	}
+}
+
+// TestEWMALatency tests the latency-aware strategy
+func TestEWMALatency(t *testing.T) {
+	lb := newStrategyLB(t, StrategyEWMALatency, "key1", "key2")
+	ctx := context.Background()
+
+	// Keys without latency data are probed first
+	lb.ObserveLatency("key1", 100*time.Millisecond)
+	if apiKey, _ := lb.GetAPIKey(); apiKey.Key != "key2" {
+		t.Fatalf("expected unmeasured key2, got %s", apiKey.Key)
+	}
+	lb.ObserveLatency("key2", 250*time.Millisecond)
+	for range 3 {
+		if apiKey, _ := lb.GetAPIKey(); apiKey.Key != "key1" {
+			t.Fatalf("expected faster key1, got %s", apiKey.Key)
+		}
+	}
+
+	// In-flight requests are taken into account: 100ms*3 > 250ms*1
+	lb.Acquire(ctx)
+	lb.Acquire(ctx)
+	if apiKey, _ := lb.Acquire(ctx); apiKey.Key != "key2" {
+		t.Fatalf("expected key2 while key1 is busy, got %s", apiKey.Key)
+	}
+
+	// Latency is smoothed with EWMA
+	lb.ObserveLatency("key1", 200*time.Millisecond)
+	for _, apiKey := range lb.GetAPIKeyList() {
+		if d := apiKey.Latency() - 130*time.Millisecond; apiKey.Key == "key1" && (d < -time.Microsecond || d > time.Microsecond) {
+			t.Errorf("expected 130ms, got %v", apiKey.Latency())
+		}
+	}
+}
+
+// TestStickyUser tests the sticky-by-user strategy
+func TestStickyUser(t *testing.T) {
+	keys := []string{"key1", "key2", "key3", "key4"}
+	lb := newStrategyLB(t, StrategyStickyUser, keys...)
+
+	assigned := make(map[string]string)
+	used := make(map[string]bool)
+	for i := range 100 {
+		user := fmt.Sprintf("user-%d", i)
+		ctx := WithUser(context.Background(), user)
+		for range 3 {
+			apiKey, _ := lb.Acquire(ctx)
+			if prev, ok := assigned[user]; ok && prev != apiKey.Key {
+				t.Fatalf("%s: expected %s, got %s", user, prev, apiKey.Key)
+			}
+			assigned[user] = apiKey.Key
+			used[apiKey.Key] = true
+		}
+	}
+	if len(used) != len(keys) {
+		t.Errorf("expected users to be spread over all keys, got %v", used)
+	}
+
+	// Only users of an unavailable key are reassigned
+	lb.SetAvailability("key1", false)
+	for user, key := range assigned {
+		apiKey, _ := lb.Acquire(WithUser(context.Background(), user))
+		if key != "key1" && apiKey.Key != key {
+			t.Errorf("%s: expected %s to be kept, got %s", user, key, apiKey.Key)
+		}
+		if apiKey.Key == "key1" {
+			t.Errorf("%s: unavailable key selected", user)
+		}
+	}
+
+	// Without user falls back to least used
+	if _, err := lb.Acquire(context.Background()); err != nil {
+		t.Errorf("failed to get API key without user: %v", err)
+	}
+}
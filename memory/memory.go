@@ -0,0 +1,101 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 11:12:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 11:12:08
+ * @Description: 对话记忆，按模型上下文窗口截断或总结历史消息
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/models"
+	"github.com/liusuxian/go-aisdk/tokenizer"
+)
+
+var (
+	errUnknownBudget = errors.New("token budget is required for models without a registered context window") // 未注册上下文窗口的模型需要指定token预算
+)
+
+// Config 对话记忆配置
+type Config struct {
+	Provider      consts.Provider // 提供商
+	Model         string          // 模型名称
+	Budget        int             // token预算，为0时使用模型上下文窗口减去 ReserveTokens
+	ReserveTokens int             // 为输出预留的token数，默认为模型最大输出token数，且不超过上下文窗口的四分之一
+	Strategy      Strategy        // 截断策略，默认 DropOldestTurns
+}
+
+// Report 截断报告
+type Report struct {
+	Budget         int                  // token预算
+	OriginalTokens int                  // 截断前的token数
+	FinalTokens    int                  // 截断后的token数
+	Dropped        []models.ChatMessage // 被移除的消息
+	Summarized     []models.ChatMessage // 被总结为摘要的消息
+	Summary        string               // 摘要内容
+}
+
+// Truncated 是否移除或总结了消息
+func (r Report) Truncated() (ok bool) {
+	return len(r.Dropped) > 0 || len(r.Summarized) > 0
+}
+
+// Memory 对话记忆
+type Memory struct {
+	config Config
+}
+
+// New 创建对话记忆
+func New(config Config) (m *Memory, err error) {
+	if config.Strategy == nil {
+		config.Strategy = DropOldestTurns{}
+	}
+	if config.Budget <= 0 {
+		spec, ok := tokenizer.LookupModel(config.Provider, config.Model)
+		if !ok || spec.ContextWindow <= 0 {
+			return nil, fmt.Errorf("%w: %s", errUnknownBudget, config.Model)
+		}
+		if config.ReserveTokens <= 0 {
+			config.ReserveTokens = min(spec.MaxOutputTokens, spec.ContextWindow/4)
+		}
+		config.Budget = spec.ContextWindow - config.ReserveTokens
+	}
+	return &Memory{
+		config: config,
+	}, nil
+}
+
+// Fit 截断消息使其不超过token预算，截断后仍超出预算时返回 tokenizer.ErrContextWindowExceeded
+func (m *Memory) Fit(ctx context.Context, messages []models.ChatMessage) (result []models.ChatMessage, report Report, err error) {
+	counter := tokenizer.NewMessageCounter(m.config.Provider, m.config.Model)
+	conv := Conversation{
+		Messages: messages,
+		Budget:   m.config.Budget,
+		Count:    counter.Count,
+	}
+	originalTokens := counter.Count(messages)
+	if originalTokens <= m.config.Budget {
+		return messages, Report{Budget: m.config.Budget, OriginalTokens: originalTokens, FinalTokens: originalTokens}, nil
+	}
+	if result, report, err = m.config.Strategy.Fit(ctx, conv); err != nil {
+		return
+	}
+	report.Budget = m.config.Budget
+	report.OriginalTokens = originalTokens
+	report.FinalTokens = counter.Count(result)
+	if report.FinalTokens > m.config.Budget {
+		err = &tokenizer.ContextWindowExceededError{
+			Provider:      m.config.Provider,
+			Model:         m.config.Model,
+			ContextWindow: m.config.Budget,
+			PromptTokens:  report.FinalTokens,
+		}
+	}
+	return
+}
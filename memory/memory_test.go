@@ -0,0 +1,194 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 15:03:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 15:03:44
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/models"
+	"github.com/liusuxian/go-aisdk/tokenizer"
+	"strings"
+	"testing"
+)
+
+// countMessages 每条消息计 10 个token
+func countMessages(messages []models.ChatMessage) (n int) {
+	return len(messages) * 10
+}
+
+// testConversation 创建测试对话：系统消息 + 三轮对话，第二轮包含工具调用
+func testConversation() (messages []models.ChatMessage) {
+	return []models.ChatMessage{
+		&models.SystemMessage{Content: "system"},
+		&models.UserMessage{Content: "q1"},
+		&models.AssistantMessage{Content: "a1"},
+		&models.UserMessage{Content: "q2"},
+		&models.AssistantMessage{ToolCalls: []models.ToolCalls{
+			{ID: "call_1", Function: &models.ToolCallsFunction{Name: "weather", Arguments: "{}"}},
+			{ID: "call_2", Function: &models.ToolCallsFunction{Name: "time", Arguments: "{}"}},
+		}},
+		&models.ToolMessage{ToolCallID: "call_1", Content: "sunny"},
+		&models.ToolMessage{ToolCallID: "call_2", Content: "noon"},
+		&models.AssistantMessage{Content: "a2"},
+		&models.UserMessage{Content: "q3"},
+	}
+}
+
+// contents 获取消息内容，工具调用消息记为 tool_calls
+func contents(messages []models.ChatMessage) (result []string) {
+	for _, message := range messages {
+		switch msg := message.(type) {
+		case *models.SystemMessage:
+			result = append(result, msg.Content)
+		case *models.UserMessage:
+			result = append(result, msg.Content)
+		case *models.AssistantMessage:
+			if len(msg.ToolCalls) > 0 {
+				result = append(result, "tool_calls")
+			} else {
+				result = append(result, msg.Content)
+			}
+		case *models.ToolMessage:
+			result = append(result, msg.Content)
+		}
+	}
+	return
+}
+
+func TestSplit(t *testing.T) {
+	units := Split(testConversation())
+	if len(units) != 7 {
+		t.Fatalf("expected 7 units, got %d", len(units))
+	}
+	// 工具调用与两个工具结果属于同一单元
+	if toolUnit := units[4]; len(toolUnit.Messages) != 3 || toolUnit.Turn != 2 {
+		t.Fatalf("unexpected tool unit: %+v", toolUnit)
+	}
+	if !units[0].Pinned || units[0].Turn != 0 {
+		t.Fatalf("expected pinned system unit, got %+v", units[0])
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	tests := []struct {
+		strategy Strategy
+		budget   int
+		want     string
+		dropped  int
+	}{
+		// 整轮移除：移除第一轮后仍超出预算，继续移除第二轮
+		{DropOldestTurns{}, 60, "system,q3", 7},
+		{DropOldestTurns{}, 80, "system,q2,tool_calls,sunny,noon,a2,q3", 2},
+		// 逐个单元移除：工具调用与工具结果成对移除
+		{DropOldestMessages{}, 60, "system,tool_calls,sunny,noon,a2,q3", 3},
+		{DropOldestMessages{}, 50, "system,a2,q3", 6},
+	}
+	for _, tt := range tests {
+		messages, report, err := tt.strategy.Fit(context.Background(), Conversation{
+			Messages: testConversation(),
+			Budget:   tt.budget,
+			Count:    countMessages,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(contents(messages), ","); got != tt.want {
+			t.Errorf("%T budget %d: expected %s, got %s", tt.strategy, tt.budget, tt.want, got)
+		}
+		if len(report.Dropped) != tt.dropped {
+			t.Errorf("%T budget %d: expected %d dropped, got %d", tt.strategy, tt.budget, tt.dropped, len(report.Dropped))
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var summarized []models.ChatMessage
+	strategy := Summarize{
+		Summarizer: func(ctx context.Context, messages []models.ChatMessage) (summary string, err error) {
+			summarized = messages
+			return "earlier", nil
+		},
+		SummaryTokens: 10,
+	}
+	messages, report, err := strategy.Fit(context.Background(), Conversation{
+		Messages: testConversation(),
+		Budget:   80,
+		Count:    countMessages,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "system," + summaryPrefix + "earlier,q2,tool_calls,sunny,noon,a2,q3"
+	if got := strings.Join(contents(messages), ","); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if len(summarized) != 2 || len(report.Summarized) != 2 || report.Summary != "earlier" || len(report.Dropped) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// 摘要失败时返回错误
+	strategy.Summarizer = func(ctx context.Context, messages []models.ChatMessage) (summary string, err error) {
+		return "", errors.New("summarize failed")
+	}
+	if _, _, err = strategy.Fit(context.Background(), Conversation{Messages: testConversation(), Budget: 80, Count: countMessages}); err == nil {
+		t.Fatal("expected summarizer error")
+	}
+}
+
+func TestMemoryFit(t *testing.T) {
+	if _, err := New(Config{Provider: consts.DeepSeek, Model: "unknown"}); err == nil {
+		t.Fatal("expected error for unknown model without budget")
+	}
+
+	m, err := New(Config{Provider: consts.DeepSeek, Model: consts.DeepSeekChat, Budget: 40})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	messages, report, err := m.Fit(context.Background(), testConversation())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !report.Truncated() || report.FinalTokens > 40 || report.OriginalTokens <= report.FinalTokens {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if got := strings.Join(contents(messages), ","); got != "system,q3" {
+		t.Fatalf("unexpected messages: %s", got)
+	}
+
+	// 保留的消息本身超出预算时返回错误
+	m, _ = New(Config{Provider: consts.DeepSeek, Model: consts.DeepSeekChat, Budget: 5})
+	if _, _, err = m.Fit(context.Background(), testConversation()); !errors.Is(err, tokenizer.ErrContextWindowExceeded) {
+		t.Fatalf("expected ErrContextWindowExceeded, got %v", err)
+	}
+}
+
+func TestChatSummarizer(t *testing.T) {
+	var request models.ChatRequest
+	summarizer := NewChatSummarizer(func(ctx context.Context, r models.ChatRequest) (response models.ChatResponse, err error) {
+		request = r
+		response.Choices = []models.ChatChoice{{Message: &models.ChatCompletionMessage{Content: " summary \n"}}}
+		return
+	}, consts.OpenAI, consts.OpenAIGPT4oMini)
+
+	summary, err := summarizer(context.Background(), testConversation()[1:3])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary != "summary" {
+		t.Fatalf("expected trimmed summary, got %q", summary)
+	}
+	if request.Model != consts.OpenAIGPT4oMini || len(request.Messages) != 2 {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+	if transcript := request.Messages[1].(*models.UserMessage).Content; transcript != "user: q1\nassistant: a1\n" {
+		t.Fatalf("unexpected transcript: %q", transcript)
+	}
+}
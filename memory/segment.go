@@ -0,0 +1,59 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 09:31:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 09:31:26
+ * @Description: 对话分段
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"github.com/liusuxian/go-aisdk/models"
+)
+
+// Unit 不可拆分的消息单元，工具调用消息与其对应的工具结果消息属于同一单元
+type Unit struct {
+	Messages []models.ChatMessage // 单元内的消息
+	Pinned   bool                 // 是否为固定保留的系统消息或开发者消息
+	Turn     int                  // 所属轮次，每条用户消息开启新的一轮，首条用户消息之前的消息属于第 0 轮
+}
+
+// Split 将消息列表拆分为消息单元
+func Split(messages []models.ChatMessage) (units []Unit) {
+	var turn int
+	for i := 0; i < len(messages); i++ {
+		switch msg := messages[i].(type) {
+		case *models.SystemMessage, *models.DeveloperMessage:
+			units = append(units, Unit{Messages: messages[i : i+1], Pinned: true, Turn: turn})
+		case *models.UserMessage:
+			turn++
+			units = append(units, Unit{Messages: messages[i : i+1], Turn: turn})
+		case *models.AssistantMessage:
+			// 工具调用与紧随其后的工具结果不可拆分
+			end := i + 1
+			if len(msg.ToolCalls) > 0 {
+				for end < len(messages) {
+					if _, ok := messages[end].(*models.ToolMessage); !ok {
+						break
+					}
+					end++
+				}
+			}
+			units = append(units, Unit{Messages: messages[i:end], Turn: turn})
+			i = end - 1
+		default:
+			units = append(units, Unit{Messages: messages[i : i+1], Turn: turn})
+		}
+	}
+	return
+}
+
+// Join 将消息单元合并为消息列表
+func Join(units []Unit) (messages []models.ChatMessage) {
+	for _, unit := range units {
+		messages = append(messages, unit.Messages...)
+	}
+	return
+}
@@ -0,0 +1,205 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 10:04:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 10:04:51
+ * @Description: 对话截断策略
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/models"
+)
+
+const (
+	defaultSummaryTokens = 512                                      // 默认为摘要预留的token数
+	summaryPrefix        = "Summary of the earlier conversation:\n" // 摘要消息前缀
+)
+
+// Counter 计算消息列表的token数量
+type Counter func(messages []models.ChatMessage) (n int)
+
+// Conversation 待截断的对话
+type Conversation struct {
+	Messages []models.ChatMessage // 消息列表
+	Budget   int                  // token预算
+	Count    Counter              // token计数函数
+}
+
+// Strategy 截断策略，系统消息和开发者消息始终保留，最后一个消息单元不会被移除
+type Strategy interface {
+	Fit(ctx context.Context, conv Conversation) (messages []models.ChatMessage, report Report, err error) // 截断对话使其不超过token预算
+}
+
+// DropOldestTurns 按轮次从最早的对话开始整轮移除
+type DropOldestTurns struct{}
+
+// Fit 截断对话
+func (s DropOldestTurns) Fit(ctx context.Context, conv Conversation) (messages []models.ChatMessage, report Report, err error) {
+	return dropOldest(conv, true)
+}
+
+// DropOldestMessages 按消息单元从最早的消息开始逐个移除，工具调用与工具结果成对移除
+type DropOldestMessages struct{}
+
+// Fit 截断对话
+func (s DropOldestMessages) Fit(ctx context.Context, conv Conversation) (messages []models.ChatMessage, report Report, err error) {
+	return dropOldest(conv, false)
+}
+
+// Summarizer 将消息列表总结为摘要文本
+type Summarizer func(ctx context.Context, messages []models.ChatMessage) (summary string, err error)
+
+// Summarize 将较早的对话总结为一条系统消息，摘要后仍超出预算时使用回退策略
+type Summarize struct {
+	Summarizer    Summarizer // 摘要函数
+	SummaryTokens int        // 为摘要预留的token数，默认 512
+	Fallback      Strategy   // 回退策略，默认 DropOldestTurns
+}
+
+// Fit 截断对话
+func (s Summarize) Fit(ctx context.Context, conv Conversation) (messages []models.ChatMessage, report Report, err error) {
+	if s.SummaryTokens <= 0 {
+		s.SummaryTokens = defaultSummaryTokens
+	}
+	if s.Fallback == nil {
+		s.Fallback = DropOldestTurns{}
+	}
+	if conv.Count(conv.Messages) <= conv.Budget {
+		return conv.Messages, Report{}, nil
+	}
+	// 计算需要移除的轮次（为摘要预留空间）
+	var (
+		units     = Split(conv.Messages)
+		dropped   = make([]bool, len(units))
+		summarize []models.ChatMessage
+		insertAt  = -1
+	)
+	budget := conv.Budget - s.SummaryTokens
+	for turn := range turnsOf(units) {
+		if conv.Count(Join(keep(units, dropped))) <= budget {
+			break
+		}
+		for i, unit := range units {
+			if !unit.Pinned && unit.Turn == turn {
+				dropped[i] = true
+			}
+		}
+	}
+	for i, unit := range units {
+		if dropped[i] {
+			summarize = append(summarize, unit.Messages...)
+			if insertAt < 0 {
+				insertAt = i
+			}
+		}
+	}
+	if len(summarize) == 0 {
+		return s.Fallback.Fit(ctx, conv)
+	}
+	// 生成摘要并插入到被摘要的位置
+	var summary string
+	if summary, err = s.Summarizer(ctx, summarize); err != nil {
+		return
+	}
+	var result []Unit
+	for i, unit := range units {
+		if i == insertAt {
+			result = append(result, Unit{Messages: []models.ChatMessage{&models.SystemMessage{Content: summaryPrefix + summary}}, Pinned: true})
+		}
+		if !dropped[i] {
+			result = append(result, unit)
+		}
+	}
+	messages = Join(result)
+	report = Report{Summarized: summarize, Summary: summary}
+	if conv.Count(messages) <= conv.Budget {
+		return
+	}
+	// 摘要超出预留空间时使用回退策略
+	var fallback Report
+	if messages, fallback, err = s.Fallback.Fit(ctx, Conversation{Messages: messages, Budget: conv.Budget, Count: conv.Count}); err != nil {
+		return
+	}
+	report.Dropped = fallback.Dropped
+	return
+}
+
+// dropOldest 从最早的对话开始移除，byTurn 为 true 时整轮移除，否则逐个消息单元移除
+func dropOldest(conv Conversation, byTurn bool) (messages []models.ChatMessage, report Report, err error) {
+	var (
+		units   = Split(conv.Messages)
+		dropped = make([]bool, len(units))
+		last    = lastUnpinned(units)
+	)
+	for i, unit := range units {
+		if conv.Count(Join(keep(units, dropped))) <= conv.Budget {
+			break
+		}
+		if unit.Pinned || dropped[i] || i == last {
+			continue
+		}
+		if !byTurn {
+			dropped[i] = true
+			continue
+		}
+		// 整轮移除，最后一轮完整保留
+		if unit.Turn == units[last].Turn {
+			continue
+		}
+		for j := i; j < len(units); j++ {
+			if units[j].Turn == unit.Turn && !units[j].Pinned {
+				dropped[j] = true
+			}
+		}
+	}
+	for i, unit := range units {
+		if dropped[i] {
+			report.Dropped = append(report.Dropped, unit.Messages...)
+		}
+	}
+	return Join(keep(units, dropped)), report, nil
+}
+
+// keep 获取未被移除的消息单元
+func keep(units []Unit, dropped []bool) (kept []Unit) {
+	for i, unit := range units {
+		if !dropped[i] {
+			kept = append(kept, unit)
+		}
+	}
+	return
+}
+
+// lastUnpinned 获取最后一个非固定消息单元的索引
+func lastUnpinned(units []Unit) (index int) {
+	for i := len(units) - 1; i >= 0; i-- {
+		if !units[i].Pinned {
+			return i
+		}
+	}
+	return -1
+}
+
+// turnsOf 按顺序返回除最后一轮外包含非固定消息的轮次
+func turnsOf(units []Unit) (turns func(yield func(int) bool)) {
+	return func(yield func(int) bool) {
+		last := lastUnpinned(units)
+		if last < 0 {
+			return
+		}
+		seen := make(map[int]bool)
+		for _, unit := range units {
+			if unit.Pinned || seen[unit.Turn] || unit.Turn == units[last].Turn {
+				continue
+			}
+			seen[unit.Turn] = true
+			if !yield(unit.Turn) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 14:25:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 14:25:17
+ * @Description: 基于聊天模型的摘要函数
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/models"
+	"strings"
+)
+
+const (
+	// DefaultSummaryPrompt 默认摘要提示词
+	DefaultSummaryPrompt = "Summarize the following conversation concisely. Preserve facts, decisions, user preferences, " +
+		"open questions and any tool results that later messages may rely on. Reply with the summary only."
+)
+
+var (
+	errEmptySummary = errors.New("empty summary") // 摘要为空
+)
+
+// ChatCompleter 聊天补全函数
+type ChatCompleter func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error)
+
+// NewChatSummarizer 创建调用聊天模型生成摘要的摘要函数
+func NewChatSummarizer(complete ChatCompleter, provider consts.Provider, model string) (summarizer Summarizer) {
+	return func(ctx context.Context, messages []models.ChatMessage) (summary string, err error) {
+		var response models.ChatResponse
+		if response, err = complete(ctx, models.ChatRequest{
+			Provider: provider,
+			Model:    model,
+			Messages: []models.ChatMessage{
+				&models.SystemMessage{Content: DefaultSummaryPrompt},
+				&models.UserMessage{Content: Transcript(messages)},
+			},
+		}); err != nil {
+			return
+		}
+		if len(response.Choices) == 0 || response.Choices[0].Message == nil || response.Choices[0].Message.Content == "" {
+			return "", errEmptySummary
+		}
+		return strings.TrimSpace(response.Choices[0].Message.Content), nil
+	}
+}
+
+// Transcript 将消息列表转换为纯文本对话记录
+func Transcript(messages []models.ChatMessage) (text string) {
+	var sb strings.Builder
+	for _, message := range messages {
+		switch msg := message.(type) {
+		case *models.SystemMessage:
+			fmt.Fprintf(&sb, "system: %s\n", msg.Content)
+		case *models.DeveloperMessage:
+			fmt.Fprintf(&sb, "developer: %s\n", msg.Content)
+		case *models.UserMessage:
+			content := msg.Content
+			for _, part := range msg.MultimodalContent {
+				if part.Text != "" {
+					content += part.Text
+				} else if part.ImageURL != nil {
+					content += "[image]"
+				}
+			}
+			fmt.Fprintf(&sb, "user: %s\n", content)
+		case *models.AssistantMessage:
+			if msg.Content != "" {
+				fmt.Fprintf(&sb, "assistant: %s\n", msg.Content)
+			}
+			for _, toolCall := range msg.ToolCalls {
+				if toolCall.Function != nil {
+					fmt.Fprintf(&sb, "assistant called tool %s(%s)\n", toolCall.Function.Name, toolCall.Function.Arguments)
+				}
+			}
+		case *models.ToolMessage:
+			fmt.Fprintf(&sb, "tool result: %s\n", msg.Content)
+		}
+	}
+	return sb.String()
+}
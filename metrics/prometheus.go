@@ -0,0 +1,355 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 10:25:47
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 16:58:10
+ * @Description: Prometheus 指标收集器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNamespace = "aisdk" // 默认指标命名空间
+)
+
+var (
+	// 默认请求耗时分桶（秒），覆盖从快速补全到长时间推理的范围
+	defaultDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+	// 基础标签
+	baseLabels = []string{"provider", "model_type", "model", "method"}
+)
+
+const (
+	tenantLabel = "tenant" // 租户标签
+)
+
+// PrometheusCollectorConfig Prometheus 指标收集器配置
+type PrometheusCollectorConfig struct {
+	Namespace       string               // 指标命名空间，默认 "aisdk"
+	Registry        *prometheus.Registry // 指标注册表，默认新建独立的注册表
+	DurationBuckets []float64            // 请求耗时分桶（秒）
+	ConstLabels     prometheus.Labels    // 固定标签
+	TenantLabel     bool                 // 是否添加 tenant 标签按租户区分指标，多租户时启用，租户数量多时注意标签基数
+}
+
+// PrometheusCollector Prometheus 指标收集器，实现 httpclient.MetricsCollector、httpclient.TokenUsageRecorder 和 admission.Observer 接口
+type PrometheusCollector struct {
+	registry        *prometheus.Registry
+	tenantLabel     bool                     // 是否添加 tenant 标签
+	requestsTotal   *prometheus.CounterVec   // 请求总数
+	errorsTotal     *prometheus.CounterVec   // 错误总数
+	retriesTotal    *prometheus.CounterVec   // 重试总数
+	requestDuration *prometheus.HistogramVec // 请求耗时
+	tokensTotal     *prometheus.CounterVec   // token使用总量
+	activeRequests  *prometheus.GaugeVec     // 活跃请求数
+	queueInFlight   *prometheus.GaugeVec     // 准入队列当前并发数
+	queueDepth      *prometheus.GaugeVec     // 准入队列当前排队数
+	queueWait       *prometheus.HistogramVec // 准入队列排队耗时
+}
+
+// NewPrometheusCollector 创建 Prometheus 指标收集器
+func NewPrometheusCollector(config PrometheusCollectorConfig) (c *PrometheusCollector, err error) {
+	if config.Namespace == "" {
+		config.Namespace = defaultNamespace
+	}
+	if config.Registry == nil {
+		config.Registry = prometheus.NewRegistry()
+	}
+	if len(config.DurationBuckets) == 0 {
+		config.DurationBuckets = defaultDurationBuckets
+	}
+	labelNames := func(extra ...string) (names []string) {
+		if config.TenantLabel {
+			extra = append([]string{tenantLabel}, extra...)
+		}
+		return append(slices.Clone(baseLabels), extra...)
+	}
+	c = &PrometheusCollector{
+		registry:    config.Registry,
+		tenantLabel: config.TenantLabel,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Name:        "requests_total",
+			Help:        "Total number of SDK requests by status.",
+			ConstLabels: config.ConstLabels,
+		}, labelNames("status")),
+		errorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Name:        "errors_total",
+			Help:        "Total number of SDK request errors by error type.",
+			ConstLabels: config.ConstLabels,
+		}, labelNames("error_type")),
+		retriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Name:        "retries_total",
+			Help:        "Total number of SDK request retries.",
+			ConstLabels: config.ConstLabels,
+		}, labelNames()),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Name:        "request_duration_seconds",
+			Help:        "SDK request duration in seconds, including retries.",
+			ConstLabels: config.ConstLabels,
+			Buckets:     config.DurationBuckets,
+		}, labelNames()),
+		tokensTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Name:        "tokens_total",
+			Help:        "Total number of tokens used by token type.",
+			ConstLabels: config.ConstLabels,
+		}, labelNames("token_type")),
+		activeRequests: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Name:        "active_requests",
+			Help:        "Number of SDK requests in flight.",
+			ConstLabels: config.ConstLabels,
+		}, labelNames()),
+		queueInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Name:        "admission_in_flight",
+			Help:        "Number of SDK requests holding an admission slot, including open streams.",
+			ConstLabels: config.ConstLabels,
+		}, []string{"queue"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Name:        "admission_queue_depth",
+			Help:        "Number of SDK requests waiting in the admission queue.",
+			ConstLabels: config.ConstLabels,
+		}, []string{"queue"}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Name:        "admission_wait_seconds",
+			Help:        "Time SDK requests spent in the admission queue by outcome.",
+			ConstLabels: config.ConstLabels,
+			Buckets:     config.DurationBuckets,
+		}, []string{"queue", "outcome"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		c.requestsTotal,
+		c.errorsTotal,
+		c.retriesTotal,
+		c.requestDuration,
+		c.tokensTotal,
+		c.activeRequests,
+		c.queueInFlight,
+		c.queueDepth,
+		c.queueWait,
+	} {
+		if err = c.registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// RecordRequestStart 记录请求开始
+func (c *PrometheusCollector) RecordRequestStart(provider, modelType, model, method string) {
+	c.recordRequestStart("", provider, modelType, model, method)
+}
+
+// RecordRequestComplete 记录请求完成
+func (c *PrometheusCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+	c.recordRequestComplete("", provider, modelType, model, method, durationMs, success)
+}
+
+// RecordError 记录错误
+func (c *PrometheusCollector) RecordError(provider, modelType, model, method, errorType string) {
+	c.recordError("", provider, modelType, model, method, errorType)
+}
+
+// RecordRetry 记录重试
+func (c *PrometheusCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+	c.recordRetry("", provider, modelType, model, method, retryCount)
+}
+
+// RecordTokenUsage 记录token使用量
+func (c *PrometheusCollector) RecordTokenUsage(provider, modelType, model, method string, usage httpclient.TokenUsage) {
+	c.recordTokenUsage("", provider, modelType, model, method, usage)
+}
+
+// ForTenant 获取记录指定租户指标的收集器，未启用 tenant 标签时返回自身
+func (c *PrometheusCollector) ForTenant(tenant string) (collector httpclient.MetricsCollector) {
+	if !c.tenantLabel {
+		return c
+	}
+	return &tenantCollector{PrometheusCollector: c, tenant: tenant}
+}
+
+// ObserveQueue 记录准入队列的并发数和排队数
+func (c *PrometheusCollector) ObserveQueue(queue string, inFlight, queued int) {
+	c.queueInFlight.WithLabelValues(queue).Set(float64(inFlight))
+	c.queueDepth.WithLabelValues(queue).Set(float64(queued))
+}
+
+// ObserveWait 记录准入队列的排队耗时
+func (c *PrometheusCollector) ObserveWait(queue string, wait time.Duration, outcome string) {
+	c.queueWait.WithLabelValues(queue, outcome).Observe(wait.Seconds())
+}
+
+// recordRequestStart 记录请求开始
+func (c *PrometheusCollector) recordRequestStart(tenant, provider, modelType, model, method string) {
+	c.activeRequests.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method)...).Inc()
+}
+
+// recordRequestComplete 记录请求完成
+func (c *PrometheusCollector) recordRequestComplete(tenant, provider, modelType, model, method string, durationMs int64, success bool) {
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	c.requestsTotal.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method, status)...).Inc()
+	c.requestDuration.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method)...).Observe(float64(durationMs) / 1000)
+	c.activeRequests.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method)...).Dec()
+}
+
+// recordError 记录错误
+func (c *PrometheusCollector) recordError(tenant, provider, modelType, model, method, errorType string) {
+	c.errorsTotal.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method, errorType)...).Inc()
+}
+
+// recordRetry 记录重试
+func (c *PrometheusCollector) recordRetry(tenant, provider, modelType, model, method string, retryCount int) {
+	c.retriesTotal.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method)...).Add(float64(retryCount))
+}
+
+// recordTokenUsage 记录token使用量
+func (c *PrometheusCollector) recordTokenUsage(tenant, provider, modelType, model, method string, usage httpclient.TokenUsage) {
+	for tokenType, tokens := range map[string]int{
+		"input":        usage.InputTokens,
+		"cached_input": usage.CachedInputTokens,
+		"output":       usage.OutputTokens,
+		"reasoning":    usage.ReasoningTokens,
+	} {
+		if tokens > 0 {
+			c.tokensTotal.WithLabelValues(c.labelValues(tenant, provider, modelType, model, method, tokenType)...).Add(float64(tokens))
+		}
+	}
+}
+
+// labelValues 获取标签值，顺序与标签名一致：基础标签、tenant 标签（启用时）、额外标签
+func (c *PrometheusCollector) labelValues(tenant, provider, modelType, model, method string, extra ...string) (values []string) {
+	values = []string{provider, modelType, model, method}
+	if c.tenantLabel {
+		values = append(values, tenant)
+	}
+	return append(values, extra...)
+}
+
+// GetMetrics 获取指标数据，返回指标名称到各标签组合取值的映射
+func (c *PrometheusCollector) GetMetrics() (metrics map[string]any) {
+	metrics = make(map[string]any)
+	families, err := c.registry.Gather()
+	if err != nil {
+		return
+	}
+	for _, family := range families {
+		values := make(map[string]float64, len(family.GetMetric()))
+		for _, metric := range family.GetMetric() {
+			key := labelKey(metric.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				values[key] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				values[key] = metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				// 直方图返回平均值（秒）
+				if count := metric.GetHistogram().GetSampleCount(); count > 0 {
+					values[key] = metric.GetHistogram().GetSampleSum() / float64(count)
+				}
+			}
+		}
+		metrics[family.GetName()] = values
+	}
+	return
+}
+
+// Reset 重置指标
+func (c *PrometheusCollector) Reset() {
+	c.requestsTotal.Reset()
+	c.errorsTotal.Reset()
+	c.retriesTotal.Reset()
+	c.requestDuration.Reset()
+	c.tokensTotal.Reset()
+	c.activeRequests.Reset()
+	c.queueInFlight.Reset()
+	c.queueDepth.Reset()
+	c.queueWait.Reset()
+}
+
+// Registry 获取指标注册表
+func (c *PrometheusCollector) Registry() (registry *prometheus.Registry) {
+	return c.registry
+}
+
+// Handler 获取用于暴露 /metrics 的 HTTP 处理器
+func (c *PrometheusCollector) Handler() (handler http.Handler) {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
+}
+
+// tenantCollector 记录指定租户指标的 Prometheus 指标收集器
+type tenantCollector struct {
+	*PrometheusCollector
+	tenant string // 租户ID
+}
+
+// RecordRequestStart 记录请求开始
+func (c *tenantCollector) RecordRequestStart(provider, modelType, model, method string) {
+	c.recordRequestStart(c.tenant, provider, modelType, model, method)
+}
+
+// RecordRequestComplete 记录请求完成
+func (c *tenantCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+	c.recordRequestComplete(c.tenant, provider, modelType, model, method, durationMs, success)
+}
+
+// RecordError 记录错误
+func (c *tenantCollector) RecordError(provider, modelType, model, method, errorType string) {
+	c.recordError(c.tenant, provider, modelType, model, method, errorType)
+}
+
+// RecordRetry 记录重试
+func (c *tenantCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+	c.recordRetry(c.tenant, provider, modelType, model, method, retryCount)
+}
+
+// RecordTokenUsage 记录token使用量
+func (c *tenantCollector) RecordTokenUsage(provider, modelType, model, method string, usage httpclient.TokenUsage) {
+	c.recordTokenUsage(c.tenant, provider, modelType, model, method, usage)
+}
+
+// labelKey 将标签转换为指标键值，与默认指标收集器保持一致，按提供商、模型类型、模型、方法的顺序拼接，其余标签追加在后面
+func labelKey(labels []*dto.LabelPair) (key string) {
+	valueMap := make(map[string]string, len(labels))
+	for _, label := range labels {
+		valueMap[label.GetName()] = label.GetValue()
+	}
+	values := make([]string, 0, len(labels))
+	for _, name := range baseLabels {
+		if value := valueMap[name]; value != "" {
+			values = append(values, value)
+		}
+		delete(valueMap, name)
+	}
+	// 其余标签（已按名称排序）
+	for _, label := range labels {
+		if value, ok := valueMap[label.GetName()]; ok && value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return "unknown"
+	}
+	return strings.Join(values, ":")
+}
@@ -0,0 +1,248 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 11:18:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-01 16:58:10
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/admission"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestContext 创建带有请求信息的上下文
+func newTestContext(method string) (ctx context.Context) {
+	return httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  "openai",
+		ModelType: "chat",
+		Model:     "gpt-4o",
+		Method:    method,
+		StartTime: time.Now(),
+		RequestID: "test-request-id",
+	})
+}
+
+// scrape 抓取指标文本
+func scrape(t *testing.T, c *PrometheusCollector) (body string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	c.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	return recorder.Body.String()
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	collector, err := NewPrometheusCollector(PrometheusCollectorConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	// 成功的请求
+	m.Process(newTestContext("CreateChatCompletion"), nil, func(ctx context.Context, request any) (response any, err error) {
+		httpclient.GetRequestInfo(ctx).Attempt = 1
+		return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+			Usage: &models.ChatUsage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14},
+		}}, nil
+	})
+	// 失败的请求
+	m.Process(newTestContext("CreateChatCompletion"), nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, &httpclient.APIError{Message: "bad request", HTTPStatusCode: http.StatusBadRequest}
+	})
+	m.Process(newTestContext("CreateChatCompletion"), nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, errors.New("boom")
+	})
+
+	body := scrape(t, collector)
+	labels := `method="CreateChatCompletion",model="gpt-4o",model_type="chat",provider="openai"`
+	for _, want := range []string{
+		`aisdk_requests_total{` + labels + `,status="success"} 1`,
+		`aisdk_requests_total{` + labels + `,status="failure"} 2`,
+		`aisdk_errors_total{error_type="api_error",` + labels + `} 1`,
+		`aisdk_errors_total{error_type="unknown",` + labels + `} 1`,
+		`aisdk_retries_total{` + labels + `} 1`,
+		`aisdk_request_duration_seconds_count{` + labels + `} 3`,
+		`aisdk_tokens_total{` + labels + `,token_type="input"} 10`,
+		`aisdk_tokens_total{` + labels + `,token_type="output"} 4`,
+		`aisdk_active_requests{` + labels + `} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+
+	metrics := collector.GetMetrics()
+	if got := metrics["aisdk_requests_total"].(map[string]float64)["openai:chat:gpt-4o:CreateChatCompletion:failure"]; got != 2 {
+		t.Errorf("expected failure count to be 2, got %v", got)
+	}
+
+	collector.Reset()
+	if body = scrape(t, collector); strings.Contains(body, "aisdk_requests_total{") {
+		t.Error("expected metrics to be reset")
+	}
+}
+
+func TestPrometheusCollector_StreamUsage(t *testing.T) {
+	collector, err := NewPrometheusCollector(PrometheusCollectorConfig{Namespace: "test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	resp, err := m.Process(newTestContext("CreateChatCompletionStream"), nil, func(ctx context.Context, request any) (response any, err error) {
+		body := io.NopCloser(strings.NewReader(
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":3,\"completion_tokens_details\":{\"reasoning_tokens\":2}}}\n\n" +
+				"data: [DONE]\n\n",
+		))
+		return models.ChatResponseStream{
+			StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](body, nil, nil),
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 流式传输结束前不记录token使用量
+	if strings.Contains(scrape(t, collector), "test_tokens_total{") {
+		t.Fatal("expected no token usage before the stream finishes")
+	}
+	if err = resp.(models.ChatResponseStream).ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+		return
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := scrape(t, collector)
+	labels := `method="CreateChatCompletionStream",model="gpt-4o",model_type="chat",provider="openai"`
+	for _, want := range []string{
+		`test_tokens_total{` + labels + `,token_type="input"} 7`,
+		`test_tokens_total{` + labels + `,token_type="output"} 3`,
+		`test_tokens_total{` + labels + `,token_type="reasoning"} 2`,
+		`test_requests_total{` + labels + `,status="success"} 1`,
+		`test_active_requests{` + labels + `} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestPrometheusCollector_StreamLifecycle(t *testing.T) {
+	collector, err := NewPrometheusCollector(PrometheusCollectorConfig{Namespace: "test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	pr, pw := io.Pipe()
+	resp, err := m.Process(newTestContext("CreateChatCompletionStream"), nil, func(ctx context.Context, request any) (response any, err error) {
+		return models.ChatResponseStream{
+			StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](pr, nil, nil),
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 流式传输未结束时仍为活跃请求，不记录请求完成
+	labels := `method="CreateChatCompletionStream",model="gpt-4o",model_type="chat",provider="openai"`
+	body := scrape(t, collector)
+	if !strings.Contains(body, `test_active_requests{`+labels+`} 1`) || strings.Contains(body, "test_requests_total{") {
+		t.Fatalf("expected the open stream to be counted as active:\n%s", body)
+	}
+	// 流式传输中途失败时记录为失败
+	go func() {
+		pw.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+		pw.CloseWithError(errors.New("connection reset"))
+	}()
+	if err = resp.(models.ChatResponseStream).ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+		return
+	}); err == nil {
+		t.Fatal("expected a stream error")
+	}
+
+	body = scrape(t, collector)
+	for _, want := range []string{
+		`test_requests_total{` + labels + `,status="failure"} 1`,
+		`test_errors_total{error_type="unknown",` + labels + `} 1`,
+		`test_request_duration_seconds_count{` + labels + `} 1`,
+		`test_active_requests{` + labels + `} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `status="success"`) {
+		t.Error("expected the failed stream not to be counted as a success")
+	}
+}
+
+func TestPrometheusCollector_TenantLabel(t *testing.T) {
+	collector, err := NewPrometheusCollector(PrometheusCollectorConfig{TenantLabel: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	for _, tenant := range []string{"acme", "acme", "globex", ""} {
+		ctx := newTestContext("CreateChatCompletion")
+		httpclient.GetRequestInfo(ctx).Tenant = tenant
+		m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+			return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+				Usage: &models.ChatUsage{PromptTokens: 5, TotalTokens: 5},
+			}}, nil
+		})
+	}
+
+	body := scrape(t, collector)
+	labels := `method="CreateChatCompletion",model="gpt-4o",model_type="chat",provider="openai"`
+	for _, want := range []string{
+		`aisdk_requests_total{` + labels + `,status="success",tenant="acme"} 2`,
+		`aisdk_requests_total{` + labels + `,status="success",tenant="globex"} 1`,
+		`aisdk_requests_total{` + labels + `,status="success",tenant=""} 1`,
+		`aisdk_tokens_total{` + labels + `,tenant="acme",token_type="input"} 10`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+	if got := collector.GetMetrics()["aisdk_requests_total"].(map[string]float64)["openai:chat:gpt-4o:CreateChatCompletion:success:globex"]; got != 1 {
+		t.Errorf("expected globex success count to be 1, got %v", got)
+	}
+}
+
+func TestPrometheusCollector_AdmissionObserver(t *testing.T) {
+	collector, err := NewPrometheusCollector(PrometheusCollectorConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := admission.NewMiddleware(admission.MiddlewareConfig{MaxConcurrency: 1, Observer: collector})
+	m.Process(newTestContext("CreateChatCompletion"), nil, func(ctx context.Context, request any) (response any, err error) {
+		return
+	})
+
+	body := scrape(t, collector)
+	for _, want := range []string{
+		`aisdk_admission_in_flight{queue="openai:gpt-4o"} 0`,
+		`aisdk_admission_queue_depth{queue="openai:gpt-4o"} 0`,
+		`aisdk_admission_wait_seconds_count{outcome="admitted",queue="openai:gpt-4o"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
@@ -2,14 +2,23 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-02 04:49:05
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-17 18:56:58
+ * @LastEditTime: 2025-08-01 16:58:10
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
  */
 package aisdk
 
-import "github.com/liusuxian/go-aisdk/httpclient"
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/admission"
+	"github.com/liusuxian/go-aisdk/cache"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/tokenizer"
+	"github.com/liusuxian/go-aisdk/tracing"
+	"github.com/liusuxian/go-aisdk/usage"
+)
 
 // WithMiddleware 添加中间件
 func WithMiddleware(m httpclient.Middleware) (opt SDKClientOption) {
@@ -39,6 +48,57 @@ func WithRetry(config httpclient.RetryMiddlewareConfig) (opt SDKClientOption) {
 	}
 }
 
+// WithCache 添加缓存中间件
+func WithCache(config cache.MiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, cache.NewMiddleware(config))
+	}
+}
+
+// WithSemanticCache 添加语义缓存中间件，未设置向量化函数时使用客户端调用指定的嵌入模型
+func WithSemanticCache(provider consts.Provider, embeddingModel string, config cache.SemanticMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		if config.Embedder == nil {
+			var embedder cache.Embedder
+			config.Embedder = func(ctx context.Context, text string) (vector []float32, err error) {
+				return embedder(ctx, text)
+			}
+			c.afterCreate = append(c.afterCreate, func(client *SDKClient) {
+				embedder = client.NewEmbedder(provider, embeddingModel)
+			})
+		}
+		c.middlewares = append(c.middlewares, cache.NewSemanticMiddleware(config))
+	}
+}
+
+// WithTracing 添加链路追踪中间件
+func WithTracing(config tracing.MiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, tracing.NewMiddleware(config))
+	}
+}
+
+// WithUsage 添加用量统计与花费预算中间件
+func WithUsage(config usage.MiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, usage.NewMiddleware(config))
+	}
+}
+
+// WithAdmission 添加准入控制中间件，限制每个提供商/模型的并发请求数，超出时按优先级排队
+func WithAdmission(config admission.MiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, admission.NewMiddleware(config))
+	}
+}
+
+// WithContextWindowCheck 添加上下文窗口检查中间件，超出模型上下文窗口的请求在发送前返回错误
+func WithContextWindowCheck() (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, tokenizer.NewMiddleware())
+	}
+}
+
 // WithDefaultMiddlewares 添加默认中间件（日志、监控、重试）
 func WithDefaultMiddlewares() (opt SDKClientOption) {
 	return func(c *clientOption) {
@@ -59,3 +119,23 @@ func (c *SDKClient) GetMetrics() (metrics map[string]any) {
 	}
 	return
 }
+
+// GetUsageSummary 按条件汇总用量（如果启用了用量统计中间件）
+func (c *SDKClient) GetUsageSummary(ctx context.Context, filter usage.Filter) (summary usage.Summary, err error) {
+	for _, mw := range c.middlewareChain.GetMiddlewares() {
+		if usageMiddleware, ok := mw.(*usage.Middleware); ok {
+			return usageMiddleware.Summary(ctx, filter)
+		}
+	}
+	return
+}
+
+// GetAdmissionStats 获取准入队列的统计信息（如果启用了准入控制中间件）
+func (c *SDKClient) GetAdmissionStats() (stats map[string]admission.Stats) {
+	for _, mw := range c.middlewareChain.GetMiddlewares() {
+		if admissionMiddleware, ok := mw.(*admission.Middleware); ok {
+			return admissionMiddleware.Stats()
+		}
+	}
+	return
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:42:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:14:56
+ * @LastEditTime: 2025-07-18 16:42:05
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -75,8 +75,8 @@ type AssistantMessage struct {
 	Refusal string `json:"refusal,omitempty" providers:"openai"`
 	// 工具调用
 	//
-	// 提供商支持: OpenAI
-	ToolCalls []ToolCalls `json:"tool_calls,omitempty" providers:"openai"`
+	// 提供商支持: OpenAI | DeepSeek | AliBL
+	ToolCalls []ToolCalls `json:"tool_calls,omitempty" providers:"openai,deepseek,alibl"`
 	// 设置此参数为 true，来强制模型在其回答中以此 assistant 消息中提供的前缀内容开始
 	//
 	// 提供商支持: DeepSeek | AliBL
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-25 22:58:00
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 11:39:17
+ * @LastEditTime: 2025-07-18 16:42:05
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -391,16 +391,16 @@ func TestAssistantMessage_MarshalJSON(t *testing.T) {
 				Role:    "",
 				Name:    "DeepSeek Bot",
 				Refusal: "Should be ignored", // deepseek不支持refusal
-				ToolCalls: []ToolCalls{ // deepseek不支持tool_calls
+				ToolCalls: []ToolCalls{
 					{
-						ID:   "should_be_ignored",
+						ID:   "call_789",
 						Type: "function",
 					},
 				},
 				Prefix:           Bool(true),
 				ReasoningContent: "DeepSeek reasoning",
 			},
-			wantB:   []byte(`{"role":"assistant","content":"DeepSeek content","name":"DeepSeek Bot","prefix":true,"reasoning_content":"DeepSeek reasoning"}`),
+			wantB:   []byte(`{"role":"assistant","content":"DeepSeek content","name":"DeepSeek Bot","tool_calls":[{"id":"call_789","type":"function"}],"prefix":true,"reasoning_content":"DeepSeek reasoning"}`),
 			wantErr: false,
 		},
 		{
@@ -420,16 +420,16 @@ func TestAssistantMessage_MarshalJSON(t *testing.T) {
 				Role:    "",
 				Name:    "Should be ignored", // alibl不支持name
 				Refusal: "Should be ignored", // alibl不支持refusal
-				ToolCalls: []ToolCalls{ // alibl不支持tool_calls
+				ToolCalls: []ToolCalls{
 					{
-						ID:   "should_be_ignored",
+						ID:   "call_789",
 						Type: "function",
 					},
 				},
 				Prefix:           Bool(true),          // alibl支持，但映射为partial
 				ReasoningContent: "Should be ignored", // alibl不支持reasoning_content
 			},
-			wantB:   []byte(`{"role":"assistant","content":"AliBL content","partial":true}`),
+			wantB:   []byte(`{"role":"assistant","content":"AliBL content","tool_calls":[{"id":"call_789","type":"function"}],"partial":true}`),
 			wantErr: false,
 		},
 		{
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:42:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 00:27:25
+ * @LastEditTime: 2025-07-30 15:37:21
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -20,5 +20,6 @@ type ModelInfo struct {
 
 // UserInfo 用户信息结构
 type UserInfo struct {
-	User string `json:"user,omitempty" providers:"openai"` // 代表你的终端用户的唯一标识符
+	User   string `json:"user,omitempty" providers:"openai"` // 代表你的终端用户的唯一标识符
+	Tenant string `json:"-"`                                 // 租户ID，用于选择租户自己的提供商配置，不发送给提供商
 }
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:42:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 22:36:41
+ * @LastEditTime: 2025-07-13 11:36:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -990,6 +990,29 @@ func (c *ChatBaseResponse) SetStreamStats(stats httpclient.StreamStats) {
 	c.StreamStats = &stats
 }
 
+// GetTokenUsage 获取token使用量
+func (c ChatBaseResponse) GetTokenUsage() (usage httpclient.TokenUsage, ok bool) {
+	if c.Usage == nil {
+		return
+	}
+	usage = httpclient.TokenUsage{
+		InputTokens:       c.Usage.PromptTokens,
+		CachedInputTokens: c.Usage.PromptCacheHitTokens,
+		OutputTokens:      c.Usage.CompletionTokens,
+		TotalTokens:       c.Usage.TotalTokens,
+	}
+	if c.Usage.PromptTokensDetails != nil && c.Usage.PromptTokensDetails.CachedTokens > 0 {
+		usage.CachedInputTokens = c.Usage.PromptTokensDetails.CachedTokens
+	}
+	if c.Usage.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = c.Usage.CompletionTokensDetails.ReasoningTokens
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	return usage, true
+}
+
 // UnmarshalJSON 反序列化JSON
 func (c *ChatBaseResponse) UnmarshalJSON(data []byte) (err error) {
 	switch consts.Provider(c.provider) {
@@ -1166,3 +1189,149 @@ type ChatResponse struct {
 type ChatResponseStream struct {
 	*httpclient.StreamReader[ChatBaseResponse]
 }
+
+// MergeChatStreamChunks 将流式传输的数据块合并为完整的聊天响应，增量内容会被合并到 Message 字段中
+func MergeChatStreamChunks(chunks []ChatBaseResponse) (response ChatBaseResponse) {
+	var (
+		choiceMap   = make(map[int]*ChatChoice)
+		choiceOrder []int
+	)
+	for _, chunk := range chunks {
+		// 合并基础信息
+		if chunk.ID != "" {
+			response.ID = chunk.ID
+		}
+		if chunk.Created != 0 {
+			response.Created = chunk.Created
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Object != "" {
+			response.Object = chunk.Object
+		}
+		if chunk.ServiceTier != "" {
+			response.ServiceTier = chunk.ServiceTier
+		}
+		if chunk.SystemFingerprint != "" {
+			response.SystemFingerprint = chunk.SystemFingerprint
+		}
+		if chunk.Usage != nil {
+			response.Usage = chunk.Usage
+		}
+		if chunk.StreamStats != nil {
+			response.StreamStats = chunk.StreamStats
+		}
+		// 合并 choices
+		for _, v := range chunk.Choices {
+			choice, ok := choiceMap[v.Index]
+			if !ok {
+				choice = &ChatChoice{
+					Index:   v.Index,
+					Message: &ChatCompletionMessage{},
+				}
+				choiceMap[v.Index] = choice
+				choiceOrder = append(choiceOrder, v.Index)
+			}
+			if v.FinishReason != "" && v.FinishReason != ChatFinishReasonNull {
+				choice.FinishReason = v.FinishReason
+			}
+			if v.LogProbs != nil {
+				if choice.LogProbs == nil {
+					choice.LogProbs = &ChatLogProbs{}
+				}
+				choice.LogProbs.Content = append(choice.LogProbs.Content, v.LogProbs.Content...)
+				choice.LogProbs.Refusal = append(choice.LogProbs.Refusal, v.LogProbs.Refusal...)
+			}
+			delta := v.Delta
+			if delta == nil {
+				delta = v.Message
+			}
+			if delta != nil {
+				mergeChatCompletionMessage(choice.Message, delta)
+			}
+		}
+	}
+	// 按出现顺序输出 choices
+	response.Choices = make([]ChatChoice, 0, len(choiceOrder))
+	for _, index := range choiceOrder {
+		response.Choices = append(response.Choices, *choiceMap[index])
+	}
+	return
+}
+
+// mergeChatCompletionMessage 将增量消息合并到目标消息中
+func mergeChatCompletionMessage(dst, delta *ChatCompletionMessage) {
+	if delta.Role != "" {
+		dst.Role = delta.Role
+	}
+	dst.Content += delta.Content
+	dst.ReasoningContent += delta.ReasoningContent
+	dst.Refusal += delta.Refusal
+	dst.Annotations = append(dst.Annotations, delta.Annotations...)
+	if delta.Audio != nil {
+		if dst.Audio == nil {
+			dst.Audio = &ChatAudioOutput{}
+		}
+		dst.Audio.Data += delta.Audio.Data
+		dst.Audio.Transcript += delta.Audio.Transcript
+		if delta.Audio.ID != "" {
+			dst.Audio.ID = delta.Audio.ID
+		}
+		if delta.Audio.ExpiresAt != 0 {
+			dst.Audio.ExpiresAt = delta.Audio.ExpiresAt
+		}
+	}
+	// 按索引合并工具调用，函数参数以增量方式拼接
+	for _, tc := range delta.ToolCalls {
+		index := indexToolCall(dst.ToolCalls, tc)
+		if index == -1 {
+			newToolCall := ToolCalls{
+				Index: tc.Index,
+				ID:    tc.ID,
+				Type:  tc.Type,
+			}
+			if tc.Function != nil {
+				newToolCall.Function = &ToolCallsFunction{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				}
+			}
+			dst.ToolCalls = append(dst.ToolCalls, newToolCall)
+			continue
+		}
+		existing := &dst.ToolCalls[index]
+		if tc.ID != "" {
+			existing.ID = tc.ID
+		}
+		if tc.Type != "" {
+			existing.Type = tc.Type
+		}
+		if tc.Function != nil {
+			if existing.Function == nil {
+				existing.Function = &ToolCallsFunction{}
+			}
+			if existing.Function.Name == "" {
+				existing.Function.Name = tc.Function.Name
+			}
+			existing.Function.Arguments += tc.Function.Arguments
+		}
+	}
+}
+
+// indexToolCall 查找增量工具调用对应的已有工具调用索引
+func indexToolCall(toolCalls []ToolCalls, delta ToolCalls) (index int) {
+	for i, tc := range toolCalls {
+		// 优先按工具ID匹配，工具ID为空时按索引匹配
+		if delta.ID != "" && tc.ID != "" {
+			if tc.ID == delta.ID {
+				return i
+			}
+			continue
+		}
+		if tc.Index == delta.Index {
+			return i
+		}
+	}
+	return -1
+}
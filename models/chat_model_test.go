@@ -0,0 +1,74 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 11:02:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-10 11:02:17
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeChatStreamChunks(t *testing.T) {
+	chunks := []ChatBaseResponse{
+		{
+			ID:    "chatcmpl-1",
+			Model: "gpt-4o",
+			Choices: []ChatChoice{
+				{Delta: &ChatCompletionMessage{Role: "assistant", Content: "Hello"}},
+			},
+		},
+		{
+			Choices: []ChatChoice{
+				{Delta: &ChatCompletionMessage{Content: ", world"}},
+			},
+		},
+		{
+			Choices: []ChatChoice{
+				{Delta: &ChatCompletionMessage{ToolCalls: []ToolCalls{
+					{Index: 0, ID: "call_1", Type: ToolTypeFunction, Function: &ToolCallsFunction{Name: "get_weather", Arguments: `{"city":`}},
+				}}},
+			},
+		},
+		{
+			Choices: []ChatChoice{
+				{Delta: &ChatCompletionMessage{ToolCalls: []ToolCalls{
+					{Index: 0, Function: &ToolCallsFunction{Arguments: `"Paris"}`}},
+				}}},
+			},
+		},
+		{
+			Choices: []ChatChoice{
+				{FinishReason: ChatFinishReasonToolCalls, Delta: &ChatCompletionMessage{}},
+			},
+			Usage: &ChatUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+	}
+
+	response := MergeChatStreamChunks(chunks)
+	want := ChatBaseResponse{
+		ID:    "chatcmpl-1",
+		Model: "gpt-4o",
+		Choices: []ChatChoice{
+			{
+				FinishReason: ChatFinishReasonToolCalls,
+				Message: &ChatCompletionMessage{
+					Role:    "assistant",
+					Content: "Hello, world",
+					ToolCalls: []ToolCalls{
+						{Index: 0, ID: "call_1", Type: ToolTypeFunction, Function: &ToolCallsFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+					},
+				},
+			},
+		},
+		Usage: &ChatUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+	if !reflect.DeepEqual(response, want) {
+		t.Errorf("MergeChatStreamChunks() = %+v, want %+v", response, want)
+	}
+}
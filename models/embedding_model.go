@@ -0,0 +1,77 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 10:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 11:36:52
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/internal/utils"
+)
+
+// EmbeddingRequest 创建嵌入向量请求
+//
+//	提供商支持: OpenAI
+type EmbeddingRequest struct {
+	UserInfo
+	Provider consts.Provider `json:"provider,omitempty"` // 提供商
+	// 要嵌入的文本列表
+	//
+	// 提供商支持: OpenAI
+	Input []string `json:"input,omitempty" providers:"openai"`
+	// 模型名称
+	//
+	// 提供商支持: OpenAI
+	Model string `json:"model,omitempty" providers:"openai"`
+	// 输出嵌入向量的维度，仅 text-embedding-3 及之后的模型支持
+	//
+	// 提供商支持: OpenAI
+	Dimensions int `json:"dimensions,omitempty" providers:"openai"`
+}
+
+// MarshalJSON 序列化JSON
+func (r EmbeddingRequest) MarshalJSON() (b []byte, err error) {
+	provider := r.Provider.String()
+	// 序列化JSON
+	r.Provider = ""
+	return utils.NewSerializer(provider).Serialize(r)
+}
+
+// EmbeddingData 嵌入向量
+type EmbeddingData struct {
+	Object    string    `json:"object,omitempty"`    // 对象类型，其值为 embedding
+	Embedding []float32 `json:"embedding,omitempty"` // 嵌入向量
+	Index     int       `json:"index"`               // 对应输入文本的索引
+}
+
+// EmbeddingUsage 嵌入的token使用信息
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens,omitempty"` // 输入文本的token数量
+	TotalTokens  int `json:"total_tokens,omitempty"`  // 使用的token总数
+}
+
+// EmbeddingResponse 创建嵌入向量响应
+type EmbeddingResponse struct {
+	Object string          `json:"object,omitempty"` // 对象类型，其值为 list
+	Data   []EmbeddingData `json:"data,omitempty"`   // 嵌入向量列表
+	Model  string          `json:"model,omitempty"`  // 模型名称
+	Usage  *EmbeddingUsage `json:"usage,omitempty"`  // 嵌入的token使用信息
+	httpclient.HttpHeader
+}
+
+// GetTokenUsage 获取token使用量
+func (r EmbeddingResponse) GetTokenUsage() (usage httpclient.TokenUsage, ok bool) {
+	if r.Usage == nil {
+		return
+	}
+	return httpclient.TokenUsage{
+		InputTokens: r.Usage.PromptTokens,
+		TotalTokens: r.Usage.TotalTokens,
+	}, true
+}
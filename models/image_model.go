@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-19 17:11:50
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 14:49:55
+ * @LastEditTime: 2025-07-13 11:36:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -344,3 +344,15 @@ type ImageResponse struct {
 	Usage   *ImageUsage         `json:"usage,omitempty"`   // 图像生成的token使用信息
 	httpclient.HttpHeader
 }
+
+// GetTokenUsage 获取token使用量
+func (r ImageResponse) GetTokenUsage() (usage httpclient.TokenUsage, ok bool) {
+	if r.Usage == nil {
+		return
+	}
+	return httpclient.TokenUsage{
+		InputTokens:  r.Usage.InputTokens,
+		OutputTokens: r.Usage.OutputTokens,
+		TotalTokens:  r.Usage.TotalTokens,
+	}, true
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-25 12:47:24
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:32:33
+ * @LastEditTime: 2025-07-28 17:19:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -28,10 +28,11 @@ func (s *aliblProvider) CreateChatCompletion(ctx context.Context, request models
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
 		Provider:   consts.AliBL,
 		Method:     http.MethodPost,
-		BaseURL:    s.providerConfig.BaseURL,
+		BaseURL:    s.BaseURL(),
 		ApiPath:    s.apiChatCompletions(request.Model),
 		Opts:       opts,
-		LB:         s.lb,
+		LB:         s.LoadBalancer(),
+		Transport:  s.HTTPTransport(),
 		Response:   &response,
 		ReqSetters: withRequestOptions(request),
 	})
@@ -44,10 +45,11 @@ func (s *aliblProvider) CreateChatCompletionStream(ctx context.Context, request
 	if stream, err = common.ExecuteStreamRequest[models.ChatBaseResponse](ctx, &common.ExecuteRequestContext{
 		Provider:   consts.AliBL,
 		Method:     http.MethodPost,
-		BaseURL:    s.providerConfig.BaseURL,
+		BaseURL:    s.BaseURL(),
 		ApiPath:    s.apiChatCompletions(request.Model),
 		Opts:       opts,
-		LB:         s.lb,
+		LB:         s.LoadBalancer(),
+		Transport:  s.HTTPTransport(),
 		ReqSetters: withRequestOptions(request),
 	}); err != nil {
 		return
@@ -61,7 +63,7 @@ func (s *aliblProvider) CreateChatCompletionStream(ctx context.Context, request
 // apiChatCompletions 获取聊天接口
 func (s *aliblProvider) apiChatCompletions(model string) (api string) {
 	// 判断模型是否支持多模态
-	if s.supportedModels[consts.ChatModel][model] == 1 {
+	if s.supportedModels[consts.ChatModel][model].IsMultimodal() {
 		return apiChatCompletionsMultimodal
 	}
 	return apiChatCompletionsText
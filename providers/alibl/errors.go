@@ -0,0 +1,45 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 11:48:13
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 11:48:13
+ * @Description: 阿里百炼（DashScope）错误码映射
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package alibl
+
+import (
+	"github.com/liusuxian/go-aisdk/errors"
+	"strings"
+)
+
+// classifyError 将 DashScope 错误码映射为统一的错误分类，同时兼容原生接口（如 InvalidApiKey）和 OpenAI 兼容接口（如 invalid_api_key）的错误码
+func classifyError(info errors.ErrorInfo) (kind error) {
+	code := info.Code
+	switch {
+	case code == "InvalidApiKey" || code == "invalid_api_key":
+		return errors.ErrAuthentication
+	case code == "AccessDenied" || strings.HasSuffix(code, ".AccessDenied") || code == "access_denied" ||
+		code == "Model.AccessDenied.Unpurchased":
+		return errors.ErrPermissionDenied
+	case code == "Arrearage" || code == "insufficient_quota" || code == "AllocationQuota.FreeTierOnly":
+		return errors.ErrQuotaExceeded
+	case code == "DataInspectionFailed" || code == "data_inspection_failed" || code == "DataInspection.Failed":
+		return errors.ErrContentFiltered
+	case code == "Throttling" || strings.HasPrefix(code, "Throttling.") || code == "limit_requests" || code == "rate_limit_exceeded":
+		return errors.ErrRateLimited
+	case code == "InternalError" || strings.HasPrefix(code, "InternalError.") || code == "internal_error" || code == "RequestTimeOut":
+		return errors.ErrServerError
+	case code == "ServiceUnavailable" || code == "SystemError" || code == "service_unavailable":
+		return errors.ErrOverloaded
+	}
+	// 输入超长时返回 InvalidParameter，通过错误信息识别
+	if errors.MessageContains(info.Message, "range of input length", "input length should be", "maximum context length", "exceeds the maximum") {
+		return errors.ErrContextLengthExceeded
+	}
+	if code == "InvalidParameter" || strings.HasPrefix(code, "InvalidParameter.") || code == "invalid_parameter_error" || code == "invalid_value" {
+		return errors.ErrInvalidRequest
+	}
+	return nil
+}
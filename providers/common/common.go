@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-20 01:15:31
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:36:16
+ * @LastEditTime: 2025-08-02 16:48:27
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -13,7 +13,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/liusuxian/go-aisdk/conf"
 	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/errors"
 	"github.com/liusuxian/go-aisdk/httpclient"
 	"github.com/liusuxian/go-aisdk/internal/utils"
 	"github.com/liusuxian/go-aisdk/loadbalancer"
@@ -35,30 +37,54 @@ type ExecuteRequestContext struct {
 	ApiPath     string                        // 请求路径
 	Opts        []httpclient.HTTPClientOption // 客户端选项
 	LB          *loadbalancer.LoadBalancer    // 负载均衡器
+	Transport   *HTTPTransport                // HTTP 传输层，为 nil 时使用默认传输层
 	FormHandler httpclient.FormBuilderHandler // 构建表单请求体处理函数
 	Response    httpclient.Response           // 响应数据
 	ReqSetters  []httpclient.RequestOption    // 请求选项
 }
 
+// NewLoadBalancer 根据提供商配置创建API密钥负载均衡器，配置加载时已校验负载均衡策略，无效时使用默认策略
+func NewLoadBalancer(config *conf.ProviderConfig) (lb *loadbalancer.LoadBalancer) {
+	var (
+		lbConfig    = config.LoadBalancer
+		strategy, _ = loadbalancer.NewStrategy(lbConfig.Strategy)
+		shared      loadbalancer.SharedState
+	)
+	// 配置了 Redis 时多个实例共享API密钥状态
+	if lbConfig.Redis != nil {
+		shared = loadbalancer.NewRedisState(loadbalancer.RedisConfig{
+			Addr:      lbConfig.Redis.Addr,
+			Username:  lbConfig.Redis.Username,
+			Password:  lbConfig.Redis.Password,
+			DB:        lbConfig.Redis.DB,
+			KeyPrefix: lbConfig.Redis.KeyPrefix,
+			PoolSize:  lbConfig.Redis.PoolSize,
+		})
+	}
+	return loadbalancer.NewLoadBalancerWithConfig(config.APIKeys, loadbalancer.Config{
+		Strategy:     strategy,
+		Shared:       shared,
+		SyncInterval: lbConfig.SyncInterval.Std(),
+		RateLimit: loadbalancer.RateLimit{
+			Requests: lbConfig.RateLimit.Requests,
+			Window:   lbConfig.RateLimit.Window.Std(),
+		},
+	})
+}
+
 // ExecuteRequest 执行请求
 func ExecuteRequest(ctx context.Context, erc *ExecuteRequestContext) (err error) {
 	// 新建 HTTP 客户端
-	hc := httpclient.NewHTTPClientWithConfig(httpclient.HTTPClientConfig{
-		BaseURL:                     erc.BaseURL,
-		HTTPClient:                  httpclient.NewDefaultHTTPDoer(defaultHTTPClientTimeout),
-		ResponseDecoder:             utils.NewDeserializer(erc.Provider.String(), false),
-		EmptyMessagesLimit:          defaultEmptyMessagesLimit,
-		StreamReturnIntervalTimeout: defaultStreamReturnIntervalTimeout,
-	})
-	// 设置客户端选项
-	for _, opt := range erc.Opts {
-		opt(hc)
+	var hc *httpclient.HTTPClient
+	if hc, err = newHTTPClient(ctx, erc, false); err != nil {
+		return
 	}
 	// 获取一个APIKey
 	var apiKey *loadbalancer.APIKey
-	if apiKey, err = erc.LB.GetAPIKey(); err != nil {
+	if apiKey, err = acquireAPIKey(ctx, erc.LB); err != nil {
 		return
 	}
+	defer erc.LB.Release(apiKey.Key)
 	// 创建请求
 	var (
 		setters = append(erc.ReqSetters, httpclient.WithKeyValue("Authorization", fmt.Sprintf("Bearer %s", apiKey.Key)))
@@ -78,30 +104,33 @@ func ExecuteRequest(ctx context.Context, erc *ExecuteRequestContext) (err error)
 	if req, err = hc.NewRequest(ctx, erc.Method, hc.FullURL(erc.ApiPath), setters...); err != nil {
 		return
 	}
-	// 发送请求
-	err = hc.SendRequest(req, erc.Response)
+	// 发送请求，将提供商返回的错误映射为统一的错误分类
+	start := time.Now()
+	err = errors.Classify(erc.Provider, hc.SendRequest(req, erc.Response))
+	// 反馈请求结果，用于管理APIKey健康状态
+	reportResult(erc.LB, apiKey.Key, err, time.Since(start))
 	return
 }
 
 // ExecuteStreamRequest 执行流式传输请求
 func ExecuteStreamRequest[T httpclient.Streamable](ctx context.Context, erc *ExecuteRequestContext) (stream *httpclient.StreamReader[T], err error) {
 	// 新建 HTTP 客户端
-	hc := httpclient.NewHTTPClientWithConfig(httpclient.HTTPClientConfig{
-		BaseURL:                     erc.BaseURL,
-		HTTPClient:                  httpclient.NewDefaultHTTPDoer(defaultHTTPClientTimeout),
-		ResponseDecoder:             utils.NewDeserializer(erc.Provider.String(), true),
-		EmptyMessagesLimit:          defaultEmptyMessagesLimit,
-		StreamReturnIntervalTimeout: defaultStreamReturnIntervalTimeout,
-	})
-	// 设置客户端选项
-	for _, opt := range erc.Opts {
-		opt(hc)
+	var hc *httpclient.HTTPClient
+	if hc, err = newHTTPClient(ctx, erc, true); err != nil {
+		return
 	}
-	// 获取一个APIKey
+	// 获取一个APIKey，流式传输结束时释放
 	var apiKey *loadbalancer.APIKey
-	if apiKey, err = erc.LB.GetAPIKey(); err != nil {
+	if apiKey, err = acquireAPIKey(ctx, erc.LB); err != nil {
 		return
 	}
+	defer func() {
+		if stream == nil {
+			erc.LB.Release(apiKey.Key)
+			return
+		}
+		stream.AddObserver(nil, func(error) { erc.LB.Release(apiKey.Key) })
+	}()
 	// 创建请求
 	var (
 		setters = append(erc.ReqSetters, httpclient.WithKeyValue("Authorization", fmt.Sprintf("Bearer %s", apiKey.Key)))
@@ -110,6 +139,68 @@ func ExecuteStreamRequest[T httpclient.Streamable](ctx context.Context, erc *Exe
 	if req, err = hc.NewRequest(ctx, erc.Method, hc.FullURL(erc.ApiPath), setters...); err != nil {
 		return
 	}
-	// 发送流式请求
-	return httpclient.SendRequestStream[T](hc, req)
+	// 发送流式请求，将提供商返回的错误映射为统一的错误分类
+	start := time.Now()
+	stream, err = httpclient.SendRequestStream[T](hc, req)
+	err = errors.Classify(erc.Provider, err)
+	// 反馈请求结果，用于管理APIKey健康状态，流式传输的延迟为收到响应头的耗时
+	reportResult(erc.LB, apiKey.Key, err, time.Since(start))
+	return
+}
+
+// newHTTPClient 新建使用提供商长连接传输层的 HTTP 客户端，依次应用上下文中的客户端选项和调用选项
+func newHTTPClient(ctx context.Context, erc *ExecuteRequestContext, isStream bool) (hc *httpclient.HTTPClient, err error) {
+	transport := erc.Transport
+	if transport == nil {
+		transport = DefaultHTTPTransport()
+	}
+	var doer httpclient.HTTPDoer
+	if doer, err = transport.NewHTTPDoer(); err != nil {
+		return
+	}
+	hc = httpclient.NewHTTPClientWithConfig(httpclient.HTTPClientConfig{
+		BaseURL:                     erc.BaseURL,
+		HTTPClient:                  doer,
+		ResponseDecoder:             utils.NewDeserializer(erc.Provider.String(), isStream),
+		EmptyMessagesLimit:          defaultEmptyMessagesLimit,
+		StreamReturnIntervalTimeout: defaultStreamReturnIntervalTimeout,
+	})
+	// 设置客户端选项，调用选项可覆盖上下文中的客户端选项
+	for _, opt := range httpclient.ClientOptionsFromContext(ctx) {
+		opt(hc)
+	}
+	for _, opt := range erc.Opts {
+		opt(hc)
+	}
+	return
+}
+
+// acquireAPIKey 从负载均衡器获取APIKey，并将上下文中的终端用户标识传递给负载均衡策略
+func acquireAPIKey(ctx context.Context, lb *loadbalancer.LoadBalancer) (apiKey *loadbalancer.APIKey, err error) {
+	return lb.Acquire(loadbalancer.WithUser(ctx, httpclient.GetRequestInfo(ctx).User))
+}
+
+// reportResult 将请求结果及延迟反馈给负载均衡器，调用方取消的请求不计入
+func reportResult(lb *loadbalancer.LoadBalancer, key string, err error, latency time.Duration) {
+	if errors.IsCanceledError(err) {
+		return
+	}
+	if err == nil {
+		lb.ObserveLatency(key, latency)
+	}
+	var outcome loadbalancer.Outcome
+	switch {
+	case err == nil:
+		outcome = loadbalancer.OutcomeSuccess
+	case errors.IsAuthenticationError(err):
+		outcome = loadbalancer.OutcomeAuthFailed
+	case errors.IsQuotaExceededError(err):
+		outcome = loadbalancer.OutcomeQuotaExceeded
+	case errors.IsRateLimitedError(err):
+		outcome = loadbalancer.OutcomeRateLimited
+	default:
+		outcome = loadbalancer.OutcomeError
+	}
+	// 请求期间APIKey可能已被注销，忽略错误
+	_ = lb.ReportResult(key, outcome, errors.RetryAfter(err))
 }
@@ -0,0 +1,121 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 10:16:32
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 15:02:47
+ * @Description: 提供商公共状态，支持配置热更新
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package common
+
+import (
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/loadbalancer"
+	"sync"
+	"sync/atomic"
+)
+
+// ProviderBase 提供商公共状态，保存提供商配置和API密钥负载均衡器，嵌入到提供商中使用（并发安全）
+type ProviderBase struct {
+	config    atomic.Pointer[conf.ProviderConfig]       // 提供商配置
+	lb        atomic.Pointer[loadbalancer.LoadBalancer] // 负载均衡器
+	transport atomic.Pointer[HTTPTransport]             // HTTP 传输层
+	mu        sync.Mutex                                // 串行化配置更新
+}
+
+// InitializeProviderConfig 初始化提供商配置
+func (b *ProviderBase) InitializeProviderConfig(config *conf.ProviderConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.config.Store(config)
+	b.lb.Store(NewLoadBalancer(config))
+	b.setTransport(NewHTTPTransport(config.HTTP))
+}
+
+// UpdateProviderConfig 热更新提供商配置，原地更新负载均衡器的API密钥列表、负载均衡策略和速率限制，未变化的API密钥保留使用次数和健康状态，
+// HTTP 传输层配置变化时替换传输层
+//
+// Redis 共享状态和同步间隔的变化在重新创建客户端后生效
+func (b *ProviderBase) UpdateProviderConfig(config *conf.ProviderConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lb := b.lb.Load()
+	if lb == nil {
+		b.config.Store(config)
+		b.lb.Store(NewLoadBalancer(config))
+		b.setTransport(NewHTTPTransport(config.HTTP))
+		return
+	}
+	// HTTP 传输层配置变化时新建传输层，正在进行的请求继续使用旧传输层
+	if old := b.config.Load(); old == nil || old.HTTP != config.HTTP {
+		b.setTransport(NewHTTPTransport(config.HTTP))
+	}
+	lb.UpdateAPIKeys(config.APIKeys)
+	// 策略未变化时保留策略状态（如轮询位置）
+	if strategy, err := loadbalancer.NewStrategy(config.LoadBalancer.Strategy); err == nil && strategy.Name() != lb.Strategy().Name() {
+		lb.SetStrategy(strategy)
+	}
+	lb.SetRateLimit(loadbalancer.RateLimit{
+		Requests: config.LoadBalancer.RateLimit.Requests,
+		Window:   config.LoadBalancer.RateLimit.Window.Std(),
+	})
+	b.config.Store(config)
+}
+
+// ProviderConfig 获取当前提供商配置
+func (b *ProviderBase) ProviderConfig() (config *conf.ProviderConfig) {
+	if config = b.config.Load(); config == nil {
+		return &conf.ProviderConfig{}
+	}
+	return
+}
+
+// BaseURL 获取当前基础URL
+func (b *ProviderBase) BaseURL() (baseURL string) {
+	return b.ProviderConfig().BaseURL
+}
+
+// LoadBalancer 获取API密钥负载均衡器，未初始化时返回没有API密钥的负载均衡器
+func (b *ProviderBase) LoadBalancer() (lb *loadbalancer.LoadBalancer) {
+	if lb = b.lb.Load(); lb == nil {
+		return loadbalancer.NewLoadBalancer(nil)
+	}
+	return
+}
+
+// HTTPTransport 获取 HTTP 传输层，未初始化时返回默认传输层
+func (b *ProviderBase) HTTPTransport() (t *HTTPTransport) {
+	if t = b.transport.Load(); t == nil {
+		return DefaultHTTPTransport()
+	}
+	return
+}
+
+// CloseIdleConnections 关闭 HTTP 传输层的空闲连接，正在进行的请求不受影响
+func (b *ProviderBase) CloseIdleConnections() {
+	if t := b.transport.Load(); t != nil {
+		t.CloseIdleConnections()
+	}
+}
+
+// Close 关闭负载均衡器的共享状态存储（如 Redis 连接）和 HTTP 传输层的空闲连接，关闭后不能再使用
+func (b *ProviderBase) Close() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.CloseIdleConnections()
+	if lb := b.lb.Load(); lb != nil {
+		return lb.Close()
+	}
+	return
+}
+
+// setTransport 替换 HTTP 传输层并关闭旧传输层的空闲连接
+func (b *ProviderBase) setTransport(t *HTTPTransport) {
+	if old := b.transport.Swap(t); old != nil {
+		old.CloseIdleConnections()
+	}
+}
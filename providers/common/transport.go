@@ -0,0 +1,88 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 14:26:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-28 14:26:40
+ * @Description: 提供商的长连接 HTTP 传输层
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package common
+
+import (
+	"fmt"
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPTransport 提供商的长连接 HTTP 传输层，所有请求共享连接池
+type HTTPTransport struct {
+	transport *http.Transport // 传输层
+	timeout   time.Duration   // 请求超时时间，0 表示不限制
+	err       error           // 配置无效时的错误，在发送请求时返回
+}
+
+var (
+	defaultTransport     *HTTPTransport // 未配置提供商时使用的默认传输层
+	defaultTransportOnce sync.Once
+)
+
+// NewHTTPTransport 根据 HTTP 传输层配置新建传输层，配置无效时在发送请求时返回错误
+func NewHTTPTransport(config conf.HTTPConfig) (t *HTTPTransport) {
+	t = &HTTPTransport{timeout: defaultHTTPClientTimeout}
+	switch {
+	case config.Timeout < 0:
+		t.timeout = 0
+	case config.Timeout > 0:
+		t.timeout = config.Timeout.Std()
+	}
+	if t.transport, t.err = httpclient.NewTransport(httpclient.TransportConfig{
+		Proxy:                 config.Proxy,
+		CACertFile:            config.CACertFile,
+		ClientCertFile:        config.ClientCertFile,
+		ClientKeyFile:         config.ClientKeyFile,
+		InsecureSkipVerify:    config.InsecureSkipVerify,
+		DialTimeout:           config.DialTimeout.Std(),
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout.Std(),
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout.Std(),
+		IdleConnTimeout:       config.IdleConnTimeout.Std(),
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		DisableHTTP2:          config.DisableHTTP2,
+	}); t.err != nil {
+		t.err = fmt.Errorf("invalid http transport config: %w", t.err)
+	}
+	return
+}
+
+// DefaultHTTPTransport 获取默认传输层
+func DefaultHTTPTransport() (t *HTTPTransport) {
+	defaultTransportOnce.Do(func() {
+		defaultTransport = NewHTTPTransport(conf.HTTPConfig{})
+	})
+	return defaultTransport
+}
+
+// Transport 获取底层传输层，配置无效时为 nil
+func (t *HTTPTransport) Transport() (transport *http.Transport) {
+	return t.transport
+}
+
+// NewHTTPDoer 新建共享本传输层的 HTTP 请求执行器，每次调用使用独立的执行器，调用选项中修改超时时间不影响其他调用
+func (t *HTTPTransport) NewHTTPDoer() (doer httpclient.HTTPDoer, err error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return httpclient.NewHTTPDoerWithTransport(t.transport, t.timeout), nil
+}
+
+// CloseIdleConnections 关闭空闲连接，正在进行的请求不受影响
+func (t *HTTPTransport) CloseIdleConnections() {
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-18 15:01:49
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:38:03
+ * @LastEditTime: 2025-07-28 17:19:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -25,13 +25,14 @@ const (
 // CreateChatCompletion 创建聊天
 func (s *deepseekProvider) CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error) {
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
-		Provider: consts.DeepSeek,
-		Method:   http.MethodPost,
-		BaseURL:  s.providerConfig.BaseURL,
-		ApiPath:  apiChatCompletions,
-		Opts:     opts,
-		LB:       s.lb,
-		Response: &response,
+		Provider:  consts.DeepSeek,
+		Method:    http.MethodPost,
+		BaseURL:   s.BaseURL(),
+		ApiPath:   apiChatCompletions,
+		Opts:      opts,
+		LB:        s.LoadBalancer(),
+		Transport: s.HTTPTransport(),
+		Response:  &response,
 		ReqSetters: []httpclient.RequestOption{
 			httpclient.WithBody(request),
 		},
@@ -43,12 +44,13 @@ func (s *deepseekProvider) CreateChatCompletion(ctx context.Context, request mod
 func (s *deepseekProvider) CreateChatCompletionStream(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponseStream, err error) {
 	var stream *httpclient.StreamReader[models.ChatBaseResponse]
 	if stream, err = common.ExecuteStreamRequest[models.ChatBaseResponse](ctx, &common.ExecuteRequestContext{
-		Provider: consts.DeepSeek,
-		Method:   http.MethodPost,
-		BaseURL:  s.providerConfig.BaseURL,
-		ApiPath:  apiChatCompletions,
-		Opts:     opts,
-		LB:       s.lb,
+		Provider:  consts.DeepSeek,
+		Method:    http.MethodPost,
+		BaseURL:   s.BaseURL(),
+		ApiPath:   apiChatCompletions,
+		Opts:      opts,
+		LB:        s.LoadBalancer(),
+		Transport: s.HTTPTransport(),
 		ReqSetters: []httpclient.RequestOption{
 			httpclient.WithBody(request),
 		},
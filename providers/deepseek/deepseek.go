@@ -2,8 +2,8 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-10 13:57:27
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 17:13:03
- * @Description: DeepSeek服务提供商实现，采用单例模式，在包导入时自动注册到提供商工厂
+ * @LastEditTime: 2025-07-29 16:08:44
+ * @Description: DeepSeek服务提供商实现，在包导入时自动将工厂函数注册到提供商工厂
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
  */
@@ -11,11 +11,10 @@ package deepseek
 
 import (
 	"context"
-	"github.com/liusuxian/go-aisdk/conf"
 	"github.com/liusuxian/go-aisdk/consts"
 	"github.com/liusuxian/go-aisdk/core"
+	"github.com/liusuxian/go-aisdk/errors"
 	"github.com/liusuxian/go-aisdk/httpclient"
-	"github.com/liusuxian/go-aisdk/loadbalancer"
 	"github.com/liusuxian/go-aisdk/models"
 	"github.com/liusuxian/go-aisdk/providers/common"
 	"net/http"
@@ -24,31 +23,34 @@ import (
 // deepseekProvider DeepSeek提供商
 type deepseekProvider struct {
 	core.DefaultProviderService
-	supportedModels map[consts.ModelType]map[string]consts.ModelFeature // 支持的模型
-	providerConfig  *conf.ProviderConfig                                // 提供商配置
-	lb              *loadbalancer.LoadBalancer                          // 负载均衡器
+	common.ProviderBase                                                     // 提供商配置和负载均衡器
+	supportedModels     map[consts.ModelType]map[string]consts.ModelFeature // 支持的模型
 }
 
-var (
-	deepseekService *deepseekProvider // deepseek提供商实例
-)
-
 const (
 	apiModels = "/models"
 )
 
-// init 包初始化时创建 deepseekProvider 实例并注册到工厂
+// defaultSupportedModels 支持的模型，所有实例共享（只读）
+var defaultSupportedModels = map[consts.ModelType]map[string]consts.ModelFeature{
+	consts.ChatModel: {
+		// chat
+		consts.DeepSeekChat:     consts.ModelFeatureJSONObject,
+		consts.DeepSeekReasoner: consts.ModelFeatureReasoning,
+	},
+}
+
+// init 包初始化时将 deepseekProvider 的工厂函数注册到提供商工厂
 func init() {
-	deepseekService = &deepseekProvider{
-		supportedModels: map[consts.ModelType]map[string]consts.ModelFeature{
-			consts.ChatModel: {
-				// chat
-				consts.DeepSeekChat:     consts.ModelFeatureNone,
-				consts.DeepSeekReasoner: consts.ModelFeatureReasoning,
-			},
-		},
+	core.RegisterProvider(consts.DeepSeek, func() core.ProviderService { return newDeepseekProvider() })
+	errors.RegisterClassifier(consts.DeepSeek, classifyError)
+}
+
+// newDeepseekProvider 创建DeepSeek提供商实例
+func newDeepseekProvider() (s *deepseekProvider) {
+	return &deepseekProvider{
+		supportedModels: defaultSupportedModels,
 	}
-	core.RegisterProvider(consts.DeepSeek, deepseekService)
 }
 
 // GetSupportedModels 获取支持的模型
@@ -56,22 +58,17 @@ func (s *deepseekProvider) GetSupportedModels() (supportedModels map[consts.Mode
 	return s.supportedModels
 }
 
-// InitializeProviderConfig 初始化提供商配置
-func (s *deepseekProvider) InitializeProviderConfig(config *conf.ProviderConfig) {
-	s.providerConfig = config
-	s.lb = loadbalancer.NewLoadBalancer(s.providerConfig.APIKeys)
-}
-
 // ListModels 列出模型
 func (s *deepseekProvider) ListModels(ctx context.Context, provider consts.Provider, opts ...httpclient.HTTPClientOption) (response models.ListModelsResponse, err error) {
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
-		Provider: consts.DeepSeek,
-		Method:   http.MethodGet,
-		BaseURL:  s.providerConfig.BaseURL,
-		ApiPath:  apiModels,
-		Opts:     opts,
-		LB:       s.lb,
-		Response: &response,
+		Provider:  consts.DeepSeek,
+		Method:    http.MethodGet,
+		BaseURL:   s.BaseURL(),
+		ApiPath:   apiModels,
+		Opts:      opts,
+		LB:        s.LoadBalancer(),
+		Transport: s.HTTPTransport(),
+		Response:  &response,
 	})
 	return
 }
@@ -0,0 +1,49 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 11:25:50
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 11:25:50
+ * @Description: DeepSeek 错误码映射
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package deepseek
+
+import (
+	"github.com/liusuxian/go-aisdk/errors"
+	"net/http"
+)
+
+// classifyError 将 DeepSeek 错误映射为统一的错误分类
+//
+// DeepSeek 主要通过 HTTP 状态码区分错误：401 认证失败，402 余额不足，422 参数错误，429 速率限制，500 服务器故障，503 服务器繁忙
+func classifyError(info errors.ErrorInfo) (kind error) {
+	switch info.StatusCode {
+	case http.StatusPaymentRequired:
+		return errors.ErrQuotaExceeded
+	case http.StatusUnprocessableEntity:
+		return errors.ErrInvalidRequest
+	case http.StatusServiceUnavailable:
+		return errors.ErrOverloaded
+	}
+	switch info.Code {
+	case "invalid_api_key":
+		return errors.ErrAuthentication
+	case "insufficient_balance", "insufficient_quota":
+		return errors.ErrQuotaExceeded
+	case "context_length_exceeded":
+		return errors.ErrContextLengthExceeded
+	case "content_filter":
+		return errors.ErrContentFiltered
+	}
+	if errors.MessageContains(info.Message, "maximum context length") {
+		return errors.ErrContextLengthExceeded
+	}
+	if errors.MessageContains(info.Message, "content risk", "content exists risk") {
+		return errors.ErrContentFiltered
+	}
+	if errors.MessageContains(info.Message, "insufficient balance") {
+		return errors.ErrQuotaExceeded
+	}
+	return nil
+}
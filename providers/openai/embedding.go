@@ -0,0 +1,41 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 10:20:14
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-28 17:19:52
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package openai
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"github.com/liusuxian/go-aisdk/providers/common"
+	"net/http"
+)
+
+const (
+	apiEmbeddings = "/embeddings"
+)
+
+// CreateEmbeddings 创建嵌入向量
+func (s *openAIProvider) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
+		Provider:  consts.OpenAI,
+		Method:    http.MethodPost,
+		BaseURL:   s.BaseURL(),
+		ApiPath:   apiEmbeddings,
+		Opts:      opts,
+		LB:        s.LoadBalancer(),
+		Transport: s.HTTPTransport(),
+		Response:  &response,
+		ReqSetters: []httpclient.RequestOption{
+			httpclient.WithBody(request),
+		},
+	})
+	return
+}
@@ -0,0 +1,51 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 11:02:37
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 11:02:37
+ * @Description: OpenAI 错误码映射
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package openai
+
+import (
+	"github.com/liusuxian/go-aisdk/errors"
+)
+
+// classifyError 将 OpenAI 错误码映射为统一的错误分类
+func classifyError(info errors.ErrorInfo) (kind error) {
+	switch info.Code {
+	case "invalid_api_key", "invalid_organization", "invalid_project":
+		return errors.ErrAuthentication
+	case "unsupported_country_region_territory", "model_not_available":
+		return errors.ErrPermissionDenied
+	case "rate_limit_exceeded":
+		return errors.ErrRateLimited
+	case "insufficient_quota", "billing_hard_limit_reached", "billing_not_active":
+		return errors.ErrQuotaExceeded
+	case "context_length_exceeded", "string_above_max_length":
+		return errors.ErrContextLengthExceeded
+	case "content_filter", "content_policy_violation", "moderation_blocked":
+		return errors.ErrContentFiltered
+	case "engine_overloaded", "server_overloaded":
+		return errors.ErrOverloaded
+	}
+	switch info.Type {
+	case "authentication_error":
+		return errors.ErrAuthentication
+	case "permission_error":
+		return errors.ErrPermissionDenied
+	case "insufficient_quota":
+		return errors.ErrQuotaExceeded
+	case "server_error":
+		return errors.ErrServerError
+	}
+	if errors.MessageContains(info.Message, "maximum context length") {
+		return errors.ErrContextLengthExceeded
+	}
+	if errors.MessageContains(info.Message, "overloaded") {
+		return errors.ErrOverloaded
+	}
+	return nil
+}
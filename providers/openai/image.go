@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-19 17:37:53
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:42:47
+ * @LastEditTime: 2025-07-28 17:19:52
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -28,13 +28,14 @@ const (
 // CreateImage 创建图像
 func (s *openAIProvider) CreateImage(ctx context.Context, request models.ImageRequest, opts ...httpclient.HTTPClientOption) (response models.ImageResponse, err error) {
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
-		Provider: consts.OpenAI,
-		Method:   http.MethodPost,
-		BaseURL:  s.providerConfig.BaseURL,
-		ApiPath:  apiImagesGenerations,
-		Opts:     opts,
-		LB:       s.lb,
-		Response: &response,
+		Provider:  consts.OpenAI,
+		Method:    http.MethodPost,
+		BaseURL:   s.BaseURL(),
+		ApiPath:   apiImagesGenerations,
+		Opts:      opts,
+		LB:        s.LoadBalancer(),
+		Transport: s.HTTPTransport(),
+		Response:  &response,
 		ReqSetters: []httpclient.RequestOption{
 			httpclient.WithBody(request),
 		},
@@ -121,10 +122,11 @@ func (s *openAIProvider) CreateImageEdit(ctx context.Context, request models.Ima
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
 		Provider:    consts.OpenAI,
 		Method:      http.MethodPost,
-		BaseURL:     s.providerConfig.BaseURL,
+		BaseURL:     s.BaseURL(),
 		ApiPath:     apiImagesEdits,
 		Opts:        opts,
-		LB:          s.lb,
+		LB:          s.LoadBalancer(),
+		Transport:   s.HTTPTransport(),
 		FormHandler: formHandler,
 		Response:    &response,
 	})
@@ -176,10 +178,11 @@ func (s *openAIProvider) CreateImageVariation(ctx context.Context, request model
 	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
 		Provider:    consts.OpenAI,
 		Method:      http.MethodPost,
-		BaseURL:     s.providerConfig.BaseURL,
+		BaseURL:     s.BaseURL(),
 		ApiPath:     apiImagesVariations,
 		Opts:        opts,
-		LB:          s.lb,
+		LB:          s.LoadBalancer(),
+		Transport:   s.HTTPTransport(),
 		FormHandler: formHandler,
 		Response:    &response,
 	})
@@ -0,0 +1,242 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 13:48:19
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-20 13:48:19
+ * @Description: 类型化的结构化输出，校验失败时自动重新提示模型修复
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/jsonschema"
+	"github.com/liusuxian/go-aisdk/models"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultName       = "response" // 默认响应格式名称
+	defaultMaxRetries = 2          // 默认最大修复次数
+	// DefaultInstruction 默认结构化输出提示词，%s 为 JSON Schema
+	DefaultInstruction = "Respond only with a JSON value that conforms to the following JSON Schema. " +
+		"Do not wrap it in code fences or add any other text.\nJSON Schema:\n%s"
+	// DefaultRepairPrompt 默认修复提示词，%s 为校验错误
+	DefaultRepairPrompt = "Your previous response is invalid: %s\n" +
+		"Reply again with only the corrected JSON value that conforms to the JSON Schema."
+)
+
+var (
+	// ErrInvalidOutput 模型输出在修复重试后仍不符合预期结构，可通过 errors.Is 判断
+	ErrInvalidOutput = errors.New("model output does not match the expected structure")
+	// 匹配 Markdown 代码块
+	codeFencePattern = regexp.MustCompile("(?s)```[a-zA-Z0-9_-]*[ \t]*\r?\n?(.*?)```")
+)
+
+var (
+	errEmptyChoices = errors.New("chat completion response contains no choices") // 响应中没有 choices
+	errRefusal      = errors.New("model refused to respond")                     // 模型拒绝响应
+)
+
+// Mode 结构化输出方式
+type Mode string
+
+const (
+	ModeAuto       Mode = ""            // 根据模型特性自动选择
+	ModeJSONSchema Mode = "json_schema" // 使用 json_schema 响应格式（OpenAI 结构化输出）
+	ModeJSONObject Mode = "json_object" // 使用 json_object 响应格式（JSON 模式），并在提示词中给出 JSON Schema
+	ModePrompt     Mode = "prompt"      // 不设置响应格式，仅在提示词中给出 JSON Schema
+)
+
+// SelectMode 根据模型特性选择结构化输出方式：优先 json_schema，其次 json_object，否则仅使用提示词
+func SelectMode(feature consts.ModelFeature) (mode Mode) {
+	switch {
+	case feature.SupportsJSONSchema():
+		return ModeJSONSchema
+	case feature.SupportsJSONObject():
+		return ModeJSONObject
+	default:
+		return ModePrompt
+	}
+}
+
+// ChatCompleter 聊天补全函数
+type ChatCompleter func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error)
+
+// Config 结构化输出配置
+type Config struct {
+	Name         string // 响应格式名称，默认 "response"
+	Description  string // 响应格式描述
+	Mode         Mode   // 结构化输出方式，默认根据模型特性自动选择
+	MaxRetries   int    // 校验失败后重新提示模型修复的最大次数，默认 2，小于0时不修复
+	Instruction  string // 结构化输出提示词，%s 为 JSON Schema，默认 DefaultInstruction
+	RepairPrompt string // 修复提示词，%s 为校验错误，默认 DefaultRepairPrompt
+}
+
+// Result 结构化输出结果
+type Result[T any] struct {
+	Value    T                   // 解析后的值
+	Response models.ChatResponse // 最后一次模型响应
+	Attempts int                 // 模型调用次数
+	Mode     Mode                // 实际使用的结构化输出方式
+}
+
+// OutputError 模型输出不符合预期结构时返回的错误
+type OutputError struct {
+	Attempts int    // 模型调用次数
+	Content  string // 最后一次模型输出
+	Err      error  // 最后一次解析或校验错误
+}
+
+// Error 实现 error 接口
+func (e *OutputError) Error() (s string) {
+	return fmt.Sprintf("%s after %d attempt(s): %v", ErrInvalidOutput, e.Attempts, e.Err)
+}
+
+// Is 支持 errors.Is(err, ErrInvalidOutput)
+func (e *OutputError) Is(target error) (ok bool) {
+	return target == ErrInvalidOutput
+}
+
+// Unwrap 返回解析或校验错误
+func (e *OutputError) Unwrap() (err error) {
+	return e.Err
+}
+
+// Create 调用模型生成 T 类型的结构化输出
+//
+// 根据 feature 选择响应格式（会覆盖请求中的 ResponseFormat），去除代码块标记后按 JSON Schema 校验并解析为 T，
+// 校验或解析失败时将错误发送给模型并要求修复，最多修复 MaxRetries 次，仍失败时返回 *OutputError
+func Create[T any](ctx context.Context, complete ChatCompleter, feature consts.ModelFeature, request models.ChatRequest, config Config) (result Result[T], err error) {
+	if config.Name == "" {
+		config.Name = defaultName
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.Instruction == "" {
+		config.Instruction = DefaultInstruction
+	}
+	if config.RepairPrompt == "" {
+		config.RepairPrompt = DefaultRepairPrompt
+	}
+	if result.Mode = config.Mode; result.Mode == ModeAuto {
+		result.Mode = SelectMode(feature)
+	}
+	// 生成 JSON Schema，严格模式不支持的类型（如 map）退回非严格模式
+	var (
+		schema map[string]any
+		strict bool
+	)
+	if result.Mode == ModeJSONSchema {
+		if schema, err = jsonschema.GenerateFor[T](jsonschema.Config{Strict: true}); err == nil {
+			strict = true
+		}
+	}
+	if schema == nil {
+		if schema, err = jsonschema.GenerateFor[T](jsonschema.Config{}); err != nil {
+			return
+		}
+	}
+	// 设置响应格式
+	switch result.Mode {
+	case ModeJSONSchema:
+		request.ResponseFormat = &models.ChatResponseFormat{
+			Type: models.ChatResponseFormatTypeJSONSchema,
+			JSONSchema: &models.ChatResponseFormatJSONSchema{
+				Name:        config.Name,
+				Description: config.Description,
+				Schema:      schema,
+				Strict:      models.Bool(strict),
+			},
+		}
+		request.Messages = slices.Clone(request.Messages)
+	default:
+		if result.Mode == ModeJSONObject {
+			request.ResponseFormat = &models.ChatResponseFormat{Type: models.ChatResponseFormatTypeJSONObject}
+		} else {
+			request.ResponseFormat = nil
+		}
+		var b []byte
+		if b, err = json.Marshal(schema); err != nil {
+			return
+		}
+		request.Messages = withInstruction(request.Messages, fmt.Sprintf(config.Instruction, b))
+	}
+	// 调用模型，校验失败时要求模型修复
+	for {
+		result.Attempts++
+		if result.Response, err = complete(ctx, request); err != nil {
+			return
+		}
+		if len(result.Response.Choices) == 0 || result.Response.Choices[0].Message == nil {
+			err = errEmptyChoices
+			return
+		}
+		message := result.Response.Choices[0].Message
+		if message.Refusal != "" {
+			err = &OutputError{Attempts: result.Attempts, Content: message.Content, Err: fmt.Errorf("%w: %s", errRefusal, message.Refusal)}
+			return
+		}
+		var parseErr error
+		if result.Value, parseErr = parse[T](schema, message.Content); parseErr == nil {
+			return
+		}
+		if result.Attempts > config.MaxRetries {
+			err = &OutputError{Attempts: result.Attempts, Content: message.Content, Err: parseErr}
+			return
+		}
+		request.Messages = append(request.Messages,
+			&models.AssistantMessage{Content: message.Content},
+			&models.UserMessage{Content: fmt.Sprintf(config.RepairPrompt, parseErr)},
+		)
+	}
+}
+
+// ExtractJSON 从模型输出中提取 JSON：去除 Markdown 代码块标记，以及 JSON 前后的说明文字
+func ExtractJSON(content string) (s string) {
+	s = strings.TrimSpace(content)
+	if m := codeFencePattern.FindStringSubmatch(s); m != nil {
+		s = strings.TrimSpace(m[1])
+	}
+	if s == "" || s[0] == '{' || s[0] == '[' {
+		return
+	}
+	// 截取第一个 { 到最后一个 } 之间的内容
+	start, end := strings.IndexByte(s, '{'), strings.LastIndexByte(s, '}')
+	if start >= 0 && end > start {
+		s = s[start : end+1]
+	}
+	return
+}
+
+// parse 提取、校验并解析模型输出
+func parse[T any](schema map[string]any, content string) (value T, err error) {
+	data := []byte(ExtractJSON(content))
+	if err = jsonschema.Validate(schema, data); err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &value)
+	return
+}
+
+// withInstruction 将结构化输出提示词追加到系统消息，没有系统消息时在开头插入
+func withInstruction(messages []models.ChatMessage, instruction string) (result []models.ChatMessage) {
+	result = make([]models.ChatMessage, 0, len(messages)+1)
+	if len(messages) > 0 {
+		if system, ok := messages[0].(*models.SystemMessage); ok && system.Content != "" {
+			merged := *system
+			merged.Content += "\n\n" + instruction
+			return append(append(result, &merged), messages[1:]...)
+		}
+	}
+	result = append(result, &models.SystemMessage{Content: instruction})
+	return append(result, messages...)
+}
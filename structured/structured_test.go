@@ -0,0 +1,158 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 15:27:33
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-20 15:27:33
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package structured
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/jsonschema"
+	"github.com/liusuxian/go-aisdk/models"
+	"strings"
+	"testing"
+)
+
+type weather struct {
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature"`
+	Unit        string  `json:"unit" jsonschema:"enum=celsius,enum=fahrenheit"`
+	Note        *string `json:"note,omitempty"`
+}
+
+// scripted 按顺序返回预设内容的聊天补全函数，并记录请求
+func scripted(requests *[]models.ChatRequest, contents ...string) (complete ChatCompleter) {
+	return func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		*requests = append(*requests, request)
+		content := contents[min(len(*requests), len(contents))-1]
+		response.Choices = []models.ChatChoice{{Message: &models.ChatCompletionMessage{Role: "assistant", Content: content}}}
+		return
+	}
+}
+
+func TestSelectMode(t *testing.T) {
+	tests := []struct {
+		feature consts.ModelFeature
+		want    Mode
+	}{
+		{consts.ModelFeatureMultimodal | consts.ModelFeatureJSONSchema | consts.ModelFeatureJSONObject, ModeJSONSchema},
+		{consts.ModelFeatureJSONObject, ModeJSONObject},
+		{consts.ModelFeatureReasoning, ModePrompt},
+	}
+	for _, tt := range tests {
+		if got := SelectMode(tt.feature); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.feature, tt.want, got)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{` {"a":1} `, `{"a":1}`},
+		{"```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"Here you go:\n```\n[1,2]\n```\nAnything else?", `[1,2]`},
+		{`Sure! {"a":{"b":1}} Hope this helps.`, `{"a":{"b":1}}`},
+		{`no json here`, `no json here`},
+	}
+	for _, tt := range tests {
+		if got := ExtractJSON(tt.content); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.content, tt.want, got)
+		}
+	}
+}
+
+func TestCreateJSONSchema(t *testing.T) {
+	var requests []models.ChatRequest
+	result, err := Create[weather](context.Background(), scripted(&requests,
+		"```json\n{\"city\":\"Paris\",\"temperature\":21.5,\"unit\":\"celsius\",\"note\":null}\n```",
+	), consts.ModelFeatureJSONSchema, models.ChatRequest{
+		Provider: consts.OpenAI,
+		Model:    consts.OpenAIGPT4o,
+		Messages: []models.ChatMessage{&models.UserMessage{Content: "weather in Paris?"}},
+	}, Config{Name: "weather"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Value.City != "Paris" || result.Value.Temperature != 21.5 || result.Value.Note != nil || result.Attempts != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	format := requests[0].ResponseFormat
+	if result.Mode != ModeJSONSchema || format.Type != models.ChatResponseFormatTypeJSONSchema ||
+		format.JSONSchema.Name != "weather" || !models.BoolValue(format.JSONSchema.Strict) {
+		t.Fatalf("unexpected response format: %+v", format)
+	}
+	// json_schema 模式不修改消息
+	if len(requests[0].Messages) != 1 {
+		t.Fatalf("unexpected messages: %d", len(requests[0].Messages))
+	}
+}
+
+func TestCreateRepair(t *testing.T) {
+	var requests []models.ChatRequest
+	result, err := Create[weather](context.Background(), scripted(&requests,
+		`{"city":"Paris","temperature":"warm","unit":"celsius"}`,
+		`{"city":"Paris","temperature":21,"unit":"kelvin"}`,
+		`{"city":"Paris","temperature":21,"unit":"celsius"}`,
+	), consts.ModelFeatureJSONObject, models.ChatRequest{
+		Messages: []models.ChatMessage{
+			&models.SystemMessage{Content: "You are a weather bot."},
+			&models.UserMessage{Content: "weather in Paris?"},
+		},
+	}, Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Attempts != 3 || result.Value.Unit != "celsius" || result.Mode != ModeJSONObject {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	first := requests[0]
+	if first.ResponseFormat.Type != models.ChatResponseFormatTypeJSONObject || len(first.Messages) != 2 {
+		t.Fatalf("unexpected first request: %+v", first)
+	}
+	// JSON Schema 追加到已有的系统消息
+	if system := first.Messages[0].(*models.SystemMessage).Content; !strings.HasPrefix(system, "You are a weather bot.\n\n") || !strings.Contains(system, `"enum":["celsius","fahrenheit"]`) {
+		t.Fatalf("unexpected system message: %s", system)
+	}
+	// 每次修复追加模型输出和校验错误
+	last := requests[2].Messages
+	if len(last) != 6 {
+		t.Fatalf("expected 6 messages, got %d", len(last))
+	}
+	if repair := last[5].(*models.UserMessage).Content; !strings.Contains(repair, `$.unit: value "kelvin" is not one of`) {
+		t.Fatalf("unexpected repair prompt: %s", repair)
+	}
+}
+
+func TestCreateInvalidOutput(t *testing.T) {
+	var requests []models.ChatRequest
+	_, err := Create[weather](context.Background(), scripted(&requests, `not json`), consts.ModelFeatureNone, models.ChatRequest{
+		Messages: []models.ChatMessage{&models.UserMessage{Content: "weather in Paris?"}},
+	}, Config{MaxRetries: 1})
+	var outputErr *OutputError
+	if !errors.Is(err, ErrInvalidOutput) || !errors.As(err, &outputErr) || outputErr.Attempts != 2 || outputErr.Content != "not json" {
+		t.Fatalf("expected OutputError after 2 attempts, got %v", err)
+	}
+	// 仅使用提示词时不设置响应格式，并插入系统消息
+	if requests[0].ResponseFormat != nil || len(requests[0].Messages) != 2 {
+		t.Fatalf("unexpected request: %+v", requests[0])
+	}
+	if _, ok := requests[0].Messages[0].(*models.SystemMessage); !ok {
+		t.Fatalf("expected instruction system message, got %T", requests[0].Messages[0])
+	}
+
+	// 不修复时只调用一次
+	requests = nil
+	_, err = Create[weather](context.Background(), scripted(&requests, `{"city":"Paris"}`), consts.ModelFeatureNone, models.ChatRequest{}, Config{MaxRetries: -1})
+	if !errors.Is(err, jsonschema.ErrValidation) || len(requests) != 1 {
+		t.Fatalf("expected validation error after 1 attempt, got %v (%d)", err, len(requests))
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-30 10:21:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 15:02:47
+ * @Description: 多租户提供商池，按租户使用各自的提供商配置
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"container/list"
+	"context"
+	goerrors "errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/core"
+	"github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/internal/redact"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxTenants = 1000 // 默认最多缓存的租户提供商数量
+)
+
+// tenantKey 租户ID在上下文中的键
+type tenantKey struct{}
+
+// WithTenant 设置请求的租户ID到上下文，优先级低于请求中的 UserInfo.Tenant
+func WithTenant(ctx context.Context, tenant string) (newCtx context.Context) {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 从上下文中获取租户ID
+func TenantFromContext(ctx context.Context) (tenant string, ok bool) {
+	tenant, ok = ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantConfigLookup 查找租户的提供商配置，ok 为 false 时该租户使用客户端自己的配置
+//
+//	配置中的API密钥和 Redis 密码默认按原样使用，只解析 TenantPoolConfig.SecretSchemes 中允许的密钥引用；
+//	查找结果会被缓存，租户配置变化后调用 SDKClient.InvalidateTenant 使其生效
+type TenantConfigLookup func(ctx context.Context, tenant string, provider consts.Provider) (config conf.ProviderConfig, ok bool, err error)
+
+// TenantPoolConfig 多租户提供商池配置
+type TenantPoolConfig struct {
+	Lookup     TenantConfigLookup // 租户配置查找函数，必须设置
+	MaxTenants int                // 最多缓存的租户提供商数量（每个租户的每个提供商计一个），超出时淘汰最久未使用的，默认1000
+	TTL        time.Duration      // 缓存有效期，过期后重新查找租户配置，0 表示不过期
+	// 租户配置中允许解析的密钥引用协议（如 keystore），默认不解析；租户可控的配置不要允许 exec、file、env，
+	// 否则租户可以在本机执行命令，或把本机的文件和环境变量作为API密钥发送到上游
+	SecretSchemes []string
+}
+
+// WithTenantPool 启用多租户提供商池，携带租户ID的请求使用租户自己的API密钥负载均衡器和 HTTP 传输层
+func WithTenantPool(config TenantPoolConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.afterCreate = append(c.afterCreate, func(client *SDKClient) {
+			client.tenantPool = newTenantPool(config)
+		})
+	}
+}
+
+// InvalidateTenant 移除租户缓存的提供商，下次请求时重新查找租户配置，未启用多租户提供商池时无效果
+func (c *SDKClient) InvalidateTenant(tenant string) {
+	if c.tenantPool != nil {
+		c.tenantPool.invalidate(tenant)
+	}
+}
+
+// tenantPoolKey 租户提供商的缓存键
+type tenantPoolKey struct {
+	tenant   string
+	provider consts.Provider
+}
+
+// tenantEntry 租户提供商的缓存项
+type tenantEntry struct {
+	key       tenantPoolKey
+	service   core.ProviderService // 租户的提供商实例，为 nil 时使用客户端自己的提供商
+	secrets   *redact.Registration // 租户配置中需要在日志中脱敏的密钥，关闭提供商时释放
+	expiresAt time.Time            // 过期时间，零值表示不过期
+	refs      int                  // 使用该提供商的进行中请求数
+	removed   bool                 // 是否已移出缓存，移出后最后一个请求结束时关闭提供商
+}
+
+// tenantPool 多租户提供商池，按最近最少使用淘汰（并发安全）
+type tenantPool struct {
+	config  TenantPoolConfig
+	entries map[tenantPoolKey]*list.Element
+	lru     *list.List // 最近使用的在前面
+	mu      sync.Mutex
+}
+
+// newTenantPool 创建多租户提供商池
+func newTenantPool(config TenantPoolConfig) (p *tenantPool) {
+	if config.MaxTenants <= 0 {
+		config.MaxTenants = defaultMaxTenants
+	}
+	return &tenantPool{
+		config:  config,
+		entries: make(map[tenantPoolKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get 获取租户的提供商，租户没有自己的配置时 ok 为 false
+//
+//	ok 为 true 时，请求结束后必须调用 release，被淘汰的提供商在所有请求结束后才关闭
+func (p *tenantPool) get(ctx context.Context, tenant string, provider consts.Provider) (ps core.ProviderService, release func(), ok bool, err error) {
+	key := tenantPoolKey{tenant: tenant, provider: provider}
+	var hit bool
+	if ps, release, ok, hit = p.load(key); hit {
+		return
+	}
+	// 在锁外查找租户配置，避免慢查询阻塞其他租户
+	if p.config.Lookup == nil {
+		return nil, nil, false, nil
+	}
+	var (
+		config  conf.ProviderConfig
+		secrets *redact.Registration
+	)
+	if config, ok, err = p.config.Lookup(ctx, tenant, provider); err != nil {
+		return nil, nil, false, fmt.Errorf("failed to lookup tenant %s config: %w", tenant, err)
+	}
+	if ok {
+		if ps, secrets, err = newTenantProvider(ctx, provider, config, p.config.SecretSchemes); err != nil {
+			return nil, nil, false, fmt.Errorf("invalid tenant %s config: %w", tenant, err)
+		}
+	}
+	ps, release = p.store(key, ps, secrets)
+	return ps, release, ps != nil, nil
+}
+
+// load 读取缓存，hit 表示缓存命中且未过期
+func (p *tenantPool) load(key tenantPoolKey) (ps core.ProviderService, release func(), ok, hit bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, exists := p.entries[key]
+	if !exists {
+		return
+	}
+	e := elem.Value.(*tenantEntry)
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		p.remove(elem)
+		return
+	}
+	p.lru.MoveToFront(elem)
+	return e.service, p.acquire(e), e.service != nil, true
+}
+
+// store 写入缓存，并发查找同一租户时保留先写入的提供商
+func (p *tenantPool) store(key tenantPoolKey, ps core.ProviderService, secrets *redact.Registration) (stored core.ProviderService, release func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, exists := p.entries[key]; exists {
+		p.lru.MoveToFront(elem)
+		e := elem.Value.(*tenantEntry)
+		if e.service != ps {
+			closeTenantProvider(ps, secrets)
+		}
+		return e.service, p.acquire(e)
+	}
+	e := &tenantEntry{key: key, service: ps, secrets: secrets}
+	if p.config.TTL > 0 {
+		e.expiresAt = time.Now().Add(p.config.TTL)
+	}
+	p.entries[key] = p.lru.PushFront(e)
+	release = p.acquire(e)
+	// 淘汰最久未使用的租户提供商
+	for p.lru.Len() > p.config.MaxTenants {
+		p.remove(p.lru.Back())
+	}
+	return ps, release
+}
+
+// acquire 登记使用缓存项提供商的请求，返回请求结束后调用的释放函数，调用方需持有锁
+func (p *tenantPool) acquire(e *tenantEntry) (release func()) {
+	if e.service == nil {
+		return nil
+	}
+	e.refs++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			if e.refs--; e.refs == 0 && e.removed {
+				closeTenantProvider(e.service, e.secrets)
+			}
+		})
+	}
+}
+
+// invalidate 移除租户所有的提供商
+func (p *tenantPool) invalidate(tenant string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, elem := range p.entries {
+		if key.tenant == tenant {
+			p.remove(elem)
+		}
+	}
+}
+
+// len 获取缓存的租户提供商数量
+func (p *tenantPool) len() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lru.Len()
+}
+
+// close 关闭所有租户的提供商并清空缓存
+func (p *tenantPool) close() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for elem := p.lru.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*tenantEntry)
+		if closer, ok := e.service.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close tenant %s provider %s: %w", e.key.tenant, e.key.provider, err))
+			}
+		}
+		e.secrets.Release()
+	}
+	p.entries = make(map[tenantPoolKey]*list.Element)
+	p.lru.Init()
+	return goerrors.Join(errs...)
+}
+
+// remove 移除缓存项，没有进行中的请求时立即关闭提供商，否则在最后一个请求结束时关闭，调用方需持有锁
+func (p *tenantPool) remove(elem *list.Element) {
+	e := p.lru.Remove(elem).(*tenantEntry)
+	delete(p.entries, e.key)
+	if e.removed = true; e.refs == 0 {
+		closeTenantProvider(e.service, e.secrets)
+	}
+}
+
+// newTenantProvider 使用租户配置创建提供商实例，只解析 schemes 中允许的密钥引用并校验配置，返回的密钥注册在关闭提供商时释放
+func newTenantProvider(ctx context.Context, provider consts.Provider, config conf.ProviderConfig, schemes []string) (ps core.ProviderService, secrets *redact.Registration, err error) {
+	var resolved conf.SDKConfig
+	if resolved, err = conf.ResolveSecretsWithSchemes(ctx, conf.SDKConfig{
+		Providers: map[string]conf.ProviderConfig{provider.String(): config},
+	}, schemes...); err != nil {
+		return
+	}
+	if err = resolved.Validate(); err != nil {
+		return
+	}
+	if ps = core.NewProvider(provider); ps == nil {
+		return nil, nil, errors.WrapProviderNotSupported(provider)
+	}
+	config = resolved.Providers[provider.String()]
+	ps.InitializeProviderConfig(&config)
+	return ps, redact.Register(resolved.Secrets()...), nil
+}
+
+// closeTenantProvider 关闭租户提供商的负载均衡器共享状态（如 Redis 连接）和 HTTP 传输层的空闲连接，并释放密钥的脱敏注册
+func closeTenantProvider(ps core.ProviderService, secrets *redact.Registration) {
+	secrets.Release()
+	switch closer := ps.(type) {
+	case io.Closer:
+		closer.Close()
+	case interface{ CloseIdleConnections() }:
+		closer.CloseIdleConnections()
+	}
+}
+
+// resolveTenant 获取请求的租户ID，请求中的 UserInfo.Tenant 优先于上下文中的租户ID
+func resolveTenant(ctx context.Context, userInfo models.UserInfo) (tenant string) {
+	if userInfo.Tenant != "" {
+		return userInfo.Tenant
+	}
+	tenant, _ = TenantFromContext(ctx)
+	return
+}
+
+// getProvider 获取处理请求的提供商，租户有自己的配置时使用租户的提供商，请求结束后必须调用 release
+func (c *SDKClient) getProvider(ctx context.Context, provider consts.Provider, tenant string) (ps core.ProviderService, release func(), err error) {
+	if tenant != "" && c.tenantPool != nil {
+		var ok bool
+		if ps, release, ok, err = c.tenantPool.get(ctx, tenant, provider); err != nil || ok {
+			return
+		}
+	}
+	if ps = c.providers[provider]; ps == nil {
+		return nil, nil, errors.WrapProviderNotSupported(provider)
+	}
+	return ps, func() {}, nil
+}
@@ -0,0 +1,228 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-30 14:52:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-30 14:52:08
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/conf"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/core"
+	"github.com/liusuxian/go-aisdk/internal/redact"
+	"github.com/liusuxian/go-aisdk/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tenantLookup 记录查找次数的租户配置查找函数
+type tenantLookup struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (l *tenantLookup) lookup(ctx context.Context, tenant string, provider consts.Provider) (config conf.ProviderConfig, ok bool, err error) {
+	l.mu.Lock()
+	l.calls[tenant]++
+	l.mu.Unlock()
+
+	switch tenant {
+	case "free":
+		return conf.ProviderConfig{}, false, nil
+	case "broken":
+		return conf.ProviderConfig{}, false, errors.New("database unavailable")
+	case "invalid":
+		return conf.ProviderConfig{BaseURL: "not a url", APIKeys: []string{"sk-invalid"}}, true, nil
+	}
+	return conf.ProviderConfig{BaseURL: "https://api.openai.com/v1", APIKeys: []string{"sk-" + tenant}}, true, nil
+}
+
+func (l *tenantLookup) count(tenant string) (n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls[tenant]
+}
+
+// closeCounter 记录关闭次数的提供商
+type closeCounter struct {
+	core.ProviderService
+	mu     sync.Mutex
+	closed int
+}
+
+func (p *closeCounter) Close() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed++
+	return
+}
+
+func (p *closeCounter) count() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// apiKeyOf 获取提供商配置中的第一个API密钥
+func apiKeyOf(ps core.ProviderService) (apiKey string) {
+	if keys := ps.(providerConfigGetter).ProviderConfig().APIKeys; len(keys) > 0 {
+		return keys[0]
+	}
+	return
+}
+
+func TestTenantPool(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		lookup = &tenantLookup{calls: make(map[string]int)}
+		client = &SDKClient{
+			providers:  newTestProviders(t, "sk-default"),
+			tenantPool: newTenantPool(TenantPoolConfig{Lookup: lookup.lookup, MaxTenants: 2}),
+		}
+	)
+	// 没有租户ID时使用客户端自己的提供商
+	ps, _, err := client.getProvider(ctx, consts.OpenAI, "")
+	if err != nil || apiKeyOf(ps) != "sk-default" {
+		t.Fatalf("expected the default provider, got %v, %v", apiKeyOf(ps), err)
+	}
+	// 租户使用自己的配置，并缓存提供商
+	acme, _, err := client.getProvider(ctx, consts.OpenAI, "acme")
+	if err != nil || apiKeyOf(acme) != "sk-acme" {
+		t.Fatalf("expected the acme provider, got %v, %v", apiKeyOf(acme), err)
+	}
+	if ps, _, _ = client.getProvider(ctx, consts.OpenAI, "acme"); ps != acme || lookup.count("acme") != 1 {
+		t.Errorf("expected the cached provider, lookups: %d", lookup.count("acme"))
+	}
+	// 租户没有自己的配置时使用客户端自己的提供商，同样缓存查找结果
+	for range 2 {
+		if ps, _, err = client.getProvider(ctx, consts.OpenAI, "free"); err != nil || ps != client.providers[consts.OpenAI] {
+			t.Fatalf("expected the default provider for free tenant, got %v", err)
+		}
+	}
+	if lookup.count("free") != 1 {
+		t.Errorf("expected 1 lookup for free tenant, got %d", lookup.count("free"))
+	}
+	// 查找失败和配置无效时返回错误，不缓存
+	for _, tenant := range []string{"broken", "invalid"} {
+		if _, _, err = client.getProvider(ctx, consts.OpenAI, tenant); err == nil {
+			t.Errorf("expected an error for tenant %s", tenant)
+		}
+	}
+	if client.tenantPool.len() != 2 {
+		t.Errorf("expected 2 cached tenants, got %d", client.tenantPool.len())
+	}
+	// 超出数量时淘汰最久未使用的租户（free）
+	client.getProvider(ctx, consts.OpenAI, "acme")
+	client.getProvider(ctx, consts.OpenAI, "globex")
+	client.getProvider(ctx, consts.OpenAI, "free")
+	if lookup.count("free") != 2 || lookup.count("acme") != 1 {
+		t.Errorf("expected free tenant to be evicted, lookups: free=%d, acme=%d", lookup.count("free"), lookup.count("acme"))
+	}
+	// 移除租户缓存后重新查找
+	client.InvalidateTenant("globex")
+	if ps, _, _ = client.getProvider(ctx, consts.OpenAI, "globex"); apiKeyOf(ps) != "sk-globex" || lookup.count("globex") != 2 {
+		t.Errorf("expected globex to be looked up again, lookups: %d", lookup.count("globex"))
+	}
+	// 未注册的提供商
+	if _, _, err = client.getProvider(ctx, consts.Provider("unknown"), ""); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestTenantPoolTTL(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		lookup = &tenantLookup{calls: make(map[string]int)}
+		pool   = newTenantPool(TenantPoolConfig{Lookup: lookup.lookup, TTL: 10 * time.Millisecond})
+	)
+	first, _, _, _ := pool.get(ctx, "acme", consts.OpenAI)
+	time.Sleep(20 * time.Millisecond)
+	second, _, _, _ := pool.get(ctx, "acme", consts.OpenAI)
+	if first == second || lookup.count("acme") != 2 {
+		t.Errorf("expected the expired provider to be recreated, lookups: %d", lookup.count("acme"))
+	}
+}
+
+func TestTenantPoolCloseEvicted(t *testing.T) {
+	var (
+		pool      = newTenantPool(TenantPoolConfig{MaxTenants: 1})
+		acme      = &closeCounter{}
+		globex    = &closeCounter{}
+		acmeKey   = tenantPoolKey{tenant: "acme", provider: consts.OpenAI}
+		globexKey = tenantPoolKey{tenant: "globex", provider: consts.OpenAI}
+	)
+	// 两个进行中的请求使用 acme 的提供商
+	_, first := pool.store(acmeKey, acme, redact.Register("sk-acme-tenant-key"))
+	_, second, _, _ := pool.load(acmeKey)
+	// 淘汰时仍有进行中的请求，最后一个请求结束后才关闭
+	_, release := pool.store(globexKey, globex, nil)
+	first()
+	first() // 重复释放只计一次
+	if acme.count() != 0 || redact.String("sk-acme-tenant-key") != redact.Mask {
+		t.Fatal("expected the evicted provider to stay open while requests are in flight")
+	}
+	second()
+	if acme.count() != 1 {
+		t.Errorf("expected acme to be closed after the last request, got %d", acme.count())
+	}
+	// 关闭后不再脱敏租户的密钥
+	if redacted := redact.String("sk-acme-tenant-key"); redacted != "sk-acme-tenant-key" {
+		t.Errorf("expected the evicted tenant key to be released, got %q", redacted)
+	}
+	// 没有进行中的请求时移除立即关闭
+	release()
+	pool.invalidate("globex")
+	if globex.count() != 1 {
+		t.Errorf("expected globex to be closed once, got %d", globex.count())
+	}
+}
+
+func TestTenantPoolSecretSchemes(t *testing.T) {
+	t.Setenv("TEST_TENANT_HOST_SECRET", "sk-host-secret")
+	conf.RegisterSecretResolver("vault", conf.SecretResolverFunc(func(ctx context.Context, ref string) (secret string, err error) {
+		return "sk-vault-" + ref, nil
+	}))
+	defer conf.RegisterSecretResolver("vault", nil)
+	var (
+		ctx    = context.Background()
+		lookup = func(ctx context.Context, tenant string, provider consts.Provider) (config conf.ProviderConfig, ok bool, err error) {
+			return conf.ProviderConfig{APIKeys: []string{"env:TEST_TENANT_HOST_SECRET", "vault:" + tenant}}, true, nil
+		}
+	)
+	// 默认不解析租户配置中的密钥引用
+	ps, _, _, err := newTenantPool(TenantPoolConfig{Lookup: lookup}).get(ctx, "acme", consts.OpenAI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys := ps.(providerConfigGetter).ProviderConfig().APIKeys; keys[0] != "env:TEST_TENANT_HOST_SECRET" || keys[1] != "vault:acme" {
+		t.Errorf("expected tenant keys to be used as is, got %v", keys)
+	}
+	// 只解析允许的协议
+	ps, _, _, err = newTenantPool(TenantPoolConfig{Lookup: lookup, SecretSchemes: []string{"vault"}}).get(ctx, "acme", consts.OpenAI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys := ps.(providerConfigGetter).ProviderConfig().APIKeys; keys[0] != "env:TEST_TENANT_HOST_SECRET" || keys[1] != "sk-vault-acme" {
+		t.Errorf("expected only allowed schemes to be resolved, got %v", keys)
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	ctx := WithTenant(context.Background(), "from-context")
+	if tenant := resolveTenant(ctx, models.UserInfo{}); tenant != "from-context" {
+		t.Errorf("expected tenant from context, got %q", tenant)
+	}
+	if tenant := resolveTenant(ctx, models.UserInfo{Tenant: "from-request"}); tenant != "from-request" {
+		t.Errorf("expected tenant from request, got %q", tenant)
+	}
+	if _, ok := TenantFromContext(WithTenant(context.Background(), "")); ok {
+		t.Error("expected an empty tenant to be ignored")
+	}
+}
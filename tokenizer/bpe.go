@@ -0,0 +1,192 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 09:42:13
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-16 09:42:13
+ * @Description: 字节级 BPE 分词器，兼容 tiktoken 编码表
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"github.com/dlclark/regexp2"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PatternCL100kBase cl100k_base 预分词正则
+	PatternCL100kBase = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	// PatternO200kBase o200k_base 预分词正则
+	PatternO200kBase = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+// BPE 字节级 BPE 分词器（并发安全）
+type BPE struct {
+	encoder map[string]int
+	decoder map[int]string
+	pattern *regexp2.Regexp
+}
+
+// NewBPE 根据合并优先级表和预分词正则创建 BPE 分词器
+func NewBPE(ranks map[string]int, pattern string) (b *BPE, err error) {
+	var re *regexp2.Regexp
+	if re, err = regexp2.Compile(pattern, regexp2.None); err != nil {
+		return nil, fmt.Errorf("compile pattern: %w", err)
+	}
+	b = &BPE{
+		encoder: ranks,
+		decoder: make(map[int]string, len(ranks)),
+		pattern: re,
+	}
+	for token, rank := range ranks {
+		b.decoder[rank] = token
+	}
+	return
+}
+
+// ParseBPE 解析 tiktoken 格式的编码表（每行为 base64 编码的字节序列和合并优先级）
+func ParseBPE(r io.Reader, pattern string) (b *BPE, err error) {
+	var (
+		ranks   = make(map[string]int)
+		scanner = bufio.NewScanner(r)
+		lineNo  int
+	)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid encoding line %d", lineNo)
+		}
+		var (
+			tokenBytes []byte
+			rankValue  int
+		)
+		if tokenBytes, err = base64.StdEncoding.DecodeString(token); err != nil {
+			return nil, fmt.Errorf("invalid encoding line %d: %w", lineNo, err)
+		}
+		if rankValue, err = strconv.Atoi(rank); err != nil {
+			return nil, fmt.Errorf("invalid encoding line %d: %w", lineNo, err)
+		}
+		ranks[string(tokenBytes)] = rankValue
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	return NewBPE(ranks, pattern)
+}
+
+// LoadBPEFile 从本地文件加载 tiktoken 格式的编码表
+func LoadBPEFile(path, pattern string) (b *BPE, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+
+	return ParseBPE(f, pattern)
+}
+
+// Encode 将文本编码为 token 列表，特殊 token 按普通文本处理
+func (b *BPE) Encode(text string) (tokens []int) {
+	b.split(text, func(piece string) {
+		tokens = b.encodePiece(piece, tokens)
+	})
+	return
+}
+
+// Decode 将 token 列表解码为文本
+func (b *BPE) Decode(tokens []int) (text string) {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(b.decoder[token])
+	}
+	return sb.String()
+}
+
+// Count 计算文本的 token 数量
+func (b *BPE) Count(text string) (n int) {
+	b.split(text, func(piece string) {
+		if _, ok := b.encoder[piece]; ok {
+			n++
+			return
+		}
+		n += len(b.encodePiece(piece, nil))
+	})
+	return
+}
+
+// split 按预分词正则切分文本
+func (b *BPE) split(text string, fn func(piece string)) {
+	m, _ := b.pattern.FindStringMatch(text)
+	for m != nil {
+		fn(m.String())
+		m, _ = b.pattern.FindNextMatch(m)
+	}
+}
+
+// encodePiece 对预分词片段执行字节对合并，结果追加到 tokens 后
+func (b *BPE) encodePiece(piece string, tokens []int) (result []int) {
+	if rank, ok := b.encoder[piece]; ok {
+		return append(tokens, rank)
+	}
+	// 与 tiktoken 的合并算法保持一致：每次合并优先级最高（数值最小）的相邻字节对
+	type part struct {
+		start int
+		rank  int
+	}
+	getRank := func(parts []part, i int) (rank int) {
+		if i+3 < len(parts) {
+			if rank, ok := b.encoder[piece[parts[i].start:parts[i+3].start]]; ok {
+				return rank
+			}
+		}
+		return math.MaxInt
+	}
+	parts := make([]part, 0, len(piece)+1)
+	for i := 0; i < len(piece)-1; i++ {
+		rank, ok := b.encoder[piece[i:i+2]]
+		if !ok {
+			rank = math.MaxInt
+		}
+		parts = append(parts, part{start: i, rank: rank})
+	}
+	parts = append(parts, part{start: len(piece) - 1, rank: math.MaxInt}, part{start: len(piece), rank: math.MaxInt})
+	for len(parts) > 1 {
+		minRank, minIndex := math.MaxInt, -1
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < minRank {
+				minRank, minIndex = parts[i].rank, i
+			}
+		}
+		if minIndex < 0 {
+			break
+		}
+		parts[minIndex].rank = getRank(parts, minIndex)
+		if minIndex > 0 {
+			parts[minIndex-1].rank = getRank(parts, minIndex-1)
+		}
+		parts = append(parts[:minIndex+1], parts[minIndex+2:]...)
+	}
+	result = tokens
+	for i := 0; i < len(parts)-1; i++ {
+		if rank, ok := b.encoder[piece[parts[i].start:parts[i+1].start]]; ok {
+			result = append(result, rank)
+			continue
+		}
+		// 编码表不完整时，未知字节按单个 token 计
+		result = append(result, -1)
+	}
+	return
+}
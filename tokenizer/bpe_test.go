@@ -0,0 +1,119 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 15:12:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-16 15:12:40
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRanks 创建测试用的合并优先级表：单字节按字节值排序，其后为若干合并
+func testRanks(merges ...string) (ranks map[string]int) {
+	ranks = make(map[string]int, 256+len(merges))
+	for i := range 256 {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, merge := range merges {
+		ranks[merge] = 256 + i
+	}
+	return
+}
+
+func TestBPEEncode(t *testing.T) {
+	b, err := NewBPE(testRanks("ll", "he", "hell", " w", "or", " wor", " world"), PatternCL100kBase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens := b.Encode("hello world")
+	// "hello" -> "hell" + "o"，" world" 直接命中
+	if want := []int{258, 'o', 262}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("expected %v, got %v", want, tokens)
+	}
+	if text := b.Decode(tokens); text != "hello world" {
+		t.Fatalf("expected decoded text %q, got %q", "hello world", text)
+	}
+	if n := b.Count("hello world"); n != len(tokens) {
+		t.Fatalf("expected count %d, got %d", len(tokens), n)
+	}
+}
+
+func TestBPESplit(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    []string
+	}{
+		{PatternCL100kBase, "Hello world", []string{"Hello", " world"}},
+		{PatternCL100kBase, "a   b", []string{"a", "  ", " b"}},
+		{PatternCL100kBase, "I'm 12345!\n\n", []string{"I", "'m", " ", "123", "45", "!\n\n"}},
+		{PatternCL100kBase, "你好，世界", []string{"你好", "，世界"}},
+		{PatternO200kBase, "HelloWorld don't", []string{"Hello", "World", " don't"}},
+		{PatternO200kBase, "path/to\n", []string{"path", "/to", "\n"}},
+	}
+	for _, tt := range tests {
+		b, err := NewBPE(testRanks(), tt.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var pieces []string
+		b.split(tt.text, func(piece string) {
+			pieces = append(pieces, piece)
+		})
+		if !reflect.DeepEqual(pieces, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.text, pieces, tt.want)
+		}
+	}
+}
+
+func TestLoadEncoding(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		sb  strings.Builder
+	)
+	for token, rank := range testRanks("he", "ll") {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	path := filepath.Join(dir, EncodingCL100kBase+encodingFileExt)
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		encodingMu.Lock()
+		delete(encodings, EncodingCL100kBase)
+		encodingMu.Unlock()
+		SetEncodingDir("")
+	})
+
+	SetEncodingDir(dir)
+	tokenizer, err := GetEncoding(EncodingCL100kBase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tokenizer.Count("hello"); n != 3 {
+		t.Fatalf("expected 3 tokens, got %d", n)
+	}
+	if _, err = GetEncoding("unknown"); err == nil {
+		t.Fatal("expected error for unknown encoding")
+	}
+}
+
+func TestApproxTokenizer(t *testing.T) {
+	if n := deepSeekApprox.Count("你好世界"); n != 3 {
+		t.Fatalf("expected 3 tokens, got %d", n)
+	}
+	if n := deepSeekApprox.Count("hello world"); n != 4 {
+		t.Fatalf("expected 4 tokens, got %d", n)
+	}
+}
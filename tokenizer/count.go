@@ -0,0 +1,265 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 11:34:47
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 15:28:46
+ * @Description: 聊天请求 token 计数
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/models"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"reflect"
+	"strings"
+)
+
+const (
+	tokensPerMessage   = 3  // 每条消息的固定开销
+	tokensPerName      = 1  // 消息包含 name 时的额外开销
+	tokensReplyPriming = 3  // 回复起始标记的开销
+	tokensPerToolCall  = 3  // 每次工具调用的固定开销
+	tokensPerTool      = 8  // 每个工具定义的固定开销
+	tokensToolsPrompt  = 12 // 存在工具定义时的额外开销
+)
+
+const (
+	imageLowDetailTokens = 85   // OpenAI 低精度图像 token 数
+	imageTileTokens      = 170  // OpenAI 高精度图像每个 512px 分块的 token 数
+	imageMaxSide         = 2048 // OpenAI 图像缩放后的最大边长
+	imageShortSide       = 768  // OpenAI 图像缩放后的最短边长
+	imageTileSize        = 512  // OpenAI 图像分块边长
+	imageDefaultSide     = 1024 // 无法获取图像尺寸时假定的边长
+	qwenPatchSize        = 28   // 通义千问视觉模型每个 token 对应的像素边长
+	qwenMinPatches       = 4    // 通义千问视觉模型默认最小 token 数
+	qwenMaxPatches       = 1280 // 通义千问视觉模型默认最大 token 数
+	qwenImageExtraTokens = 2    // 通义千问视觉模型图像起止标记
+)
+
+// CountTokens 计算聊天请求输入的 token 数量，包含消息开销、工具定义和图像
+//
+//	OpenAI 编码表未加载时使用近似估算；工具定义与图像的计数均为近似值
+func CountTokens(request models.ChatRequest) (n int, err error) {
+	c := newCounter(request.Provider, request.Model)
+	n = c.countMessages(request.Messages)
+	var toolTokens int
+	if toolTokens, err = c.countTools(request.Tools); err != nil {
+		return
+	}
+	n += toolTokens
+	return
+}
+
+// CountText 使用模型对应的分词器计算文本的 token 数量
+func CountText(provider consts.Provider, model, text string) (n int) {
+	return newCounter(provider, model).tokenizer.Count(text)
+}
+
+// MessageCounter 消息 token 计数器，按消息缓存计数结果，适用于对同一组消息反复计数的场景（非并发安全）
+type MessageCounter struct {
+	c     *counter
+	cache map[models.ChatMessage]int
+}
+
+// NewMessageCounter 创建消息 token 计数器
+func NewMessageCounter(provider consts.Provider, model string) (mc *MessageCounter) {
+	return &MessageCounter{
+		c:     newCounter(provider, model),
+		cache: make(map[models.ChatMessage]int),
+	}
+}
+
+// Count 计算消息列表的 token 数量，包含消息开销和回复起始标记
+func (mc *MessageCounter) Count(messages []models.ChatMessage) (n int) {
+	for _, message := range messages {
+		// 仅缓存可比较的消息（内置消息类型均为指针）
+		if message == nil || !reflect.TypeOf(message).Comparable() {
+			n += mc.c.countMessage(message)
+			continue
+		}
+		tokens, ok := mc.cache[message]
+		if !ok {
+			tokens = mc.c.countMessage(message)
+			mc.cache[message] = tokens
+		}
+		n += tokens
+	}
+	if len(messages) > 0 {
+		n += tokensReplyPriming
+	}
+	return
+}
+
+// counter token 计数器
+type counter struct {
+	provider  consts.Provider
+	tokenizer Tokenizer
+}
+
+// newCounter 创建 token 计数器
+func newCounter(provider consts.Provider, model string) (c *counter) {
+	tokenizer, err := GetEncoding(encodingFor(provider, model))
+	if err != nil {
+		tokenizer = openAIApprox
+	}
+	return &counter{
+		provider:  provider,
+		tokenizer: tokenizer,
+	}
+}
+
+// countMessages 计算消息列表的 token 数量
+func (c *counter) countMessages(messages []models.ChatMessage) (n int) {
+	for _, message := range messages {
+		n += c.countMessage(message)
+	}
+	if len(messages) > 0 {
+		n += tokensReplyPriming
+	}
+	return
+}
+
+// countMessage 计算单条消息的 token 数量
+func (c *counter) countMessage(message models.ChatMessage) (n int) {
+	n = tokensPerMessage
+	var role, name string
+	switch msg := message.(type) {
+	case *models.SystemMessage:
+		role, name = "system", msg.Name
+		n += c.tokenizer.Count(msg.Content)
+	case *models.DeveloperMessage:
+		role, name = "developer", msg.Name
+		n += c.tokenizer.Count(msg.Content)
+	case *models.UserMessage:
+		role, name = "user", msg.Name
+		n += c.tokenizer.Count(msg.Content)
+		for _, part := range msg.MultimodalContent {
+			n += c.countUserPart(part)
+		}
+	case *models.AssistantMessage:
+		role, name = "assistant", msg.Name
+		n += c.tokenizer.Count(msg.Content) + c.tokenizer.Count(msg.Refusal) + c.tokenizer.Count(msg.ReasoningContent)
+		for _, part := range msg.MultimodalContent {
+			n += c.tokenizer.Count(part.Text) + c.tokenizer.Count(part.Refusal)
+		}
+		for _, toolCall := range msg.ToolCalls {
+			n += tokensPerToolCall + c.tokenizer.Count(toolCall.ID)
+			if toolCall.Function != nil {
+				n += c.tokenizer.Count(toolCall.Function.Name) + c.tokenizer.Count(toolCall.Function.Arguments)
+			}
+		}
+	case *models.ToolMessage:
+		role = "tool"
+		n += c.tokenizer.Count(msg.Content) + c.tokenizer.Count(msg.ToolCallID)
+	default:
+		// 未知消息类型按序列化后的内容估算
+		if b, err := json.Marshal(message); err == nil {
+			n += c.tokenizer.Count(string(b))
+		}
+	}
+	n += c.tokenizer.Count(role)
+	if name != "" {
+		n += tokensPerName + c.tokenizer.Count(name)
+	}
+	return
+}
+
+// countUserPart 计算用户消息内容片段的 token 数量
+func (c *counter) countUserPart(part models.ChatUserMsgPart) (n int) {
+	n = c.tokenizer.Count(part.Text)
+	if part.ImageURL != nil {
+		n += c.countImage(part.ImageURL)
+	}
+	if part.InputVideo != nil {
+		for range part.InputVideo.VideoImgList {
+			n += c.countImage(&models.ChatUserMsgImageURL{})
+		}
+	}
+	return
+}
+
+// countImage 计算图像的 token 数量，无法获取图像尺寸时按默认尺寸估算
+func (c *counter) countImage(imageURL *models.ChatUserMsgImageURL) (n int) {
+	width, height, ok := imageSize(imageURL.URL)
+	if c.provider == consts.AliBL {
+		maxPatches := qwenMaxPatches
+		if imageURL.MaxPixels != nil && *imageURL.MaxPixels > 0 {
+			maxPatches = *imageURL.MaxPixels / (qwenPatchSize * qwenPatchSize)
+		}
+		if !ok {
+			return maxPatches + qwenImageExtraTokens
+		}
+		patches := int(math.Ceil(float64(width)/qwenPatchSize) * math.Ceil(float64(height)/qwenPatchSize))
+		return min(max(patches, qwenMinPatches), maxPatches) + qwenImageExtraTokens
+	}
+	if imageURL.Detail == models.ChatUserMsgImageURLDetailLow {
+		return imageLowDetailTokens
+	}
+	if !ok {
+		width, height = imageDefaultSide, imageDefaultSide
+	}
+	w, h := float64(width), float64(height)
+	if w > imageMaxSide || h > imageMaxSide {
+		scale := imageMaxSide / max(w, h)
+		w, h = w*scale, h*scale
+	}
+	if shortSide := min(w, h); shortSide > imageShortSide {
+		scale := imageShortSide / shortSide
+		w, h = w*scale, h*scale
+	}
+	tiles := int(math.Ceil(w/imageTileSize) * math.Ceil(h/imageTileSize))
+	return tiles*imageTileTokens + imageLowDetailTokens
+}
+
+// countTools 计算工具定义的 token 数量
+func (c *counter) countTools(tools []models.ChatTool) (n int, err error) {
+	if len(tools) == 0 {
+		return
+	}
+	n = tokensToolsPrompt
+	for _, tool := range tools {
+		n += tokensPerTool
+		if tool.Function == nil {
+			continue
+		}
+		n += c.tokenizer.Count(tool.Function.Name) + c.tokenizer.Count(tool.Function.Description)
+		if len(tool.Function.Parameters) > 0 {
+			var b []byte
+			if b, err = json.Marshal(tool.Function.Parameters); err != nil {
+				return
+			}
+			n += c.tokenizer.Count(string(b))
+		}
+	}
+	return
+}
+
+// imageSize 从 base64 data URL 中解析图像尺寸
+func imageSize(url string) (width, height int, ok bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return
+	}
+	_, data, found := strings.Cut(url, ";base64,")
+	if !found {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	return config.Width, config.Height, true
+}
@@ -0,0 +1,141 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 15:47:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-16 15:47:09
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/models"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// pngDataURL 创建指定尺寸的 PNG data URL
+func pngDataURL(t *testing.T, width, height int) (url string) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestCountTokens(t *testing.T) {
+	request := models.ChatRequest{
+		Provider: consts.DeepSeek,
+		Model:    consts.DeepSeekChat,
+		Messages: []models.ChatMessage{
+			&models.SystemMessage{Content: "你好世界"},
+			&models.UserMessage{Content: "hello world", Name: "bob"},
+		},
+	}
+	n, err := CountTokens(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// system: 3 + 3 + 2("system")；user: 3 + 4 + 2("user") + 1 + 1("bob")；回复起始: 3
+	if want := 8 + 11 + 3; n != want {
+		t.Fatalf("expected %d tokens, got %d", want, n)
+	}
+	// 工具定义计入 token 数
+	request.Tools = []models.ChatTool{{
+		Type: "function",
+		Function: &models.ChatToolFunction{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters:  map[string]any{"type": "object"},
+		},
+	}}
+	withTools, err := CountTokens(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if withTools <= n+tokensToolsPrompt+tokensPerTool {
+		t.Fatalf("expected tools to add tokens, got %d (without tools %d)", withTools, n)
+	}
+}
+
+func TestCountImageTokens(t *testing.T) {
+	tests := []struct {
+		provider consts.Provider
+		imageURL models.ChatUserMsgImageURL
+		want     int
+	}{
+		// 1024x1024 缩放至 768x768，4 个分块
+		{consts.OpenAI, models.ChatUserMsgImageURL{URL: pngDataURL(t, 1024, 1024)}, 4*imageTileTokens + imageLowDetailTokens},
+		// 2048x4096 缩放至 1024x2048，再缩放至 768x1536，6 个分块
+		{consts.OpenAI, models.ChatUserMsgImageURL{URL: pngDataURL(t, 2048, 4096)}, 6*imageTileTokens + imageLowDetailTokens},
+		{consts.OpenAI, models.ChatUserMsgImageURL{URL: "https://example.com/a.png", Detail: models.ChatUserMsgImageURLDetailLow}, imageLowDetailTokens},
+		// 280x560 -> 10x20 个 28px 分块
+		{consts.AliBL, models.ChatUserMsgImageURL{URL: pngDataURL(t, 280, 560)}, 200 + qwenImageExtraTokens},
+		{consts.AliBL, models.ChatUserMsgImageURL{URL: "https://example.com/a.png"}, qwenMaxPatches + qwenImageExtraTokens},
+	}
+	for _, tt := range tests {
+		c := newCounter(tt.provider, "")
+		if got := c.countImage(&tt.imageURL); got != tt.want {
+			t.Errorf("%s image tokens = %d, want %d", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestLookupModel(t *testing.T) {
+	spec, ok := LookupModel(consts.OpenAI, consts.OpenAIGPT4oMini20240718)
+	if !ok || spec.ContextWindow != 128000 || spec.Encoding != EncodingO200kBase {
+		t.Fatalf("unexpected spec: %+v, %v", spec, ok)
+	}
+	spec, ok = LookupModel(consts.OpenAI, consts.OpenAIGPT4_0613)
+	if !ok || spec.ContextWindow != 8192 || spec.Encoding != EncodingCL100kBase {
+		t.Fatalf("unexpected spec: %+v, %v", spec, ok)
+	}
+	if _, ok = LookupModel(consts.OpenAI, "gpt-4omni"); ok {
+		t.Fatal("expected no match for unknown model")
+	}
+}
+
+func TestCheckContextWindow(t *testing.T) {
+	RegisterModel(consts.DeepSeek, "test-small", ModelSpec{Encoding: EncodingDeepSeek, ContextWindow: 100})
+	maxTokens := 50
+	request := models.ChatRequest{
+		Provider: consts.DeepSeek,
+		Model:    "test-small",
+		Messages: []models.ChatMessage{
+			&models.UserMessage{Content: strings.Repeat("hello ", 20)},
+		},
+		MaxCompletionTokens: &maxTokens,
+	}
+	promptTokens, err := CheckContextWindow(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v (prompt tokens %d)", err, promptTokens)
+	}
+
+	request.Messages = append(request.Messages, &models.UserMessage{Content: strings.Repeat("hello ", 20)})
+	_, err = CheckContextWindow(request)
+	if !errors.Is(err, ErrContextWindowExceeded) {
+		t.Fatalf("expected ErrContextWindowExceeded, got %v", err)
+	}
+	var windowErr *ContextWindowExceededError
+	if !errors.As(err, &windowErr) || windowErr.ContextWindow != 100 || windowErr.CompletionTokens != 50 {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// 中间件在发送前拒绝请求
+	var called bool
+	_, err = NewMiddleware().Process(context.Background(), request, func(ctx context.Context, request any) (response any, err error) {
+		called = true
+		return
+	})
+	if !errors.Is(err, ErrContextWindowExceeded) || called {
+		t.Fatalf("expected request rejected before sending, got err=%v called=%v", err, called)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 10:20:35
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-16 10:20:35
+ * @Description: 编码注册表
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode"
+)
+
+const (
+	EncodingCL100kBase = "cl100k_base" // OpenAI GPT-4、GPT-3.5 系列
+	EncodingO200kBase  = "o200k_base"  // OpenAI GPT-4o、GPT-4.1、o 系列
+	EncodingQwen       = "qwen"        // 通义千问系列（近似估算）
+	EncodingDeepSeek   = "deepseek"    // DeepSeek 系列（近似估算）
+)
+
+const (
+	encodingDirEnv  = "AISDK_TIKTOKEN_DIR" // 编码表目录环境变量
+	encodingFileExt = ".tiktoken"          // 编码表文件扩展名
+)
+
+var (
+	errEncodingNotFound = errors.New("encoding not found") // 编码未找到
+)
+
+// Tokenizer 分词器接口
+type Tokenizer interface {
+	Count(text string) (n int) // 计算文本的 token 数量
+}
+
+// ApproxTokenizer 按字符比例近似估算 token 数量的分词器
+type ApproxTokenizer struct {
+	CJKTokensPerChar   float64 // 每个中日韩字符对应的 token 数
+	OtherTokensPerChar float64 // 每个其他字符对应的 token 数
+}
+
+// Count 计算文本的 token 数量
+func (t ApproxTokenizer) Count(text string) (n int) {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(cjk)*t.CJKTokensPerChar + float64(other)*t.OtherTokensPerChar))
+}
+
+var (
+	// 近似估算比例参考各提供商官方文档的换算说明
+	qwenApprox     = ApproxTokenizer{CJKTokensPerChar: 0.6, OtherTokensPerChar: 0.25}
+	deepSeekApprox = ApproxTokenizer{CJKTokensPerChar: 0.6, OtherTokensPerChar: 0.3}
+	// OpenAI 编码表未加载时使用的近似估算
+	openAIApprox = ApproxTokenizer{CJKTokensPerChar: 1, OtherTokensPerChar: 0.25}
+	// 内置编码的预分词正则
+	encodingPatterns = map[string]string{
+		EncodingCL100kBase: PatternCL100kBase,
+		EncodingO200kBase:  PatternO200kBase,
+	}
+)
+
+var (
+	encodingMu  sync.RWMutex
+	encodingDir = os.Getenv(encodingDirEnv)
+	encodings   = map[string]Tokenizer{
+		EncodingQwen:     qwenApprox,
+		EncodingDeepSeek: deepSeekApprox,
+	}
+)
+
+// RegisterEncoding 注册编码
+func RegisterEncoding(name string, tokenizer Tokenizer) {
+	encodingMu.Lock()
+	defer encodingMu.Unlock()
+
+	encodings[name] = tokenizer
+}
+
+// LoadEncoding 从本地 tiktoken 编码表文件加载内置编码（cl100k_base、o200k_base）并注册
+func LoadEncoding(name, path string) (err error) {
+	pattern, ok := encodingPatterns[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", errEncodingNotFound, name)
+	}
+	var b *BPE
+	if b, err = LoadBPEFile(path, pattern); err != nil {
+		return fmt.Errorf("load encoding %s: %w", name, err)
+	}
+	RegisterEncoding(name, b)
+	return
+}
+
+// SetEncodingDir 设置编码表目录，首次使用内置编码时从该目录加载 <name>.tiktoken，默认读取环境变量 AISDK_TIKTOKEN_DIR
+func SetEncodingDir(dir string) {
+	encodingMu.Lock()
+	defer encodingMu.Unlock()
+
+	encodingDir = dir
+}
+
+// GetEncoding 获取编码，内置编码未注册时尝试从编码表目录加载
+func GetEncoding(name string) (tokenizer Tokenizer, err error) {
+	encodingMu.RLock()
+	tokenizer, ok := encodings[name]
+	dir := encodingDir
+	encodingMu.RUnlock()
+	if ok {
+		return
+	}
+	if _, builtin := encodingPatterns[name]; !builtin || dir == "" {
+		return nil, fmt.Errorf("%w: %s", errEncodingNotFound, name)
+	}
+	if err = LoadEncoding(name, filepath.Join(dir, name+encodingFileExt)); err != nil {
+		return
+	}
+	return GetEncoding(name)
+}
@@ -0,0 +1,107 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 10:58:02
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-16 10:58:02
+ * @Description: 模型上下文窗口注册表
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"github.com/liusuxian/go-aisdk/consts"
+	"strings"
+	"sync"
+)
+
+// ModelSpec 模型规格
+type ModelSpec struct {
+	Encoding        string // 编码名称
+	ContextWindow   int    // 上下文窗口大小（输入与输出token之和）
+	MaxOutputTokens int    // 最大输出token数
+}
+
+var (
+	modelMu    sync.RWMutex
+	modelSpecs = map[consts.Provider]map[string]ModelSpec{
+		consts.OpenAI: {
+			consts.OpenAIGPT4o:             {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 16384},
+			consts.OpenAIChatGPT4oLatest:   {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 16384},
+			consts.OpenAIGPT4oMini:         {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 16384},
+			consts.OpenAIGPT4Dot1:          {Encoding: EncodingO200kBase, ContextWindow: 1047576, MaxOutputTokens: 32768},
+			consts.OpenAIGPT4Dot5Preview:   {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 16384},
+			consts.OpenAIO1:                {Encoding: EncodingO200kBase, ContextWindow: 200000, MaxOutputTokens: 100000},
+			consts.OpenAIO1Mini:            {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 65536},
+			consts.OpenAIO1Preview:         {Encoding: EncodingO200kBase, ContextWindow: 128000, MaxOutputTokens: 32768},
+			consts.OpenAIO3:                {Encoding: EncodingO200kBase, ContextWindow: 200000, MaxOutputTokens: 100000},
+			consts.OpenAIO4Mini:            {Encoding: EncodingO200kBase, ContextWindow: 200000, MaxOutputTokens: 100000},
+			consts.OpenAIGPT4Turbo:         {Encoding: EncodingCL100kBase, ContextWindow: 128000, MaxOutputTokens: 4096},
+			consts.OpenAIGPT4_0125Preview:  {Encoding: EncodingCL100kBase, ContextWindow: 128000, MaxOutputTokens: 4096},
+			consts.OpenAIGPT4_1106Preview:  {Encoding: EncodingCL100kBase, ContextWindow: 128000, MaxOutputTokens: 4096},
+			consts.OpenAIGPT4VisionPreview: {Encoding: EncodingCL100kBase, ContextWindow: 128000, MaxOutputTokens: 4096},
+			consts.OpenAIGPT4:              {Encoding: EncodingCL100kBase, ContextWindow: 8192, MaxOutputTokens: 8192},
+			consts.OpenAIGPT4_32K:          {Encoding: EncodingCL100kBase, ContextWindow: 32768, MaxOutputTokens: 32768},
+			consts.OpenAIGPT3Dot5Turbo:     {Encoding: EncodingCL100kBase, ContextWindow: 16385, MaxOutputTokens: 4096},
+		},
+		consts.DeepSeek: {
+			consts.DeepSeekChat:     {Encoding: EncodingDeepSeek, ContextWindow: 65536, MaxOutputTokens: 8192},
+			consts.DeepSeekReasoner: {Encoding: EncodingDeepSeek, ContextWindow: 65536, MaxOutputTokens: 65536},
+		},
+		consts.AliBL: {
+			consts.AliBLQwenMax:       {Encoding: EncodingQwen, ContextWindow: 32768, MaxOutputTokens: 8192},
+			consts.AliBLQwenPlus:      {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 16384},
+			consts.AliBLQwenTurbo:     {Encoding: EncodingQwen, ContextWindow: 1000000, MaxOutputTokens: 16384},
+			consts.AliBLQwenLong:      {Encoding: EncodingQwen, ContextWindow: 10000000, MaxOutputTokens: 8192},
+			consts.AliBLQwqPlus:       {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 8192},
+			consts.AliBLQwenVlMax:     {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 8192},
+			consts.AliBLQwenVlPlus:    {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 8192},
+			consts.AliBLQvqMax:        {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 8192},
+			consts.AliBLQvqPlus:       {Encoding: EncodingQwen, ContextWindow: 131072, MaxOutputTokens: 8192},
+			consts.AliBLQwenOmniTurbo: {Encoding: EncodingQwen, ContextWindow: 32768, MaxOutputTokens: 2048},
+		},
+	}
+	// 未注册模型时各提供商使用的默认编码
+	providerEncodings = map[consts.Provider]string{
+		consts.OpenAI:   EncodingO200kBase,
+		consts.DeepSeek: EncodingDeepSeek,
+		consts.AliBL:    EncodingQwen,
+	}
+)
+
+// RegisterModel 注册模型规格
+func RegisterModel(provider consts.Provider, model string, spec ModelSpec) {
+	modelMu.Lock()
+	defer modelMu.Unlock()
+
+	if _, ok := modelSpecs[provider]; !ok {
+		modelSpecs[provider] = make(map[string]ModelSpec)
+	}
+	modelSpecs[provider][model] = spec
+}
+
+// LookupModel 获取模型规格，未精确匹配时按最长前缀匹配（如 gpt-4o-2024-08-06 匹配 gpt-4o）
+func LookupModel(provider consts.Provider, model string) (spec ModelSpec, ok bool) {
+	modelMu.RLock()
+	defer modelMu.RUnlock()
+
+	specs := modelSpecs[provider]
+	if spec, ok = specs[model]; ok {
+		return
+	}
+	var matched string
+	for name, s := range specs {
+		if len(name) > len(matched) && strings.HasPrefix(model, name+"-") {
+			matched, spec, ok = name, s, true
+		}
+	}
+	return
+}
+
+// encodingFor 获取模型使用的编码名称
+func encodingFor(provider consts.Provider, model string) (encoding string) {
+	if spec, ok := LookupModel(provider, model); ok && spec.Encoding != "" {
+		return spec.Encoding
+	}
+	return providerEncodings[provider]
+}
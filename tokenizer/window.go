@@ -0,0 +1,96 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 14:08:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 15:31:40
+ * @Description: 上下文窗口检查
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/consts"
+	sdkerrors "github.com/liusuxian/go-aisdk/errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+)
+
+// ErrContextWindowExceeded 请求超出模型上下文窗口，可通过 errors.Is 判断
+var ErrContextWindowExceeded = errors.New("context window exceeded")
+
+// ContextWindowExceededError 请求超出模型上下文窗口时返回的错误
+type ContextWindowExceededError struct {
+	Provider         consts.Provider // 提供商
+	Model            string          // 模型名称
+	ContextWindow    int             // 上下文窗口大小
+	PromptTokens     int             // 输入token数（估算）
+	CompletionTokens int             // 请求的最大输出token数
+}
+
+// Error 实现 error 接口
+func (e *ContextWindowExceededError) Error() (s string) {
+	return fmt.Sprintf("%s: model %s has a context window of %d tokens, but the request needs %d tokens (%d prompt + %d completion)",
+		ErrContextWindowExceeded, e.Model, e.ContextWindow, e.PromptTokens+e.CompletionTokens, e.PromptTokens, e.CompletionTokens)
+}
+
+// Is 支持 errors.Is(err, ErrContextWindowExceeded)，同时属于 sdkerrors.ErrContextLengthExceeded 错误分类
+func (e *ContextWindowExceededError) Is(target error) (ok bool) {
+	return target == ErrContextWindowExceeded || target == sdkerrors.ErrContextLengthExceeded
+}
+
+// CheckContextWindow 检查请求是否超出模型上下文窗口，返回输入token数，未注册的模型不做检查
+func CheckContextWindow(request models.ChatRequest) (promptTokens int, err error) {
+	if promptTokens, err = CountTokens(request); err != nil {
+		return
+	}
+	spec, ok := LookupModel(request.Provider, request.Model)
+	if !ok || spec.ContextWindow <= 0 {
+		return
+	}
+	var completionTokens int
+	if request.MaxCompletionTokens != nil {
+		completionTokens = *request.MaxCompletionTokens
+	}
+	if promptTokens+completionTokens > spec.ContextWindow {
+		err = &ContextWindowExceededError{
+			Provider:         request.Provider,
+			Model:            request.Model,
+			ContextWindow:    spec.ContextWindow,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+		}
+	}
+	return
+}
+
+// Middleware 上下文窗口检查中间件，请求超出模型上下文窗口时在发送前返回错误
+type Middleware struct{}
+
+// NewMiddleware 创建上下文窗口检查中间件
+func NewMiddleware() (m *Middleware) {
+	return &Middleware{}
+}
+
+// Process 处理请求
+func (m *Middleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	if chatReq, ok := request.(models.ChatRequest); ok {
+		if _, err = CheckContextWindow(chatReq); err != nil {
+			return
+		}
+	}
+	return next(ctx, request)
+}
+
+// Name 返回中间件名称
+func (m *Middleware) Name() (name string) {
+	return "context_window"
+}
+
+// Priority 返回中间件优先级
+func (m *Middleware) Priority() (priority int) {
+	return 12 // 在监控之后、缓存之前执行，超出上下文窗口的请求不会发送也不会重试
+}
@@ -0,0 +1,362 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-18 09:46:30
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-19 16:08:51
+ * @Description: 工具调用循环
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/liusuxian/go-aisdk/jsonschema"
+	"github.com/liusuxian/go-aisdk/models"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxIterations = 10 // 默认最大模型调用次数
+)
+
+var (
+	// ErrMaxIterations 达到最大模型调用次数时模型仍在请求调用工具，可通过 errors.Is 判断
+	ErrMaxIterations = errors.New("tool runner reached max iterations")
+	// 工具名称规则，与 OpenAI 函数名称要求一致
+	toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+)
+
+var (
+	errInvalidToolName = errors.New("invalid tool name")                            // 工具名称无效
+	errToolExists      = errors.New("tool already registered")                      // 工具已注册
+	errNilHandler      = errors.New("tool handler is nil")                          // 工具处理函数为空
+	errUnknownTool     = errors.New("unknown tool")                                 // 未注册的工具
+	errEmptyChoices    = errors.New("chat completion response contains no choices") // 响应中没有 choices
+)
+
+// Handler 工具处理函数，arguments 为模型生成的 JSON 参数
+type Handler func(ctx context.Context, arguments string) (result string, err error)
+
+// TypedHandler 将参数解析为 T 类型的工具处理函数，返回值为字符串时原样返回，否则序列化为 JSON
+func TypedHandler[T any](fn func(ctx context.Context, args T) (result any, err error)) (handler Handler) {
+	return func(ctx context.Context, arguments string) (result string, err error) {
+		var args T
+		if arguments != "" {
+			if err = json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		var value any
+		if value, err = fn(ctx, args); err != nil {
+			return
+		}
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		var b []byte
+		if b, err = json.Marshal(value); err != nil {
+			return
+		}
+		return string(b), nil
+	}
+}
+
+// NewTool 根据参数类型 T 生成参数的 JSON Schema 并创建工具，strict 为 true 时生成 OpenAI 严格模式的 Schema
+func NewTool[T any](name, description string, strict bool, fn func(ctx context.Context, args T) (result any, err error)) (tool Tool, err error) {
+	var parameters map[string]any
+	if parameters, err = jsonschema.GenerateFor[T](jsonschema.Config{Strict: strict}); err != nil {
+		return
+	}
+	tool = Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+		Handler:     TypedHandler(fn),
+	}
+	if strict {
+		tool.Strict = models.Bool(true)
+	}
+	return
+}
+
+// Tool 工具
+type Tool struct {
+	Name        string         // 工具名称，必须是 a-z, A-Z, 0-9 或者包含下划线和破折号，最大长度为 64
+	Description string         // 工具描述
+	Parameters  map[string]any // 参数的 JSON Schema
+	Strict      *bool          // 是否启用严格模式（仅 OpenAI 支持）
+	Handler     Handler        // 工具处理函数
+	Timeout     time.Duration  // 工具执行超时时间，为0时使用执行器的默认超时时间
+}
+
+// Call 工具调用记录
+type Call struct {
+	ID        string        // 工具调用ID
+	Name      string        // 工具名称
+	Arguments string        // 调用参数
+	Result    string        // 返回给模型的结果（出错时为错误信息）
+	Err       error         // 工具执行错误
+	Duration  time.Duration // 执行耗时
+}
+
+// Result 工具调用循环的执行结果
+type Result struct {
+	Response   models.ChatResponse  // 最后一次模型响应
+	Messages   []models.ChatMessage // 完整的消息列表，包含模型的工具调用消息和工具结果消息
+	Iterations int                  // 模型调用次数
+	Calls      []Call               // 工具调用记录
+}
+
+// ChatCompleter 聊天补全函数
+type ChatCompleter func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error)
+
+// ChatStreamer 流式聊天补全函数
+type ChatStreamer func(ctx context.Context, request models.ChatRequest) (response models.ChatResponseStream, err error)
+
+// RunnerConfig 工具执行器配置
+type RunnerConfig struct {
+	MaxIterations int                                                 // 最大模型调用次数，默认 10
+	ToolTimeout   time.Duration                                       // 工具执行的默认超时时间，为0时不限制
+	FormatError   func(call models.ToolCalls, err error) (msg string) // 将工具执行错误转换为返回给模型的消息，默认为 "error: <错误信息>"
+	OnCall        func(ctx context.Context, call Call)                // 每次工具调用完成后的回调
+}
+
+// Runner 工具执行器，负责注册工具并执行模型调用与工具调用的循环（并发安全）
+type Runner struct {
+	config RunnerConfig
+	mu     sync.RWMutex
+	tools  map[string]Tool
+	order  []string
+}
+
+// NewRunner 创建工具执行器
+func NewRunner(config RunnerConfig) (r *Runner) {
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = defaultMaxIterations
+	}
+	if config.FormatError == nil {
+		config.FormatError = func(call models.ToolCalls, err error) (msg string) {
+			return "error: " + err.Error()
+		}
+	}
+	return &Runner{
+		config: config,
+		tools:  make(map[string]Tool),
+	}
+}
+
+// Register 注册工具
+func (r *Runner) Register(tools ...Tool) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tool := range tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			return fmt.Errorf("%w: %q", errInvalidToolName, tool.Name)
+		}
+		if tool.Handler == nil {
+			return fmt.Errorf("%w: %s", errNilHandler, tool.Name)
+		}
+		if _, ok := r.tools[tool.Name]; ok {
+			return fmt.Errorf("%w: %s", errToolExists, tool.Name)
+		}
+		r.tools[tool.Name] = tool
+		r.order = append(r.order, tool.Name)
+	}
+	return
+}
+
+// Tools 获取已注册工具的定义，按注册顺序排列
+func (r *Runner) Tools() (tools []models.ChatTool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools = make([]models.ChatTool, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		tools = append(tools, models.ChatTool{
+			Type: models.ToolTypeFunction,
+			Function: &models.ChatToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+				Strict:      tool.Strict,
+			},
+		})
+	}
+	return
+}
+
+// Run 使用非流式聊天补全执行工具调用循环，直到模型不再请求调用工具
+func (r *Runner) Run(ctx context.Context, complete ChatCompleter, request models.ChatRequest) (result Result, err error) {
+	return r.run(ctx, request, func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		return complete(ctx, request)
+	})
+}
+
+// RunStream 使用流式聊天补全执行工具调用循环，onChunk 接收每次模型调用的数据块，可为空
+func (r *Runner) RunStream(ctx context.Context, stream ChatStreamer, request models.ChatRequest, onChunk func(chunk models.ChatBaseResponse) (err error)) (result Result, err error) {
+	return r.run(ctx, request, func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		var s models.ChatResponseStream
+		if s, err = stream(ctx, request); err != nil {
+			return
+		}
+		var chunks []models.ChatBaseResponse
+		if err = s.ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+			if isFinished {
+				return
+			}
+			chunks = append(chunks, chunk)
+			if onChunk != nil {
+				return onChunk(chunk)
+			}
+			return
+		}); err != nil {
+			return
+		}
+		response.ChatBaseResponse = models.MergeChatStreamChunks(chunks)
+		return
+	})
+}
+
+// run 执行工具调用循环
+func (r *Runner) run(ctx context.Context, request models.ChatRequest, complete ChatCompleter) (result Result, err error) {
+	if len(request.Tools) == 0 {
+		request.Tools = r.Tools()
+	}
+	parallel := request.ParallelToolCalls != nil && *request.ParallelToolCalls
+	result.Messages = slices.Clone(request.Messages)
+	for result.Iterations < r.config.MaxIterations {
+		request.Messages = result.Messages
+		result.Iterations++
+		if result.Response, err = complete(ctx, request); err != nil {
+			return
+		}
+		if len(result.Response.Choices) == 0 || result.Response.Choices[0].Message == nil {
+			err = errEmptyChoices
+			return
+		}
+		message := result.Response.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			result.Messages = append(result.Messages, &models.AssistantMessage{Content: message.Content})
+			return
+		}
+		toolCalls := normalizeToolCalls(message.ToolCalls)
+		result.Messages = append(result.Messages, &models.AssistantMessage{Content: message.Content, ToolCalls: toolCalls})
+		// 执行工具调用，结果按工具调用顺序追加
+		calls := r.execute(ctx, toolCalls, parallel)
+		for _, call := range calls {
+			result.Messages = append(result.Messages, &models.ToolMessage{Content: call.Result, ToolCallID: call.ID})
+		}
+		result.Calls = append(result.Calls, calls...)
+	}
+	err = fmt.Errorf("%w (%d)", ErrMaxIterations, r.config.MaxIterations)
+	return
+}
+
+// execute 执行工具调用
+func (r *Runner) execute(ctx context.Context, toolCalls []models.ToolCalls, parallel bool) (calls []Call) {
+	calls = make([]Call, len(toolCalls))
+	if !parallel || len(toolCalls) == 1 {
+		for i, toolCall := range toolCalls {
+			calls[i] = r.call(ctx, toolCall)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls[i] = r.call(ctx, toolCall)
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+// call 执行单个工具调用，错误会转换为返回给模型的消息
+func (r *Runner) call(ctx context.Context, toolCall models.ToolCalls) (call Call) {
+	call = Call{
+		ID:        toolCall.ID,
+		Name:      toolCall.Function.Name,
+		Arguments: toolCall.Function.Arguments,
+	}
+	start := time.Now()
+	defer func() {
+		call.Duration = time.Since(start)
+		if call.Err != nil {
+			call.Result = r.config.FormatError(toolCall, call.Err)
+		}
+		if r.config.OnCall != nil {
+			r.config.OnCall(ctx, call)
+		}
+	}()
+
+	r.mu.RLock()
+	tool, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		call.Err = fmt.Errorf("%w: %s", errUnknownTool, call.Name)
+		return
+	}
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = r.config.ToolTimeout
+	}
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	call.Result, call.Err = safeCall(callCtx, tool.Handler, call.Arguments)
+	return
+}
+
+// safeCall 执行工具处理函数，捕获 panic 并在超时后返回
+func safeCall(ctx context.Context, handler Handler, arguments string) (result string, err error) {
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("tool panicked: %v", p)}
+			}
+		}()
+		result, err := handler(ctx, arguments)
+		done <- outcome{result: result, err: err}
+	}()
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// normalizeToolCalls 规范化工具调用，去除流式传输的索引并补全类型，以便作为历史消息发送
+func normalizeToolCalls(toolCalls []models.ToolCalls) (result []models.ToolCalls) {
+	result = make([]models.ToolCalls, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		if toolCall.Function == nil {
+			toolCall.Function = &models.ToolCallsFunction{}
+		}
+		if toolCall.Type == "" {
+			toolCall.Type = models.ToolTypeFunction
+		}
+		toolCall.Index = 0
+		result = append(result, toolCall)
+	}
+	return
+}
@@ -0,0 +1,246 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-18 11:20:14
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-18 11:20:14
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tools
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// toolCallResponse 创建请求调用工具的响应
+func toolCallResponse(calls ...models.ToolCalls) (response models.ChatResponse) {
+	response.Choices = []models.ChatChoice{{
+		FinishReason: models.ChatFinishReasonToolCalls,
+		Message:      &models.ChatCompletionMessage{Role: "assistant", ToolCalls: calls},
+	}}
+	return
+}
+
+// textResponse 创建文本响应
+func textResponse(content string) (response models.ChatResponse) {
+	response.Choices = []models.ChatChoice{{
+		FinishReason: models.ChatFinishReasonStop,
+		Message:      &models.ChatCompletionMessage{Role: "assistant", Content: content},
+	}}
+	return
+}
+
+// toolCall 创建工具调用
+func toolCall(id, name, arguments string) (call models.ToolCalls) {
+	return models.ToolCalls{ID: id, Type: models.ToolTypeFunction, Function: &models.ToolCallsFunction{Name: name, Arguments: arguments}}
+}
+
+// newTestRunner 创建注册了测试工具的执行器
+func newTestRunner(t *testing.T, config RunnerConfig) (r *Runner) {
+	type weatherArgs struct {
+		City string `json:"city"`
+	}
+	r = NewRunner(config)
+	if err := r.Register(
+		Tool{
+			Name:        "get_weather",
+			Description: "Get the weather of a city",
+			Parameters:  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			Handler: TypedHandler(func(ctx context.Context, args weatherArgs) (result any, err error) {
+				return map[string]string{"city": args.City, "weather": "sunny"}, nil
+			}),
+		},
+		Tool{
+			Name: "fail",
+			Handler: func(ctx context.Context, arguments string) (result string, err error) {
+				return "", errors.New("boom")
+			},
+		},
+		Tool{
+			Name:    "slow",
+			Timeout: 20 * time.Millisecond,
+			Handler: func(ctx context.Context, arguments string) (result string, err error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+		},
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return
+}
+
+func TestRunnerRegister(t *testing.T) {
+	r := newTestRunner(t, RunnerConfig{})
+	if err := r.Register(Tool{Name: "get_weather", Handler: func(ctx context.Context, arguments string) (string, error) { return "", nil }}); err == nil {
+		t.Fatal("expected duplicate tool error")
+	}
+	if err := r.Register(Tool{Name: "bad name", Handler: func(ctx context.Context, arguments string) (string, error) { return "", nil }}); err == nil {
+		t.Fatal("expected invalid name error")
+	}
+	if err := r.Register(Tool{Name: "nil_handler"}); err == nil {
+		t.Fatal("expected nil handler error")
+	}
+	defs := r.Tools()
+	if len(defs) != 3 || defs[0].Function.Name != "get_weather" || defs[0].Type != models.ToolTypeFunction {
+		t.Fatalf("unexpected tool definitions: %+v", defs)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	var (
+		r        = newTestRunner(t, RunnerConfig{})
+		requests []models.ChatRequest
+		complete = func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+			requests = append(requests, request)
+			if len(requests) == 1 {
+				return toolCallResponse(
+					toolCall("call_1", "get_weather", `{"city":"Paris"}`),
+					toolCall("call_2", "fail", `{}`),
+					toolCall("call_3", "missing", `{}`),
+					toolCall("call_4", "slow", `{}`),
+				), nil
+			}
+			return textResponse("It is sunny in Paris"), nil
+		}
+	)
+	result, err := r.Run(context.Background(), complete, models.ChatRequest{
+		Messages:          []models.ChatMessage{&models.UserMessage{Content: "weather in Paris?"}},
+		ParallelToolCalls: models.Bool(true),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Iterations != 2 || len(requests) != 2 {
+		t.Fatalf("expected 2 iterations, got %d", result.Iterations)
+	}
+	if len(requests[0].Tools) != 3 {
+		t.Fatalf("expected registered tools to be sent, got %d", len(requests[0].Tools))
+	}
+	// 用户消息 + 工具调用消息 + 4 条工具结果 + 最终回复
+	if len(result.Messages) != 7 || len(requests[1].Messages) != 6 {
+		t.Fatalf("unexpected messages: %d, second request %d", len(result.Messages), len(requests[1].Messages))
+	}
+	wants := []string{
+		`{"city":"Paris","weather":"sunny"}`,
+		"error: boom",
+		"error: unknown tool: missing",
+		"error: context deadline exceeded",
+	}
+	for i, want := range wants {
+		msg := result.Messages[2+i].(*models.ToolMessage)
+		if msg.Content != want || msg.ToolCallID != result.Calls[i].ID {
+			t.Errorf("tool result %d: expected %q, got %q (%s)", i, want, msg.Content, msg.ToolCallID)
+		}
+	}
+	if !errors.Is(result.Calls[3].Err, context.DeadlineExceeded) {
+		t.Errorf("expected slow tool to time out, got %v", result.Calls[3].Err)
+	}
+	if final := result.Messages[6].(*models.AssistantMessage); final.Content != "It is sunny in Paris" {
+		t.Errorf("unexpected final message: %+v", final)
+	}
+}
+
+func TestRunnerParallel(t *testing.T) {
+	var (
+		running, peak atomic.Int32
+		r             = NewRunner(RunnerConfig{})
+		handler       = func(ctx context.Context, arguments string) (result string, err error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return "ok", nil
+		}
+	)
+	r.Register(Tool{Name: "work", Handler: handler})
+	run := func(parallel bool) {
+		var calls int
+		r.Run(context.Background(), func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+			if calls++; calls == 1 {
+				return toolCallResponse(toolCall("a", "work", ""), toolCall("b", "work", ""), toolCall("c", "work", "")), nil
+			}
+			return textResponse("done"), nil
+		}, models.ChatRequest{ParallelToolCalls: models.Bool(parallel)})
+	}
+
+	run(false)
+	if peak.Load() != 1 {
+		t.Fatalf("expected sequential execution, got peak %d", peak.Load())
+	}
+	peak.Store(0)
+	run(true)
+	if peak.Load() != 3 {
+		t.Fatalf("expected parallel execution, got peak %d", peak.Load())
+	}
+}
+
+func TestRunnerMaxIterations(t *testing.T) {
+	r := newTestRunner(t, RunnerConfig{MaxIterations: 3})
+	result, err := r.Run(context.Background(), func(ctx context.Context, request models.ChatRequest) (response models.ChatResponse, err error) {
+		return toolCallResponse(toolCall("call", "get_weather", `{"city":"Paris"}`)), nil
+	}, models.ChatRequest{})
+	if !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("expected ErrMaxIterations, got %v", err)
+	}
+	if result.Iterations != 3 || len(result.Calls) != 3 {
+		t.Fatalf("unexpected result: iterations %d, calls %d", result.Iterations, len(result.Calls))
+	}
+}
+
+func TestRunnerRunStream(t *testing.T) {
+	var (
+		r      = newTestRunner(t, RunnerConfig{})
+		calls  int
+		chunks int
+		bodies = []string{
+			// 工具调用参数分多个数据块传输
+			"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"\"}}]}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"Paris\\\"}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n" +
+				"data: [DONE]\n\n",
+			"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Sunny\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\" in Paris\"},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: [DONE]\n\n",
+		}
+	)
+	result, err := r.RunStream(context.Background(), func(ctx context.Context, request models.ChatRequest) (response models.ChatResponseStream, err error) {
+		body := io.NopCloser(strings.NewReader(bodies[calls]))
+		calls++
+		return models.ChatResponseStream{
+			StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](body, nil, nil),
+		}, nil
+	}, models.ChatRequest{Messages: []models.ChatMessage{&models.UserMessage{Content: "weather in Paris?"}}}, func(chunk models.ChatBaseResponse) (err error) {
+		chunks++
+		return
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chunks != 5 || result.Iterations != 2 {
+		t.Fatalf("unexpected chunks %d, iterations %d", chunks, result.Iterations)
+	}
+	if len(result.Calls) != 1 || result.Calls[0].Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected calls: %+v", result.Calls)
+	}
+	assistant := result.Messages[1].(*models.AssistantMessage)
+	if assistant.ToolCalls[0].Index != 0 || assistant.ToolCalls[0].ID != "call_1" {
+		t.Fatalf("unexpected assistant tool calls: %+v", assistant.ToolCalls)
+	}
+	if content := result.Response.Choices[0].Message.Content; content != "Sunny in Paris" {
+		t.Fatalf("unexpected final content: %q", content)
+	}
+}
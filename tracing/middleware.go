@@ -0,0 +1,359 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 10:16:27
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 15:02:47
+ * @Description: OpenTelemetry 链路追踪中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	instrumentationName = "github.com/liusuxian/go-aisdk" // 追踪器名称
+	cacheHeaderKey      = "X-AISDK-Cache"                 // 缓存命中标记头键
+)
+
+// GenAI 语义约定属性键
+const (
+	AttrGenAISystem             = attribute.Key("gen_ai.system")                  // 提供商
+	AttrGenAIOperationName      = attribute.Key("gen_ai.operation.name")          // 操作名称
+	AttrGenAIRequestModel       = attribute.Key("gen_ai.request.model")           // 请求的模型
+	AttrGenAIRequestTemperature = attribute.Key("gen_ai.request.temperature")     // 温度
+	AttrGenAIRequestTopP        = attribute.Key("gen_ai.request.top_p")           // 核采样
+	AttrGenAIRequestMaxTokens   = attribute.Key("gen_ai.request.max_tokens")      // 最大生成token数
+	AttrGenAIRequestStop        = attribute.Key("gen_ai.request.stop_sequences")  // 停止序列
+	AttrGenAIResponseID         = attribute.Key("gen_ai.response.id")             // 响应ID
+	AttrGenAIResponseModel      = attribute.Key("gen_ai.response.model")          // 响应的模型
+	AttrGenAIFinishReasons      = attribute.Key("gen_ai.response.finish_reasons") // 结束原因
+	AttrGenAIUsageInputTokens   = attribute.Key("gen_ai.usage.input_tokens")      // 输入token数
+	AttrGenAIUsageOutputTokens  = attribute.Key("gen_ai.usage.output_tokens")     // 输出token数
+	AttrErrorType               = attribute.Key("error.type")                     // 错误类型
+)
+
+// SDK 自定义属性键
+const (
+	AttrRequestID         = attribute.Key("aisdk.request_id")            // 请求ID
+	AttrMethod            = attribute.Key("aisdk.method")                // 方法名称
+	AttrModelType         = attribute.Key("aisdk.model_type")            // 模型类型
+	AttrRetryAttempts     = attribute.Key("aisdk.retry.attempts")        // 重试次数
+	AttrCacheHit          = attribute.Key("aisdk.cache.hit")             // 是否命中缓存
+	AttrStreamChunkCount  = attribute.Key("aisdk.stream.chunk_count")    // 流式传输的数据块数量
+	AttrStreamChunkIndex  = attribute.Key("aisdk.stream.chunk_index")    // 数据块序号
+	AttrStreamFirstChunk  = attribute.Key("aisdk.stream.first_chunk_ms") // 首个数据块耗时
+	AttrStreamCloseReason = attribute.Key("aisdk.stream.close_reason")   // 流式传输结束原因
+	EventStreamChunk      = "gen_ai.stream.chunk"                        // 流式传输数据块事件
+)
+
+// MiddlewareConfig 链路追踪中间件配置
+type MiddlewareConfig struct {
+	TracerProvider    trace.TracerProvider          // 追踪器提供者，默认使用全局追踪器提供者
+	Propagator        propagation.TextMapPropagator // 上下文传播器，默认使用 W3C Trace Context
+	RecordChunkEvents bool                          // 是否为流式传输的每个数据块记录事件
+}
+
+// Middleware 链路追踪中间件
+//
+//	每次 SDK 调用创建一个 span，并将追踪上下文注入到发往提供商的 HTTP 请求中
+type Middleware struct {
+	config MiddlewareConfig
+	tracer trace.Tracer
+}
+
+// NewMiddleware 创建链路追踪中间件
+func NewMiddleware(config MiddlewareConfig) (m *Middleware) {
+	if config.TracerProvider == nil {
+		config.TracerProvider = otel.GetTracerProvider()
+	}
+	if config.Propagator == nil {
+		config.Propagator = propagation.TraceContext{}
+	}
+	return &Middleware{
+		config: config,
+		tracer: config.TracerProvider.Tracer(instrumentationName),
+	}
+}
+
+// Process 处理请求
+func (m *Middleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	operation := operationName(requestInfo.Method)
+	spanName := operation
+	if requestInfo.Model != "" {
+		spanName = operation + " " + requestInfo.Model
+	}
+	// 创建 span
+	var span trace.Span
+	ctx, span = m.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(requestAttributes(requestInfo, operation, request)...),
+	)
+	// 将追踪上下文注入到 HTTP 请求头
+	ctx = httpclient.WithHeaderInjector(ctx, func(ctx context.Context, header http.Header) {
+		m.config.Propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	})
+	// 执行下一个处理器
+	response, err = next(ctx, request)
+	span.SetAttributes(AttrRetryAttempts.Int(requestInfo.Attempt))
+	if err != nil {
+		endWithError(span, err)
+		return
+	}
+	// 记录响应信息
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		span.SetAttributes(AttrCacheHit.Bool(resp.Header().Get(cacheHeaderKey) != ""))
+		span.SetAttributes(chatResponseAttributes(resp.ChatBaseResponse)...)
+		span.End()
+	case models.ChatResponseStream:
+		if resp.StreamReader == nil {
+			span.End()
+			return
+		}
+		span.SetAttributes(AttrCacheHit.Bool(resp.Header().Get(cacheHeaderKey) != ""))
+		m.traceStream(span, resp)
+	case models.EmbeddingResponse:
+		if resp.Usage != nil {
+			span.SetAttributes(AttrGenAIUsageInputTokens.Int(resp.Usage.PromptTokens))
+		}
+		if resp.Model != "" {
+			span.SetAttributes(AttrGenAIResponseModel.String(resp.Model))
+		}
+		span.End()
+	case models.ImageResponse:
+		if resp.Usage != nil {
+			span.SetAttributes(
+				AttrGenAIUsageInputTokens.Int(resp.Usage.InputTokens),
+				AttrGenAIUsageOutputTokens.Int(resp.Usage.OutputTokens),
+			)
+		}
+		span.End()
+	default:
+		span.End()
+	}
+	return
+}
+
+// forceFlusher 支持导出缓冲 span 的追踪器提供者，如 OpenTelemetry SDK 的 TracerProvider
+type forceFlusher interface {
+	ForceFlush(ctx context.Context) (err error)
+}
+
+// Flush 导出追踪器提供者中缓冲的 span，追踪器提供者不支持 ForceFlush 时无操作（不会关闭追踪器提供者）
+func (m *Middleware) Flush(ctx context.Context) (err error) {
+	if flusher, ok := m.config.TracerProvider.(forceFlusher); ok {
+		return flusher.ForceFlush(ctx)
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *Middleware) Name() (name string) {
+	return "tracing"
+}
+
+// Priority 返回中间件优先级
+func (m *Middleware) Priority() (priority int) {
+	return 5 // 链路追踪中间件优先级最高，span 覆盖缓存、重试等全部处理过程
+}
+
+// traceStream 追踪流式传输，流式传输结束时结束 span
+func (m *Middleware) traceStream(span trace.Span, stream models.ChatResponseStream) {
+	var (
+		mu            sync.Mutex
+		startTime     = time.Now()
+		chunkCount    int
+		finishReasons = make(map[int]string)
+		merged        models.ChatBaseResponse
+	)
+	stream.AddHook(httpclient.StreamHook[models.ChatBaseResponse]{
+		OnChunk: func(chunk models.ChatBaseResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			chunkCount++
+			if chunkCount == 1 {
+				span.SetAttributes(AttrStreamFirstChunk.Int64(time.Since(startTime).Milliseconds()))
+			}
+			if chunk.ID != "" {
+				merged.ID = chunk.ID
+			}
+			if chunk.Model != "" {
+				merged.Model = chunk.Model
+			}
+			if chunk.Usage != nil {
+				merged.Usage = chunk.Usage
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != "" {
+					finishReasons[choice.Index] = string(choice.FinishReason)
+				}
+			}
+			if m.config.RecordChunkEvents {
+				attrs := []attribute.KeyValue{AttrStreamChunkIndex.Int(chunkCount - 1)}
+				if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+					attrs = append(attrs, AttrGenAIFinishReasons.StringSlice([]string{string(chunk.Choices[0].FinishReason)}))
+				}
+				span.AddEvent(EventStreamChunk, trace.WithAttributes(attrs...))
+			}
+		},
+		OnClose: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			indexes := make([]int, 0, len(finishReasons))
+			for index := range finishReasons {
+				indexes = append(indexes, index)
+			}
+			sort.Ints(indexes)
+			for _, index := range indexes {
+				merged.Choices = append(merged.Choices, models.ChatChoice{Index: index, FinishReason: models.ChatFinishReason(finishReasons[index])})
+			}
+			span.SetAttributes(chatResponseAttributes(merged)...)
+			span.SetAttributes(AttrStreamChunkCount.Int(chunkCount))
+			switch {
+			case err == nil:
+				span.SetAttributes(AttrStreamCloseReason.String("finished"))
+			case errors.Is(err, httpclient.ErrStreamClosed):
+				// 调用方提前关闭流不视为错误
+				span.SetAttributes(AttrStreamCloseReason.String("closed"))
+			default:
+				span.SetAttributes(AttrStreamCloseReason.String("error"))
+				endWithError(span, err)
+				return
+			}
+			span.End()
+		},
+	})
+}
+
+// operationName 根据方法名称获取操作名称
+func operationName(method string) (operation string) {
+	switch method {
+	case "CreateChatCompletion", "CreateChatCompletionStream":
+		return "chat"
+	case "CreateEmbeddings":
+		return "embeddings"
+	case "CreateImage", "CreateImageEdit", "CreateImageVariation":
+		return "image_generation"
+	}
+	return method
+}
+
+// requestAttributes 获取请求属性
+func requestAttributes(requestInfo *httpclient.RequestInfo, operation string, request any) (attrs []attribute.KeyValue) {
+	attrs = []attribute.KeyValue{
+		AttrGenAISystem.String(requestInfo.Provider),
+		AttrGenAIOperationName.String(operation),
+		AttrMethod.String(requestInfo.Method),
+		AttrRequestID.String(requestInfo.RequestID),
+	}
+	if requestInfo.Model != "" {
+		attrs = append(attrs, AttrGenAIRequestModel.String(requestInfo.Model))
+	}
+	if requestInfo.ModelType != "" {
+		attrs = append(attrs, AttrModelType.String(requestInfo.ModelType))
+	}
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok {
+		return
+	}
+	if chatReq.Temperature != nil {
+		attrs = append(attrs, AttrGenAIRequestTemperature.Float64(float64(*chatReq.Temperature)))
+	}
+	if chatReq.TopP != nil {
+		attrs = append(attrs, AttrGenAIRequestTopP.Float64(float64(*chatReq.TopP)))
+	}
+	if chatReq.MaxCompletionTokens != nil {
+		attrs = append(attrs, AttrGenAIRequestMaxTokens.Int(*chatReq.MaxCompletionTokens))
+	}
+	if len(chatReq.Stop) > 0 {
+		attrs = append(attrs, AttrGenAIRequestStop.StringSlice(chatReq.Stop))
+	}
+	return
+}
+
+// chatResponseAttributes 获取聊天响应属性
+func chatResponseAttributes(response models.ChatBaseResponse) (attrs []attribute.KeyValue) {
+	if response.ID != "" {
+		attrs = append(attrs, AttrGenAIResponseID.String(response.ID))
+	}
+	if response.Model != "" {
+		attrs = append(attrs, AttrGenAIResponseModel.String(response.Model))
+	}
+	if response.Usage != nil {
+		attrs = append(attrs,
+			AttrGenAIUsageInputTokens.Int(response.Usage.PromptTokens),
+			AttrGenAIUsageOutputTokens.Int(response.Usage.CompletionTokens),
+		)
+	}
+	finishReasons := make([]string, 0, len(response.Choices))
+	for _, choice := range response.Choices {
+		if choice.FinishReason != "" {
+			finishReasons = append(finishReasons, string(choice.FinishReason))
+		}
+	}
+	if len(finishReasons) > 0 {
+		attrs = append(attrs, AttrGenAIFinishReasons.StringSlice(finishReasons))
+	}
+	return
+}
+
+// endWithError 记录错误并结束 span
+func endWithError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(AttrErrorType.String(errorType(err)))
+	span.End()
+}
+
+// errorType 获取低基数的错误类型
+func errorType(err error) (typ string) {
+	var (
+		apiErr *httpclient.APIError
+		reqErr *httpclient.RequestError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		if apiErr.HTTPStatusCode > 0 {
+			return strconv.Itoa(apiErr.HTTPStatusCode)
+		}
+		if apiErr.Type != "" {
+			return apiErr.Type
+		}
+	case errors.As(err, &reqErr):
+		if reqErr.HTTPStatusCode > 0 {
+			return strconv.Itoa(reqErr.HTTPStatusCode)
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "_OTHER"
+}
+
+// DefaultMiddlewareConfig 默认链路追踪配置
+func DefaultMiddlewareConfig() (config MiddlewareConfig) {
+	return MiddlewareConfig{
+		TracerProvider:    otel.GetTracerProvider(),
+		Propagator:        propagation.TraceContext{},
+		RecordChunkEvents: true,
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 11:32:05
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-12 11:32:05
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tracing
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newTestMiddleware 创建使用内存导出器的链路追踪中间件
+func newTestMiddleware() (m *Middleware, exporter *tracetest.InMemoryExporter) {
+	exporter = tracetest.NewInMemoryExporter()
+	m = NewMiddleware(MiddlewareConfig{
+		TracerProvider:    sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		RecordChunkEvents: true,
+	})
+	return
+}
+
+// newTestContext 创建带有请求信息的上下文
+func newTestContext(method string) (ctx context.Context) {
+	return httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  consts.OpenAI.String(),
+		ModelType: consts.ChatModel.String(),
+		Model:     consts.OpenAIGPT4o,
+		Method:    method,
+		RequestID: "test-request-id",
+	})
+}
+
+// attrMap 将 span 属性转换为 map
+func attrMap(attrs []attribute.KeyValue) (m map[attribute.Key]attribute.Value) {
+	m = make(map[attribute.Key]attribute.Value, len(attrs))
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Value
+	}
+	return
+}
+
+func TestMiddleware_Chat(t *testing.T) {
+	var (
+		m, exporter = newTestMiddleware()
+		traceparent string
+		request     = models.ChatRequest{
+			Provider:    consts.OpenAI,
+			Model:       consts.OpenAIGPT4o,
+			Messages:    []models.ChatMessage{&models.UserMessage{Content: "hello"}},
+			Temperature: models.Float32(0.5),
+		}
+		next = func(ctx context.Context, request any) (response any, err error) {
+			req, err := httpclient.NewHTTPClient("https://api.example.com").NewRequest(ctx, http.MethodPost, "https://api.example.com/chat/completions")
+			if err != nil {
+				return nil, err
+			}
+			traceparent = req.Header.Get("traceparent")
+			return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+				ID:    "chatcmpl-1",
+				Model: "gpt-4o-2024-08-06",
+				Choices: []models.ChatChoice{
+					{FinishReason: models.ChatFinishReasonStop, Message: &models.ChatCompletionMessage{Content: "Hi"}},
+				},
+				Usage: &models.ChatUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+			}}, nil
+		}
+	)
+
+	if _, err := m.Process(newTestContext("CreateChatCompletion"), request, next); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "chat "+consts.OpenAIGPT4o {
+		t.Errorf("expected span name to be chat gpt-4o, got %s", span.Name)
+	}
+	if traceparent == "" || !strings.Contains(traceparent, span.SpanContext.TraceID().String()) {
+		t.Errorf("expected traceparent to contain trace id %s, got %q", span.SpanContext.TraceID(), traceparent)
+	}
+	attrs := attrMap(span.Attributes)
+	for key, want := range map[attribute.Key]any{
+		AttrGenAISystem:            "openai",
+		AttrGenAIOperationName:     "chat",
+		AttrGenAIRequestModel:      consts.OpenAIGPT4o,
+		AttrGenAIResponseID:        "chatcmpl-1",
+		AttrGenAIResponseModel:     "gpt-4o-2024-08-06",
+		AttrGenAIUsageInputTokens:  int64(10),
+		AttrGenAIUsageOutputTokens: int64(2),
+		AttrRequestID:              "test-request-id",
+		AttrRetryAttempts:          int64(0),
+	} {
+		if got := attrs[key].AsInterface(); got != want {
+			t.Errorf("expected %s to be %v, got %v", key, want, got)
+		}
+	}
+	if reasons := attrs[AttrGenAIFinishReasons].AsStringSlice(); len(reasons) != 1 || reasons[0] != "stop" {
+		t.Errorf("expected finish reasons to be [stop], got %v", reasons)
+	}
+	if temperature := attrs[AttrGenAIRequestTemperature].AsFloat64(); temperature != 0.5 {
+		t.Errorf("expected temperature to be 0.5, got %v", temperature)
+	}
+}
+
+func TestMiddleware_ChatStream(t *testing.T) {
+	var (
+		m, exporter = newTestMiddleware()
+		next        = func(ctx context.Context, request any) (response any, err error) {
+			body := io.NopCloser(strings.NewReader(
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+					"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\n" +
+					"data: {\"id\":\"chatcmpl-2\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2}}\n\n" +
+					"data: [DONE]\n\n",
+			))
+			return models.ChatResponseStream{
+				StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](body, nil, nil),
+			}, nil
+		}
+	)
+
+	resp, err := m.Process(newTestContext("CreateChatCompletionStream"), models.ChatRequest{}, next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Fatal("expected span to stay open until the stream finishes")
+	}
+	if err = resp.(models.ChatResponseStream).ForEach(func(chunk models.ChatBaseResponse, isFinished bool) (err error) {
+		return
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if len(span.Events) != 3 {
+		t.Errorf("expected 3 chunk events, got %d", len(span.Events))
+	}
+	attrs := attrMap(span.Attributes)
+	if got := attrs[AttrStreamChunkCount].AsInt64(); got != 3 {
+		t.Errorf("expected chunk count to be 3, got %d", got)
+	}
+	if got := attrs[AttrGenAIUsageOutputTokens].AsInt64(); got != 2 {
+		t.Errorf("expected output tokens to be 2, got %d", got)
+	}
+	if reasons := attrs[AttrGenAIFinishReasons].AsStringSlice(); len(reasons) != 1 || reasons[0] != "stop" {
+		t.Errorf("expected finish reasons to be [stop], got %v", reasons)
+	}
+	if got := attrs[AttrStreamCloseReason].AsString(); got != "finished" {
+		t.Errorf("expected close reason to be finished, got %s", got)
+	}
+}
+
+func TestMiddleware_Error(t *testing.T) {
+	var (
+		m, exporter = newTestMiddleware()
+		next        = func(ctx context.Context, request any) (response any, err error) {
+			httpclient.GetRequestInfo(ctx).Attempt = 2
+			return nil, &httpclient.APIError{Message: "rate limited", HTTPStatusCode: http.StatusTooManyRequests}
+		}
+	)
+
+	if _, err := m.Process(newTestContext("CreateChatCompletion"), models.ChatRequest{}, next); err == nil {
+		t.Fatal("expected error")
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Status.Code != codes.Error {
+		t.Errorf("expected status to be error, got %v", span.Status.Code)
+	}
+	attrs := attrMap(span.Attributes)
+	if got := attrs[AttrErrorType].AsString(); got != "429" {
+		t.Errorf("expected error type to be 429, got %s", got)
+	}
+	if got := attrs[AttrRetryAttempts].AsInt64(); got != 2 {
+		t.Errorf("expected retry attempts to be 2, got %d", got)
+	}
+}
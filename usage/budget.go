@@ -0,0 +1,116 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 10:41:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-15 10:41:17
+ * @Description: 花费预算
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded 超出花费预算，可通过 errors.Is 判断
+var ErrBudgetExceeded = errors.New("usage budget exceeded")
+
+// Window 预算统计窗口
+type Window string
+
+const (
+	WindowHour  Window = "hour"  // 自然小时
+	WindowDay   Window = "day"   // 自然日
+	WindowMonth Window = "month" // 自然月
+	WindowTotal Window = "total" // 不限时间，NewMiddleware 会关闭默认内存存储的过期清理，自定义存储需自行保留全部数据
+)
+
+// Start 获取统计窗口的起始时间，WindowTotal 返回零值
+func (w Window) Start(now time.Time) (start time.Time) {
+	switch w {
+	case WindowHour:
+		return now.Truncate(time.Hour)
+	case WindowDay:
+		year, month, day := now.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	case WindowMonth:
+		year, month, _ := now.Date()
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// Budget 花费预算
+type Budget struct {
+	User      string  // 用户标识，为空时对每个用户分别生效（不适用于未携带用户标识的请求）
+	Global    bool    // 为 true 时统计所有用户的总花费，忽略 User
+	Model     string  // 模型名称，为空时统计所有模型
+	Window    Window  // 统计窗口，默认 WindowDay
+	SoftLimit float64 // 软限制，超过后触发回调但不拒绝请求，为0时不启用
+	HardLimit float64 // 硬限制，达到后拒绝请求，为0时不启用
+}
+
+// appliesTo 判断预算是否适用于该请求
+func (b Budget) appliesTo(user, model string) (ok bool) {
+	if !b.Global && b.User != "" && b.User != user {
+		return false
+	}
+	// 匿名请求没有可区分的用户，按用户统计会汇总所有用户的花费
+	if !b.Global && b.User == "" && user == "" {
+		return false
+	}
+	return b.Model == "" || b.Model == model
+}
+
+// filter 获取预算对应的查询条件
+func (b Budget) filter(user string, now time.Time) (filter Filter) {
+	window := b.Window
+	if window == "" {
+		window = WindowDay
+	}
+	filter = Filter{
+		Model: b.Model,
+		Since: window.Start(now),
+	}
+	if !b.Global {
+		filter.User = user
+	}
+	return
+}
+
+// BudgetExceededError 超出硬限制时返回的错误
+type BudgetExceededError struct {
+	Budget Budget  // 触发的预算
+	User   string  // 用户标识
+	Spent  float64 // 统计窗口内已花费金额
+}
+
+// Error 实现 error 接口
+func (e *BudgetExceededError) Error() (s string) {
+	scope := "user " + e.User
+	if e.Budget.Global {
+		scope = "all users"
+	}
+	if e.Budget.Model != "" {
+		scope += " on model " + e.Budget.Model
+	}
+	window := e.Budget.Window
+	if window == "" {
+		window = WindowDay
+	}
+	return fmt.Sprintf("%s: %s spent %.6f of %.6f in %s window", ErrBudgetExceeded, scope, e.Spent, e.Budget.HardLimit, window)
+}
+
+// Is 支持 errors.Is(err, ErrBudgetExceeded)
+func (e *BudgetExceededError) Is(target error) (ok bool) {
+	return target == ErrBudgetExceeded
+}
+
+// IsBudgetExceeded 判断错误是否为超出花费预算
+func IsBudgetExceeded(err error) (ok bool) {
+	return errors.Is(err, ErrBudgetExceeded)
+}
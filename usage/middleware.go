@@ -0,0 +1,220 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 11:15:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 15:02:47
+ * @Description: 用量统计与花费预算中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"time"
+)
+
+// MiddlewareConfig 用量统计中间件配置
+type MiddlewareConfig struct {
+	Pricing     *PricingTable                                                        // 价格表，默认使用 DefaultPricingTable，未配置价格的模型费用记为0
+	Store       Store                                                                // 用量存储，默认使用内存存储
+	Budgets     []Budget                                                             // 花费预算
+	OnSoftLimit func(ctx context.Context, budget Budget, user string, spent float64) // 首次超过软限制时的回调
+	OnRecord    func(ctx context.Context, record Record)                             // 记录用量后的回调
+}
+
+// Middleware 用量统计中间件，计算每次调用的费用并按用户、模型、时间窗口汇总，超过硬限制时拒绝请求
+//
+//	预算检查与用量记录之间不加锁，并发请求可能使实际花费略超过硬限制
+type Middleware struct {
+	config MiddlewareConfig
+}
+
+// NewMiddleware 创建用量统计中间件
+func NewMiddleware(config MiddlewareConfig) (m *Middleware) {
+	if config.Pricing == nil {
+		config.Pricing = DefaultPricingTable()
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore(MemoryStoreConfig{})
+	}
+	// 不限时间的预算需要全部历史数据，关闭内存存储的过期清理
+	if store, ok := config.Store.(*MemoryStore); ok && hasTotalBudget(config.Budgets) {
+		store.keepForever()
+	}
+	return &Middleware{
+		config: config,
+	}
+}
+
+// hasTotalBudget 判断是否存在不限时间的预算
+func hasTotalBudget(budgets []Budget) (ok bool) {
+	for _, budget := range budgets {
+		if budget.Window == WindowTotal {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMiddlewareConfig 默认用量统计中间件配置
+func DefaultMiddlewareConfig() (config MiddlewareConfig) {
+	return MiddlewareConfig{
+		Pricing: DefaultPricingTable(),
+		Store:   NewMemoryStore(MemoryStoreConfig{}),
+	}
+}
+
+// Process 处理请求
+func (m *Middleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	record := Record{
+		RequestID: requestInfo.RequestID,
+		User:      requestInfo.User,
+		Provider:  requestInfo.Provider,
+		ModelType: requestInfo.ModelType,
+		Model:     requestInfo.Model,
+		Method:    requestInfo.Method,
+	}
+	// 检查硬限制
+	if err = m.checkBudgets(ctx, record); err != nil {
+		return
+	}
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 记录用量
+	switch resp := response.(type) {
+	case models.ImageResponse:
+		record.Images = len(resp.Data)
+		record.Usage, _ = resp.GetTokenUsage()
+		m.record(ctx, record, request)
+	case httpclient.TokenUsageReporter:
+		if usage, ok := resp.GetTokenUsage(); ok {
+			record.Usage = usage
+			m.record(ctx, record, request)
+		}
+	case httpclient.StreamObservable:
+		// 流式传输在请求返回后才结束，记录用量时不能继承请求的取消信号
+		recordCtx := context.WithoutCancel(ctx)
+		httpclient.ObserveStreamUsage(resp, func(usage httpclient.TokenUsage, ok bool, err error) {
+			if ok {
+				record.Usage = usage
+				m.record(recordCtx, record, request)
+			}
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *Middleware) Name() (name string) {
+	return "usage"
+}
+
+// Priority 返回中间件优先级
+func (m *Middleware) Priority() (priority int) {
+	return 18 // 用量统计中间件在缓存之后、重试之前执行，命中缓存的请求不计费，重试只记录最终结果
+}
+
+// Flush 刷新用量存储中缓冲的记录，用量存储未实现 httpclient.Flusher 接口时无操作
+func (m *Middleware) Flush(ctx context.Context) (err error) {
+	if flusher, ok := m.config.Store.(httpclient.Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return
+}
+
+// Store 获取用量存储
+func (m *Middleware) Store() (store Store) {
+	return m.config.Store
+}
+
+// Summary 按条件汇总用量
+func (m *Middleware) Summary(ctx context.Context, filter Filter) (summary Summary, err error) {
+	return m.config.Store.Sum(ctx, filter)
+}
+
+// Cost 计算单次调用的费用，未配置价格时返回 false
+func (m *Middleware) Cost(provider, model string, usage httpclient.TokenUsage, request any, images int) (cost float64, ok bool) {
+	var price ModelPrice
+	if price, ok = m.config.Pricing.Get(consts.Provider(provider), model); !ok {
+		return
+	}
+	cost = price.TokenCost(usage)
+	if images > 0 {
+		size, quality := imageOptions(request)
+		if imagePrice, found := price.ImagePrice(size, quality); found {
+			cost += imagePrice * float64(images)
+		}
+	}
+	return
+}
+
+// checkBudgets 检查硬限制，存储查询失败时放行请求
+func (m *Middleware) checkBudgets(ctx context.Context, record Record) (err error) {
+	now := time.Now()
+	for _, budget := range m.config.Budgets {
+		if budget.HardLimit <= 0 || !budget.appliesTo(record.User, record.Model) {
+			continue
+		}
+		summary, sumErr := m.config.Store.Sum(ctx, budget.filter(record.User, now))
+		if sumErr != nil {
+			continue
+		}
+		if summary.Cost >= budget.HardLimit {
+			return &BudgetExceededError{
+				Budget: budget,
+				User:   record.User,
+				Spent:  summary.Cost,
+			}
+		}
+	}
+	return
+}
+
+// record 计算费用并记录用量，首次超过软限制时触发回调
+func (m *Middleware) record(ctx context.Context, record Record, request any) {
+	record.Cost, _ = m.Cost(record.Provider, record.Model, record.Usage, request, record.Images)
+	record.Time = time.Now()
+	if err := m.config.Store.Add(ctx, record); err != nil {
+		return
+	}
+	if m.config.OnRecord != nil {
+		m.config.OnRecord(ctx, record)
+	}
+	if m.config.OnSoftLimit == nil || record.Cost <= 0 {
+		return
+	}
+	for _, budget := range m.config.Budgets {
+		if budget.SoftLimit <= 0 || !budget.appliesTo(record.User, record.Model) {
+			continue
+		}
+		summary, err := m.config.Store.Sum(ctx, budget.filter(record.User, record.Time))
+		if err != nil {
+			continue
+		}
+		// 仅在本次调用使花费越过软限制时触发
+		if summary.Cost >= budget.SoftLimit && summary.Cost-record.Cost < budget.SoftLimit {
+			m.config.OnSoftLimit(ctx, budget, record.User, summary.Cost)
+		}
+	}
+}
+
+// imageOptions 获取图像请求的尺寸和质量
+func imageOptions(request any) (size models.ImageSize, quality models.ImageQuality) {
+	switch req := request.(type) {
+	case models.ImageRequest:
+		return req.Size, req.Quality
+	case models.ImageEditRequest:
+		return req.Size, req.Quality
+	case models.ImageVariationRequest:
+		return req.Size, ""
+	}
+	return
+}
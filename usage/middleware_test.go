@@ -0,0 +1,209 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 14:20:49
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-15 14:20:49
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"context"
+	"errors"
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"testing"
+	"time"
+)
+
+// newTestContext 创建带请求信息的测试上下文
+func newTestContext(user, model string) (ctx context.Context) {
+	return httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  consts.OpenAI.String(),
+		ModelType: consts.ChatModel.String(),
+		Model:     model,
+		Method:    "CreateChatCompletion",
+		User:      user,
+	})
+}
+
+// chatHandler 返回固定用量的聊天响应
+func chatHandler(promptTokens, completionTokens int) (next httpclient.MWHandler) {
+	return func(ctx context.Context, request any) (response any, err error) {
+		return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+			Usage: &models.ChatUsage{PromptTokens: promptTokens, CompletionTokens: completionTokens},
+		}}, nil
+	}
+}
+
+func TestMiddlewareRecord(t *testing.T) {
+	var (
+		store = NewMemoryStore(MemoryStoreConfig{})
+		m     = NewMiddleware(MiddlewareConfig{Store: store})
+		ctx   = context.Background()
+	)
+	if _, err := m.Process(newTestContext("alice", consts.OpenAIGPT4o), models.ChatRequest{}, chatHandler(1_000_000, 100_000)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.Process(newTestContext("bob", consts.OpenAIGPT4oMini), models.ChatRequest{}, chatHandler(1_000_000, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	summary, _ := m.Summary(ctx, Filter{User: "alice"})
+	if summary.Requests != 1 || summary.Usage.InputTokens != 1_000_000 || !almostEqual(summary.Cost, 3.5) {
+		t.Fatalf("unexpected alice summary: %+v", summary)
+	}
+	summary, _ = m.Summary(ctx, Filter{Model: consts.OpenAIGPT4oMini})
+	if summary.Requests != 1 || !almostEqual(summary.Cost, 0.15) {
+		t.Fatalf("unexpected model summary: %+v", summary)
+	}
+	summary, _ = m.Summary(ctx, Filter{Since: time.Now().Add(time.Hour)})
+	if summary.Requests != 0 {
+		t.Fatalf("expected no records in future window, got %+v", summary)
+	}
+	summary, _ = m.Summary(ctx, Filter{})
+	if summary.Requests != 2 || !almostEqual(summary.Cost, 3.65) {
+		t.Fatalf("unexpected total summary: %+v", summary)
+	}
+}
+
+func TestMiddlewareImageCost(t *testing.T) {
+	m := NewMiddleware(MiddlewareConfig{})
+	ctx := httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider: consts.OpenAI.String(),
+		Model:    consts.OpenAIDallE3,
+		Method:   "CreateImage",
+		User:     "alice",
+	})
+	request := models.ImageRequest{Size: models.ImageSize1024x1024, Quality: models.ImageQualityHD}
+	m.Process(ctx, request, func(ctx context.Context, request any) (response any, err error) {
+		return models.ImageResponse{Data: []models.ImageResponseData{{URL: "a"}, {URL: "b"}}}, nil
+	})
+
+	summary, _ := m.Summary(context.Background(), Filter{User: "alice"})
+	if summary.Images != 2 || !almostEqual(summary.Cost, 0.16) {
+		t.Fatalf("unexpected image summary: %+v", summary)
+	}
+}
+
+func TestMiddlewareBudgets(t *testing.T) {
+	var (
+		softHits []string
+		m        = NewMiddleware(MiddlewareConfig{
+			Budgets: []Budget{
+				{Window: WindowDay, SoftLimit: 1, HardLimit: 5},
+				{Global: true, Model: consts.OpenAIGPT4oMini, HardLimit: 0.1},
+			},
+			OnSoftLimit: func(ctx context.Context, budget Budget, user string, spent float64) {
+				softHits = append(softHits, user)
+			},
+		})
+		calls int
+		next  = func(ctx context.Context, request any) (response any, err error) {
+			calls++
+			return chatHandler(1_000_000, 0)(ctx, request)
+		}
+	)
+	// 每次调用花费 2.5，第一次调用越过软限制，第二次调用后达到硬限制
+	for range 2 {
+		if _, err := m.Process(newTestContext("alice", consts.OpenAIGPT4o), models.ChatRequest{}, next); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(softHits) != 1 || softHits[0] != "alice" {
+		t.Fatalf("expected one soft limit callback, got %v", softHits)
+	}
+	_, err := m.Process(newTestContext("alice", consts.OpenAIGPT4o), models.ChatRequest{}, next)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.User != "alice" || !almostEqual(budgetErr.Spent, 5) {
+		t.Fatalf("unexpected budget error: %#v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected rejected request not to call next, got %d calls", calls)
+	}
+	// 其他用户的预算独立计算
+	if _, err = m.Process(newTestContext("bob", consts.OpenAIGPT4o), models.ChatRequest{}, next); err != nil {
+		t.Fatalf("unexpected error for bob: %v", err)
+	}
+	// 全局预算统计所有用户
+	m.Process(newTestContext("carol", consts.OpenAIGPT4oMini), models.ChatRequest{}, next)
+	if _, err = m.Process(newTestContext("dave", consts.OpenAIGPT4oMini), models.ChatRequest{}, next); !IsBudgetExceeded(err) {
+		t.Fatalf("expected global budget exceeded, got %v", err)
+	}
+}
+
+func TestMiddlewareBudgetsAnonymous(t *testing.T) {
+	var (
+		softHits []string
+		m        = NewMiddleware(MiddlewareConfig{
+			Budgets: []Budget{{Window: WindowDay, SoftLimit: 1, HardLimit: 2}},
+			OnSoftLimit: func(ctx context.Context, budget Budget, user string, spent float64) {
+				softHits = append(softHits, user)
+			},
+		})
+		next = chatHandler(1_000_000, 0)
+	)
+	// 每次调用花费 2.5，两个用户的总花费已超过每用户的硬限制
+	for _, user := range []string{"alice", "bob"} {
+		if _, err := m.Process(newTestContext(user, consts.OpenAIGPT4o), models.ChatRequest{}, next); err != nil {
+			t.Fatalf("unexpected error for %s: %v", user, err)
+		}
+	}
+	// 匿名请求不受每用户预算限制，也不触发软限制回调
+	for range 2 {
+		if _, err := m.Process(newTestContext("", consts.OpenAIGPT4o), models.ChatRequest{}, next); err != nil {
+			t.Fatalf("unexpected error for anonymous request: %v", err)
+		}
+	}
+	if len(softHits) != 2 || softHits[0] != "alice" || softHits[1] != "bob" {
+		t.Fatalf("unexpected soft limit callbacks: %q", softHits)
+	}
+	// 具名用户的预算不受匿名请求影响
+	if _, err := m.Process(newTestContext("alice", consts.OpenAIGPT4o), models.ChatRequest{}, next); !IsBudgetExceeded(err) {
+		t.Fatalf("expected alice budget exceeded, got %v", err)
+	}
+	if summary, _ := m.Summary(context.Background(), Filter{User: "bob"}); summary.Requests != 1 {
+		t.Fatalf("unexpected bob summary: %+v", summary)
+	}
+}
+
+func TestMiddlewareTotalBudgetRetention(t *testing.T) {
+	var (
+		store = NewMemoryStore(MemoryStoreConfig{})
+		m     = NewMiddleware(MiddlewareConfig{
+			Store:   store,
+			Budgets: []Budget{{Window: WindowTotal, HardLimit: 2}},
+		})
+		ctx = context.Background()
+	)
+	// 超过默认保留时长的用量仍计入不限时间的预算
+	store.Add(ctx, Record{User: "alice", Model: consts.OpenAIGPT4o, Cost: 2.5, Time: time.Now().Add(-60 * 24 * time.Hour)})
+	store.Add(ctx, Record{User: "bob", Model: consts.OpenAIGPT4o, Cost: 1})
+	if _, err := m.Process(newTestContext("alice", consts.OpenAIGPT4o), models.ChatRequest{}, chatHandler(1, 0)); !IsBudgetExceeded(err) {
+		t.Fatalf("expected alice lifetime budget exceeded, got %v", err)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("expected old buckets to be kept, got %d", store.Len())
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2025, 7, 15, 13, 45, 30, 0, time.UTC)
+	tests := map[Window]time.Time{
+		WindowHour:  time.Date(2025, 7, 15, 13, 0, 0, 0, time.UTC),
+		WindowDay:   time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC),
+		WindowMonth: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		WindowTotal: {},
+	}
+	for window, want := range tests {
+		if got := window.Start(now); !got.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", window, want, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 09:36:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-15 09:36:08
+ * @Description: 模型价格表
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"sync"
+)
+
+const (
+	tokensPerMillion = 1_000_000 // 价格计量单位（每百万token）
+)
+
+// ImagePriceKey 图像价格键
+type ImagePriceKey struct {
+	Size    models.ImageSize    // 图像尺寸，为空时匹配所有尺寸
+	Quality models.ImageQuality // 图像质量，为空时匹配所有质量
+}
+
+// ModelPrice 模型价格，token价格以每百万token计
+type ModelPrice struct {
+	Input       float64                   // 输入token价格
+	CachedInput float64                   // 命中缓存的输入token价格，为0时按输入token价格计算
+	Output      float64                   // 输出token价格
+	Reasoning   float64                   // 推理token价格，为0时按输出token价格计算（推理token包含在输出token中）
+	Images      map[ImagePriceKey]float64 // 每张图像的价格
+}
+
+// TokenCost 计算token费用
+func (p ModelPrice) TokenCost(usage httpclient.TokenUsage) (cost float64) {
+	var (
+		cachedInput = min(usage.CachedInputTokens, usage.InputTokens)
+		input       = usage.InputTokens - cachedInput
+		reasoning   = min(usage.ReasoningTokens, usage.OutputTokens)
+		output      = usage.OutputTokens - reasoning
+	)
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	reasoningPrice := p.Reasoning
+	if reasoningPrice == 0 {
+		reasoningPrice = p.Output
+	}
+	cost = float64(input)*p.Input +
+		float64(cachedInput)*cachedPrice +
+		float64(output)*p.Output +
+		float64(reasoning)*reasoningPrice
+	return cost / tokensPerMillion
+}
+
+// ImagePrice 获取每张图像的价格，依次匹配尺寸和质量、仅尺寸、仅质量、默认价格
+func (p ModelPrice) ImagePrice(size models.ImageSize, quality models.ImageQuality) (price float64, ok bool) {
+	for _, key := range []ImagePriceKey{
+		{Size: size, Quality: quality},
+		{Size: size},
+		{Quality: quality},
+		{},
+	} {
+		if price, ok = p.Images[key]; ok {
+			return
+		}
+	}
+	return
+}
+
+// PricingTable 价格表，按提供商和模型维护价格（并发安全）
+//
+//	价格表不区分币种，同一价格表中的价格应使用相同的币种
+type PricingTable struct {
+	mu     sync.RWMutex
+	prices map[consts.Provider]map[string]ModelPrice
+}
+
+// NewPricingTable 创建空的价格表
+func NewPricingTable() (t *PricingTable) {
+	return &PricingTable{
+		prices: make(map[consts.Provider]map[string]ModelPrice),
+	}
+}
+
+// Set 设置模型价格
+func (t *PricingTable) Set(provider consts.Provider, model string, price ModelPrice) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.prices[provider]; !ok {
+		t.prices[provider] = make(map[string]ModelPrice)
+	}
+	t.prices[provider][model] = price
+}
+
+// Get 获取模型价格
+func (t *PricingTable) Get(provider consts.Provider, model string) (price ModelPrice, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	price, ok = t.prices[provider][model]
+	return
+}
+
+// DefaultPricingTable 默认价格表（美元，仅供参考，请以提供商官方价格为准）
+func DefaultPricingTable() (t *PricingTable) {
+	t = NewPricingTable()
+	// OpenAI
+	t.Set(consts.OpenAI, consts.OpenAIGPT4o, ModelPrice{Input: 2.5, CachedInput: 1.25, Output: 10})
+	t.Set(consts.OpenAI, consts.OpenAIGPT4oMini, ModelPrice{Input: 0.15, CachedInput: 0.075, Output: 0.6})
+	t.Set(consts.OpenAI, consts.OpenAIGPT4Dot1, ModelPrice{Input: 2, CachedInput: 0.5, Output: 8})
+	t.Set(consts.OpenAI, consts.OpenAIGPT4Dot1Mini, ModelPrice{Input: 0.4, CachedInput: 0.1, Output: 1.6})
+	t.Set(consts.OpenAI, consts.OpenAIGPT4Dot1Nano, ModelPrice{Input: 0.1, CachedInput: 0.025, Output: 0.4})
+	t.Set(consts.OpenAI, consts.OpenAIO3, ModelPrice{Input: 2, CachedInput: 0.5, Output: 8})
+	t.Set(consts.OpenAI, consts.OpenAIO4Mini, ModelPrice{Input: 1.1, CachedInput: 0.275, Output: 4.4})
+	t.Set(consts.OpenAI, consts.OpenAITextEmbedding3Small, ModelPrice{Input: 0.02})
+	t.Set(consts.OpenAI, consts.OpenAITextEmbedding3Large, ModelPrice{Input: 0.13})
+	t.Set(consts.OpenAI, consts.OpenAITextEmbeddingAda002, ModelPrice{Input: 0.1})
+	t.Set(consts.OpenAI, consts.OpenAIDallE2, ModelPrice{Images: map[ImagePriceKey]float64{
+		{Size: models.ImageSize256x256}:   0.016,
+		{Size: models.ImageSize512x512}:   0.018,
+		{Size: models.ImageSize1024x1024}: 0.02,
+	}})
+	t.Set(consts.OpenAI, consts.OpenAIDallE3, ModelPrice{Images: map[ImagePriceKey]float64{
+		{Size: models.ImageSize1024x1024}:                                 0.04,
+		{Size: models.ImageSize1024x1792}:                                 0.08,
+		{Size: models.ImageSize1792x1024}:                                 0.08,
+		{Size: models.ImageSize1024x1024, Quality: models.ImageQualityHD}: 0.08,
+		{Size: models.ImageSize1024x1792, Quality: models.ImageQualityHD}: 0.12,
+		{Size: models.ImageSize1792x1024, Quality: models.ImageQualityHD}: 0.12,
+	}})
+	// DeepSeek
+	t.Set(consts.DeepSeek, consts.DeepSeekChat, ModelPrice{Input: 0.27, CachedInput: 0.07, Output: 1.1})
+	t.Set(consts.DeepSeek, consts.DeepSeekReasoner, ModelPrice{Input: 0.55, CachedInput: 0.14, Output: 2.19})
+	return
+}
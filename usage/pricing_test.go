@@ -0,0 +1,69 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 14:02:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-15 14:02:26
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"github.com/liusuxian/go-aisdk/consts"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"github.com/liusuxian/go-aisdk/models"
+	"math"
+	"testing"
+)
+
+// almostEqual 判断浮点数是否近似相等
+func almostEqual(a, b float64) (ok bool) {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestModelPriceTokenCost(t *testing.T) {
+	price := ModelPrice{Input: 2, CachedInput: 0.5, Output: 8, Reasoning: 10}
+	cost := price.TokenCost(httpclient.TokenUsage{
+		InputTokens:       1_000_000,
+		CachedInputTokens: 400_000,
+		OutputTokens:      500_000,
+		ReasoningTokens:   200_000,
+	})
+	// 600k*2 + 400k*0.5 + 300k*8 + 200k*10
+	if want := 1.2 + 0.2 + 2.4 + 2.0; !almostEqual(cost, want) {
+		t.Fatalf("expected cost %v, got %v", want, cost)
+	}
+	// 未配置缓存和推理价格时按输入和输出价格计算
+	price = ModelPrice{Input: 1, Output: 2}
+	cost = price.TokenCost(httpclient.TokenUsage{
+		InputTokens:       1_000_000,
+		CachedInputTokens: 500_000,
+		OutputTokens:      1_000_000,
+		ReasoningTokens:   500_000,
+	})
+	if want := 3.0; !almostEqual(cost, want) {
+		t.Fatalf("expected cost %v, got %v", want, cost)
+	}
+}
+
+func TestModelPriceImagePrice(t *testing.T) {
+	price, _ := DefaultPricingTable().Get(consts.OpenAI, consts.OpenAIDallE3)
+	tests := []struct {
+		size    models.ImageSize
+		quality models.ImageQuality
+		want    float64
+		ok      bool
+	}{
+		{models.ImageSize1024x1024, "", 0.04, true},
+		{models.ImageSize1024x1024, models.ImageQualityStandard, 0.04, true},
+		{models.ImageSize1792x1024, models.ImageQualityHD, 0.12, true},
+		{models.ImageSize256x256, "", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := price.ImagePrice(tt.size, tt.quality)
+		if ok != tt.ok || !almostEqual(got, tt.want) {
+			t.Errorf("ImagePrice(%s, %s) = %v, %v; want %v, %v", tt.size, tt.quality, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 10:08:41
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-15 10:08:41
+ * @Description: 用量记录存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package usage
+
+import (
+	"context"
+	"github.com/liusuxian/go-aisdk/httpclient"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBucketSize = time.Minute         // 默认聚合时间粒度
+	defaultRetention  = 31 * 24 * time.Hour // 默认保留时长
+)
+
+// Record 单次调用的用量记录
+type Record struct {
+	RequestID string                // 请求ID
+	User      string                // 用户标识
+	Provider  string                // 提供商
+	ModelType string                // 模型类型
+	Model     string                // 模型名称
+	Method    string                // 方法名称
+	Usage     httpclient.TokenUsage // token使用量
+	Images    int                   // 生成的图像数量
+	Cost      float64               // 费用
+	Time      time.Time             // 记录时间
+}
+
+// Filter 用量查询条件，字段为空时不按该字段过滤
+type Filter struct {
+	User     string    // 用户标识
+	Provider string    // 提供商
+	Model    string    // 模型名称
+	Since    time.Time // 起始时间（包含）
+	Until    time.Time // 结束时间（不包含）
+}
+
+// Summary 用量汇总
+type Summary struct {
+	Requests int64                 // 调用次数
+	Usage    httpclient.TokenUsage // token使用量
+	Images   int                   // 生成的图像数量
+	Cost     float64               // 费用
+}
+
+// add 累加用量记录
+func (s *Summary) add(record Record) {
+	s.Requests++
+	s.Usage.InputTokens += record.Usage.InputTokens
+	s.Usage.CachedInputTokens += record.Usage.CachedInputTokens
+	s.Usage.OutputTokens += record.Usage.OutputTokens
+	s.Usage.ReasoningTokens += record.Usage.ReasoningTokens
+	s.Usage.TotalTokens += record.Usage.TotalTokens
+	s.Images += record.Images
+	s.Cost += record.Cost
+}
+
+// merge 合并用量汇总
+func (s *Summary) merge(other Summary) {
+	s.Requests += other.Requests
+	s.Usage.InputTokens += other.Usage.InputTokens
+	s.Usage.CachedInputTokens += other.Usage.CachedInputTokens
+	s.Usage.OutputTokens += other.Usage.OutputTokens
+	s.Usage.ReasoningTokens += other.Usage.ReasoningTokens
+	s.Usage.TotalTokens += other.Usage.TotalTokens
+	s.Images += other.Images
+	s.Cost += other.Cost
+}
+
+// Store 用量存储接口
+type Store interface {
+	Add(ctx context.Context, record Record) (err error)                  // 添加用量记录
+	Sum(ctx context.Context, filter Filter) (summary Summary, err error) // 按条件汇总用量
+}
+
+// MemoryStoreConfig 内存用量存储配置
+type MemoryStoreConfig struct {
+	BucketSize time.Duration // 聚合时间粒度，查询的起止时间按该粒度对齐，默认1分钟
+	Retention  time.Duration // 保留时长，超过保留时长的数据会被清理，为0时默认31天，小于0时不清理
+}
+
+// bucketKey 聚合桶键
+type bucketKey struct {
+	user     string
+	provider string
+	model    string
+	start    int64 // 桶起始时间（Unix纳秒）
+}
+
+// MemoryStore 内存用量存储，按用户、提供商、模型和时间粒度聚合（并发安全）
+type MemoryStore struct {
+	mu         sync.RWMutex
+	bucketSize time.Duration
+	retention  time.Duration
+	buckets    map[bucketKey]*Summary
+	lastPrune  time.Time
+}
+
+// NewMemoryStore 创建内存用量存储
+func NewMemoryStore(config MemoryStoreConfig) (s *MemoryStore) {
+	if config.BucketSize <= 0 {
+		config.BucketSize = defaultBucketSize
+	}
+	if config.Retention == 0 {
+		config.Retention = defaultRetention
+	}
+	return &MemoryStore{
+		bucketSize: config.BucketSize,
+		retention:  config.Retention,
+		buckets:    make(map[bucketKey]*Summary),
+	}
+}
+
+// Add 添加用量记录
+func (s *MemoryStore) Add(ctx context.Context, record Record) (err error) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	key := bucketKey{
+		user:     record.User,
+		provider: record.Provider,
+		model:    record.Model,
+		start:    record.Time.Truncate(s.bucketSize).UnixNano(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary, ok := s.buckets[key]
+	if !ok {
+		summary = &Summary{}
+		s.buckets[key] = summary
+	}
+	summary.add(record)
+	// 每个聚合粒度最多清理一次过期数据
+	if now := time.Now(); s.retention > 0 && now.Sub(s.lastPrune) >= s.bucketSize {
+		s.prune(now)
+		s.lastPrune = now
+	}
+	return
+}
+
+// Sum 按条件汇总用量
+func (s *MemoryStore) Sum(ctx context.Context, filter Filter) (summary Summary, err error) {
+	var since, until int64
+	if !filter.Since.IsZero() {
+		since = filter.Since.Truncate(s.bucketSize).UnixNano()
+	}
+	if !filter.Until.IsZero() {
+		until = filter.Until.UnixNano()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, bucket := range s.buckets {
+		if (filter.User != "" && key.user != filter.User) ||
+			(filter.Provider != "" && key.provider != filter.Provider) ||
+			(filter.Model != "" && key.model != filter.Model) ||
+			(since != 0 && key.start < since) ||
+			(until != 0 && key.start >= until) {
+			continue
+		}
+		summary.merge(*bucket)
+	}
+	return
+}
+
+// Len 获取聚合桶数量
+func (s *MemoryStore) Len() (n int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.buckets)
+}
+
+// keepForever 关闭过期清理
+func (s *MemoryStore) keepForever() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = -1
+}
+
+// prune 清理过期数据
+func (s *MemoryStore) prune(now time.Time) {
+	expire := now.Add(-s.retention).UnixNano()
+	for key := range s.buckets {
+		if key.start < expire {
+			delete(s.buckets, key)
+		}
+	}
+}